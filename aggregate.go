@@ -0,0 +1,658 @@
+package dumbDatabase
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+
+	"dumbDB/backend"
+	"dumbDB/keys"
+
+	"github.com/boltdb/bolt"
+)
+
+// State of materialized aggregates of a bucket is kept in a hidden bucket
+// with this prefix followed by the bucket name.
+const AGGREGATE_BUCKET_PREFIX = HIDDEN_BUCKET_PREFIX + "agg."
+
+// Aggregate functions
+const (
+	AGG_COUNT = "count"
+	AGG_SUM   = "sum"
+	AGG_MIN   = "min"
+	AGG_MAX   = "max"
+	AGG_AVG   = "avg"
+)
+
+/*
+ * Aggregator
+ * An aggregate function over a numeric field. Values which are not JSON
+ * numbers are left out, Min, Max and Avg are NaN if there are none.
+ */
+type Aggregator struct {
+	Fn    string
+	Field string `json:",omitempty"`
+}
+
+// No of records.
+func Count() Aggregator { return Aggregator{Fn: AGG_COUNT} }
+
+// Sum of field.
+func Sum(field string) Aggregator { return Aggregator{Fn: AGG_SUM, Field: field} }
+
+// Smallest value of field.
+func Min(field string) Aggregator { return Aggregator{Fn: AGG_MIN, Field: field} }
+
+// Largest value of field.
+func Max(field string) Aggregator { return Aggregator{Fn: AGG_MAX, Field: field} }
+
+// Mean of field.
+func Avg(field string) Aggregator { return Aggregator{Fn: AGG_AVG, Field: field} }
+
+/*
+ * Group
+ * Aggregated values of the records with the same value of the GroupBy
+ * field. Key is that value, nil without GroupBy and for records without
+ * the field. Values has one value per Aggregator, in their order.
+ */
+type Group struct {
+	Key    interface{}
+	Values []float64
+}
+
+type aggregateDef struct {
+	GroupBy string       `json:",omitempty"`
+	Aggs    []Aggregator `json:"Aggregators"`
+}
+
+/*
+ * aggState
+ * Running values of the aggregators of one group.
+ */
+type aggState struct {
+	docs     uint64
+	n        []uint64
+	sum      []float64
+	min, max []float64
+}
+
+func newAggState(k int) *aggState {
+	s := &aggState{n: make([]uint64, k), sum: make([]float64, k), min: make([]float64, k), max: make([]float64, k)}
+	for i := range s.min {
+		s.min[i], s.max[i] = math.NaN(), math.NaN()
+	}
+	return s
+}
+
+func (s *aggState) add(aggs []Aggregator, doc map[string]interface{}) {
+	s.docs++
+	for i, a := range aggs {
+		v, ok := aggValue(a, doc)
+		if !ok {
+			continue
+		}
+		s.n[i]++
+		s.sum[i] += v
+		if s.n[i] == 1 || v < s.min[i] {
+			s.min[i] = v
+		}
+		if s.n[i] == 1 || v > s.max[i] {
+			s.max[i] = v
+		}
+	}
+}
+
+func (s *aggState) values(aggs []Aggregator) []float64 {
+	vals := make([]float64, len(aggs))
+	for i, a := range aggs {
+		switch a.Fn {
+		case AGG_COUNT:
+			vals[i] = float64(s.docs)
+		case AGG_SUM:
+			vals[i] = s.sum[i]
+		case AGG_MIN:
+			vals[i] = s.min[i]
+		case AGG_MAX:
+			vals[i] = s.max[i]
+		case AGG_AVG:
+			vals[i] = math.NaN()
+			if s.n[i] > 0 {
+				vals[i] = s.sum[i] / float64(s.n[i])
+			}
+		}
+	}
+	return vals
+}
+
+func aggValue(a Aggregator, doc map[string]interface{}) (float64, bool) {
+	if a.Fn == AGG_COUNT {
+		return 0, false
+	}
+	v, _ := fieldValue(doc, a.Field)
+	f, ok := v.(float64)
+	if f == 0 {
+		// -0 and 0 have different keys
+		f = 0
+	}
+	return f, ok
+}
+
+func checkAggregators(aggs []Aggregator) error {
+	if len(aggs) == 0 {
+		return fmt.Errorf("%w: no aggregators", ErrInvalidQuery)
+	}
+	for _, a := range aggs {
+		switch a.Fn {
+		case AGG_COUNT:
+		case AGG_SUM, AGG_MIN, AGG_MAX, AGG_AVG:
+			if a.Field == "" {
+				return fmt.Errorf("%w: %s without a field", ErrInvalidQuery, a.Fn)
+			}
+		default:
+			return fmt.Errorf("%w: unknown aggregate function %q", ErrInvalidQuery, a.Fn)
+		}
+	}
+	return nil
+}
+
+/*
+ * groupKey
+ * Value of the group field of doc. ok is false if it is an array or an
+ * object, such records are left out of grouped aggregates.
+ */
+func groupKey(doc map[string]interface{}, field string) (key interface{}, ok bool) {
+	if field == "" {
+		return nil, true
+	}
+	v, _ := fieldValue(doc, field)
+	switch f := v.(type) {
+	case []interface{}, map[string]interface{}:
+		return nil, false
+	case float64:
+		if f == 0 {
+			// -0 and 0 are the same group
+			return 0.0, true
+		}
+	}
+	return v, true
+}
+
+/*
+ * GroupBy
+ * Aggregate the records of a query per value of field. See Aggregate.
+ */
+func (q *Query) GroupBy(field string) *Query {
+	q.group_by = field
+	return q
+}
+
+/*
+ * Aggregate
+ * Run the query and aggregate the matching records in one read
+ * transaction, without keeping them in memory. Without GroupBy there is
+ * exactly one group. Groups are sorted by key like OrderBy sorts values.
+ * OrderBy and Select are ignored.
+ * db.Query("Orders").GroupBy("Category").Aggregate(dDB.Count(), dDB.Sum("Total"))
+ * @param 	aggs		aggregators, their values are in this order
+ */
+func (q *Query) Aggregate(aggs ...Aggregator) ([]Group, error) {
+	return q.AggregateCtx(context.Background(), aggs...)
+}
+
+/*
+ * AggregateCtx
+ * Aggregate with a context passed to the operation hooks.
+ */
+func (q *Query) AggregateCtx(ctx context.Context, aggs ...Aggregator) ([]Group, error) {
+	if err := checkAggregators(aggs); err != nil {
+		return nil, err
+	}
+	states := make(map[interface{}]*aggState)
+	if q.group_by == "" {
+		states[nil] = newAggState(len(aggs))
+	}
+	stream := *q
+	stream.order, stream.fields = nil, nil
+	err := stream.EachCtx(ctx, func(doc Document) error {
+		key, ok := groupKey(doc.Value, q.group_by)
+		if !ok {
+			return nil
+		}
+		s := states[key]
+		if s == nil {
+			s = newAggState(len(aggs))
+			states[key] = s
+		}
+		s.add(aggs, doc.Value)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make([]Group, 0, len(states))
+	for key, s := range states {
+		groups = append(groups, Group{Key: key, Values: s.values(aggs)})
+	}
+	sortGroups(groups)
+	return groups, nil
+}
+
+/*
+ * sortGroups
+ * Sort groups by key like OrderBy sorts values.
+ */
+func sortGroups(groups []Group) {
+	sort.Slice(groups, func(i, j int) bool {
+		a, b := groups[i].Key, groups[j].Key
+		if ra, rb := sortRank(a, true), sortRank(b, true); ra != rb {
+			return ra < rb
+		}
+		cmp, _ := compareScalars(a, b)
+		return cmp < 0
+	})
+}
+
+func aggregateBucket(bucket string) []byte {
+	return []byte(AGGREGATE_BUCKET_PREFIX + bucket)
+}
+
+func aggregateDefKey(bucket string) []byte {
+	return []byte("aggregate." + bucket)
+}
+
+/*
+ * CreateAggregate
+ * Keep the aggregates of the JSON objects of bucket up to date as records
+ * are stored and removed, so reading them with GetAggregate does not
+ * scan the bucket. Existing records are aggregated right away. Sums are
+ * updated by adding and subtracting, so they may drift by float rounding.
+ * @param 	bucket		name of bucket
+ * @param 	name		name of the aggregate, unique in bucket
+ * @param 	group_by	field to group by, "" for one group
+ * @param 	aggs		aggregators
+ */
+func (db *DumbDB) CreateAggregate(bucket string, name string, group_by string, aggs ...Aggregator) error {
+	return db.CreateAggregateCtx(context.Background(), bucket, name, group_by, aggs...)
+}
+
+/*
+ * CreateAggregateCtx
+ * CreateAggregate with a context passed to the operation hooks. Returns
+ * ctx.Err() if ctx is done before the operation completes.
+ */
+func (db *DumbDB) CreateAggregateCtx(ctx context.Context, bucket string, name string, group_by string,
+	aggs ...Aggregator) (err error) {
+
+	ctx, op := db.startOp(ctx, "CreateAggregate", bucket, 0)
+	defer func() { db.finishOp(ctx, op, err) }()
+
+	if db.read_only {
+		return bolt.ErrDatabaseReadOnly
+	}
+	if err = checkUserBucket(bucket); err != nil {
+		return err
+	}
+	if err = checkAggregators(aggs); err != nil {
+		return err
+	}
+	def := aggregateDef{GroupBy: group_by, Aggs: aggs}
+	err = db.update(ctx, func(tx backend.Tx) error {
+		defs, e := bucketAggregates(tx, bucket)
+		if e != nil {
+			return e
+		}
+		if _, ok := defs[name]; ok {
+			return fmt.Errorf("%w: aggregate %q exists", ErrInvalidQuery, name)
+		}
+		if defs == nil {
+			defs = make(map[string]aggregateDef)
+		}
+		defs[name] = def
+		if e = db.setBucketAggregates(tx, bucket, defs); e != nil {
+			return e
+		}
+		bkt := tx.Bucket([]byte(bucket))
+		if bkt == nil {
+			return nil
+		}
+		return scanCursor(ctx, bkt.Cursor(), ScanOptions{}, func(k, v []byte) error {
+			return updateAggregate(tx, bucket, name, def, nil, decodeObject(v))
+		})
+	})
+	return
+}
+
+/*
+ * DropAggregate
+ * Stop maintaining the aggregate name of bucket and remove its state.
+ * Dropping a missing aggregate does nothing.
+ * @param 	bucket		name of bucket
+ * @param 	name		name given to CreateAggregate
+ */
+func (db *DumbDB) DropAggregate(bucket string, name string) error {
+	return db.DropAggregateCtx(context.Background(), bucket, name)
+}
+
+/*
+ * DropAggregateCtx
+ * DropAggregate with a context passed to the operation hooks. Returns
+ * ctx.Err() if ctx is done before the operation completes.
+ */
+func (db *DumbDB) DropAggregateCtx(ctx context.Context, bucket string, name string) (err error) {
+	ctx, op := db.startOp(ctx, "DropAggregate", bucket, 0)
+	defer func() { db.finishOp(ctx, op, err) }()
+
+	if db.read_only {
+		return bolt.ErrDatabaseReadOnly
+	}
+	if err = checkUserBucket(bucket); err != nil {
+		return err
+	}
+	err = db.update(ctx, func(tx backend.Tx) error {
+		defs, e := bucketAggregates(tx, bucket)
+		if e != nil {
+			return e
+		}
+		if _, ok := defs[name]; !ok {
+			return nil
+		}
+		delete(defs, name)
+		if e = db.setBucketAggregates(tx, bucket, defs); e != nil {
+			return e
+		}
+		state := tx.Bucket(aggregateBucket(bucket))
+		if state == nil {
+			return nil
+		}
+		var entries [][]byte
+		e = scanCursor(ctx, state.Cursor(), ScanOptions{Prefix: keys.MustEncode(name)}, func(k, _ []byte) error {
+			entries = append(entries, bytes.Clone(k))
+			return nil
+		})
+		for _, k := range entries {
+			if e == nil {
+				e = state.Delete(k)
+			}
+		}
+		return e
+	})
+	return
+}
+
+/*
+ * GetAggregate
+ * Current groups of the aggregate name of bucket, as Query.Aggregate
+ * would return them.
+ * @param 	bucket		name of bucket
+ * @param 	name		name given to CreateAggregate
+ */
+func (db *DumbDB) GetAggregate(bucket string, name string) ([]Group, error) {
+	return db.GetAggregateCtx(context.Background(), bucket, name)
+}
+
+/*
+ * GetAggregateCtx
+ * GetAggregate with a context passed to the operation hooks. Returns
+ * ctx.Err() if ctx is done before the groups are read.
+ */
+func (db *DumbDB) GetAggregateCtx(ctx context.Context, bucket string, name string) (groups []Group, err error) {
+	ctx, op := db.startOp(ctx, "GetAggregate", bucket, 0)
+	defer func() { db.finishOp(ctx, op, err) }()
+
+	err = db.view(ctx, func(tx backend.Tx) error {
+		defs, e := bucketAggregates(tx, bucket)
+		if e != nil {
+			return e
+		}
+		def, ok := defs[name]
+		if !ok {
+			return fmt.Errorf("%w: no aggregate %q", ErrInvalidQuery, name)
+		}
+		groups, e = readAggregate(ctx, tx, bucket, name, def)
+		return e
+	})
+	op.Results = len(groups)
+	return
+}
+
+func bucketAggregates(tx backend.Tx, bucket string) (defs map[string]aggregateDef, err error) {
+	meta := tx.Bucket([]byte(META_BUCKET))
+	if meta == nil {
+		return nil, nil
+	}
+	v := meta.Get(aggregateDefKey(bucket))
+	if v == nil {
+		return nil, nil
+	}
+	err = json.Unmarshal(v, &defs)
+	return
+}
+
+func (db *DumbDB) setBucketAggregates(tx backend.Tx, bucket string, defs map[string]aggregateDef) error {
+	if len(defs) == 0 {
+		return db.setDefinition(tx, bucket, aggregateDefKey(bucket), nil)
+	}
+	v, err := json.Marshal(defs)
+	if err != nil {
+		return err
+	}
+	return db.setDefinition(tx, bucket, aggregateDefKey(bucket), v)
+}
+
+/*
+ * updateAggregates
+ * Move a record from old to val in the aggregates of bucket, nil if the
+ * record was created or removed. Called inside the writing transaction.
+ */
+func updateAggregates(tx backend.Tx, bucket string, defs map[string]aggregateDef, old, val []byte) error {
+	old_doc, new_doc := decodeObject(old), decodeObject(val)
+	for name, def := range defs {
+		if err := updateAggregate(tx, bucket, name, def, old_doc, new_doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+ * aggregateGroup
+ * Start of the state keys of the group of key in the aggregate name. A
+ * string too long for the keys is replaced by its hash, flagged with
+ * RANK_LONG_STRING, and returned as long to be kept in the state record.
+ */
+func aggregateGroup(name string, key interface{}) (group []interface{}, long []byte, ok bool) {
+	group, ok = indexTuple(name, key)
+	if !ok {
+		return nil, nil, false
+	}
+	// The longest key of a group is a Min or Max value
+	str, is_str := key.(string)
+	if is_str && len(keys.MustEncode(append(group, int64(0), 0.0)...)) > MAX_KEY_LEN {
+		sum := sha256.Sum256([]byte(str))
+		return []interface{}{name, RANK_LONG_STRING, sum[:]}, []byte(str), true
+	}
+	return group, nil, true
+}
+
+/*
+ * updateAggregate
+ * The state of a group is kept under (name, group, -1) as the no of
+ * records followed by the no and sum of the values of every aggregator,
+ * and by the group value if it is a long string. Min and Max also count
+ * every value under (name, group, aggregator, value), their first and
+ * last entries are the min and max.
+ */
+func updateAggregate(tx backend.Tx, bucket string, name string, def aggregateDef,
+	old_doc, new_doc map[string]interface{}) error {
+
+	state, err := tx.CreateBucketIfNotExists(aggregateBucket(bucket))
+	if err != nil {
+		return err
+	}
+	apply := func(doc map[string]interface{}, sign int64) error {
+		if doc == nil {
+			return nil
+		}
+		key, ok := groupKey(doc, def.GroupBy)
+		if !ok {
+			return nil
+		}
+		group, long, ok := aggregateGroup(name, key)
+		if !ok {
+			return nil
+		}
+		state_key := keys.MustEncode(append(group, int64(-1))...)
+		s := decodeAggState(state.Get(state_key), len(def.Aggs))
+		s.docs += uint64(sign)
+		for i, a := range def.Aggs {
+			v, ok := aggValue(a, doc)
+			if !ok {
+				continue
+			}
+			s.n[i] += uint64(sign)
+			s.sum[i] += float64(sign) * v
+			if a.Fn != AGG_MIN && a.Fn != AGG_MAX {
+				continue
+			}
+			k := keys.MustEncode(append(group, int64(i), v)...)
+			n := int64(0)
+			if c := state.Get(k); len(c) == 8 {
+				n = int64(binary.BigEndian.Uint64(c))
+			}
+			if n += sign; n <= 0 {
+				err = state.Delete(k)
+			} else {
+				err = state.Put(k, binary.BigEndian.AppendUint64(nil, uint64(n)))
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if s.docs == 0 {
+			return state.Delete(state_key)
+		}
+		return state.Put(state_key, append(s.encode(), long...))
+	}
+	if err = apply(old_doc, -1); err != nil {
+		return err
+	}
+	return apply(new_doc, 1)
+}
+
+func (s *aggState) encode() []byte {
+	b := binary.BigEndian.AppendUint64(nil, s.docs)
+	for i := range s.n {
+		b = binary.BigEndian.AppendUint64(b, s.n[i])
+		b = binary.BigEndian.AppendUint64(b, math.Float64bits(s.sum[i]))
+	}
+	return b
+}
+
+/*
+ * decodeAggState
+ * State of a group with k aggregators, b may be followed by the group
+ * value.
+ */
+func decodeAggState(b []byte, k int) *aggState {
+	s := newAggState(k)
+	if len(b) < 8+16*k {
+		return s
+	}
+	s.docs = binary.BigEndian.Uint64(b)
+	for i := 0; i < k; i++ {
+		s.n[i] = binary.BigEndian.Uint64(b[8+16*i:])
+		s.sum[i] = math.Float64frombits(binary.BigEndian.Uint64(b[16+16*i:]))
+	}
+	return s
+}
+
+/*
+ * readAggregate
+ * Walk the state of an aggregate group by group. Min and max entries of a
+ * group follow its state, in value order.
+ */
+func readAggregate(ctx context.Context, tx backend.Tx, bucket string, name string, def aggregateDef) ([]Group, error) {
+	groups := make([]Group, 0)
+	state := tx.Bucket(aggregateBucket(bucket))
+	if state == nil {
+		if def.GroupBy == "" {
+			groups = append(groups, Group{Values: newAggState(len(def.Aggs)).values(def.Aggs)})
+		}
+		return groups, nil
+	}
+
+	var cur *aggState
+	var cur_key interface{}
+	long := false
+	flush := func() {
+		if cur != nil {
+			groups = append(groups, Group{Key: cur_key, Values: cur.values(def.Aggs)})
+		}
+	}
+	err := scanCursor(ctx, state.Cursor(), ScanOptions{Prefix: keys.MustEncode(name)}, func(k, v []byte) error {
+		tuple, err := keys.Decode(k)
+		if err != nil || len(tuple) < 3 {
+			return keys.ErrInvalidKey
+		}
+		key, rest := groupValue(tuple[1:])
+		if len(rest) == 0 {
+			return keys.ErrInvalidKey
+		}
+		slot, _ := rest[0].(int64)
+		if slot < 0 {
+			flush()
+			cur, cur_key = decodeAggState(v, len(def.Aggs)), key
+			if rank, _ := tuple[1].(int64); rank == RANK_LONG_STRING {
+				if len(v) < 8+16*len(def.Aggs) {
+					return keys.ErrInvalidKey
+				}
+				cur_key, long = string(v[8+16*len(def.Aggs):]), true
+			}
+			return nil
+		}
+		val, ok := rest[len(rest)-1].(float64)
+		if cur == nil || int(slot) >= len(def.Aggs) || !ok {
+			return keys.ErrInvalidKey
+		}
+		if math.IsNaN(cur.min[slot]) {
+			cur.min[slot] = val
+		}
+		cur.max[slot] = val
+		return nil
+	})
+	flush()
+	if long {
+		// Long strings follow all other groups in the state
+		sortGroups(groups)
+	}
+	if len(groups) == 0 && def.GroupBy == "" {
+		groups = append(groups, Group{Values: newAggState(len(def.Aggs)).values(def.Aggs)})
+	}
+	return groups, err
+}
+
+/*
+ * groupValue
+ * Group key from the decoded (rank, value) of indexTuple, and the rest of
+ * the tuple.
+ */
+func groupValue(tuple []interface{}) (key interface{}, rest []interface{}) {
+	rank, _ := tuple[0].(int64)
+	if rank == RANK_NULL || len(tuple) < 2 {
+		return nil, tuple[1:]
+	}
+	switch rank {
+	case RANK_BOOL:
+		b, _ := tuple[1].(int64)
+		key = b == 1
+	default:
+		key = tuple[1]
+	}
+	return key, tuple[2:]
+}
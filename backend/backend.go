@@ -0,0 +1,150 @@
+/*
+ * Package backend defines the storage engine interface used by DumbDB.
+ * Implementations live in the subpackages: boltdb (github.com/boltdb/bolt,
+ * the default), bbolt (go.etcd.io/bbolt) and memory.
+ *
+ * The interfaces follow bolt: a single writer and concurrent readers, each
+ * transaction sees a consistent snapshot, keys are ordered byte-wise and
+ * values returned by a transaction are only valid until it ends.
+ */
+package backend
+
+import (
+	"context"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// Errors returned by every backend. These are the errors of boltdb/bolt,
+// which DumbDB has always returned, other backends translate theirs.
+var (
+	ErrDatabaseNotOpen    = bolt.ErrDatabaseNotOpen
+	ErrTxNotWritable      = bolt.ErrTxNotWritable
+	ErrTxClosed           = bolt.ErrTxClosed
+	ErrBucketNotFound     = bolt.ErrBucketNotFound
+	ErrBucketExists       = bolt.ErrBucketExists
+	ErrBucketNameRequired = bolt.ErrBucketNameRequired
+	ErrKeyRequired        = bolt.ErrKeyRequired
+	ErrKeyTooLarge        = bolt.ErrKeyTooLarge
+	ErrValueTooLarge      = bolt.ErrValueTooLarge
+	ErrIncompatibleValue  = bolt.ErrIncompatibleValue
+)
+
+// Limits shared by all backends, the same as bolt's.
+const (
+	MAX_KEY_SIZE   = bolt.MaxKeySize
+	MAX_VALUE_SIZE = bolt.MaxValueSize
+)
+
+/*
+ * Opener
+ * Opens the backend stored at path, waiting for a file lock held by
+ * another process until ctx is done.
+ */
+type Opener func(ctx context.Context, path string) (Backend, error)
+
+/*
+ * Backend
+ * An open store of buckets of ordered key-value pairs.
+ */
+type Backend interface {
+	// Run fn in a read-only transaction.
+	View(fn func(Tx) error) error
+	// Run fn in a read-write transaction, committed if fn returns nil.
+	// Only one runs at a time.
+	Update(fn func(Tx) error) error
+	// File of the backend, "" if it is not stored in a file.
+	Path() string
+	Stats() Stats
+	Close() error
+}
+
+type Tx interface {
+	Writable() bool
+	// Bucket by name, nil if it does not exist.
+	Bucket(name []byte) Bucket
+	CreateBucket(name []byte) (Bucket, error)
+	CreateBucketIfNotExists(name []byte) (Bucket, error)
+	DeleteBucket(name []byte) error
+	// Call fn for every bucket, in name order.
+	ForEach(fn func(name []byte, b Bucket) error) error
+	// Call fn once the transaction has committed.
+	OnCommit(fn func())
+	// Consistency errors, the channel is closed when the check is done.
+	Check() <-chan error
+}
+
+/*
+ * Bucket
+ * Ordered key-value pairs, and nested buckets stored under keys of their
+ * own. Get returns nil and cursors return a nil value for the key of a
+ * nested bucket, Put and Delete fail on it with ErrIncompatibleValue.
+ */
+type Bucket interface {
+	// Value of key, nil if it does not exist.
+	Get(key []byte) []byte
+	Put(key []byte, value []byte) error
+	Delete(key []byte) error
+	// Nested bucket by name, nil if it does not exist.
+	Bucket(name []byte) Bucket
+	CreateBucket(name []byte) (Bucket, error)
+	CreateBucketIfNotExists(name []byte) (Bucket, error)
+	DeleteBucket(name []byte) error
+	Cursor() Cursor
+	Sequence() uint64
+	SetSequence(v uint64) error
+	NextSequence() (uint64, error)
+	Stats() BucketStats
+}
+
+/*
+ * Cursor
+ * Walks the keys of a bucket in order. All methods return nil keys past
+ * either end. Seek moves to the first key >= seek.
+ */
+type Cursor interface {
+	First() (key []byte, value []byte)
+	Last() (key []byte, value []byte)
+	Next() (key []byte, value []byte)
+	Prev() (key []byte, value []byte)
+	Seek(seek []byte) (key []byte, value []byte)
+}
+
+/*
+ * Stats
+ * Freelist and transaction stats. Backends fill in what they track.
+ */
+type Stats struct {
+	FreePageN     int
+	PendingPageN  int
+	FreeAlloc     int
+	FreelistInuse int
+	TxN           int
+	OpenTxN       int
+	Tx            TxStats
+}
+
+type TxStats struct {
+	PageCount     int
+	PageAlloc     int
+	CursorCount   int
+	NodeCount     int
+	NodeDeref     int
+	Rebalance     int
+	RebalanceTime time.Duration
+	Split         int
+	Spill         int
+	SpillTime     time.Duration
+	Write         int
+	WriteTime     time.Duration
+}
+
+// Size of a bucket. Sizes are in bytes.
+type BucketStats struct {
+	KeyN      int
+	Depth     int
+	PageN     int
+	Allocated int
+	InUse     int
+}
@@ -0,0 +1,200 @@
+/*
+ * Package boltdb is the github.com/boltdb/bolt backend, the default
+ * backend of DumbDB.
+ */
+package boltdb
+
+import (
+	"context"
+	"time"
+
+	"dumbDB/backend"
+
+	"github.com/boltdb/bolt"
+)
+
+// How often a blocked open retries to lock the DB file.
+const LOCK_POLL_INTERVAL = 100 * time.Millisecond
+
+type db struct {
+	bdb *bolt.DB
+}
+
+/*
+ * Open
+ * Open or create the bolt file at path, polling for the file lock until
+ * ctx is done. Satisfies backend.Opener.
+ */
+func Open(ctx context.Context, path string) (backend.Backend, error) {
+	for {
+		bdb, err := bolt.Open(path, 0600, &bolt.Options{Timeout: LOCK_POLL_INTERVAL})
+		if err == nil {
+			return &db{bdb: bdb}, nil
+		}
+		if err != bolt.ErrTimeout {
+			return nil, err
+		}
+		if e := ctx.Err(); e != nil {
+			return nil, e
+		}
+	}
+}
+
+func (d *db) View(fn func(backend.Tx) error) error {
+	return d.bdb.View(func(t *bolt.Tx) error { return fn(tx{t}) })
+}
+
+func (d *db) Update(fn func(backend.Tx) error) error {
+	return d.bdb.Update(func(t *bolt.Tx) error { return fn(tx{t}) })
+}
+
+func (d *db) Path() string {
+	return d.bdb.Path()
+}
+
+func (d *db) Close() error {
+	return d.bdb.Close()
+}
+
+func (d *db) Stats() backend.Stats {
+	s := d.bdb.Stats()
+	return backend.Stats{
+		FreePageN:     s.FreePageN,
+		PendingPageN:  s.PendingPageN,
+		FreeAlloc:     s.FreeAlloc,
+		FreelistInuse: s.FreelistInuse,
+		TxN:           s.TxN,
+		OpenTxN:       s.OpenTxN,
+		Tx: backend.TxStats{
+			PageCount:     s.TxStats.PageCount,
+			PageAlloc:     s.TxStats.PageAlloc,
+			CursorCount:   s.TxStats.CursorCount,
+			NodeCount:     s.TxStats.NodeCount,
+			NodeDeref:     s.TxStats.NodeDeref,
+			Rebalance:     s.TxStats.Rebalance,
+			RebalanceTime: s.TxStats.RebalanceTime,
+			Split:         s.TxStats.Split,
+			Spill:         s.TxStats.Spill,
+			SpillTime:     s.TxStats.SpillTime,
+			Write:         s.TxStats.Write,
+			WriteTime:     s.TxStats.WriteTime,
+		},
+	}
+}
+
+type tx struct {
+	t *bolt.Tx
+}
+
+func (t tx) Writable() bool {
+	return t.t.Writable()
+}
+
+func (t tx) Bucket(name []byte) backend.Bucket {
+	if b := t.t.Bucket(name); b != nil {
+		return bucket{b}
+	}
+	return nil
+}
+
+func (t tx) CreateBucket(name []byte) (backend.Bucket, error) {
+	b, err := t.t.CreateBucket(name)
+	if err != nil {
+		return nil, err
+	}
+	return bucket{b}, nil
+}
+
+func (t tx) CreateBucketIfNotExists(name []byte) (backend.Bucket, error) {
+	b, err := t.t.CreateBucketIfNotExists(name)
+	if err != nil {
+		return nil, err
+	}
+	return bucket{b}, nil
+}
+
+func (t tx) DeleteBucket(name []byte) error {
+	return t.t.DeleteBucket(name)
+}
+
+func (t tx) ForEach(fn func(name []byte, b backend.Bucket) error) error {
+	return t.t.ForEach(func(name []byte, b *bolt.Bucket) error { return fn(name, bucket{b}) })
+}
+
+func (t tx) OnCommit(fn func()) {
+	t.t.OnCommit(fn)
+}
+
+func (t tx) Check() <-chan error {
+	return t.t.Check()
+}
+
+type bucket struct {
+	b *bolt.Bucket
+}
+
+func (b bucket) Get(key []byte) []byte {
+	return b.b.Get(key)
+}
+
+func (b bucket) Put(key []byte, value []byte) error {
+	return b.b.Put(key, value)
+}
+
+func (b bucket) Delete(key []byte) error {
+	return b.b.Delete(key)
+}
+
+func (b bucket) Bucket(name []byte) backend.Bucket {
+	if nb := b.b.Bucket(name); nb != nil {
+		return bucket{nb}
+	}
+	return nil
+}
+
+func (b bucket) CreateBucket(name []byte) (backend.Bucket, error) {
+	nb, err := b.b.CreateBucket(name)
+	if err != nil {
+		return nil, err
+	}
+	return bucket{nb}, nil
+}
+
+func (b bucket) CreateBucketIfNotExists(name []byte) (backend.Bucket, error) {
+	nb, err := b.b.CreateBucketIfNotExists(name)
+	if err != nil {
+		return nil, err
+	}
+	return bucket{nb}, nil
+}
+
+func (b bucket) DeleteBucket(name []byte) error {
+	return b.b.DeleteBucket(name)
+}
+
+func (b bucket) Cursor() backend.Cursor {
+	return b.b.Cursor()
+}
+
+func (b bucket) Sequence() uint64 {
+	return b.b.Sequence()
+}
+
+func (b bucket) SetSequence(v uint64) error {
+	return b.b.SetSequence(v)
+}
+
+func (b bucket) NextSequence() (uint64, error) {
+	return b.b.NextSequence()
+}
+
+func (b bucket) Stats() backend.BucketStats {
+	s := b.b.Stats()
+	return backend.BucketStats{
+		KeyN:      s.KeyN,
+		Depth:     s.Depth,
+		PageN:     s.BranchPageN + s.BranchOverflowN + s.LeafPageN + s.LeafOverflowN,
+		Allocated: s.BranchAlloc + s.LeafAlloc,
+		InUse:     s.BranchInuse + s.LeafInuse + s.InlineBucketInuse,
+	}
+}
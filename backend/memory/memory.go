@@ -0,0 +1,424 @@
+/*
+ * Package memory is a backend keeping all data in memory. It has the same
+ * semantics as the bolt backends: ordered keys, one writer at a time,
+ * readers seeing a consistent snapshot and writes which are only visible
+ * once committed. Nothing is persisted.
+ */
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"dumbDB/backend"
+)
+
+/*
+ * bucketData
+ * Committed state of a bucket. Never modified, writes replace it.
+ */
+type bucketData struct {
+	root  *node
+	keyN  int
+	bytes int
+	seq   uint64
+}
+
+type db struct {
+	mu      sync.RWMutex
+	buckets map[string]*bucketData
+	closed  bool
+	// Held by the writing transaction.
+	write sync.Mutex
+
+	tx_n      int64
+	open_tx_n int64
+	writes    int64
+	write_ns  int64
+}
+
+/*
+ * New
+ * An empty in-memory backend.
+ */
+func New() backend.Backend {
+	return &db{buckets: make(map[string]*bucketData)}
+}
+
+/*
+ * Open
+ * An empty in-memory backend. path is ignored, every call returns a new
+ * store. Satisfies backend.Opener.
+ */
+func Open(_ context.Context, _ string) (backend.Backend, error) {
+	return New(), nil
+}
+
+/*
+ * snapshot
+ * The committed buckets, nil once the DB is closed.
+ */
+func (d *db) snapshot() map[string]*bucketData {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.closed {
+		return nil
+	}
+	return d.buckets
+}
+
+func (d *db) View(fn func(backend.Tx) error) error {
+	buckets := d.snapshot()
+	if buckets == nil {
+		return backend.ErrDatabaseNotOpen
+	}
+	atomic.AddInt64(&d.open_tx_n, 1)
+	defer atomic.AddInt64(&d.open_tx_n, -1)
+	atomic.AddInt64(&d.tx_n, 1)
+	return fn(&tx{buckets: buckets})
+}
+
+func (d *db) Update(fn func(backend.Tx) error) error {
+	d.write.Lock()
+	defer d.write.Unlock()
+	committed := d.snapshot()
+	if committed == nil {
+		return backend.ErrDatabaseNotOpen
+	}
+
+	buckets := make(map[string]*bucketData, len(committed))
+	for name, b := range committed {
+		buckets[name] = b
+	}
+	t := &tx{buckets: buckets, writable: true}
+	if err := fn(t); err != nil {
+		return err
+	}
+
+	start := time.Now()
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return backend.ErrDatabaseNotOpen
+	}
+	d.buckets = t.buckets
+	d.mu.Unlock()
+	atomic.AddInt64(&d.writes, 1)
+	atomic.AddInt64(&d.write_ns, int64(time.Since(start)))
+
+	for _, f := range t.on_commit {
+		f()
+	}
+	return nil
+}
+
+func (d *db) Path() string {
+	return ""
+}
+
+func (d *db) Stats() backend.Stats {
+	return backend.Stats{
+		TxN:     int(atomic.LoadInt64(&d.tx_n)),
+		OpenTxN: int(atomic.LoadInt64(&d.open_tx_n)),
+		Tx: backend.TxStats{
+			Write:     int(atomic.LoadInt64(&d.writes)),
+			WriteTime: time.Duration(atomic.LoadInt64(&d.write_ns)),
+		},
+	}
+}
+
+func (d *db) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return backend.ErrDatabaseNotOpen
+	}
+	d.closed = true
+	d.buckets = nil
+	return nil
+}
+
+type tx struct {
+	buckets   map[string]*bucketData
+	writable  bool
+	on_commit []func()
+}
+
+func (t *tx) Writable() bool {
+	return t.writable
+}
+
+func (t *tx) Bucket(name []byte) backend.Bucket {
+	if _, ok := t.buckets[string(name)]; !ok {
+		return nil
+	}
+	return &bucket{tx: t, path: []string{string(name)}}
+}
+
+func (t *tx) CreateBucket(name []byte) (backend.Bucket, error) {
+	if !t.writable {
+		return nil, backend.ErrTxNotWritable
+	}
+	if len(name) == 0 {
+		return nil, backend.ErrBucketNameRequired
+	}
+	if _, ok := t.buckets[string(name)]; ok {
+		return nil, backend.ErrBucketExists
+	}
+	t.buckets[string(name)] = &bucketData{}
+	return &bucket{tx: t, path: []string{string(name)}}, nil
+}
+
+func (t *tx) CreateBucketIfNotExists(name []byte) (backend.Bucket, error) {
+	if b := t.Bucket(name); b != nil {
+		return b, nil
+	}
+	return t.CreateBucket(name)
+}
+
+func (t *tx) DeleteBucket(name []byte) error {
+	if !t.writable {
+		return backend.ErrTxNotWritable
+	}
+	if _, ok := t.buckets[string(name)]; !ok {
+		return backend.ErrBucketNotFound
+	}
+	delete(t.buckets, string(name))
+	return nil
+}
+
+func (t *tx) ForEach(fn func(name []byte, b backend.Bucket) error) error {
+	names := make([]string, 0, len(t.buckets))
+	for name := range t.buckets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := fn([]byte(name), &bucket{tx: t, path: []string{name}}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *tx) OnCommit(fn func()) {
+	t.on_commit = append(t.on_commit, fn)
+}
+
+/*
+ * Check
+ * Nothing to check in memory, the channel is returned closed.
+ */
+func (t *tx) Check() <-chan error {
+	ch := make(chan error)
+	close(ch)
+	return ch
+}
+
+/*
+ * bucket
+ * Handle to a bucket of a transaction, nested buckets follow the names
+ * of their parents in path. It reads the current state of the bucket in
+ * the transaction, so it sees the transaction's own writes.
+ */
+type bucket struct {
+	tx   *tx
+	path []string
+}
+
+/*
+ * find
+ * Committed state of the bucket in the transaction, nil if it or one of
+ * its parents does not exist.
+ */
+func (b *bucket) find() *bucketData {
+	d := b.tx.buckets[b.path[0]]
+	for _, name := range b.path[1:] {
+		if d == nil {
+			return nil
+		}
+		n := get(d.root, []byte(name))
+		if n == nil {
+			return nil
+		}
+		d = n.sub
+	}
+	return d
+}
+
+func (b *bucket) data() *bucketData {
+	if d := b.find(); d != nil {
+		return d
+	}
+	return &bucketData{}
+}
+
+/*
+ * update
+ * Replace the state of the bucket in the transaction, and that of its
+ * parents up to the top-level bucket.
+ */
+func (b *bucket) update(d *bucketData) error {
+	if !b.tx.writable {
+		return backend.ErrTxNotWritable
+	}
+	if b.find() == nil {
+		return backend.ErrBucketNotFound
+	}
+	for i := len(b.path) - 1; i > 0; i-- {
+		parent := *(&bucket{tx: b.tx, path: b.path[:i]}).find()
+		k := []byte(b.path[i])
+		parent.root = insert(parent.root, k, nil, d, priority(k))
+		d = &parent
+	}
+	b.tx.buckets[b.path[0]] = d
+	return nil
+}
+
+func (b *bucket) nested(name []byte) *bucket {
+	path := append(append([]string(nil), b.path...), string(name))
+	return &bucket{tx: b.tx, path: path}
+}
+
+func (b *bucket) Get(key []byte) []byte {
+	if n := get(b.data().root, key); n != nil {
+		return n.val
+	}
+	return nil
+}
+
+func (b *bucket) Put(key []byte, value []byte) error {
+	if !b.tx.writable {
+		return backend.ErrTxNotWritable
+	}
+	switch {
+	case len(key) == 0:
+		return backend.ErrKeyRequired
+	case len(key) > backend.MAX_KEY_SIZE:
+		return backend.ErrKeyTooLarge
+	case len(value) > backend.MAX_VALUE_SIZE:
+		return backend.ErrValueTooLarge
+	}
+	d := *b.data()
+	if old := get(d.root, key); old != nil {
+		if old.sub != nil {
+			return backend.ErrIncompatibleValue
+		}
+		d.bytes -= len(old.key) + len(old.val)
+	} else {
+		d.keyN++
+	}
+	k := append([]byte(nil), key...)
+	v := append([]byte{}, value...)
+	d.root = insert(d.root, k, v, nil, priority(k))
+	d.bytes += len(k) + len(v)
+	return b.update(&d)
+}
+
+func (b *bucket) Delete(key []byte) error {
+	if !b.tx.writable {
+		return backend.ErrTxNotWritable
+	}
+	d := *b.data()
+	old := get(d.root, key)
+	if old == nil {
+		return nil
+	}
+	if old.sub != nil {
+		return backend.ErrIncompatibleValue
+	}
+	d.root = remove(d.root, key)
+	d.keyN--
+	d.bytes -= len(old.key) + len(old.val)
+	return b.update(&d)
+}
+
+func (b *bucket) Bucket(name []byte) backend.Bucket {
+	if n := get(b.data().root, name); n != nil && n.sub != nil {
+		return b.nested(name)
+	}
+	return nil
+}
+
+func (b *bucket) CreateBucket(name []byte) (backend.Bucket, error) {
+	if !b.tx.writable {
+		return nil, backend.ErrTxNotWritable
+	}
+	switch {
+	case len(name) == 0:
+		return nil, backend.ErrBucketNameRequired
+	case len(name) > backend.MAX_KEY_SIZE:
+		return nil, backend.ErrKeyTooLarge
+	}
+	d := *b.data()
+	if old := get(d.root, name); old != nil {
+		if old.sub != nil {
+			return nil, backend.ErrBucketExists
+		}
+		return nil, backend.ErrIncompatibleValue
+	}
+	k := append([]byte(nil), name...)
+	d.root = insert(d.root, k, nil, &bucketData{}, priority(k))
+	d.keyN++
+	d.bytes += len(k)
+	if err := b.update(&d); err != nil {
+		return nil, err
+	}
+	return b.nested(name), nil
+}
+
+func (b *bucket) CreateBucketIfNotExists(name []byte) (backend.Bucket, error) {
+	if nb := b.Bucket(name); nb != nil {
+		return nb, nil
+	}
+	return b.CreateBucket(name)
+}
+
+func (b *bucket) DeleteBucket(name []byte) error {
+	if !b.tx.writable {
+		return backend.ErrTxNotWritable
+	}
+	d := *b.data()
+	old := get(d.root, name)
+	if old == nil {
+		return backend.ErrBucketNotFound
+	}
+	if old.sub == nil {
+		return backend.ErrIncompatibleValue
+	}
+	d.root = remove(d.root, name)
+	d.keyN--
+	d.bytes -= len(old.key)
+	return b.update(&d)
+}
+
+func (b *bucket) Cursor() backend.Cursor {
+	return &cursor{root: b.data().root}
+}
+
+func (b *bucket) Sequence() uint64 {
+	return b.data().seq
+}
+
+func (b *bucket) SetSequence(v uint64) error {
+	d := *b.data()
+	d.seq = v
+	return b.update(&d)
+}
+
+func (b *bucket) NextSequence() (uint64, error) {
+	d := *b.data()
+	d.seq++
+	if err := b.update(&d); err != nil {
+		return 0, err
+	}
+	return d.seq, nil
+}
+
+func (b *bucket) Stats() backend.BucketStats {
+	d := b.data()
+	return backend.BucketStats{KeyN: d.keyN, Allocated: d.bytes, InUse: d.bytes}
+}
@@ -0,0 +1,228 @@
+package memory
+
+import (
+	"bytes"
+	"hash/fnv"
+)
+
+/*
+ * node
+ * Node of a persistent treap ordered by key. Nodes are never modified once
+ * they are reachable from a committed bucket, writes copy the path from
+ * the root to the changed node instead.
+ */
+type node struct {
+	key []byte
+	// nil for a nested bucket
+	val []byte
+	// Nested bucket stored under key
+	sub   *bucketData
+	prio  uint32
+	left  *node
+	right *node
+}
+
+/*
+ * priority
+ * Heap priority of a key. Derived from the key so trees are reproducible.
+ */
+func priority(key []byte) uint32 {
+	h := fnv.New32a()
+	h.Write(key)
+	return h.Sum32()
+}
+
+func get(n *node, key []byte) *node {
+	for n != nil {
+		switch c := bytes.Compare(key, n.key); {
+		case c < 0:
+			n = n.left
+		case c > 0:
+			n = n.right
+		default:
+			return n
+		}
+	}
+	return nil
+}
+
+/*
+ * insert
+ * Tree with key set to val, or to the nested bucket sub if it is not nil.
+ * The nodes of n are left unchanged.
+ */
+func insert(n *node, key, val []byte, sub *bucketData, prio uint32) *node {
+	if n == nil {
+		return &node{key: key, val: val, sub: sub, prio: prio}
+	}
+	cp := *n
+	switch c := bytes.Compare(key, n.key); {
+	case c < 0:
+		cp.left = insert(n.left, key, val, sub, prio)
+		if cp.left.prio > cp.prio {
+			return rotateRight(&cp)
+		}
+	case c > 0:
+		cp.right = insert(n.right, key, val, sub, prio)
+		if cp.right.prio > cp.prio {
+			return rotateLeft(&cp)
+		}
+	default:
+		cp.val, cp.sub = val, sub
+	}
+	return &cp
+}
+
+/*
+ * remove
+ * Tree without key, which must exist. The nodes of n are left unchanged.
+ */
+func remove(n *node, key []byte) *node {
+	if n == nil {
+		return nil
+	}
+	cp := *n
+	switch c := bytes.Compare(key, n.key); {
+	case c < 0:
+		cp.left = remove(n.left, key)
+	case c > 0:
+		cp.right = remove(n.right, key)
+	default:
+		return merge(n.left, n.right)
+	}
+	return &cp
+}
+
+/*
+ * merge
+ * Join two trees, all keys of a being smaller than those of b.
+ */
+func merge(a, b *node) *node {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	if a.prio > b.prio {
+		cp := *a
+		cp.right = merge(a.right, b)
+		return &cp
+	}
+	cp := *b
+	cp.left = merge(a, b.left)
+	return &cp
+}
+
+// Rotations only touch nodes freshly copied by insert.
+func rotateRight(n *node) *node {
+	l := n.left
+	n.left = l.right
+	l.right = n
+	return l
+}
+
+func rotateLeft(n *node) *node {
+	r := n.right
+	n.right = r.left
+	r.left = n
+	return r
+}
+
+/*
+ * cursor
+ * Walks a tree in key order. path holds the nodes from the root to the
+ * current node, it is empty once the cursor moved past either end.
+ */
+type cursor struct {
+	root *node
+	path []*node
+}
+
+func (c *cursor) current() ([]byte, []byte) {
+	if len(c.path) == 0 {
+		return nil, nil
+	}
+	n := c.path[len(c.path)-1]
+	return n.key, n.val
+}
+
+func (c *cursor) First() ([]byte, []byte) {
+	c.path = c.path[:0]
+	for n := c.root; n != nil; n = n.left {
+		c.path = append(c.path, n)
+	}
+	return c.current()
+}
+
+func (c *cursor) Last() ([]byte, []byte) {
+	c.path = c.path[:0]
+	for n := c.root; n != nil; n = n.right {
+		c.path = append(c.path, n)
+	}
+	return c.current()
+}
+
+func (c *cursor) Next() ([]byte, []byte) {
+	if len(c.path) == 0 {
+		return nil, nil
+	}
+	n := c.path[len(c.path)-1]
+	if n.right != nil {
+		for n = n.right; n != nil; n = n.left {
+			c.path = append(c.path, n)
+		}
+		return c.current()
+	}
+	// Go up until we come from a left child.
+	for len(c.path) > 1 {
+		child := c.path[len(c.path)-1]
+		c.path = c.path[:len(c.path)-1]
+		if c.path[len(c.path)-1].left == child {
+			return c.current()
+		}
+	}
+	c.path = c.path[:0]
+	return nil, nil
+}
+
+func (c *cursor) Prev() ([]byte, []byte) {
+	if len(c.path) == 0 {
+		return nil, nil
+	}
+	n := c.path[len(c.path)-1]
+	if n.left != nil {
+		for n = n.left; n != nil; n = n.right {
+			c.path = append(c.path, n)
+		}
+		return c.current()
+	}
+	for len(c.path) > 1 {
+		child := c.path[len(c.path)-1]
+		c.path = c.path[:len(c.path)-1]
+		if c.path[len(c.path)-1].right == child {
+			return c.current()
+		}
+	}
+	c.path = c.path[:0]
+	return nil, nil
+}
+
+func (c *cursor) Seek(seek []byte) ([]byte, []byte) {
+	c.path = c.path[:0]
+	best := -1
+	for n := c.root; n != nil; {
+		c.path = append(c.path, n)
+		switch cmp := bytes.Compare(seek, n.key); {
+		case cmp < 0:
+			best = len(c.path) - 1
+			n = n.left
+		case cmp > 0:
+			n = n.right
+		default:
+			return c.current()
+		}
+	}
+	c.path = c.path[:best+1]
+	return c.current()
+}
@@ -0,0 +1,189 @@
+package dumbDatabase
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"dumbDB/backend"
+
+	"github.com/boltdb/bolt"
+)
+
+// Matched by errors.Is for every *ConflictError.
+var ErrConflict = errors.New("conflicting write")
+
+/*
+ * ConflictError
+ * Returned by the conditional writes when the stored value does not
+ * match the expected one. Nothing was written.
+ * Current is the stored value, nil if the key does not exist.
+ */
+type ConflictError struct {
+	Op      string
+	Bucket  string
+	Key     []byte
+	Current []byte
+}
+
+func (e *ConflictError) Error() string {
+	if e.Current == nil {
+		return fmt.Sprintf("%s: %s: key %q does not exist", e.Op, e.Bucket, e.Key)
+	}
+	return fmt.Sprintf("%s: %s: key %q has a different value", e.Op, e.Bucket, e.Key)
+}
+
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+/*
+ * CompareAndSwap
+ * Store new_val if the value of key is old_val. A nil old_val expects the key
+ * not to exist. Fails with a *ConflictError otherwise.
+ * @param 	bucket		name of bucket, created if needed
+ * @param 	key		byte slice containing key
+ * @param 	old_val		expected value
+ * @param 	new_val		value to store
+ */
+func (db *DumbDB) CompareAndSwap(bucket string, key, old_val, new_val []byte) error {
+	return db.CompareAndSwapCtx(context.Background(), bucket, key, old_val, new_val)
+}
+
+/*
+ * CompareAndSwapCtx
+ * CompareAndSwap with a context passed to the operation hooks. Returns
+ * ctx.Err() if ctx is done before the operation completes.
+ */
+func (db *DumbDB) CompareAndSwapCtx(ctx context.Context, bucket string, key, old_val, new_val []byte) error {
+	return db.conditionalWrite(ctx, "CompareAndSwap", bucket, key, new_val, opPut, func(cur []byte) bool {
+		if old_val == nil {
+			return cur == nil
+		}
+		return cur != nil && bytes.Equal(cur, old_val)
+	})
+}
+
+/*
+ * PutIfAbsent
+ * Store val if key does not exist. Fails with a *ConflictError otherwise.
+ * @param 	bucket		name of bucket, created if needed
+ * @param 	key		byte slice containing key
+ * @param 	val		value to store
+ */
+func (db *DumbDB) PutIfAbsent(bucket string, key, val []byte) error {
+	return db.PutIfAbsentCtx(context.Background(), bucket, key, val)
+}
+
+/*
+ * PutIfAbsentCtx
+ * PutIfAbsent with a context passed to the operation hooks. Returns
+ * ctx.Err() if ctx is done before the operation completes.
+ */
+func (db *DumbDB) PutIfAbsentCtx(ctx context.Context, bucket string, key, val []byte) error {
+	return db.conditionalWrite(ctx, "PutIfAbsent", bucket, key, val, opPut, func(cur []byte) bool {
+		return cur == nil
+	})
+}
+
+/*
+ * ReplaceIfExists
+ * Store val if key exists. Fails with a *ConflictError otherwise.
+ * @param 	bucket		name of bucket
+ * @param 	key		byte slice containing key
+ * @param 	val		value to store
+ */
+func (db *DumbDB) ReplaceIfExists(bucket string, key, val []byte) error {
+	return db.ReplaceIfExistsCtx(context.Background(), bucket, key, val)
+}
+
+/*
+ * ReplaceIfExistsCtx
+ * ReplaceIfExists with a context passed to the operation hooks. Returns
+ * ctx.Err() if ctx is done before the operation completes.
+ */
+func (db *DumbDB) ReplaceIfExistsCtx(ctx context.Context, bucket string, key, val []byte) error {
+	return db.conditionalWrite(ctx, "ReplaceIfExists", bucket, key, val, opPut, func(cur []byte) bool {
+		return cur != nil
+	})
+}
+
+/*
+ * DeleteIfEquals
+ * Remove key if its value is old_val. Fails with a *ConflictError if the
+ * value differs or the key does not exist.
+ * @param 	bucket		name of bucket
+ * @param 	key		byte slice containing key
+ * @param 	old_val		expected value
+ */
+func (db *DumbDB) DeleteIfEquals(bucket string, key, old_val []byte) error {
+	return db.DeleteIfEqualsCtx(context.Background(), bucket, key, old_val)
+}
+
+/*
+ * DeleteIfEqualsCtx
+ * DeleteIfEquals with a context passed to the operation hooks. Returns
+ * ctx.Err() if ctx is done before the operation completes.
+ */
+func (db *DumbDB) DeleteIfEqualsCtx(ctx context.Context, bucket string, key, old_val []byte) error {
+	return db.conditionalWrite(ctx, "DeleteIfEquals", bucket, key, nil, opDelete, func(cur []byte) bool {
+		return cur != nil && bytes.Equal(cur, old_val)
+	})
+}
+
+/*
+ * conditionalWrite
+ * Put val, or delete key, in a single write transaction if ok accepts the
+ * current value of key, nil if it does not exist.
+ */
+func (db *DumbDB) conditionalWrite(ctx context.Context, name string, bucket string, key, val []byte,
+	w_op byte, ok func(cur []byte) bool) (err error) {
+
+	ctx, op := db.startOp(ctx, name, bucket, len(key))
+	defer func() { db.finishOp(ctx, op, err) }()
+
+	if db.read_only {
+		return bolt.ErrDatabaseReadOnly
+	}
+	if err = checkUserBucket(bucket); err != nil {
+		return err
+	}
+	err = db.update(ctx, func(tx backend.Tx) error {
+
+		if len(key) > MAX_KEY_LEN {
+			return bolt.ErrKeyTooLarge
+		}
+
+		var cur []byte
+		bkt := tx.Bucket([]byte(bucket))
+		if bkt != nil {
+			cur = bkt.Get(key)
+		}
+		if !ok(cur) {
+			if cur != nil {
+				cur = append([]byte(nil), cur...)
+			}
+			return &ConflictError{Op: name, Bucket: bucket, Key: key, Current: cur}
+		}
+
+		if w_op == opDelete {
+			op.Results = 1
+			return db.deleteRecord(ctx, tx, bkt, bucket, key)
+		}
+
+		if bkt == nil {
+			b, e := tx.CreateBucketIfNotExists([]byte(bucket))
+			if e != nil {
+				return e
+			}
+			bkt = b
+		}
+		if val == nil {
+			val = []byte{}
+		}
+		op.Results = 1
+		return db.putRecord(ctx, tx, bkt, bucket, key, val)
+	})
+	return
+}
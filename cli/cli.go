@@ -0,0 +1,250 @@
+/*
+ * Package cli implements the dumbdb command line tool, which inspects and
+ * edits .dumbDB files.
+ *
+ *	dumbdb scan -key-enc uint64 -limit 10 users.dumbDB Users
+ */
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	dDB "dumbDB"
+)
+
+// Exit codes returned by Run.
+const (
+	EXIT_OK    = 0
+	EXIT_ERROR = 1
+	EXIT_USAGE = 2
+)
+
+// Listen address of serve.
+const DEFAULT_ADDR = "localhost:8080"
+
+var errUsage = errors.New("usage")
+
+type command struct {
+	args  string
+	help  string
+	flags func(fs *flag.FlagSet, o *options)
+	run   func(e *env, args []string) error
+	nargs int
+	// Extra optional arguments allowed after nargs, -1 for any number
+	optional int
+}
+
+/*
+ * options
+ * Flags shared by the subcommands. Each subcommand only registers the
+ * flags it uses.
+ */
+type options struct {
+	key_enc   string
+	value_enc string
+	prefix    string
+	start     string
+	end       string
+	reverse   bool
+	limit     int
+	pretty    bool
+	output    string
+	verbose   bool
+	addr      string
+	grpc_addr string
+}
+
+/*
+ * env
+ * What a subcommand runs with.
+ */
+type env struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+	opts   options
+}
+
+var commands = map[string]*command{
+	"buckets": {args: "<file>", help: "list buckets and their key counts", run: cmdBuckets, nargs: 1},
+	"get": {args: "<file> <bucket> <key>", help: "print the value of a key",
+		flags: keyValueFlags, run: cmdGet, nargs: 3},
+	"put": {args: "<file> <bucket> <key> <value|->", help: "store a value, - reads it from stdin",
+		flags: keyValueFlags, run: cmdPut, nargs: 4},
+	"rm": {args: "<file> <bucket> [key]", help: "remove a key, or the whole bucket if no key is given",
+		flags: keyValueFlags, run: cmdRm, nargs: 2, optional: 1},
+	"scan": {args: "<file> <bucket>", help: "print the records of a bucket as key<TAB>value",
+		flags: scanFlags, run: cmdScan, nargs: 2},
+	"dump": {args: "<file> [bucket...]", help: "write records as JSON lines, all buckets if none are given",
+		flags: outputFlags, run: cmdDump, nargs: 1, optional: -1},
+	"load": {args: "<file> [input]", help: "store records written by dump, reads stdin if no input is given",
+		run: cmdLoad, nargs: 1, optional: 1},
+	"stats": {args: "<file>", help: "print DB statistics as JSON", run: cmdStats, nargs: 1},
+	"compact": {args: "<file>", help: "rewrite the file without free pages, in place unless -o is given",
+		flags: outputFlags, run: cmdCompact, nargs: 1},
+	"check": {args: "<file>", help: "verify the consistency of the file", run: cmdCheck, nargs: 1},
+	"validate": {args: "<file> <bucket>", help: "list the records not matching the JSON schema of the bucket",
+		flags: keyFlags, run: cmdValidate, nargs: 2},
+	"shell": {args: "<file>", help: "interactive shell, reads commands from stdin if it is not a terminal",
+		run: cmdShell, nargs: 1},
+	"serve": {args: "<file>", help: "serve the HTTP/JSON and gRPC APIs until interrupted",
+		flags: serveFlags, run: cmdServe, nargs: 1},
+}
+
+func keyFlags(fs *flag.FlagSet, o *options) {
+	fs.StringVar(&o.key_enc, "key-enc", ENC_UTF8, "key encoding: utf8, hex, base64 or uint64")
+}
+
+func keyValueFlags(fs *flag.FlagSet, o *options) {
+	keyFlags(fs, o)
+	fs.StringVar(&o.value_enc, "value-enc", ENC_AUTO, "value encoding: auto, utf8, hex or base64")
+}
+
+func scanFlags(fs *flag.FlagSet, o *options) {
+	keyValueFlags(fs, o)
+	fs.StringVar(&o.prefix, "prefix", "", "only keys starting with prefix, in -key-enc")
+	fs.StringVar(&o.start, "start", "", "only keys >= start, in -key-enc")
+	fs.StringVar(&o.end, "end", "", "only keys < end, in -key-enc")
+	fs.BoolVar(&o.reverse, "reverse", false, "walk from the largest key down")
+	fs.IntVar(&o.limit, "limit", 0, "max no of records, 0 for no limit")
+	fs.BoolVar(&o.pretty, "pretty", false, "indent JSON values")
+}
+
+func outputFlags(fs *flag.FlagSet, o *options) {
+	fs.StringVar(&o.output, "o", "", "output file")
+}
+
+func serveFlags(fs *flag.FlagSet, o *options) {
+	fs.StringVar(&o.addr, "addr", DEFAULT_ADDR, "address to listen on")
+	fs.StringVar(&o.grpc_addr, "grpc-addr", "", "address to serve gRPC on, disabled if empty")
+}
+
+/*
+ * Run
+ * Run the tool with the given arguments, without the program name.
+ * @returns 	code		process exit code
+ */
+func Run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		usage(stderr)
+		return EXIT_USAGE
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "dumbdb: unknown command %q\n", args[0])
+		usage(stderr)
+		return EXIT_USAGE
+	}
+
+	e := &env{stdin: stdin, stdout: stdout, stderr: stderr}
+	fs := flag.NewFlagSet("dumbdb "+args[0], flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.BoolVar(&e.opts.verbose, "v", false, "log DB operations to stderr")
+	if cmd.flags != nil {
+		cmd.flags(fs, &e.opts)
+	}
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "usage: dumbdb %s [flags] %s\n", args[0], cmd.args)
+		fs.PrintDefaults()
+	}
+
+	pos, err := parseArgs(fs, args[1:])
+	if err != nil {
+		return EXIT_USAGE
+	}
+	if len(pos) < cmd.nargs || (cmd.optional >= 0 && len(pos) > cmd.nargs+cmd.optional) {
+		fs.Usage()
+		return EXIT_USAGE
+	}
+
+	if err = cmd.run(e, pos); err != nil {
+		if err == errUsage {
+			fs.Usage()
+			return EXIT_USAGE
+		}
+		fmt.Fprintf(stderr, "dumbdb %s: %s\n", args[0], err.Error())
+		return EXIT_ERROR
+	}
+	return EXIT_OK
+}
+
+/*
+ * parseArgs
+ * Parse flags which may be mixed with the positional arguments.
+ * @returns 	pos		positional arguments
+ */
+func parseArgs(fs *flag.FlagSet, args []string) (pos []string, err error) {
+	for {
+		if err = fs.Parse(args); err != nil {
+			return nil, err
+		}
+		rest := fs.Args()
+		// Parse stops after "--", everything after it is positional
+		if parsed := len(args) - len(rest); parsed > 0 && args[parsed-1] == "--" {
+			return append(pos, rest...), nil
+		}
+		if len(rest) == 0 {
+			return
+		}
+		pos = append(pos, rest[0])
+		args = rest[1:]
+	}
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: dumbdb <command> [flags] <file> [args]")
+	fmt.Fprintln(w, "\ncommands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-8s %-34s %s\n", name, commands[name].args, commands[name].help)
+	}
+	fmt.Fprintln(w, "\nRun dumbdb <command> -h for the flags of a command.")
+}
+
+/*
+ * openDB
+ * Open a .dumbDB file by path. The file is created if it does not exist.
+ */
+func (e *env) openDB(path string) (*dDB.DumbDB, error) {
+	if !strings.HasSuffix(path, dDB.DEFAULT_SUFFIX) {
+		return nil, fmt.Errorf("%s: file name must end in %s", path, dDB.DEFAULT_SUFFIX)
+	}
+	var log_out io.Writer
+	if e.opts.verbose {
+		log_out = e.stderr
+	}
+	dir, name := filepath.Split(strings.TrimSuffix(path, dDB.DEFAULT_SUFFIX))
+	if dir == "" {
+		dir = "."
+	}
+	db := dDB.NewDumbDB(filepath.Clean(dir), name, log_out)
+	if db == nil {
+		return nil, fmt.Errorf("%s: failed to open database", path)
+	}
+	if e.opts.verbose {
+		db.SetLogLevel(dDB.LevelDebug)
+	}
+	return db, nil
+}
+
+/*
+ * openExisting
+ * openDB which fails instead of creating a missing file.
+ */
+func (e *env) openExisting(path string) (*dDB.DumbDB, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	return e.openDB(path)
+}
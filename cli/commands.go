@@ -0,0 +1,357 @@
+package cli
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	dDB "dumbDB"
+)
+
+/*
+ * dumpRecord
+ * One line of dump output. Keys and values are base64 encoded by
+ * encoding/json, so any bytes survive a dump and load. Path names the
+ * nested bucket of the record, a record without key holds the sequence of
+ * the bucket instead.
+ */
+type dumpRecord struct {
+	Bucket   string   `json:"bucket"`
+	Path     [][]byte `json:"path,omitempty"`
+	Key      []byte   `json:"key,omitempty"`
+	Value    []byte   `json:"value,omitempty"`
+	Sequence uint64   `json:"sequence,omitempty"`
+}
+
+/*
+ * dumpBucket
+ * Write the sequence and records of n, then those of its nested buckets.
+ */
+func dumpBucket(enc *json.Encoder, n *dDB.Nested, bucket string, path [][]byte) error {
+	seq, err := n.Sequence()
+	if err != nil {
+		return err
+	}
+	if seq != 0 {
+		if err = enc.Encode(dumpRecord{Bucket: bucket, Path: path, Sequence: seq}); err != nil {
+			return err
+		}
+	}
+	err = n.Scan(dDB.ScanOptions{}, func(k, v []byte) error {
+		return enc.Encode(dumpRecord{Bucket: bucket, Path: path, Key: k, Value: v})
+	})
+	if err != nil {
+		return err
+	}
+	names, err := n.Buckets()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		sub := append(append([][]byte(nil), path...), name)
+		if err = dumpBucket(enc, n.Nested(name), bucket, sub); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func cmdBuckets(e *env, args []string) error {
+	db, err := e.openExisting(args[0])
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	names, err := db.Buckets()
+	if err != nil {
+		return err
+	}
+	st, err := db.Stats()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		fmt.Fprintf(e.stdout, "%s\t%d\n", name, st.Buckets[name].KeyN)
+	}
+	return nil
+}
+
+func cmdGet(e *env, args []string) error {
+	key, err := decodeBytes(args[2], e.opts.key_enc)
+	if err != nil {
+		return fmt.Errorf("invalid key: %s", err.Error())
+	}
+	db, err := e.openExisting(args[0])
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	val, err := db.Get(key, args[1])
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(e.stdout, formatValue(val, e.opts.value_enc, true))
+	return nil
+}
+
+func cmdPut(e *env, args []string) error {
+	key, err := decodeBytes(args[2], e.opts.key_enc)
+	if err != nil {
+		return fmt.Errorf("invalid key: %s", err.Error())
+	}
+	var val []byte
+	if args[3] == "-" {
+		if val, err = io.ReadAll(e.stdin); err != nil {
+			return err
+		}
+	} else if val, err = decodeBytes(args[3], e.opts.value_enc); err != nil {
+		return fmt.Errorf("invalid value: %s", err.Error())
+	}
+
+	db, err := e.openDB(args[0])
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.Store([][]byte{key, val}, args[1])
+}
+
+func cmdRm(e *env, args []string) error {
+	var key []byte
+	var err error
+	if len(args) == 3 {
+		if key, err = decodeBytes(args[2], e.opts.key_enc); err != nil {
+			return fmt.Errorf("invalid key: %s", err.Error())
+		}
+	}
+	db, err := e.openExisting(args[0])
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if len(args) == 2 {
+		return db.RemoveBucket(args[1])
+	}
+	return db.Remove(key, args[1])
+}
+
+func cmdScan(e *env, args []string) error {
+	opts := dDB.ScanOptions{Reverse: e.opts.reverse, Limit: e.opts.limit}
+	bounds := []struct {
+		arg string
+		dst *[]byte
+	}{{e.opts.prefix, &opts.Prefix}, {e.opts.start, &opts.Start}, {e.opts.end, &opts.End}}
+	for _, b := range bounds {
+		if b.arg == "" {
+			continue
+		}
+		v, err := decodeBytes(b.arg, e.opts.key_enc)
+		if err != nil {
+			return fmt.Errorf("invalid key: %s", err.Error())
+		}
+		*b.dst = v
+	}
+
+	db, err := e.openExisting(args[0])
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	w := bufio.NewWriter(e.stdout)
+	defer w.Flush()
+	return db.Scan(args[1], opts, func(k, v []byte) error {
+		_, err := fmt.Fprintf(w, "%s\t%s\n", encodeKey(k, e.opts.key_enc), formatValue(v, e.opts.value_enc, e.opts.pretty))
+		return err
+	})
+}
+
+func cmdDump(e *env, args []string) error {
+	db, err := e.openExisting(args[0])
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	buckets := args[1:]
+	if len(buckets) == 0 {
+		if buckets, err = db.Buckets(); err != nil {
+			return err
+		}
+	}
+
+	out := e.stdout
+	if e.opts.output != "" {
+		f, err := os.Create(e.opts.output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	w := bufio.NewWriter(out)
+	enc := json.NewEncoder(w)
+	err = db.View(func(tx *dDB.Tx) error {
+		for _, bucket := range buckets {
+			if err := dumpBucket(enc, tx.Nested(bucket), bucket, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+func cmdLoad(e *env, args []string) error {
+	in := e.stdin
+	if len(args) == 2 {
+		f, err := os.Open(args[1])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	db, err := e.openDB(args[0])
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	dec := json.NewDecoder(bufio.NewReader(in))
+	n := 0
+	for {
+		var rec dumpRecord
+		if err = dec.Decode(&rec); err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("record %d: %s", n+1, err.Error())
+		}
+		if rec.Bucket == "" || (len(rec.Key) == 0 && rec.Sequence == 0) {
+			return fmt.Errorf("record %d: bucket and key or sequence are required", n+1)
+		}
+		if rec.Value == nil {
+			rec.Value = []byte{}
+		}
+		switch {
+		case len(rec.Key) == 0:
+			err = db.Update(func(tx *dDB.Tx) error {
+				return tx.Nested(rec.Bucket, rec.Path...).SetSequence(rec.Sequence)
+			})
+		case len(rec.Path) > 0:
+			err = db.Update(func(tx *dDB.Tx) error {
+				return tx.Nested(rec.Bucket, rec.Path...).Put(rec.Key, rec.Value)
+			})
+		default:
+			err = db.Store([][]byte{rec.Key, rec.Value}, rec.Bucket)
+		}
+		if err != nil {
+			return fmt.Errorf("record %d: %s", n+1, err.Error())
+		}
+		n++
+	}
+	fmt.Fprintf(e.stderr, "loaded %d records\n", n)
+	return nil
+}
+
+func cmdStats(e *env, args []string) error {
+	db, err := e.openExisting(args[0])
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	st, err := db.Stats()
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(e.stdout, string(b))
+	return nil
+}
+
+func cmdCompact(e *env, args []string) error {
+	db, err := e.openExisting(args[0])
+	if err != nil {
+		return err
+	}
+	before, _ := os.Stat(args[0])
+
+	dst := e.opts.output
+	if dst == "" {
+		dst = args[0] + ".compact"
+	}
+	if _, err = os.Stat(dst); err == nil {
+		db.Close()
+		return fmt.Errorf("%s already exists", dst)
+	}
+	err = db.CompactTo(dst)
+	db.Close()
+	if err != nil {
+		os.Remove(dst)
+		return err
+	}
+	if e.opts.output == "" {
+		if err = os.Rename(dst, args[0]); err != nil {
+			return err
+		}
+		dst = args[0]
+	}
+	if after, err := os.Stat(dst); err == nil && before != nil {
+		fmt.Fprintf(e.stderr, "%d -> %d bytes\n", before.Size(), after.Size())
+	}
+	return nil
+}
+
+func cmdCheck(e *env, args []string) error {
+	db, err := e.openExisting(args[0])
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err = db.Check(); err != nil {
+		return err
+	}
+	fmt.Fprintln(e.stdout, "ok")
+	return nil
+}
+
+func cmdValidate(e *env, args []string) error {
+	db, err := e.openExisting(args[0])
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	invalid, err := db.ValidateBucket(args[1])
+	if err != nil {
+		return err
+	}
+	for _, v := range invalid {
+		for _, f := range v.Errors {
+			path := f.Path
+			if path == "" {
+				path = "/"
+			}
+			fmt.Fprintf(e.stdout, "%s\t%s\t%s\n", encodeKey(v.Key, e.opts.key_enc), path, f.Message)
+		}
+	}
+	if len(invalid) > 0 {
+		return fmt.Errorf("%d invalid records", len(invalid))
+	}
+	fmt.Fprintln(e.stdout, "ok")
+	return nil
+}
@@ -0,0 +1,107 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"unicode/utf8"
+)
+
+// Key and value encodings accepted by the -key-enc and -value-enc flags.
+const (
+	ENC_UTF8   = "utf8"
+	ENC_HEX    = "hex"
+	ENC_BASE64 = "base64"
+	// Little-endian uint64, as written by UserRecord.GetKey
+	ENC_UINT64 = "uint64"
+	// Values only: pretty JSON, else utf8, else hex
+	ENC_AUTO = "auto"
+)
+
+/*
+ * decodeBytes
+ * Parse a key or value given on the command line.
+ * @param 	s		command line argument
+ * @param 	enc		one of the ENC_ constants
+ */
+func decodeBytes(s string, enc string) ([]byte, error) {
+	switch enc {
+	case ENC_UTF8, ENC_AUTO:
+		return []byte(s), nil
+	case ENC_HEX:
+		return hex.DecodeString(s)
+	case ENC_BASE64:
+		return base64.StdEncoding.DecodeString(s)
+	case ENC_UINT64:
+		n, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		b := make([]byte, 8)
+		binary.LittleEndian.PutUint64(b, n)
+		return b, nil
+	}
+	return nil, fmt.Errorf("unknown encoding %q", enc)
+}
+
+/*
+ * encodeKey
+ * Format a key for output. Keys which are not 8 bytes long can not be
+ * shown as uint64 and are printed as hex.
+ */
+func encodeKey(b []byte, enc string) string {
+	switch enc {
+	case ENC_HEX:
+		return hex.EncodeToString(b)
+	case ENC_BASE64:
+		return base64.StdEncoding.EncodeToString(b)
+	case ENC_UINT64:
+		if len(b) == 8 {
+			return strconv.FormatUint(binary.LittleEndian.Uint64(b), 10)
+		}
+		return hex.EncodeToString(b)
+	case ENC_AUTO:
+		if utf8.Valid(b) && isPrintable(b) {
+			return string(b)
+		}
+		return hex.EncodeToString(b)
+	}
+	return string(b)
+}
+
+/*
+ * formatValue
+ * Format a value for output. With ENC_AUTO JSON values are indented when
+ * pretty is set and compacted otherwise.
+ */
+func formatValue(b []byte, enc string, pretty bool) string {
+	if enc != ENC_AUTO {
+		return encodeKey(b, enc)
+	}
+	if json.Valid(b) {
+		var buf bytes.Buffer
+		var err error
+		if pretty {
+			err = json.Indent(&buf, b, "", "  ")
+		} else {
+			err = json.Compact(&buf, b)
+		}
+		if err == nil {
+			return buf.String()
+		}
+	}
+	return encodeKey(b, ENC_AUTO)
+}
+
+func isPrintable(b []byte) bool {
+	for _, r := range string(b) {
+		if r < 0x20 && r != '\t' && r != '\n' || r == 0x7f {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"dumbDB/rpc"
+	"dumbDB/server"
+
+	"google.golang.org/grpc"
+)
+
+// Time given to open requests to finish after an interrupt.
+const SHUTDOWN_TIMEOUT = 5 * time.Second
+
+func cmdServe(e *env, args []string) error {
+	db, err := e.openDB(args[0])
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	srv := &http.Server{Addr: e.opts.addr, Handler: server.NewHandler(db)}
+	done := make(chan error, 1)
+	go func() { done <- srv.ListenAndServe() }()
+	fmt.Fprintf(e.stderr, "serving %s on http://%s\n", args[0], e.opts.addr)
+
+	if e.opts.grpc_addr != "" {
+		lis, err := net.Listen("tcp", e.opts.grpc_addr)
+		if err != nil {
+			srv.Close()
+			return err
+		}
+		gs := grpc.NewServer()
+		rpc.Register(gs, db)
+		go gs.Serve(lis)
+		defer gs.GracefulStop()
+		fmt.Fprintf(e.stderr, "serving gRPC on %s\n", e.opts.grpc_addr)
+	}
+
+	select {
+	case err = <-done:
+		return err
+	case <-ctx.Done():
+	}
+	sctx, cancel := context.WithTimeout(context.Background(), SHUTDOWN_TIMEOUT)
+	defer cancel()
+	if err = srv.Shutdown(sctx); err != nil {
+		return err
+	}
+	if err = <-done; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,649 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	dDB "dumbDB"
+
+	"github.com/boltdb/bolt"
+	"github.com/peterh/liner"
+)
+
+// Output formats of the shell.
+const (
+	FORMAT_TABLE = "table"
+	FORMAT_JSON  = "json"
+	FORMAT_RAW   = "raw"
+)
+
+// Shell history, kept in the home directory.
+const HISTORY_FILE = ".dumbdb_history"
+
+// Returned by Shell.Exec for exit and quit.
+var ErrQuit = errors.New("quit")
+
+var errNoBucket = errors.New("no bucket selected, run use <bucket> first")
+
+type shellCommand struct {
+	args string
+	help string
+	run  func(s *Shell, args []string, rest string) error
+}
+
+var shellCommands map[string]*shellCommand
+
+func init() {
+	shellCommands = map[string]*shellCommand{
+		"use":      {"<bucket>", "select the bucket used by get, put, del and scan", (*Shell).cmdUse},
+		"buckets":  {"", "list buckets", (*Shell).cmdBuckets},
+		"get":      {"<key>", "print the value of a key", (*Shell).cmdGet},
+		"put":      {"<key> <value>", "store a value, the rest of the line is the value", (*Shell).cmdPut},
+		"del":      {"<key>", "remove a key", (*Shell).cmdDel},
+		"scan":     {"[-reverse] [-limit n] [prefix]", "print the records of the bucket", (*Shell).cmdScan},
+		"begin":    {"", "start a transaction, writes are applied on commit", (*Shell).cmdBegin},
+		"commit":   {"", "apply the writes of the transaction atomically", (*Shell).cmdCommit},
+		"rollback": {"", "discard the writes of the transaction", (*Shell).cmdRollback},
+		"format":   {"[table|json|raw]", "show or set the output format", (*Shell).cmdFormat},
+		"keyenc":   {"[utf8|hex|base64|uint64]", "show or set the key encoding", (*Shell).cmdKeyEnc},
+		"help":     {"", "list commands", (*Shell).cmdHelp},
+		"exit":     {"", "leave the shell", (*Shell).cmdExit},
+		"quit":     {"", "leave the shell", (*Shell).cmdExit},
+	}
+}
+
+/*
+ * pendingWrite
+ * A write buffered between begin and commit, val is nil for deletes.
+ */
+type pendingWrite struct {
+	bucket string
+	key    []byte
+	val    []byte
+}
+
+/*
+ * Shell
+ * Interactive shell over a DumbDB. Lines are run with Exec, the caller
+ * reads them from a terminal or a script.
+ */
+type Shell struct {
+	db      *dDB.DumbDB
+	out     io.Writer
+	bucket  string
+	format  string
+	key_enc string
+	in_tx   bool
+	pending []pendingWrite
+}
+
+/*
+ * NewShell
+ * Shell printing to out, using the table format and utf8 keys.
+ */
+func NewShell(db *dDB.DumbDB, out io.Writer) *Shell {
+	return &Shell{db: db, out: out, format: FORMAT_TABLE, key_enc: ENC_UTF8}
+}
+
+/*
+ * Prompt
+ * Shows the selected bucket, and a * while a transaction is open.
+ */
+func (s *Shell) Prompt() string {
+	tx := ""
+	if s.in_tx {
+		tx = "*"
+	}
+	return "dumbdb:" + s.bucket + tx + "> "
+}
+
+/*
+ * Exec
+ * Run one line. Returns ErrQuit when the shell should exit.
+ */
+func (s *Shell) Exec(line string) error {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return nil
+	}
+	name, rest := splitWord(line)
+	cmd, ok := shellCommands[strings.ToLower(name)]
+	if !ok {
+		return fmt.Errorf("unknown command %q, run help for the list", name)
+	}
+	args, err := splitArgs(rest)
+	if err != nil {
+		return err
+	}
+	return cmd.run(s, args, rest)
+}
+
+/*
+ * Complete
+ * Completions of a partial line: command names, and bucket names after use.
+ */
+func (s *Shell) Complete(line string) []string {
+	ret_val := make([]string, 0)
+	name, rest := splitWord(line)
+	if !strings.ContainsAny(line, " \t") {
+		for c := range shellCommands {
+			if strings.HasPrefix(c, name) {
+				ret_val = append(ret_val, c+" ")
+			}
+		}
+	} else if strings.ToLower(name) == "use" {
+		buckets, _ := s.db.Buckets()
+		for _, b := range buckets {
+			if strings.HasPrefix(b, rest) {
+				ret_val = append(ret_val, name+" "+b)
+			}
+		}
+	}
+	sort.Strings(ret_val)
+	return ret_val
+}
+
+/*
+ * Close
+ * Roll back an open transaction. Returns the no of discarded writes.
+ */
+func (s *Shell) Close() int {
+	n := len(s.pending)
+	s.in_tx, s.pending = false, nil
+	return n
+}
+
+func (s *Shell) cmdUse(args []string, _ string) error {
+	if len(args) != 1 {
+		return errors.New("usage: use <bucket>")
+	}
+	s.bucket = args[0]
+	return nil
+}
+
+func (s *Shell) cmdBuckets(_ []string, _ string) error {
+	buckets, err := s.db.Buckets()
+	if err != nil {
+		return err
+	}
+	for _, b := range buckets {
+		fmt.Fprintln(s.out, b)
+	}
+	return nil
+}
+
+func (s *Shell) cmdGet(args []string, _ string) error {
+	if len(args) != 1 {
+		return errors.New("usage: get <key>")
+	}
+	if s.bucket == "" {
+		return errNoBucket
+	}
+	key, err := decodeBytes(args[0], s.key_enc)
+	if err != nil {
+		return fmt.Errorf("invalid key: %s", err.Error())
+	}
+
+	val, found := s.pendingValue(s.bucket, key)
+	if !found {
+		if val, err = s.db.Get(key, s.bucket); err != nil {
+			if err == bolt.ErrKeyRequired {
+				return errors.New("key not found")
+			}
+			return err
+		}
+	} else if val == nil {
+		return errors.New("key not found")
+	}
+	return s.printRecords([]record{{key, val}}, false)
+}
+
+func (s *Shell) cmdPut(_ []string, rest string) error {
+	if s.bucket == "" {
+		return errNoBucket
+	}
+	k, v, err := nextArg(rest)
+	if err != nil {
+		return err
+	}
+	if k == "" || v == "" {
+		return errors.New("usage: put <key> <value>")
+	}
+	key, err := decodeBytes(k, s.key_enc)
+	if err != nil {
+		return fmt.Errorf("invalid key: %s", err.Error())
+	}
+	// A single quoted string is unquoted, anything else is stored as is.
+	if strings.HasPrefix(v, "\"") {
+		if uq, e := strconv.Unquote(v); e == nil {
+			v = uq
+		}
+	}
+	if s.in_tx {
+		if len(key) > dDB.MAX_KEY_LEN {
+			return bolt.ErrKeyTooLarge
+		}
+		s.pending = append(s.pending, pendingWrite{s.bucket, key, []byte(v)})
+		return nil
+	}
+	return s.db.Store([][]byte{key, []byte(v)}, s.bucket)
+}
+
+func (s *Shell) cmdDel(args []string, _ string) error {
+	if len(args) != 1 {
+		return errors.New("usage: del <key>")
+	}
+	if s.bucket == "" {
+		return errNoBucket
+	}
+	key, err := decodeBytes(args[0], s.key_enc)
+	if err != nil {
+		return fmt.Errorf("invalid key: %s", err.Error())
+	}
+	if s.in_tx {
+		s.pending = append(s.pending, pendingWrite{s.bucket, key, nil})
+		return nil
+	}
+	return s.db.Remove(key, s.bucket)
+}
+
+func (s *Shell) cmdScan(args []string, _ string) error {
+	if s.bucket == "" {
+		return errNoBucket
+	}
+	var opts dDB.ScanOptions
+	fs := flag.NewFlagSet("scan", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.BoolVar(&opts.Reverse, "reverse", false, "")
+	fs.IntVar(&opts.Limit, "limit", 0, "")
+	pos, err := parseArgs(fs, args)
+	if err != nil || len(pos) > 1 {
+		return errors.New("usage: scan [-reverse] [-limit n] [prefix]")
+	}
+	if len(pos) == 1 {
+		if opts.Prefix, err = decodeBytes(pos[0], s.key_enc); err != nil {
+			return fmt.Errorf("invalid key: %s", err.Error())
+		}
+	}
+
+	records, err := s.scan(opts)
+	if err != nil {
+		return err
+	}
+	return s.printRecords(records, true)
+}
+
+/*
+ * scan
+ * Records selected by opts, with the writes of an open transaction applied.
+ */
+func (s *Shell) scan(opts dDB.ScanOptions) ([]record, error) {
+	records := make([]record, 0)
+	if !s.in_tx || len(s.pending) == 0 {
+		err := s.db.Scan(s.bucket, opts, func(k, v []byte) error {
+			records = append(records, record{append([]byte(nil), k...), append([]byte(nil), v...)})
+			return nil
+		})
+		if err == bolt.ErrBucketNotFound {
+			err = nil
+		}
+		return records, err
+	}
+
+	limit, reverse := opts.Limit, opts.Reverse
+	opts.Limit, opts.Reverse = 0, false
+	values := make(map[string][]byte)
+	err := s.db.Scan(s.bucket, opts, func(k, v []byte) error {
+		values[string(k)] = append([]byte(nil), v...)
+		return nil
+	})
+	if err != nil && err != bolt.ErrBucketNotFound {
+		return nil, err
+	}
+	for _, w := range s.pending {
+		if w.bucket != s.bucket {
+			continue
+		}
+		if w.val == nil {
+			delete(values, string(w.key))
+		} else if bytes.HasPrefix(w.key, opts.Prefix) {
+			values[string(w.key)] = w.val
+		}
+	}
+
+	for k, v := range values {
+		records = append(records, record{[]byte(k), v})
+	}
+	sort.Slice(records, func(i, j int) bool {
+		less := bytes.Compare(records[i].key, records[j].key) < 0
+		if reverse {
+			return !less
+		}
+		return less
+	})
+	if limit > 0 && len(records) > limit {
+		records = records[:limit]
+	}
+	return records, nil
+}
+
+/*
+ * pendingValue
+ * Value of a key written in the open transaction. found is false if the
+ * transaction did not write the key, val is nil if it removed it.
+ */
+func (s *Shell) pendingValue(bucket string, key []byte) (val []byte, found bool) {
+	for i := len(s.pending) - 1; i >= 0; i-- {
+		w := s.pending[i]
+		if w.bucket != bucket {
+			continue
+		}
+		if bytes.Equal(w.key, key) {
+			return w.val, true
+		}
+	}
+	return nil, false
+}
+
+func (s *Shell) cmdBegin(_ []string, _ string) error {
+	if s.in_tx {
+		return errors.New("transaction already open")
+	}
+	s.in_tx = true
+	return nil
+}
+
+func (s *Shell) cmdCommit(_ []string, _ string) error {
+	if !s.in_tx {
+		return errors.New("no open transaction")
+	}
+	err := s.db.Update(func(tx *dDB.Tx) error {
+		for _, w := range s.pending {
+			var err error
+			if w.val == nil {
+				err = tx.Remove(w.key, w.bucket)
+			} else {
+				err = tx.Store([][]byte{w.key, w.val}, w.bucket)
+			}
+			// Deleting from a bucket which does not exist is a no-op
+			if err != nil && err != bolt.ErrBucketNotFound {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("commit failed, transaction still open: %s", err.Error())
+	}
+	fmt.Fprintf(s.out, "committed %d writes\n", len(s.pending))
+	s.Close()
+	return nil
+}
+
+func (s *Shell) cmdRollback(_ []string, _ string) error {
+	if !s.in_tx {
+		return errors.New("no open transaction")
+	}
+	fmt.Fprintf(s.out, "discarded %d writes\n", s.Close())
+	return nil
+}
+
+func (s *Shell) cmdFormat(args []string, _ string) error {
+	if len(args) == 0 {
+		fmt.Fprintln(s.out, s.format)
+		return nil
+	}
+	switch args[0] {
+	case FORMAT_TABLE, FORMAT_JSON, FORMAT_RAW:
+		s.format = args[0]
+		return nil
+	}
+	return errors.New("usage: format [table|json|raw]")
+}
+
+func (s *Shell) cmdKeyEnc(args []string, _ string) error {
+	if len(args) == 0 {
+		fmt.Fprintln(s.out, s.key_enc)
+		return nil
+	}
+	switch args[0] {
+	case ENC_UTF8, ENC_HEX, ENC_BASE64, ENC_UINT64:
+		s.key_enc = args[0]
+		return nil
+	}
+	return errors.New("usage: keyenc [utf8|hex|base64|uint64]")
+}
+
+func (s *Shell) cmdHelp(_ []string, _ string) error {
+	names := make([]string, 0, len(shellCommands))
+	for name := range shellCommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	w := tabwriter.NewWriter(s.out, 0, 4, 2, ' ', 0)
+	for _, name := range names {
+		fmt.Fprintf(w, "%s %s\t%s\n", name, shellCommands[name].args, shellCommands[name].help)
+	}
+	return w.Flush()
+}
+
+func (s *Shell) cmdExit(_ []string, _ string) error {
+	return ErrQuit
+}
+
+type record struct {
+	key []byte
+	val []byte
+}
+
+/*
+ * printRecords
+ * Print records in the current format. table is a KEY/VALUE table, json a
+ * JSON array of {"key", "value"} objects (a single object for get) and raw
+ * the value bytes as stored.
+ */
+func (s *Shell) printRecords(records []record, list bool) error {
+	switch s.format {
+	case FORMAT_RAW:
+		for _, r := range records {
+			if list {
+				fmt.Fprintf(s.out, "%s\t", encodeKey(r.key, s.key_enc))
+			}
+			s.out.Write(r.val)
+			fmt.Fprintln(s.out)
+		}
+		return nil
+	case FORMAT_JSON:
+		type jsonRecord struct {
+			Key   string      `json:"key"`
+			Value interface{} `json:"value"`
+		}
+		objs := make([]jsonRecord, 0, len(records))
+		for _, r := range records {
+			var v interface{} = json.RawMessage(r.val)
+			if !json.Valid(r.val) {
+				v = encodeKey(r.val, ENC_AUTO)
+			}
+			objs = append(objs, jsonRecord{encodeKey(r.key, s.key_enc), v})
+		}
+		var b []byte
+		var err error
+		if list {
+			b, err = json.MarshalIndent(objs, "", "  ")
+		} else {
+			b, err = json.MarshalIndent(objs[0], "", "  ")
+		}
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(s.out, string(b))
+		return err
+	}
+
+	w := tabwriter.NewWriter(s.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tVALUE")
+	for _, r := range records {
+		fmt.Fprintf(w, "%s\t%s\n", encodeKey(r.key, s.key_enc), formatValue(r.val, ENC_AUTO, false))
+	}
+	if list {
+		fmt.Fprintf(w, "(%d records)\n", len(records))
+	}
+	return w.Flush()
+}
+
+/*
+ * splitWord
+ * Split the first word off a line.
+ */
+func splitWord(line string) (word, rest string) {
+	line = strings.TrimLeft(line, " \t")
+	i := strings.IndexAny(line, " \t")
+	if i < 0 {
+		return line, ""
+	}
+	return line[:i], strings.TrimLeft(line[i:], " \t")
+}
+
+/*
+ * nextArg
+ * Split the first word off a line. The word can be a double quoted Go string.
+ */
+func nextArg(line string) (arg, rest string, err error) {
+	line = strings.TrimLeft(line, " \t")
+	if !strings.HasPrefix(line, "\"") {
+		arg, rest = splitWord(line)
+		return
+	}
+	end := 1
+	for end < len(line) && line[end] != '"' {
+		if line[end] == '\\' {
+			end++
+		}
+		end++
+	}
+	if end >= len(line) {
+		return "", "", errors.New("unterminated quoted string")
+	}
+	if arg, err = strconv.Unquote(line[:end+1]); err != nil {
+		return "", "", err
+	}
+	return arg, strings.TrimLeft(line[end+1:], " \t"), nil
+}
+
+/*
+ * splitArgs
+ * Split a line into words, see nextArg.
+ */
+func splitArgs(line string) ([]string, error) {
+	args := make([]string, 0)
+	for line = strings.TrimSpace(line); line != ""; {
+		arg, rest, err := nextArg(line)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		line = rest
+	}
+	return args, nil
+}
+
+func cmdShell(e *env, args []string) error {
+	db, err := e.openDB(args[0])
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	sh := NewShell(db, e.stdout)
+	if f, ok := e.stdin.(*os.File); ok && isTerminal(f) {
+		return runInteractive(sh, e)
+	}
+
+	// Script mode: no prompt, errors are reported with their line no.
+	failed := false
+	scanner := bufio.NewScanner(e.stdin)
+	for n := 1; scanner.Scan(); n++ {
+		err = sh.Exec(scanner.Text())
+		if err == ErrQuit {
+			break
+		}
+		if err != nil {
+			fmt.Fprintf(e.stderr, "line %d: %s\n", n, err.Error())
+			failed = true
+		}
+	}
+	if n := sh.Close(); n > 0 {
+		fmt.Fprintf(e.stderr, "discarded %d writes of uncommitted transaction\n", n)
+	}
+	if err = scanner.Err(); err == nil && failed {
+		err = errors.New("some commands failed")
+	}
+	return err
+}
+
+/*
+ * runInteractive
+ * Read lines from the terminal with history and tab completion.
+ */
+func runInteractive(sh *Shell, e *env) error {
+	line := liner.NewLiner()
+	defer line.Close()
+	line.SetCtrlCAborts(true)
+	line.SetCompleter(sh.Complete)
+
+	history := ""
+	if home, err := os.UserHomeDir(); err == nil {
+		history = filepath.Join(home, HISTORY_FILE)
+		if f, err := os.Open(history); err == nil {
+			line.ReadHistory(f)
+			f.Close()
+		}
+	}
+
+	fmt.Fprintln(e.stdout, "Type help for the list of commands.")
+	for {
+		l, err := line.Prompt(sh.Prompt())
+		if err == liner.ErrPromptAborted {
+			continue
+		}
+		if err != nil {
+			fmt.Fprintln(e.stdout)
+			break
+		}
+		if strings.TrimSpace(l) != "" {
+			line.AppendHistory(l)
+		}
+		if err = sh.Exec(l); err == ErrQuit {
+			break
+		} else if err != nil {
+			fmt.Fprintf(e.stderr, "error: %s\n", err.Error())
+		}
+	}
+	if n := sh.Close(); n > 0 {
+		fmt.Fprintf(e.stderr, "discarded %d writes of uncommitted transaction\n", n)
+	}
+
+	if history != "" {
+		if f, err := os.Create(history); err == nil {
+			line.WriteHistory(f)
+			f.Close()
+		}
+	}
+	return nil
+}
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
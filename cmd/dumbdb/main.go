@@ -0,0 +1,15 @@
+/*
+ * dumbdb inspects and edits .dumbDB files. Run it without arguments for
+ * the list of commands.
+ */
+package main
+
+import (
+	"os"
+
+	"dumbDB/cli"
+)
+
+func main() {
+	os.Exit(cli.Run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
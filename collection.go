@@ -0,0 +1,98 @@
+package dumbDatabase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+)
+
+// Returned by the function passed to Collection.Modify to delete the key.
+var ErrDelete = errors.New("delete key")
+
+/*
+ * Collection
+ * Typed view of a bucket. Values are stored as JSON.
+ */
+type Collection[T any] struct {
+	db     *DumbDB
+	bucket string
+}
+
+/*
+ * NewCollection
+ * Collection of T values stored in bucket.
+ * @param 	db		DB holding the bucket
+ * @param 	bucket		name of bucket
+ */
+func NewCollection[T any](db *DumbDB, bucket string) *Collection[T] {
+	return &Collection[T]{db: db, bucket: bucket}
+}
+
+/*
+ * Get
+ * Decoded value of key. Fails like DumbDB.Get if it does not exist.
+ */
+func (c *Collection[T]) Get(key []byte) (*T, error) {
+	b, err := c.db.Get(key, c.bucket)
+	if err != nil {
+		return nil, err
+	}
+	v := new(T)
+	if err = json.Unmarshal(b, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+/*
+ * Put
+ * Store v under key.
+ */
+func (c *Collection[T]) Put(key []byte, v *T) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.db.Store([][]byte{key, b}, c.bucket)
+}
+
+/*
+ * Delete
+ * Remove key.
+ */
+func (c *Collection[T]) Delete(key []byte) error {
+	return c.db.Remove(key, c.bucket)
+}
+
+/*
+ * Modify
+ * Update the value of key in place, see DumbDB.Modify. fn gets the decoded
+ * value, the zero T if the key does not exist, and changes it. Returning
+ * ErrDelete removes the key, any other error aborts without writing.
+ * @param 	key		byte slice containing key
+ * @param 	fn		changes the value
+ */
+func (c *Collection[T]) Modify(key []byte, fn func(v *T) error) error {
+	return c.ModifyCtx(context.Background(), key, fn)
+}
+
+/*
+ * ModifyCtx
+ * Modify with a context passed to the operation hooks.
+ */
+func (c *Collection[T]) ModifyCtx(ctx context.Context, key []byte, fn func(v *T) error) error {
+	return c.db.ModifyCtx(ctx, c.bucket, key, func(old []byte) ([]byte, error) {
+		v := new(T)
+		if old != nil {
+			if err := json.Unmarshal(old, v); err != nil {
+				return nil, err
+			}
+		}
+		if err := fn(v); err == ErrDelete {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+		return json.Marshal(v)
+	})
+}
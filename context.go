@@ -0,0 +1,77 @@
+package dumbDatabase
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"dumbDB/backend"
+	"dumbDB/backend/boltdb"
+)
+
+// How often a blocked open retries to lock the DB file.
+const LOCK_POLL_INTERVAL = boltdb.LOCK_POLL_INTERVAL
+
+/*
+ * NewDumbDBCtx
+ * NewDumbDB which gives up waiting for the file lock held by another
+ * process when ctx is done. NewDumbDB waits indefinitely.
+ * @param 	ctx		cancels waiting for the file lock
+ * @param 	root_path	directory containing the DB file
+ * @param 	name		name of the DB
+ * @param 	logger_out	log output
+ * @param 	opts		options, see WithBackend
+ */
+func NewDumbDBCtx(ctx context.Context, root_path string, name string, logger_out io.Writer, opts ...Option) *DumbDB {
+	dumbDB := new(DumbDB)
+	dumbDB.initLogger(logger_out)
+	dumbDB.write_lock = make(chan struct{}, 1)
+	dumbDB.opener = newConfig(opts).opener
+
+	if err := dumbDB.open(ctx, root_path, name); err != nil {
+		return nil
+	}
+	return dumbDB
+}
+
+/*
+ * view
+ * Run fn in a read transaction, recording its duration.
+ */
+func (db *DumbDB) view(ctx context.Context, fn func(backend.Tx) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	start := time.Now()
+	err := db.dbP.View(fn)
+	db.counters.recordTx(false, time.Since(start))
+	return err
+}
+
+/*
+ * update
+ * Run fn in a write transaction, recording its duration. Waiting for
+ * other writers is abandoned when ctx is done.
+ */
+func (db *DumbDB) update(ctx context.Context, fn func(backend.Tx) error) error {
+	select {
+	case db.write_lock <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-db.write_lock }()
+
+	start := time.Now()
+	err := db.dbP.Update(func(tx backend.Tx) error {
+		if e := ctx.Err(); e != nil {
+			return e
+		}
+		return fn(tx)
+	})
+	db.counters.recordTx(true, time.Since(start))
+	return err
+}
+
+func isContextErr(err error) bool {
+	return err == context.Canceled || err == context.DeadlineExceeded
+}
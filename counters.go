@@ -0,0 +1,246 @@
+package dumbDatabase
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"math"
+
+	"dumbDB/backend"
+
+	"github.com/boltdb/bolt"
+)
+
+// Returned when an increment would overflow an int64 counter.
+var ErrCounterOverflow = errors.New("counter overflow")
+
+/*
+ * Increment
+ * One change of IncrBatch.
+ */
+type Increment struct {
+	Bucket string
+	Key    []byte
+	Delta  int64
+}
+
+/*
+ * Incr
+ * Add delta to the counter stored at key and return the new value. A
+ * missing counter starts at 0. Counters are stored as 8 byte big-endian
+ * int64, other values fail with bolt.ErrIncompatibleValue.
+ * @param 	bucket		name of bucket, created if needed
+ * @param 	key		byte slice containing key
+ * @param 	delta		value to add, may be negative
+ */
+func (db *DumbDB) Incr(bucket string, key []byte, delta int64) (int64, error) {
+	return db.IncrCtx(context.Background(), bucket, key, delta)
+}
+
+/*
+ * IncrCtx
+ * Incr with a context passed to the operation hooks. Returns ctx.Err()
+ * if ctx is done before the operation completes.
+ */
+func (db *DumbDB) IncrCtx(ctx context.Context, bucket string, key []byte, delta int64) (int64, error) {
+	vals, err := db.incrBatch(ctx, "Incr", []Increment{{Bucket: bucket, Key: key, Delta: delta}})
+	if err != nil {
+		return 0, err
+	}
+	return vals[0], nil
+}
+
+/*
+ * Decr
+ * Incr with -delta.
+ */
+func (db *DumbDB) Decr(bucket string, key []byte, delta int64) (int64, error) {
+	if delta == math.MinInt64 {
+		return 0, ErrCounterOverflow
+	}
+	return db.IncrCtx(context.Background(), bucket, key, -delta)
+}
+
+/*
+ * IncrBatch
+ * Apply all increments in one transaction, either all or none. Returns
+ * the new value of each counter, in order. A key may appear more than once.
+ * @param 	incrs		counters and deltas
+ */
+func (db *DumbDB) IncrBatch(incrs []Increment) ([]int64, error) {
+	return db.IncrBatchCtx(context.Background(), incrs)
+}
+
+/*
+ * IncrBatchCtx
+ * IncrBatch with a context passed to the operation hooks. Returns ctx.Err()
+ * if ctx is done before the operation completes.
+ */
+func (db *DumbDB) IncrBatchCtx(ctx context.Context, incrs []Increment) ([]int64, error) {
+	return db.incrBatch(ctx, "IncrBatch", incrs)
+}
+
+func (db *DumbDB) incrBatch(ctx context.Context, name string, incrs []Increment) (vals []int64, err error) {
+	bucket, key_len := "", 0
+	for _, i := range incrs {
+		key_len += len(i.Key)
+	}
+	if len(incrs) > 0 {
+		bucket = incrs[0].Bucket
+	}
+	ctx, op := db.startOp(ctx, name, bucket, key_len)
+	defer func() { db.finishOp(ctx, op, err) }()
+
+	if db.read_only {
+		return nil, bolt.ErrDatabaseReadOnly
+	}
+	for _, i := range incrs {
+		if err = checkUserBucket(i.Bucket); err != nil {
+			return nil, err
+		}
+	}
+	vals = make([]int64, len(incrs))
+	err = db.update(ctx, func(tx backend.Tx) error {
+		for n, i := range incrs {
+			e := db.updateCounter(ctx, tx, i.Bucket, i.Key, func(old []byte) ([]byte, error) {
+				cur := int64(0)
+				if old != nil {
+					if len(old) != 8 {
+						return nil, bolt.ErrIncompatibleValue
+					}
+					cur = int64(binary.BigEndian.Uint64(old))
+				}
+				if (i.Delta > 0 && cur > math.MaxInt64-i.Delta) || (i.Delta < 0 && cur < math.MinInt64-i.Delta) {
+					return nil, ErrCounterOverflow
+				}
+				vals[n] = cur + i.Delta
+				return binary.BigEndian.AppendUint64(nil, uint64(vals[n])), nil
+			})
+			if e != nil {
+				return e
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	op.Results = len(vals)
+	return
+}
+
+/*
+ * IncrFloat
+ * Add delta to the float counter stored at key and return the new value.
+ * A missing counter starts at 0. Float counters are stored as 8 byte
+ * big-endian IEEE 754 bits.
+ * @param 	bucket		name of bucket, created if needed
+ * @param 	key		byte slice containing key
+ * @param 	delta		value to add, may be negative
+ */
+func (db *DumbDB) IncrFloat(bucket string, key []byte, delta float64) (float64, error) {
+	return db.IncrFloatCtx(context.Background(), bucket, key, delta)
+}
+
+/*
+ * IncrFloatCtx
+ * IncrFloat with a context passed to the operation hooks. Returns ctx.Err()
+ * if ctx is done before the operation completes.
+ */
+func (db *DumbDB) IncrFloatCtx(ctx context.Context, bucket string, key []byte, delta float64) (val float64, err error) {
+	ctx, op := db.startOp(ctx, "IncrFloat", bucket, len(key))
+	defer func() { db.finishOp(ctx, op, err) }()
+
+	if db.read_only {
+		return 0, bolt.ErrDatabaseReadOnly
+	}
+	if err = checkUserBucket(bucket); err != nil {
+		return 0, err
+	}
+	err = db.update(ctx, func(tx backend.Tx) error {
+		return db.updateCounter(ctx, tx, bucket, key, func(old []byte) ([]byte, error) {
+			val = 0
+			if old != nil {
+				if len(old) != 8 {
+					return nil, bolt.ErrIncompatibleValue
+				}
+				val = math.Float64frombits(binary.BigEndian.Uint64(old))
+			}
+			val += delta
+			return binary.BigEndian.AppendUint64(nil, math.Float64bits(val)), nil
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+	op.Results = 1
+	return
+}
+
+/*
+ * DecrFloat
+ * IncrFloat with -delta.
+ */
+func (db *DumbDB) DecrFloat(bucket string, key []byte, delta float64) (float64, error) {
+	return db.IncrFloatCtx(context.Background(), bucket, key, -delta)
+}
+
+/*
+ * GetCounter
+ * Value of the counter stored at key by Incr, 0 if it does not exist.
+ * @param 	bucket		name of bucket
+ * @param 	key		byte slice containing key
+ */
+func (db *DumbDB) GetCounter(bucket string, key []byte) (int64, error) {
+	v, err := db.Get(key, bucket)
+	if err == bolt.ErrKeyRequired || err == bolt.ErrBucketNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if len(v) != 8 {
+		return 0, bolt.ErrIncompatibleValue
+	}
+	return int64(binary.BigEndian.Uint64(v)), nil
+}
+
+/*
+ * GetFloatCounter
+ * Value of the counter stored at key by IncrFloat, 0 if it does not exist.
+ * @param 	bucket		name of bucket
+ * @param 	key		byte slice containing key
+ */
+func (db *DumbDB) GetFloatCounter(bucket string, key []byte) (float64, error) {
+	v, err := db.Get(key, bucket)
+	if err == bolt.ErrKeyRequired || err == bolt.ErrBucketNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if len(v) != 8 {
+		return 0, bolt.ErrIncompatibleValue
+	}
+	return math.Float64frombits(binary.BigEndian.Uint64(v)), nil
+}
+
+/*
+ * updateCounter
+ * Replace the value of key with the one computed by fn from the old one,
+ * nil if it does not exist, inside the writing transaction.
+ */
+func (db *DumbDB) updateCounter(ctx context.Context, tx backend.Tx, bucket string, key []byte, fn func(old []byte) ([]byte, error)) error {
+	if len(key) > MAX_KEY_LEN {
+		return bolt.ErrKeyTooLarge
+	}
+	bkt, err := tx.CreateBucketIfNotExists([]byte(bucket))
+	if err != nil {
+		return err
+	}
+	val, err := fn(bkt.Get(key))
+	if err != nil {
+		return err
+	}
+	return db.putRecord(ctx, tx, bkt, bucket, key, val)
+}
@@ -1,11 +1,14 @@
 package dumbDatabase
 
 import (
+	"context"
 	"github.com/boltdb/bolt"
+	"dumbDB/backend"
 	"io"
-	"log"
 	"os"
+	"time"
 	"encoding/json"
+	"encoding/hex"
 )
 
 const MAX_KEY_LEN = 1024 //bytes
@@ -13,45 +16,91 @@ const DEFAULT_SUFFIX = ".dumbDB"
 
 type DumbDB struct {
 	DbFullName string
-	// Connection to boltDB (more like file descriptor)
-	dbP *bolt.DB
+	// Storage backend, boltDB unless WithBackend was given
+	dbP backend.Backend
+	opener backend.Opener
 	// Logger
-	err_log *log.Logger
-	info_log *log.Logger
+	logger Logger
+	log_level LogLevel
+	// Followers only accept writes from the replication stream
+	read_only bool
+	// Replication log and follower position
+	repl replicationState
+	// Operation counters reported by Stats
+	counters opCounters
+	// Called around every operation
+	op_hooks []OpHook
+	// Held by the writer, lets waiting writers give up
+	write_lock chan struct{}
+	// Callbacks for committed changes
+	watch watchState
+	// Key generators of StoreAuto by bucket
+	ids idState
+	// Store and Remove triggers by bucket
+	triggers triggerState
+	// Compiled JSON schemas by bucket
+	schemas schemaCache
 }
 
 func (db *DumbDB) initLogger(logger_op io.Writer)  {
-	db.err_log = log.New(logger_op, "DumbDB\tERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
-	db.info_log = log.New(logger_op, "DumbDB\tINFO\t", log.Ldate|log.Ltime|log.Lshortfile)
+	db.log_level = DEFAULT_LOG_LEVEL
+	if logger_op == nil {
+		db.logger = NopLogger()
+		return
+	}
+	db.logger = NewTextLogger(logger_op)
 }
 
-func NewDumbDB(root_path string, name string, logger_out io.Writer) *DumbDB {
+func NewDumbDB(root_path string, name string, logger_out io.Writer, opts ...Option) *DumbDB {
+	return NewDumbDBCtx(context.Background(), root_path, name, logger_out, opts...)
+}
 
-	dumbDB := new(DumbDB)
-	dumbDB.initLogger(logger_out)
+func (dumbDB *DumbDB) open(ctx context.Context, root_path string, name string) error {
 
 	if _, e := os.Stat(root_path); e != nil && os.IsNotExist(e) {
-		dumbDB.err_log.Printf("Root path invalid %s", root_path)
-		return nil
+		dumbDB.logError("Root path invalid", "op", "Open", "path", root_path)
+		return e
 	}
+	start := time.Now()
 	dumbDB.DbFullName = root_path + "/" + name + DEFAULT_SUFFIX
-	db, err := bolt.Open(dumbDB.DbFullName, 0600, nil)
+	db, err := dumbDB.opener(ctx, dumbDB.DbFullName)
 	if err != nil {
-		dumbDB.err_log.Printf("Failed to open new database path %s", dumbDB.DbFullName)
-		return nil
+		dumbDB.logOp("Open", "", start, err, "path", dumbDB.DbFullName)
+		return err
 	}
 	dumbDB.dbP = db
-	dumbDB.info_log.Printf("Created new DB %s", dumbDB.dbP.Path())
+	dumbDB.logInfo("Opened DB", "op", "Open", "path", dumbDB.DbFullName, "duration", time.Since(start))
+
+	return nil
+}
+
+/*
+ * Close
+ * Stop all watches and close the DB file.
+ */
+func (db *DumbDB) Close() error {
+	db.watch.closeAll()
+	return db.dbP.Close()
+}
 
-	return dumbDB
+/*
+ * recordWrite
+ * Called inside the writing transaction for every write, to replicate it
+ * and notify watchers and AfterCommit triggers once it commits.
+ */
+func (db *DumbDB) recordWrite(tx backend.Tx, op byte, bucket, key, val []byte) error {
+	db.notifyWatchers(tx, op, bucket, key, val)
+	db.commitTriggers(tx, op, bucket, key, val)
+	return db.logWrite(tx, op, bucket, key, val)
 }
 
 /*
  * This is a no-op. We use this in the testing package.
+ * Deprecated: use Stats.
  */
 func (db * DumbDB) PrintStats() {
 	if os.Getenv("test") == "1" {
-		st := db.dbP.Stats()
+		st, _ := db.Stats()
 		json.NewEncoder(os.Stdout).Encode(st)
 	}
 }
@@ -62,17 +111,60 @@ func (db * DumbDB) PrintStats() {
  * @param 	bucket		name of bucket
  */
 func (db *DumbDB) RemoveBucket(bucket string) (err error) {
-	err = db.dbP.Update(func(tx *bolt.Tx) error {
-		e := tx.DeleteBucket([]byte(bucket))
-		if e != nil {
-			db.err_log.Printf("Error removing Bucket %s.", bucket)
-			return e
-		}
-		return nil
+	return db.RemoveBucketCtx(context.Background(), bucket)
+}
+
+/*
+ * RemoveBucketCtx
+ * RemoveBucket with a context passed to the operation hooks. Returns ctx.Err()
+ * if ctx is done before the operation completes.
+ */
+func (db *DumbDB) RemoveBucketCtx(ctx context.Context, bucket string) (err error) {
+	ctx, op := db.startOp(ctx, "RemoveBucket", bucket, 0)
+	defer func() { db.finishOp(ctx, op, err) }()
+
+	if db.read_only {
+		return bolt.ErrDatabaseReadOnly
+	}
+	if err = checkUserBucket(bucket); err != nil {
+		return err
+	}
+	err = db.update(ctx, func(tx backend.Tx) error {
+		return db.deleteBucket(tx, bucket)
 	})
 	return
 }
 
+/*
+ * Buckets
+ * Names of the buckets in the DB, in byte order.
+ * @returns 	names		bucket names
+ */
+func (db *DumbDB) Buckets() (names []string, err error) {
+	return db.BucketsCtx(context.Background())
+}
+
+/*
+ * BucketsCtx
+ * Buckets with a context passed to the operation hooks. Returns ctx.Err()
+ * if ctx is done before the operation completes.
+ */
+func (db *DumbDB) BucketsCtx(ctx context.Context) (names []string, err error) {
+	ctx, op := db.startOp(ctx, "Buckets", "", 0)
+	names = make([]string, 0)
+	err = db.view(ctx, func(tx backend.Tx) error {
+		return tx.ForEach(func(name []byte, _ backend.Bucket) error {
+			if !isHiddenBucket(name) {
+				names = append(names, string(name))
+			}
+			return nil
+		})
+	})
+	op.Results = len(names)
+	db.finishOp(ctx, op, err)
+	return
+}
+
 /*
  * Get
  * Pointed Get query. Using key.
@@ -81,22 +173,32 @@ func (db *DumbDB) RemoveBucket(bucket string) (err error) {
  * @returns 	ret_val		return value as byte slice
  */
 func (db *DumbDB) Get(key []byte, bucket string) (ret_val []byte, err error) {
+	return db.GetCtx(context.Background(), key, bucket)
+}
 
-	err = db.dbP.View(func(tx *bolt.Tx) error {
+/*
+ * GetCtx
+ * Get with a context passed to the operation hooks. Returns ctx.Err()
+ * if ctx is done before the operation completes.
+ */
+func (db *DumbDB) GetCtx(ctx context.Context, key []byte, bucket string) (ret_val []byte, err error) {
+
+	ctx, op := db.startOp(ctx, "Get", bucket, len(key))
+	err = db.view(ctx, func(tx backend.Tx) error {
 		bkt := tx.Bucket([]byte(bucket))
 		if bkt == nil {
-			db.err_log.Println("Bucket not created yet.")
 			return bolt.ErrBucketNotFound
 		}
 
 		ret_val = bkt.Get(key)
 		if ret_val != nil {
-			db.info_log.Println("Found key.")
+			op.Results = 1
 			return nil
 		}
 
 		return bolt.ErrKeyRequired
 	})
+	db.finishOp(ctx, op, err)
 	return
 }
 
@@ -108,20 +210,38 @@ func (db *DumbDB) Get(key []byte, bucket string) (ret_val []byte, err error) {
  * @returns 	values		return values as slices of byte slice
  */
 func (db *DumbDB) GetMultiple(keys [][]byte, bucket string) (values [][]byte, err error) {
+	return db.GetMultipleCtx(context.Background(), keys, bucket)
+}
+
+/*
+ * GetMultipleCtx
+ * GetMultiple with a context passed to the operation hooks. Returns ctx.Err()
+ * if ctx is done before the operation completes.
+ */
+func (db *DumbDB) GetMultipleCtx(ctx context.Context, keys [][]byte, bucket string) (values [][]byte, err error) {
 
-	err = db.dbP.View(func(tx *bolt.Tx) error {
+	key_len := 0
+	for _, key := range keys {
+		key_len += len(key)
+	}
+	ctx, op := db.startOp(ctx, "GetMultiple", bucket, key_len)
+	var missing []byte
+	err = db.view(ctx, func(tx backend.Tx) error {
 		bkt := tx.Bucket([]byte(bucket))
 		if bkt == nil {
-			db.err_log.Println("Bucket not created yet.")
 			return bolt.ErrBucketNotFound
 		}
 
 		for _, key := range keys {
+			if e := ctx.Err(); e != nil {
+				values = nil
+				return e
+			}
 			value := bkt.Get(key)
 			if value != nil {
 				values = append(values, value)
 			} else {
-				db.err_log.Println("Could not find value for key:%s", key)
+				missing = key
 				values = nil
 				return bolt.ErrInvalid
 			}
@@ -129,6 +249,8 @@ func (db *DumbDB) GetMultiple(keys [][]byte, bucket string) (values [][]byte, er
 		// Will return empty array if no error occurred
 		return nil
 	})
+	op.Results = len(values)
+	db.finishOp(ctx, op, err, "keys", len(keys), "missing_key", hex.EncodeToString(missing))
 	return
 }
 
@@ -139,23 +261,43 @@ func (db *DumbDB) GetMultiple(keys [][]byte, bucket string) (values [][]byte, er
  * @returns 	ret_val[]	returns slice of records. Each record is a byte slice.
  */
 func (db *DumbDB) GetAll(bucket string) (ret_val [][]byte, err error) {
+	return db.GetAllCtx(context.Background(), bucket)
+}
 
+/*
+ * GetAllCtx
+ * GetAll with a context passed to the operation hooks. Returns ctx.Err()
+ * if ctx is done before the operation completes.
+ */
+func (db *DumbDB) GetAllCtx(ctx context.Context, bucket string) (ret_val [][]byte, err error) {
+
+	ctx, op := db.startOp(ctx, "GetAll", bucket, 0)
 	ret_val = make([][]byte, 0)
-	err = db.dbP.View(func(tx *bolt.Tx) error {
+	err = db.view(ctx, func(tx backend.Tx) error {
 		bkt := tx.Bucket([]byte(bucket))
 		if bkt == nil {
-			db.err_log.Println("Bucket not created yet.")
 			return bolt.ErrBucketNotFound
 		}
 
 		c := bkt.Cursor()
 
 		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			if e := ctx.Err(); e != nil {
+				return e
+			}
+			// Nested bucket
+			if v == nil {
+				continue
+			}
 			ret_val = append(ret_val, v)
-			db.info_log.Println("Added value")
 		}
 		return nil
 	})
+	if isContextErr(err) {
+		ret_val = nil
+	}
+	op.Results = len(ret_val)
+	db.finishOp(ctx, op, err)
 	return
 }
 
@@ -170,12 +312,21 @@ func (db *DumbDB) GetAll(bucket string) (ret_val [][]byte, err error) {
  * @returns 		ret_val[]	returns slice of records. Each record is a byte slice.
  */
 func (db *DumbDB) GetLimited(bucket string, size int, cookie []byte) (ret_val [][]byte, err error) {
+	return db.GetLimitedCtx(context.Background(), bucket, size, cookie)
+}
+
+/*
+ * GetLimitedCtx
+ * GetLimited with a context passed to the operation hooks. Returns ctx.Err()
+ * if ctx is done before the operation completes.
+ */
+func (db *DumbDB) GetLimitedCtx(ctx context.Context, bucket string, size int, cookie []byte) (ret_val [][]byte, err error) {
+	ctx, op := db.startOp(ctx, "GetLimited", bucket, len(cookie))
 	ret_val = make([][]byte, size)
 	itr := 0
-	err = db.dbP.View(func(tx *bolt.Tx) error {
+	err = db.view(ctx, func(tx backend.Tx) error {
 		bkt := tx.Bucket([]byte(bucket))
 		if bkt == nil {
-			db.err_log.Println("Bucket not created yet.")
 			return bolt.ErrBucketNotFound
 		}
 
@@ -187,7 +338,7 @@ func (db *DumbDB) GetLimited(bucket string, size int, cookie []byte) (ret_val []
 			// previous search. Initialize the first to the previous val.
 			_k, _ := c.Seek(cookie)
 			if _k == nil {
-				db.err_log.Println("Got invalid cookie.")
+				db.logWarn("Got invalid cookie.", "op", "GetLimited", "bucket", bucket, "duration", time.Since(op.Start))
 				return bolt.ErrKeyRequired
 			}
 			init_kv[0], init_kv[1] = c.Prev()
@@ -196,12 +347,22 @@ func (db *DumbDB) GetLimited(bucket string, size int, cookie []byte) (ret_val []
 		}
 
 		for k, v := init_kv[0], init_kv[1]; k != nil && itr < size; k, v = c.Prev() {
+			if e := ctx.Err(); e != nil {
+				return e
+			}
+			if v == nil {
+				continue
+			}
 			ret_val[itr] = v
-			db.info_log.Println("Added value")
 			itr++
 		}
 		return nil
 	})
+	if isContextErr(err) {
+		ret_val = nil
+	}
+	op.Results = itr
+	db.finishOp(ctx, op, err)
 	return
 }
 
@@ -213,7 +374,25 @@ func (db *DumbDB) GetLimited(bucket string, size int, cookie []byte) (ret_val []
  * @returns 		error
  */
 func (db *DumbDB) Store(record [][]byte, bucket string) error {
-	return db.dbP.Update(func(tx *bolt.Tx) error {
+	return db.StoreCtx(context.Background(), record, bucket)
+}
+
+/*
+ * StoreCtx
+ * Store with a context passed to the operation hooks. Returns ctx.Err()
+ * if ctx is done before the operation completes.
+ */
+func (db *DumbDB) StoreCtx(ctx context.Context, record [][]byte, bucket string) (err error) {
+	ctx, op := db.startOp(ctx, "Store", bucket, len(record[0]))
+	defer func() { db.finishOp(ctx, op, err) }()
+
+	if db.read_only {
+		return bolt.ErrDatabaseReadOnly
+	}
+	if err = checkUserBucket(bucket); err != nil {
+		return err
+	}
+	err = db.update(ctx, func(tx backend.Tx) error {
 
 		if len(record[0]) > MAX_KEY_LEN {
 			return bolt.ErrKeyTooLarge
@@ -224,9 +403,9 @@ func (db *DumbDB) Store(record [][]byte, bucket string) error {
 			return err
 		}
 
-		err = bkt.Put(record[0], record[1])
-		return err;
+		return db.putRecord(ctx, tx, bkt, bucket, record[0], record[1])
 	})
+	return
 }
 
 /*
@@ -237,7 +416,25 @@ func (db *DumbDB) Store(record [][]byte, bucket string) error {
  * @returns 		error
  */
 func (db *DumbDB) Remove(key []byte, bucket string) error {
-	return db.dbP.Update(func(tx *bolt.Tx) error {
+	return db.RemoveCtx(context.Background(), key, bucket)
+}
+
+/*
+ * RemoveCtx
+ * Remove with a context passed to the operation hooks. Returns ctx.Err()
+ * if ctx is done before the operation completes.
+ */
+func (db *DumbDB) RemoveCtx(ctx context.Context, key []byte, bucket string) (err error) {
+	ctx, op := db.startOp(ctx, "Remove", bucket, len(key))
+	defer func() { db.finishOp(ctx, op, err) }()
+
+	if db.read_only {
+		return bolt.ErrDatabaseReadOnly
+	}
+	if err = checkUserBucket(bucket); err != nil {
+		return err
+	}
+	err = db.update(ctx, func(tx backend.Tx) error {
 
 		if len(key) > MAX_KEY_LEN {
 			return bolt.ErrKeyTooLarge
@@ -245,15 +442,10 @@ func (db *DumbDB) Remove(key []byte, bucket string) error {
 
 		bkt := tx.Bucket([]byte(bucket))
 		if bkt == nil {
-			db.err_log.Println("Failed to open bucket.")
 			return bolt.ErrBucketNotFound
 		}
 
-		err := bkt.Delete(key)
-		if err != nil {
-			db.err_log.Printf("Failed to delete entry. ERR %v", err)
-			return err
-		}
-		return nil
+		return db.deleteRecord(ctx, tx, bkt, bucket, key)
 	})
+	return
 }
@@ -0,0 +1,190 @@
+package dumbDatabase
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"math/big"
+	"sync"
+	"time"
+)
+
+const (
+	CROCKFORD_BASE32 = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+	// In byte order, unlike big.Int.Text(62)
+	BASE62 = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	// KSUID timestamps are seconds since this time
+	KSUID_EPOCH = 1400000000
+)
+
+/*
+ * timeOrderedGenerator
+ * IDs made of a timestamp and random bytes. IDs with the same timestamp
+ * increment the random bytes of the previous one, so keys generated by one
+ * generator always sort in the order they were made. If set, rnd_mask has
+ * the random bits of each byte, the others are overwritten by encode.
+ */
+type timeOrderedGenerator struct {
+	mu       sync.Mutex
+	now      func() uint64
+	rnd_len  int
+	rnd_mask []byte
+	encode   func(ts uint64, rnd []byte) []byte
+
+	last_ts  uint64
+	last_rnd []byte
+}
+
+func (g *timeOrderedGenerator) NextID(_ func() (uint64, error)) ([]byte, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ts := g.now()
+	if g.last_rnd != nil && ts <= g.last_ts {
+		// Same tick, or the clock went back.
+		ts = g.last_ts
+		if !increment(g.last_rnd, g.rnd_mask) {
+			ts++
+		}
+	} else {
+		g.last_rnd = make([]byte, g.rnd_len)
+		if _, err := rand.Read(g.last_rnd); err != nil {
+			g.last_rnd = nil
+			return nil, err
+		}
+		for i, m := range g.rnd_mask {
+			g.last_rnd[i] &= m
+		}
+	}
+	g.last_ts = ts
+	return g.encode(ts, g.last_rnd), nil
+}
+
+/*
+ * increment
+ * Add one to the big-endian number made of the bits of b set in mask, or
+ * of all bits if mask is nil. Returns false when it wrapped to zero.
+ */
+func increment(b, mask []byte) bool {
+	for i := len(b) - 1; i >= 0; i-- {
+		m := byte(0xff)
+		if mask != nil {
+			m = mask[i]
+		}
+		// The bits outside m are set, so the carry passes over them
+		b[i] = (b[i] | ^m) + 1
+		b[i] &= m
+		if b[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func unixMilli() uint64 {
+	return uint64(time.Now().UnixMilli())
+}
+
+/*
+ * ULIDGenerator
+ * ULIDs: 48 bit millisecond timestamp and 80 random bits, as 26 character
+ * Crockford base32 keys.
+ */
+func ULIDGenerator() IDGenerator {
+	return &timeOrderedGenerator{
+		now:     unixMilli,
+		rnd_len: 10,
+		encode: func(ts uint64, rnd []byte) []byte {
+			b := make([]byte, 16)
+			binary.BigEndian.PutUint64(b, ts<<16)
+			copy(b[6:], rnd)
+			return encodeBase32(b, 26)
+		},
+	}
+}
+
+/*
+ * KSUIDGenerator
+ * KSUIDs: 32 bit timestamp in seconds since KSUID_EPOCH and 128 random
+ * bits, as 27 character base62 keys.
+ */
+func KSUIDGenerator() IDGenerator {
+	return &timeOrderedGenerator{
+		now:     func() uint64 { return uint64(time.Now().Unix() - KSUID_EPOCH) },
+		rnd_len: 16,
+		encode: func(ts uint64, rnd []byte) []byte {
+			b := make([]byte, 20)
+			binary.BigEndian.PutUint32(b, uint32(ts))
+			copy(b[4:], rnd)
+			return encodeBase62(b, 27)
+		},
+	}
+}
+
+/*
+ * UUIDv7Generator
+ * Version 7 UUIDs: 48 bit millisecond timestamp and 74 random bits, as 36
+ * character lower case keys. Only the random bits are incremented, the
+ * version and variant bits stay as they are.
+ */
+func UUIDv7Generator() IDGenerator {
+	return &timeOrderedGenerator{
+		now:      unixMilli,
+		rnd_len:  10,
+		rnd_mask: []byte{0x0f, 0xff, 0x3f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		encode: func(ts uint64, rnd []byte) []byte {
+			u := make([]byte, 16)
+			binary.BigEndian.PutUint64(u, ts<<16)
+			copy(u[6:], rnd)
+			u[6] = 0x70 | u[6]&0x0f
+			u[8] = 0x80 | u[8]&0x3f
+
+			s := make([]byte, 36)
+			hex.Encode(s, u[:4])
+			s[8] = '-'
+			hex.Encode(s[9:], u[4:6])
+			s[13] = '-'
+			hex.Encode(s[14:], u[6:8])
+			s[18] = '-'
+			hex.Encode(s[19:], u[8:10])
+			s[23] = '-'
+			hex.Encode(s[24:], u[10:])
+			return s
+		},
+	}
+}
+
+/*
+ * encodeBase32
+ * n characters of the big-endian number b in Crockford base32.
+ */
+func encodeBase32(b []byte, n int) []byte {
+	out := make([]byte, n)
+	acc, bits, j := uint(0), 0, len(b)-1
+	for i := n - 1; i >= 0; i-- {
+		for bits < 5 && j >= 0 {
+			acc |= uint(b[j]) << bits
+			bits += 8
+			j--
+		}
+		out[i] = CROCKFORD_BASE32[acc&31]
+		acc >>= 5
+		bits -= 5
+	}
+	return out
+}
+
+/*
+ * encodeBase62
+ * n characters of the big-endian number b in base62, padded with zeros.
+ */
+func encodeBase62(b []byte, n int) []byte {
+	out := make([]byte, n)
+	num := new(big.Int).SetBytes(b)
+	base, rem := big.NewInt(62), new(big.Int)
+	for i := n - 1; i >= 0; i-- {
+		num.DivMod(num, base, rem)
+		out[i] = BASE62[rem.Int64()]
+	}
+	return out
+}
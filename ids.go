@@ -0,0 +1,162 @@
+package dumbDatabase
+
+import (
+	"context"
+	"sync"
+
+	"dumbDB/backend"
+
+	"github.com/boltdb/bolt"
+)
+
+/*
+ * IDGenerator
+ * Generates the keys assigned by StoreAuto. NextID is called inside the
+ * write transaction, next returns the next value of the bucket sequence.
+ */
+type IDGenerator interface {
+	NextID(next func() (uint64, error)) ([]byte, error)
+}
+
+type sequenceGenerator struct{}
+
+/*
+ * SequenceGenerator
+ * The default generator: the bucket sequence as 8 byte big-endian key, so
+ * keys sort in insertion order.
+ */
+func SequenceGenerator() IDGenerator {
+	return sequenceGenerator{}
+}
+
+func (sequenceGenerator) NextID(next func() (uint64, error)) ([]byte, error) {
+	seq, err := next()
+	if err != nil {
+		return nil, err
+	}
+	return seqKey(seq), nil
+}
+
+type idState struct {
+	mu   sync.Mutex
+	gens map[string]IDGenerator
+}
+
+/*
+ * SetIDGenerator
+ * Select the generator of the keys StoreAuto assigns in bucket.
+ * @param 	bucket		name of bucket
+ * @param 	gen		generator, nil for SequenceGenerator
+ */
+func (db *DumbDB) SetIDGenerator(bucket string, gen IDGenerator) {
+	db.ids.mu.Lock()
+	defer db.ids.mu.Unlock()
+	if gen == nil {
+		delete(db.ids.gens, bucket)
+		return
+	}
+	if db.ids.gens == nil {
+		db.ids.gens = make(map[string]IDGenerator)
+	}
+	db.ids.gens[bucket] = gen
+}
+
+func (db *DumbDB) idGenerator(bucket string) IDGenerator {
+	db.ids.mu.Lock()
+	defer db.ids.mu.Unlock()
+	if gen, ok := db.ids.gens[bucket]; ok {
+		return gen
+	}
+	return sequenceGenerator{}
+}
+
+/*
+ * NextID
+ * Increment and return the sequence of bucket. The bucket is created if it
+ * does not exist. The first ID is 1.
+ * @param 	bucket		name of bucket
+ */
+func (db *DumbDB) NextID(bucket string) (uint64, error) {
+	return db.NextIDCtx(context.Background(), bucket)
+}
+
+/*
+ * NextIDCtx
+ * NextID with a context passed to the operation hooks. Returns ctx.Err()
+ * if ctx is done before the operation completes.
+ */
+func (db *DumbDB) NextIDCtx(ctx context.Context, bucket string) (id uint64, err error) {
+	ctx, op := db.startOp(ctx, "NextID", bucket, 0)
+	defer func() { db.finishOp(ctx, op, err) }()
+
+	if db.read_only {
+		return 0, bolt.ErrDatabaseReadOnly
+	}
+	if err = checkUserBucket(bucket); err != nil {
+		return 0, err
+	}
+	err = db.update(ctx, func(tx backend.Tx) error {
+		bkt, e := tx.CreateBucketIfNotExists([]byte(bucket))
+		if e != nil {
+			return e
+		}
+		id, e = db.nextSequence(tx, bkt, bucket)
+		return e
+	})
+	if err != nil {
+		id = 0
+	}
+	return
+}
+
+/*
+ * StoreAuto
+ * Store value under a new key from the generator of the bucket, see
+ * SetIDGenerator. The bucket will be created if it is a first insert.
+ * @param 	bucket		name of bucket
+ * @param 	value		value to store
+ * @returns 	key		assigned key
+ */
+func (db *DumbDB) StoreAuto(bucket string, value []byte) (key []byte, err error) {
+	return db.StoreAutoCtx(context.Background(), bucket, value)
+}
+
+/*
+ * StoreAutoCtx
+ * StoreAuto with a context passed to the operation hooks. Returns ctx.Err()
+ * if ctx is done before the operation completes.
+ */
+func (db *DumbDB) StoreAutoCtx(ctx context.Context, bucket string, value []byte) (key []byte, err error) {
+	ctx, op := db.startOp(ctx, "StoreAuto", bucket, 0)
+	defer func() { db.finishOp(ctx, op, err) }()
+
+	if db.read_only {
+		return nil, bolt.ErrDatabaseReadOnly
+	}
+	if err = checkUserBucket(bucket); err != nil {
+		return nil, err
+	}
+	gen := db.idGenerator(bucket)
+	err = db.update(ctx, func(tx backend.Tx) error {
+		bkt, e := tx.CreateBucketIfNotExists([]byte(bucket))
+		if e != nil {
+			return e
+		}
+		next := func() (uint64, error) { return db.nextSequence(tx, bkt, bucket) }
+		if key, e = gen.NextID(next); e != nil {
+			return e
+		}
+		if len(key) > MAX_KEY_LEN {
+			return bolt.ErrKeyTooLarge
+		}
+		if value == nil {
+			value = []byte{}
+		}
+		op.Results = 1
+		return db.putRecord(ctx, tx, bkt, bucket, key, value)
+	})
+	if err != nil {
+		key = nil
+	}
+	return
+}
@@ -0,0 +1,450 @@
+package dumbDatabase
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+
+	"dumbDB/backend"
+	"dumbDB/keys"
+
+	"github.com/boltdb/bolt"
+)
+
+// Index entries of a bucket are kept in a hidden bucket with this prefix
+// followed by the bucket name.
+const INDEX_BUCKET_PREFIX = HIDDEN_BUCKET_PREFIX + "idx."
+
+// Order of JSON types in an index, after the field name.
+const (
+	RANK_NULL int64 = iota
+	RANK_BOOL
+	RANK_NUMBER
+	RANK_STRING
+	// Strings whose entry would be longer than MAX_KEY_LEN, indexed by
+	// record key only. Queries on strings check all of them.
+	RANK_LONG_STRING
+)
+
+func indexBucket(bucket string) []byte {
+	return []byte(INDEX_BUCKET_PREFIX + bucket)
+}
+
+func indexDefKey(bucket string) []byte {
+	return []byte("index." + bucket)
+}
+
+/*
+ * CreateIndex
+ * Index the values of field in the JSON objects of bucket, for Query.
+ * Existing records are indexed right away, later writes keep the index up
+ * to date. Strings, numbers, booleans and null are indexed; records without
+ * the field, with an array or object in it, or which are not JSON objects
+ * are left out. Creating an existing index does nothing.
+ * @param 	bucket		name of bucket
+ * @param 	field		name of the field, nested fields are separated by "."
+ */
+func (db *DumbDB) CreateIndex(bucket string, field string) error {
+	return db.CreateIndexCtx(context.Background(), bucket, field)
+}
+
+/*
+ * CreateIndexCtx
+ * CreateIndex with a context passed to the operation hooks. Returns
+ * ctx.Err() if ctx is done before the operation completes.
+ */
+func (db *DumbDB) CreateIndexCtx(ctx context.Context, bucket string, field string) (err error) {
+	ctx, op := db.startOp(ctx, "CreateIndex", bucket, 0)
+	defer func() { db.finishOp(ctx, op, err) }()
+
+	if db.read_only {
+		return bolt.ErrDatabaseReadOnly
+	}
+	if err = checkUserBucket(bucket); err != nil {
+		return err
+	}
+	if field == "" {
+		return ErrInvalidQuery
+	}
+	err = db.update(ctx, func(tx backend.Tx) error {
+		fields, e := bucketIndexes(tx, bucket)
+		if e != nil {
+			return e
+		}
+		for _, f := range fields {
+			if f == field {
+				return nil
+			}
+		}
+		if e = db.setBucketIndexes(tx, bucket, append(fields, field)); e != nil {
+			return e
+		}
+		bkt := tx.Bucket([]byte(bucket))
+		if bkt == nil {
+			return nil
+		}
+		idx, e := tx.CreateBucketIfNotExists(indexBucket(bucket))
+		if e != nil {
+			return e
+		}
+		return scanCursor(ctx, bkt.Cursor(), ScanOptions{}, func(k, v []byte) error {
+			entry := indexEntry(field, k, decodeObject(v))
+			if entry == nil {
+				return nil
+			}
+			op.Results++
+			return idx.Put(entry, []byte{})
+		})
+	})
+	return
+}
+
+/*
+ * DropIndex
+ * Remove the index of field in bucket. Dropping a missing index does
+ * nothing.
+ * @param 	bucket		name of bucket
+ * @param 	field		name of the indexed field
+ */
+func (db *DumbDB) DropIndex(bucket string, field string) error {
+	return db.DropIndexCtx(context.Background(), bucket, field)
+}
+
+/*
+ * DropIndexCtx
+ * DropIndex with a context passed to the operation hooks. Returns
+ * ctx.Err() if ctx is done before the operation completes.
+ */
+func (db *DumbDB) DropIndexCtx(ctx context.Context, bucket string, field string) (err error) {
+	ctx, op := db.startOp(ctx, "DropIndex", bucket, 0)
+	defer func() { db.finishOp(ctx, op, err) }()
+
+	if db.read_only {
+		return bolt.ErrDatabaseReadOnly
+	}
+	if err = checkUserBucket(bucket); err != nil {
+		return err
+	}
+	err = db.update(ctx, func(tx backend.Tx) error {
+		fields, e := bucketIndexes(tx, bucket)
+		if e != nil {
+			return e
+		}
+		kept := make([]string, 0, len(fields))
+		for _, f := range fields {
+			if f != field {
+				kept = append(kept, f)
+			}
+		}
+		if len(kept) == len(fields) {
+			return nil
+		}
+		if e = db.setBucketIndexes(tx, bucket, kept); e != nil {
+			return e
+		}
+		idx := tx.Bucket(indexBucket(bucket))
+		if idx == nil {
+			return nil
+		}
+		var entries [][]byte
+		e = scanCursor(ctx, idx.Cursor(), ScanOptions{Prefix: keys.MustEncode(field)}, func(k, _ []byte) error {
+			entries = append(entries, bytes.Clone(k))
+			return nil
+		})
+		for _, k := range entries {
+			if e == nil {
+				e = idx.Delete(k)
+			}
+		}
+		return e
+	})
+	return
+}
+
+/*
+ * Indexes
+ * Indexed fields of bucket, in the order they were created.
+ * @param 	bucket		name of bucket
+ */
+func (db *DumbDB) Indexes(bucket string) (fields []string, err error) {
+	ctx, op := db.startOp(context.Background(), "Indexes", bucket, 0)
+	err = db.view(ctx, func(tx backend.Tx) error {
+		fields, err = bucketIndexes(tx, bucket)
+		return err
+	})
+	if fields == nil {
+		fields = make([]string, 0)
+	}
+	op.Results = len(fields)
+	db.finishOp(ctx, op, err)
+	return
+}
+
+func bucketIndexes(tx backend.Tx, bucket string) (fields []string, err error) {
+	meta := tx.Bucket([]byte(META_BUCKET))
+	if meta == nil {
+		return nil, nil
+	}
+	v := meta.Get(indexDefKey(bucket))
+	if v == nil {
+		return nil, nil
+	}
+	err = json.Unmarshal(v, &fields)
+	return
+}
+
+func (db *DumbDB) setBucketIndexes(tx backend.Tx, bucket string, fields []string) error {
+	if len(fields) == 0 {
+		return db.setDefinition(tx, bucket, indexDefKey(bucket), nil)
+	}
+	v, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	return db.setDefinition(tx, bucket, indexDefKey(bucket), v)
+}
+
+/*
+ * updateIndexes
+ * Replace the index entries of key for old with those for val, nil if
+ * the record was removed. Called inside the writing transaction.
+ */
+func updateIndexes(tx backend.Tx, bucket string, fields []string, key, old, val []byte) error {
+	idx, err := tx.CreateBucketIfNotExists(indexBucket(bucket))
+	if err != nil {
+		return err
+	}
+	old_doc, new_doc := decodeObject(old), decodeObject(val)
+	for _, f := range fields {
+		old_entry, new_entry := indexEntry(f, key, old_doc), indexEntry(f, key, new_doc)
+		if bytes.Equal(old_entry, new_entry) {
+			continue
+		}
+		if old_entry != nil {
+			if err = idx.Delete(old_entry); err != nil {
+				return err
+			}
+		}
+		if new_entry != nil {
+			if err = idx.Put(new_entry, []byte{}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+/*
+ * indexEntry
+ * Key of the index entry of field for the record key, nil if the record
+ * has no indexable value for field. Entries sort by field, JSON type,
+ * value and record key. A string too long for the entry is left out, and
+ * the entry is flagged with RANK_LONG_STRING.
+ */
+func indexEntry(field string, key []byte, doc map[string]interface{}) []byte {
+	v, ok := fieldValue(doc, field)
+	if !ok {
+		return nil
+	}
+	tuple, ok := indexTuple(field, v)
+	if !ok {
+		return nil
+	}
+	entry := keys.MustEncode(append(tuple, key)...)
+	if len(entry) > MAX_KEY_LEN && tuple[1] == RANK_STRING {
+		return keys.MustEncode(field, RANK_LONG_STRING, key)
+	}
+	return entry
+}
+
+/*
+ * hasLongStrings
+ * The index has entries of field flagged with RANK_LONG_STRING, which
+ * are not in the order of their values.
+ */
+func hasLongStrings(idx backend.Bucket, field string) bool {
+	prefix := keys.MustEncode(field, RANK_LONG_STRING)
+	k, _ := idx.Cursor().Seek(prefix)
+	return k != nil && bytes.HasPrefix(k, prefix)
+}
+
+/*
+ * indexTuple
+ * Start of the index entries of field with value v. ok is false if v can
+ * not be indexed.
+ */
+func indexTuple(field string, v interface{}) (tuple []interface{}, ok bool) {
+	switch v := v.(type) {
+	case nil:
+		return []interface{}{field, RANK_NULL}, true
+	case bool:
+		b := int64(0)
+		if v {
+			b = 1
+		}
+		return []interface{}{field, RANK_BOOL, b}, true
+	case float64:
+		if v == 0 {
+			// -0 and 0 have different keys
+			v = 0
+		}
+		return []interface{}{field, RANK_NUMBER, v}, true
+	case string:
+		return []interface{}{field, RANK_STRING, v}, true
+	}
+	return nil, false
+}
+
+func indexedKey(entry []byte) ([]byte, error) {
+	tuple, err := keys.Decode(entry)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := tuple[len(tuple)-1].([]byte)
+	if !ok {
+		return nil, keys.ErrInvalidKey
+	}
+	return key, nil
+}
+
+/*
+ * decodeObject
+ * val as a JSON object, nil if it is not one.
+ */
+func decodeObject(val []byte) map[string]interface{} {
+	if val == nil {
+		return nil
+	}
+	var doc map[string]interface{}
+	if json.Unmarshal(val, &doc) != nil {
+		return nil
+	}
+	return doc
+}
+
+/*
+ * fieldValue
+ * Value of a possibly nested field, separated by ".", in doc.
+ */
+func fieldValue(doc map[string]interface{}, field string) (interface{}, bool) {
+	var v interface{} = doc
+	for _, name := range strings.Split(field, ".") {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if v, ok = obj[name]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+/*
+ * derivedData
+ * Indexes, materialized aggregates and full-text index kept for a bucket.
+ */
+type derivedData struct {
+	indexes    []string
+	aggregates map[string]aggregateDef
+	text       *TextIndex
+}
+
+/*
+ * loadDerived
+ * Derived data of bucket as of tx, nil if it has none.
+ */
+func loadDerived(tx backend.Tx, bucket string) (*derivedData, error) {
+	fields, err := bucketIndexes(tx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	aggs, err := bucketAggregates(tx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	text, err := bucketTextIndex(tx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 && len(aggs) == 0 && text == nil {
+		return nil, nil
+	}
+	return &derivedData{indexes: fields, aggregates: aggs, text: text}, nil
+}
+
+/*
+ * dropDerived
+ * Delete the index entries, aggregates and full-text index of bucket,
+ * keeping their definitions.
+ */
+func dropDerived(tx backend.Tx, bucket string) error {
+	for _, name := range [][]byte{indexBucket(bucket), aggregateBucket(bucket), textIndexBucket(bucket)} {
+		if err := tx.DeleteBucket(name); err != nil && err != bolt.ErrBucketNotFound {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+ * isDerivedBucket
+ * name is the bucket of index entries, aggregates or full-text index of
+ * a user bucket.
+ */
+func isDerivedBucket(name []byte) bool {
+	for _, prefix := range []string{INDEX_BUCKET_PREFIX, AGGREGATE_BUCKET_PREFIX, TEXT_INDEX_BUCKET_PREFIX} {
+		if bytes.HasPrefix(name, []byte(prefix)) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+ * rebuildDerived
+ * Recreate the derived data of bucket from its records and the current
+ * definitions. Used by followers, which receive definitions but not the
+ * derived data.
+ */
+func rebuildDerived(ctx context.Context, tx backend.Tx, bucket string) error {
+	if err := dropDerived(tx, bucket); err != nil {
+		return err
+	}
+	derived, err := loadDerived(tx, bucket)
+	if err != nil || derived == nil {
+		return err
+	}
+	bkt := tx.Bucket([]byte(bucket))
+	if bkt == nil {
+		return nil
+	}
+	return scanCursor(ctx, bkt.Cursor(), ScanOptions{}, func(k, v []byte) error {
+		return derived.update(tx, bucket, k, nil, v)
+	})
+}
+
+/*
+ * update
+ * Move the record key from old to val, nil if it was created or removed.
+ */
+func (d *derivedData) update(tx backend.Tx, bucket string, key, old, val []byte) error {
+	if d == nil {
+		return nil
+	}
+	if len(d.indexes) > 0 {
+		if err := updateIndexes(tx, bucket, d.indexes, key, old, val); err != nil {
+			return err
+		}
+	}
+	if len(d.aggregates) > 0 {
+		if err := updateAggregates(tx, bucket, d.aggregates, old, val); err != nil {
+			return err
+		}
+	}
+	if d.text != nil {
+		return updateTextIndex(tx, bucket, d.text, key, val)
+	}
+	return nil
+}
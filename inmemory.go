@@ -0,0 +1,24 @@
+package dumbDatabase
+
+import (
+	"dumbDB/backend/memory"
+)
+
+// DbFullName of DBs created with NewInMemory.
+const IN_MEMORY_NAME = ":memory:"
+
+/*
+ * NewInMemory
+ * A DB kept in memory only, with the same semantics as a DB file. It is
+ * lost on Close unless saved with SaveTo. Logs are discarded, see
+ * SetLogger.
+ */
+func NewInMemory() *DumbDB {
+	db := new(DumbDB)
+	db.initLogger(nil)
+	db.write_lock = make(chan struct{}, 1)
+	db.opener = memory.Open
+	db.DbFullName = IN_MEMORY_NAME
+	db.dbP = memory.New()
+	return db
+}
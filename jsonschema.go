@@ -0,0 +1,679 @@
+package dumbDatabase
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+/*
+ * jsonSchema
+ * A compiled JSON Schema. Supports the validation keywords of draft 2020-12
+ * except format, dependent*, unevaluated*, prefixItems and contains, and
+ * $ref to "#" or a JSON pointer into the same document. A $ref has to
+ * descend into a property or an item before it loops back to its schema.
+ * Unknown keywords are ignored, as the spec requires.
+ */
+type jsonSchema struct {
+	// false schema
+	reject bool
+
+	types []string
+	enum  []interface{}
+	konst interface{}
+	// konst is set, it may be null
+	has_const bool
+
+	minimum, maximum                     *float64
+	exclusive_minimum, exclusive_maximum *float64
+	multiple_of                          *float64
+
+	min_length, max_length *int
+	pattern                *regexp.Regexp
+
+	items                *jsonSchema
+	min_items, max_items *int
+	unique_items         bool
+
+	properties           map[string]*jsonSchema
+	pattern_properties   []patternSchema
+	additional           *jsonSchema
+	required             []string
+	min_props, max_props *int
+
+	all_of, any_of, one_of []*jsonSchema
+	not                    *jsonSchema
+	ref                    *jsonSchema
+}
+
+// Schema of the properties matching re, in patternProperties
+type patternSchema struct {
+	re     *regexp.Regexp
+	schema *jsonSchema
+}
+
+type schemaCompiler struct {
+	root interface{}
+	refs map[string]*jsonSchema
+	// Compiled schemas in order, with their location
+	schemas []*jsonSchema
+	at      map[*jsonSchema]string
+}
+
+/*
+ * compileSchema
+ * Parse and compile a JSON Schema document. Fails with ErrInvalidSchema.
+ */
+func compileSchema(doc []byte) (*jsonSchema, error) {
+	root, err := decodeJSON(doc)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSchema, err.Error())
+	}
+	c := &schemaCompiler{root: root, refs: make(map[string]*jsonSchema), at: make(map[*jsonSchema]string)}
+	s, err := c.compile(root, "")
+	if err == nil {
+		err = c.checkLoops()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSchema, err.Error())
+	}
+	return s, nil
+}
+
+func decodeJSON(doc []byte) (v interface{}, err error) {
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.UseNumber()
+	if err = dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("data after the JSON value")
+	}
+	return v, nil
+}
+
+func (c *schemaCompiler) compile(v interface{}, at string) (*jsonSchema, error) {
+	s := &jsonSchema{}
+	return s, c.compileInto(s, v, at)
+}
+
+func (c *schemaCompiler) compileInto(s *jsonSchema, v interface{}, at string) (err error) {
+	c.schemas = append(c.schemas, s)
+	c.at[s] = at
+	switch v := v.(type) {
+	case bool:
+		s.reject = !v
+		return nil
+	case map[string]interface{}:
+		return c.compileObject(s, v, at)
+	}
+	return fmt.Errorf("%s: schema must be an object or a boolean", pointerOrRoot(at))
+}
+
+func (c *schemaCompiler) compileObject(s *jsonSchema, m map[string]interface{}, at string) (err error) {
+	if ref, ok := m["$ref"]; ok {
+		r, ok := ref.(string)
+		if !ok {
+			return fmt.Errorf("%s/$ref: must be a string", at)
+		}
+		if s.ref, err = c.resolve(r); err != nil {
+			return fmt.Errorf("%s/$ref: %s", at, err.Error())
+		}
+	}
+	if t, ok := m["type"]; ok {
+		if s.types, err = stringList(t); err != nil {
+			return fmt.Errorf("%s/type: %s", at, err.Error())
+		}
+	}
+	if e, ok := m["enum"]; ok {
+		if s.enum, ok = e.([]interface{}); !ok {
+			return fmt.Errorf("%s/enum: must be an array", at)
+		}
+	}
+	s.konst, s.has_const = m["const"]
+
+	numbers := []struct {
+		name string
+		dst  **float64
+	}{
+		{"minimum", &s.minimum}, {"maximum", &s.maximum},
+		{"exclusiveMinimum", &s.exclusive_minimum}, {"exclusiveMaximum", &s.exclusive_maximum},
+		{"multipleOf", &s.multiple_of},
+	}
+	for _, n := range numbers {
+		if *n.dst, err = numberKeyword(m, n.name, at); err != nil {
+			return err
+		}
+	}
+	if s.multiple_of != nil && *s.multiple_of <= 0 {
+		return fmt.Errorf("%s/multipleOf: must be > 0", at)
+	}
+	counts := []struct {
+		name string
+		dst  **int
+	}{
+		{"minLength", &s.min_length}, {"maxLength", &s.max_length},
+		{"minItems", &s.min_items}, {"maxItems", &s.max_items},
+		{"minProperties", &s.min_props}, {"maxProperties", &s.max_props},
+	}
+	for _, n := range counts {
+		if *n.dst, err = countKeyword(m, n.name, at); err != nil {
+			return err
+		}
+	}
+	if p, ok := m["pattern"]; ok {
+		if s.pattern, err = compilePattern(p); err != nil {
+			return fmt.Errorf("%s/pattern: %s", at, err.Error())
+		}
+	}
+	if u, ok := m["uniqueItems"]; ok {
+		if s.unique_items, ok = u.(bool); !ok {
+			return fmt.Errorf("%s/uniqueItems: must be a boolean", at)
+		}
+	}
+	if r, ok := m["required"]; ok {
+		if s.required, err = stringList(r); err != nil {
+			return fmt.Errorf("%s/required: %s", at, err.Error())
+		}
+	}
+
+	if i, ok := m["items"]; ok {
+		if s.items, err = c.compile(i, at+"/items"); err != nil {
+			return err
+		}
+	}
+	if a, ok := m["additionalProperties"]; ok {
+		if s.additional, err = c.compile(a, at+"/additionalProperties"); err != nil {
+			return err
+		}
+	}
+	if n, ok := m["not"]; ok {
+		if s.not, err = c.compile(n, at+"/not"); err != nil {
+			return err
+		}
+	}
+	if p, ok := m["properties"]; ok {
+		if s.properties, err = c.compileMap(p, at+"/properties"); err != nil {
+			return err
+		}
+	}
+	if p, ok := m["patternProperties"]; ok {
+		props, err := c.compileMap(p, at+"/patternProperties")
+		if err != nil {
+			return err
+		}
+		// Sorted by pattern, so errors come out in the same order every time
+		exprs := make([]string, 0, len(props))
+		for expr := range props {
+			exprs = append(exprs, expr)
+		}
+		sort.Strings(exprs)
+		s.pattern_properties = make([]patternSchema, len(exprs))
+		for i, expr := range exprs {
+			re, err := compilePattern(expr)
+			if err != nil {
+				return fmt.Errorf("%s/patternProperties: %s", at, err.Error())
+			}
+			s.pattern_properties[i] = patternSchema{re: re, schema: props[expr]}
+		}
+	}
+	lists := []struct {
+		name string
+		dst  *[]*jsonSchema
+	}{{"allOf", &s.all_of}, {"anyOf", &s.any_of}, {"oneOf", &s.one_of}}
+	for _, l := range lists {
+		v, ok := m[l.name]
+		if !ok {
+			continue
+		}
+		arr, ok := v.([]interface{})
+		if !ok || len(arr) == 0 {
+			return fmt.Errorf("%s/%s: must be a non-empty array", at, l.name)
+		}
+		for i, sub := range arr {
+			cs, err := c.compile(sub, fmt.Sprintf("%s/%s/%d", at, l.name, i))
+			if err != nil {
+				return err
+			}
+			*l.dst = append(*l.dst, cs)
+		}
+	}
+	return nil
+}
+
+func (c *schemaCompiler) compileMap(v interface{}, at string) (map[string]*jsonSchema, error) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s: must be an object", at)
+	}
+	compiled := make(map[string]*jsonSchema, len(m))
+	for name, sub := range m {
+		s, err := c.compile(sub, at+"/"+escapePointer(name))
+		if err != nil {
+			return nil, err
+		}
+		compiled[name] = s
+	}
+	return compiled, nil
+}
+
+/*
+ * resolve
+ * Schema a local $ref points to. The schema is registered before it is
+ * compiled, so recursive references end up pointing to it.
+ */
+func (c *schemaCompiler) resolve(ref string) (*jsonSchema, error) {
+	if s, ok := c.refs[ref]; ok {
+		return s, nil
+	}
+	if !strings.HasPrefix(ref, "#") {
+		return nil, fmt.Errorf("only references into the same document are supported, got %q", ref)
+	}
+	target := c.root
+	if ptr := ref[1:]; ptr != "" {
+		if ptr[0] != '/' {
+			return nil, fmt.Errorf("invalid JSON pointer %q", ptr)
+		}
+		for _, tok := range strings.Split(ptr[1:], "/") {
+			tok = strings.NewReplacer("~1", "/", "~0", "~").Replace(tok)
+			switch t := target.(type) {
+			case map[string]interface{}:
+				target = t[tok]
+			case []interface{}:
+				i, err := strconv.Atoi(tok)
+				if err != nil || i < 0 || i >= len(t) {
+					return nil, fmt.Errorf("%q not found", ref)
+				}
+				target = t[i]
+			default:
+				target = nil
+			}
+			if target == nil {
+				return nil, fmt.Errorf("%q not found", ref)
+			}
+		}
+	}
+	s := &jsonSchema{}
+	c.refs[ref] = s
+	return s, c.compileInto(s, target, ref[1:])
+}
+
+/*
+ * checkLoops
+ * Fail if a schema reaches itself through $ref, allOf, anyOf, oneOf or
+ * not, which apply to the same value, without descending into a property
+ * or an item. Checking a value against it would never end.
+ */
+func (c *schemaCompiler) checkLoops() error {
+	const (
+		visiting = iota + 1
+		visited
+	)
+	state := make(map[*jsonSchema]int, len(c.schemas))
+	var visit func(s *jsonSchema) error
+	visit = func(s *jsonSchema) error {
+		switch state[s] {
+		case visiting:
+			return fmt.Errorf("%s: $ref loops back to the schema without descending into the value",
+				pointerOrRoot(c.at[s]))
+		case visited:
+			return nil
+		}
+		state[s] = visiting
+		same := append([]*jsonSchema{s.ref, s.not}, s.all_of...)
+		same = append(append(same, s.any_of...), s.one_of...)
+		for _, sub := range same {
+			if sub == nil {
+				continue
+			}
+			if err := visit(sub); err != nil {
+				return err
+			}
+		}
+		state[s] = visited
+		return nil
+	}
+	for _, s := range c.schemas {
+		if err := visit(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func stringList(v interface{}) ([]string, error) {
+	if s, ok := v.(string); ok {
+		return []string{s}, nil
+	}
+	arr, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("must be a string or an array of strings")
+	}
+	list := make([]string, len(arr))
+	for i, e := range arr {
+		if list[i], ok = e.(string); !ok {
+			return nil, fmt.Errorf("must be a string or an array of strings")
+		}
+	}
+	return list, nil
+}
+
+func numberKeyword(m map[string]interface{}, name, at string) (*float64, error) {
+	v, ok := m[name]
+	if !ok {
+		return nil, nil
+	}
+	n, ok := v.(json.Number)
+	if !ok {
+		return nil, fmt.Errorf("%s/%s: must be a number", at, name)
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return nil, fmt.Errorf("%s/%s: %s", at, name, err.Error())
+	}
+	return &f, nil
+}
+
+func countKeyword(m map[string]interface{}, name, at string) (*int, error) {
+	f, err := numberKeyword(m, name, at)
+	if err != nil || f == nil {
+		return nil, err
+	}
+	if *f < 0 || *f != math.Trunc(*f) {
+		return nil, fmt.Errorf("%s/%s: must be a non-negative integer", at, name)
+	}
+	n := int(*f)
+	return &n, nil
+}
+
+func compilePattern(v interface{}) (*regexp.Regexp, error) {
+	p, ok := v.(string)
+	if !ok {
+		return nil, fmt.Errorf("must be a string")
+	}
+	return regexp.Compile(p)
+}
+
+func escapePointer(tok string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(tok)
+}
+
+func pointerOrRoot(at string) string {
+	if at == "" {
+		return "(root)"
+	}
+	return at
+}
+
+/*
+ * validate
+ * Check doc against s. Returns an error for every failing keyword, with the
+ * JSON pointer of the value it failed on. nil if doc is valid.
+ */
+func (s *jsonSchema) validate(doc []byte) []FieldError {
+	v, err := decodeJSON(doc)
+	if err != nil {
+		return []FieldError{{Path: "", Message: "invalid JSON: " + err.Error()}}
+	}
+	var errs []FieldError
+	s.check(v, "", &errs)
+	return errs
+}
+
+func (s *jsonSchema) valid(v interface{}) bool {
+	var errs []FieldError
+	s.check(v, "", &errs)
+	return len(errs) == 0
+}
+
+func (s *jsonSchema) check(v interface{}, path string, errs *[]FieldError) {
+	fail := func(format string, args ...interface{}) {
+		*errs = append(*errs, FieldError{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+	if s.reject {
+		fail("no value is allowed")
+		return
+	}
+	if s.ref != nil {
+		s.ref.check(v, path, errs)
+	}
+	if len(s.types) > 0 && !s.hasType(v) {
+		fail("expected %s, got %s", strings.Join(s.types, " or "), jsonType(v))
+		return
+	}
+	if s.enum != nil && !containsJSON(s.enum, v) {
+		fail("must be one of the enum values")
+	}
+	if s.has_const && !equalJSON(s.konst, v) {
+		fail("must be equal to the const value")
+	}
+
+	switch v := v.(type) {
+	case json.Number:
+		s.checkNumber(v, fail)
+	case string:
+		n := utf8.RuneCountInString(v)
+		if s.min_length != nil && n < *s.min_length {
+			fail("length must be >= %d", *s.min_length)
+		}
+		if s.max_length != nil && n > *s.max_length {
+			fail("length must be <= %d", *s.max_length)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			fail("must match pattern %q", s.pattern.String())
+		}
+	case []interface{}:
+		s.checkArray(v, path, errs, fail)
+	case map[string]interface{}:
+		s.checkObject(v, path, errs, fail)
+	}
+
+	for _, sub := range s.all_of {
+		sub.check(v, path, errs)
+	}
+	if s.any_of != nil {
+		matched := false
+		for _, sub := range s.any_of {
+			if sub.valid(v) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			fail("must match at least one schema in anyOf")
+		}
+	}
+	if s.one_of != nil {
+		matched := 0
+		for _, sub := range s.one_of {
+			if sub.valid(v) {
+				matched++
+			}
+		}
+		if matched != 1 {
+			fail("must match exactly one schema in oneOf, matches %d", matched)
+		}
+	}
+	if s.not != nil && s.not.valid(v) {
+		fail("must not match the schema in not")
+	}
+}
+
+func (s *jsonSchema) checkNumber(n json.Number, fail func(string, ...interface{})) {
+	f, err := n.Float64()
+	if err != nil {
+		fail("invalid number: %s", err.Error())
+		return
+	}
+	if s.minimum != nil && f < *s.minimum {
+		fail("must be >= %v", *s.minimum)
+	}
+	if s.maximum != nil && f > *s.maximum {
+		fail("must be <= %v", *s.maximum)
+	}
+	if s.exclusive_minimum != nil && f <= *s.exclusive_minimum {
+		fail("must be > %v", *s.exclusive_minimum)
+	}
+	if s.exclusive_maximum != nil && f >= *s.exclusive_maximum {
+		fail("must be < %v", *s.exclusive_maximum)
+	}
+	if s.multiple_of != nil {
+		q := f / *s.multiple_of
+		if math.Abs(q-math.Round(q)) > 1e-9 {
+			fail("must be a multiple of %v", *s.multiple_of)
+		}
+	}
+}
+
+func (s *jsonSchema) checkArray(arr []interface{}, path string, errs *[]FieldError, fail func(string, ...interface{})) {
+	if s.min_items != nil && len(arr) < *s.min_items {
+		fail("must have at least %d items", *s.min_items)
+	}
+	if s.max_items != nil && len(arr) > *s.max_items {
+		fail("must have at most %d items", *s.max_items)
+	}
+	if s.unique_items {
+	unique:
+		for i := range arr {
+			for j := 0; j < i; j++ {
+				if equalJSON(arr[i], arr[j]) {
+					fail("items %d and %d are equal", j, i)
+					break unique
+				}
+			}
+		}
+	}
+	if s.items != nil {
+		for i, item := range arr {
+			s.items.check(item, path+"/"+strconv.Itoa(i), errs)
+		}
+	}
+}
+
+func (s *jsonSchema) checkObject(obj map[string]interface{}, path string, errs *[]FieldError, fail func(string, ...interface{})) {
+	if s.min_props != nil && len(obj) < *s.min_props {
+		fail("must have at least %d properties", *s.min_props)
+	}
+	if s.max_props != nil && len(obj) > *s.max_props {
+		fail("must have at most %d properties", *s.max_props)
+	}
+	for _, name := range s.required {
+		if _, ok := obj[name]; !ok {
+			fail("missing required property %q", name)
+		}
+	}
+	// Sorted, so errors come out in the same order every time
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		at := path + "/" + escapePointer(name)
+		matched := false
+		if sub, ok := s.properties[name]; ok {
+			sub.check(obj[name], at, errs)
+			matched = true
+		}
+		for _, p := range s.pattern_properties {
+			if p.re.MatchString(name) {
+				p.schema.check(obj[name], at, errs)
+				matched = true
+			}
+		}
+		if !matched && s.additional != nil {
+			if s.additional.reject {
+				*errs = append(*errs, FieldError{Path: at, Message: "property is not allowed"})
+			} else {
+				s.additional.check(obj[name], at, errs)
+			}
+		}
+	}
+}
+
+func (s *jsonSchema) hasType(v interface{}) bool {
+	actual := jsonType(v)
+	for _, t := range s.types {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+func jsonType(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	case json.Number:
+		if f, err := v.Float64(); err == nil && f == math.Trunc(f) && !math.IsInf(f, 0) {
+			return "integer"
+		}
+	}
+	return "number"
+}
+
+func containsJSON(list []interface{}, v interface{}) bool {
+	for _, e := range list {
+		if equalJSON(e, v) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+ * equalJSON
+ * Equality of decoded JSON values, numbers are equal if their values are,
+ * so 1 and 1.0 are.
+ */
+func equalJSON(a, b interface{}) bool {
+	switch a := a.(type) {
+	case json.Number:
+		n, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		fa, e1 := a.Float64()
+		fb, e2 := n.Float64()
+		return e1 == nil && e2 == nil && fa == fb
+	case []interface{}:
+		arr, ok := b.([]interface{})
+		if !ok || len(arr) != len(a) {
+			return false
+		}
+		for i := range a {
+			if !equalJSON(a[i], arr[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}:
+		obj, ok := b.(map[string]interface{})
+		if !ok || len(obj) != len(a) {
+			return false
+		}
+		for k, v := range a {
+			w, ok := obj[k]
+			if !ok || !equalJSON(v, w) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
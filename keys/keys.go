@@ -0,0 +1,242 @@
+/*
+ * Package keys encodes tuples of values into keys whose byte order is the
+ * order of the tuples, so scans return records in numeric, lexical or time
+ * order.
+ *
+ *	key := keys.MustEncode("user", uint64(2))
+ *	start, end, _ := keys.PrefixRange("user")
+ *	db.Scan("Users", dDB.ScanOptions{Start: start, End: end}, fn)
+ *
+ * Elements compare by type first, in the order bytes, string, int, uint,
+ * float, time, then by value. Every element is self-delimiting, so the
+ * encoding of a tuple is a prefix of the encodings of all tuples starting
+ * with it.
+ */
+package keys
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Type tags, in sort order.
+const (
+	TAG_BYTES  byte = 0x01
+	TAG_STRING byte = 0x02
+	TAG_INT    byte = 0x05
+	TAG_UINT   byte = 0x06
+	TAG_FLOAT  byte = 0x07
+	TAG_TIME   byte = 0x08
+)
+
+var (
+	// Returned by Decode on bytes which are not an encoded tuple.
+	ErrInvalidKey = errors.New("invalid tuple key")
+
+	// Returned by Encode for values of other types.
+	ErrUnsupportedType = errors.New("unsupported key element type")
+)
+
+/*
+ * Encode
+ * Key of the tuple vals. Supported are the int, uint and float types,
+ * string, []byte and time.Time. Times keep nanoseconds and must be between
+ * the years 1678 and 2262.
+ * @param 	vals		elements of the tuple
+ */
+func Encode(vals ...interface{}) ([]byte, error) {
+	b := make([]byte, 0, 16*len(vals))
+	for _, v := range vals {
+		switch x := v.(type) {
+		case []byte:
+			b = appendEscaped(append(b, TAG_BYTES), x)
+		case string:
+			b = appendEscaped(append(b, TAG_STRING), []byte(x))
+		case int:
+			b = appendInt(append(b, TAG_INT), int64(x))
+		case int8:
+			b = appendInt(append(b, TAG_INT), int64(x))
+		case int16:
+			b = appendInt(append(b, TAG_INT), int64(x))
+		case int32:
+			b = appendInt(append(b, TAG_INT), int64(x))
+		case int64:
+			b = appendInt(append(b, TAG_INT), x)
+		case uint:
+			b = binary.BigEndian.AppendUint64(append(b, TAG_UINT), uint64(x))
+		case uint8:
+			b = binary.BigEndian.AppendUint64(append(b, TAG_UINT), uint64(x))
+		case uint16:
+			b = binary.BigEndian.AppendUint64(append(b, TAG_UINT), uint64(x))
+		case uint32:
+			b = binary.BigEndian.AppendUint64(append(b, TAG_UINT), uint64(x))
+		case uint64:
+			b = binary.BigEndian.AppendUint64(append(b, TAG_UINT), x)
+		case float32:
+			b = appendFloat(append(b, TAG_FLOAT), float64(x))
+		case float64:
+			b = appendFloat(append(b, TAG_FLOAT), x)
+		case time.Time:
+			b = appendInt(append(b, TAG_TIME), x.UnixNano())
+		default:
+			return nil, fmt.Errorf("%w: %T", ErrUnsupportedType, v)
+		}
+	}
+	return b, nil
+}
+
+/*
+ * MustEncode
+ * Encode which panics on unsupported types.
+ */
+func MustEncode(vals ...interface{}) []byte {
+	b, err := Encode(vals...)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+/*
+ * Decode
+ * Elements of an encoded tuple. Integers are returned as int64 or uint64,
+ * floats as float64 and times in UTC.
+ * @param 	key		encoded tuple
+ */
+func Decode(key []byte) ([]interface{}, error) {
+	vals := make([]interface{}, 0)
+	for len(key) > 0 {
+		tag := key[0]
+		key = key[1:]
+		switch tag {
+		case TAG_BYTES, TAG_STRING:
+			v, n, err := readEscaped(key)
+			if err != nil {
+				return nil, err
+			}
+			key = key[n:]
+			if tag == TAG_STRING {
+				vals = append(vals, string(v))
+			} else {
+				vals = append(vals, v)
+			}
+		case TAG_INT, TAG_UINT, TAG_FLOAT, TAG_TIME:
+			if len(key) < 8 {
+				return nil, ErrInvalidKey
+			}
+			u := binary.BigEndian.Uint64(key)
+			key = key[8:]
+			switch tag {
+			case TAG_INT:
+				vals = append(vals, int64(u^(1<<63)))
+			case TAG_UINT:
+				vals = append(vals, u)
+			case TAG_FLOAT:
+				vals = append(vals, decodeFloat(u))
+			default:
+				vals = append(vals, time.Unix(0, int64(u^(1<<63))).UTC())
+			}
+		default:
+			return nil, ErrInvalidKey
+		}
+	}
+	return vals, nil
+}
+
+/*
+ * Prefix
+ * Encoded partial tuple, for ScanOptions.Prefix. Same as Encode.
+ */
+func Prefix(vals ...interface{}) ([]byte, error) {
+	return Encode(vals...)
+}
+
+/*
+ * PrefixRange
+ * Bounds [start, end) of all keys starting with the partial tuple vals,
+ * for ScanOptions.Start and ScanOptions.End.
+ */
+func PrefixRange(vals ...interface{}) (start []byte, end []byte, err error) {
+	if start, err = Encode(vals...); err != nil {
+		return nil, nil, err
+	}
+	return start, PrefixEnd(start), nil
+}
+
+/*
+ * PrefixEnd
+ * Smallest key greater than all keys starting with prefix, nil if there
+ * is none.
+ */
+func PrefixEnd(prefix []byte) []byte {
+	end := bytes.Clone(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return nil
+}
+
+// Flipping the sign bit orders negative numbers before positive ones.
+func appendInt(b []byte, v int64) []byte {
+	return binary.BigEndian.AppendUint64(b, uint64(v)^(1<<63))
+}
+
+// Negative floats have all bits flipped, positive ones the sign bit.
+func appendFloat(b []byte, v float64) []byte {
+	u := math.Float64bits(v)
+	if u&(1<<63) != 0 {
+		u = ^u
+	} else {
+		u ^= 1 << 63
+	}
+	return binary.BigEndian.AppendUint64(b, u)
+}
+
+func decodeFloat(u uint64) float64 {
+	if u&(1<<63) != 0 {
+		u ^= 1 << 63
+	} else {
+		u = ^u
+	}
+	return math.Float64frombits(u)
+}
+
+/*
+ * appendEscaped
+ * v terminated by 0x00, with 0x00 bytes in v escaped as 0x00 0xff.
+ */
+func appendEscaped(b []byte, v []byte) []byte {
+	for _, c := range v {
+		b = append(b, c)
+		if c == 0x00 {
+			b = append(b, 0xff)
+		}
+	}
+	return append(b, 0x00)
+}
+
+func readEscaped(b []byte) (v []byte, n int, err error) {
+	v = make([]byte, 0)
+	for n < len(b) {
+		c := b[n]
+		n++
+		if c != 0x00 {
+			v = append(v, c)
+			continue
+		}
+		if n < len(b) && b[n] == 0xff {
+			v = append(v, 0x00)
+			n++
+			continue
+		}
+		return v, n, nil
+	}
+	return nil, 0, ErrInvalidKey
+}
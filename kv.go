@@ -0,0 +1,69 @@
+package dumbDatabase
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrInvalidChange = errors.New("invalid change op")
+
+/*
+ * KV
+ * The key-value API of DumbDB. Remote clients such as rpc.Client implement
+ * it too, so code using a KV can switch between a local and a remote store.
+ */
+type KV interface {
+	Get(key []byte, bucket string) ([]byte, error)
+	GetCtx(ctx context.Context, key []byte, bucket string) ([]byte, error)
+	GetMultiple(keys [][]byte, bucket string) ([][]byte, error)
+	GetMultipleCtx(ctx context.Context, keys [][]byte, bucket string) ([][]byte, error)
+	Store(record [][]byte, bucket string) error
+	StoreCtx(ctx context.Context, record [][]byte, bucket string) error
+	Remove(key []byte, bucket string) error
+	RemoveCtx(ctx context.Context, key []byte, bucket string) error
+	Scan(bucket string, opts ScanOptions, fn func(key, val []byte) error) error
+	ScanCtx(ctx context.Context, bucket string, opts ScanOptions, fn func(key, val []byte) error) error
+	Watch(bucket string, prefix []byte, fn func(Change)) (cancel func())
+	Txn(changes []Change) error
+	TxnCtx(ctx context.Context, changes []Change) error
+	Close() error
+}
+
+var _ KV = (*DumbDB)(nil)
+
+/*
+ * Txn
+ * Apply a batch of changes in one transaction. Either all changes are
+ * written or, if one fails, none.
+ * @param 	changes		puts, deletes and bucket removals, in order
+ */
+func (db *DumbDB) Txn(changes []Change) error {
+	return db.TxnCtx(context.Background(), changes)
+}
+
+/*
+ * TxnCtx
+ * Txn with a context passed to the operation hooks. Nothing is written if
+ * ctx is done before the transaction commits.
+ */
+func (db *DumbDB) TxnCtx(ctx context.Context, changes []Change) error {
+	return db.UpdateCtx(ctx, func(tx *Tx) error {
+		for _, c := range changes {
+			var err error
+			switch c.Op {
+			case ChangePut:
+				err = tx.Store([][]byte{c.Key, c.Value}, c.Bucket)
+			case ChangeDelete:
+				err = tx.Remove(c.Key, c.Bucket)
+			case ChangeDeleteBucket:
+				err = tx.RemoveBucket(c.Bucket)
+			default:
+				err = ErrInvalidChange
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,144 @@
+package dumbDatabase
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+type LogLevel int
+
+const (
+	LevelDebug LogLevel = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+	// Disables logging
+	LevelOff
+)
+
+const DEFAULT_LOG_LEVEL = LevelInfo
+
+/*
+ * Logger
+ * Structured logger used by DumbDB. kv is a list of alternating keys and
+ * values, e.g. "bucket", "Users", "duration", time.Millisecond.
+ */
+type Logger interface {
+	Debug(msg string, kv ...interface{})
+	Info(msg string, kv ...interface{})
+	Warn(msg string, kv ...interface{})
+	Error(msg string, kv ...interface{})
+}
+
+type slogLogger struct {
+	l *slog.Logger
+}
+
+/*
+ * NewSlogLogger
+ * Logger writing to a log/slog logger.
+ */
+func NewSlogLogger(l *slog.Logger) Logger {
+	return slogLogger{l: l}
+}
+
+/*
+ * NewTextLogger
+ * Logger writing text lines to w. This is what NewDumbDB uses.
+ */
+func NewTextLogger(w io.Writer) Logger {
+	h := slog.NewTextHandler(w, &slog.HandlerOptions{Level: slog.LevelDebug})
+	return NewSlogLogger(slog.New(h).With("lib", "DumbDB"))
+}
+
+func (s slogLogger) Debug(msg string, kv ...interface{}) { s.log(slog.LevelDebug, msg, kv) }
+func (s slogLogger) Info(msg string, kv ...interface{})  { s.log(slog.LevelInfo, msg, kv) }
+func (s slogLogger) Warn(msg string, kv ...interface{})  { s.log(slog.LevelWarn, msg, kv) }
+func (s slogLogger) Error(msg string, kv ...interface{}) { s.log(slog.LevelError, msg, kv) }
+
+func (s slogLogger) log(level slog.Level, msg string, kv []interface{}) {
+	s.l.Log(context.Background(), level, msg, kv...)
+}
+
+type nopLogger struct{}
+
+/*
+ * NopLogger
+ * Logger discarding everything.
+ */
+func NopLogger() Logger {
+	return nopLogger{}
+}
+
+func (nopLogger) Debug(string, ...interface{}) {}
+func (nopLogger) Info(string, ...interface{})  {}
+func (nopLogger) Warn(string, ...interface{})  {}
+func (nopLogger) Error(string, ...interface{}) {}
+
+/*
+ * SetLogger
+ * Replace the logger. A nil logger disables logging.
+ * Should be called before the DB is shared between goroutines.
+ */
+func (db *DumbDB) SetLogger(l Logger) {
+	if l == nil {
+		l = NopLogger()
+	}
+	db.logger = l
+}
+
+/*
+ * SetLogLevel
+ * Only log messages at or above level. Successful operations are logged at
+ * LevelDebug, so the default level of LevelInfo only logs problems.
+ */
+func (db *DumbDB) SetLogLevel(level LogLevel) {
+	db.log_level = level
+}
+
+func (db *DumbDB) logDebug(msg string, kv ...interface{}) {
+	if db.log_level <= LevelDebug {
+		db.logger.Debug(msg, kv...)
+	}
+}
+
+func (db *DumbDB) logInfo(msg string, kv ...interface{}) {
+	if db.log_level <= LevelInfo {
+		db.logger.Info(msg, kv...)
+	}
+}
+
+func (db *DumbDB) logWarn(msg string, kv ...interface{}) {
+	if db.log_level <= LevelWarn {
+		db.logger.Warn(msg, kv...)
+	}
+}
+
+func (db *DumbDB) logError(msg string, kv ...interface{}) {
+	if db.log_level <= LevelError {
+		db.logger.Error(msg, kv...)
+	}
+}
+
+/*
+ * logOp
+ * Log the outcome of an operation. Misses are not errors and are only
+ * logged at debug level.
+ */
+func (db *DumbDB) logOp(op string, bucket string, start time.Time, err error, kv ...interface{}) {
+	kv = append([]interface{}{"op", op, "bucket", bucket, "duration", time.Since(start)}, kv...)
+	switch {
+	case err == nil:
+		db.logDebug(op, kv...)
+	case err == bolt.ErrKeyRequired || isMiss(op, err):
+		db.logDebug(op+" miss", kv...)
+	case err == bolt.ErrBucketNotFound || isContextErr(err):
+		db.logWarn(op+" failed", append(kv, "error", err)...)
+	default:
+		db.logError(op+" failed", append(kv, "error", err)...)
+	}
+}
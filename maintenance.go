@@ -0,0 +1,100 @@
+package dumbDatabase
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+
+	"dumbDB/backend"
+	"dumbDB/backend/boltdb"
+)
+
+/*
+ * CompactTo
+ * Write a compacted copy of the DB to a new file. Free pages are not
+ * copied, so the new file is usually smaller. Writers are not blocked.
+ * The copy uses the backend of the DB, or boltdb/bolt if that backend is
+ * not stored in a file.
+ * @param 	path		path of the new file, must not exist
+ */
+func (db *DumbDB) CompactTo(path string) (err error) {
+	ctx, op := db.startOp(context.Background(), "CompactTo", "", 0)
+	defer func() { db.finishOp(ctx, op, err, "path", path) }()
+
+	open := db.opener
+	if db.dbP.Path() == "" {
+		open = boltdb.Open
+	}
+	return db.copyTo(ctx, op, open, path)
+}
+
+/*
+ * SaveTo
+ * Write a copy of the DB to a bolt file, which can be opened with
+ * NewDumbDB. An existing file at path is replaced once the copy is
+ * complete. Mostly used to persist a DB created with NewInMemory.
+ * @param 	path		path of the file
+ */
+func (db *DumbDB) SaveTo(path string) (err error) {
+	ctx, op := db.startOp(context.Background(), "SaveTo", "", 0)
+	defer func() { db.finishOp(ctx, op, err, "path", path) }()
+
+	tmp := path + ".saving"
+	os.Remove(tmp)
+	if err = db.copyTo(ctx, op, boltdb.Open, tmp); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+/*
+ * copyTo
+ * Copy all buckets, hidden ones included, to a new backend at path in a
+ * single read transaction.
+ */
+func (db *DumbDB) copyTo(ctx context.Context, op *OpInfo, open backend.Opener, path string) error {
+	dst, err := open(ctx, path)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	return db.view(ctx, func(src backend.Tx) error {
+		return dst.Update(func(tx backend.Tx) error {
+			return src.ForEach(func(name []byte, b backend.Bucket) error {
+				nb, e := tx.CreateBucket(name)
+				if e != nil {
+					return e
+				}
+				op.Results++
+				return copyBucket(nb, b)
+			})
+		})
+	})
+}
+
+/*
+ * Check
+ * Verify the consistency of the DB file. Returns nil if it is consistent,
+ * otherwise an error listing all problems found. Backends which are not
+ * stored in a file are always consistent.
+ */
+func (db *DumbDB) Check() (err error) {
+	ctx, op := db.startOp(context.Background(), "Check", "", 0)
+	defer func() { db.finishOp(ctx, op, err) }()
+
+	problems := make([]string, 0)
+	err = db.view(ctx, func(tx backend.Tx) error {
+		for e := range tx.Check() {
+			problems = append(problems, e.Error())
+		}
+		return nil
+	})
+	if err == nil && len(problems) > 0 {
+		op.Results = len(problems)
+		err = errors.New("inconsistent database: " + strings.Join(problems, "; "))
+	}
+	return
+}
@@ -0,0 +1,158 @@
+/*
+ * Package metrics exports the statistics of a DumbDB to Prometheus.
+ *
+ *	prometheus.MustRegister(metrics.NewCollector(db))
+ *
+ * or, without a Prometheus registry of your own,
+ *
+ *	http.Handle("/metrics", metrics.Handler(db))
+ */
+package metrics
+
+import (
+	"net/http"
+
+	dDB "dumbDB"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const NAMESPACE = "dumbdb"
+
+/*
+ * Collector
+ * prometheus.Collector reading DumbDB.Stats on every scrape.
+ */
+type Collector struct {
+	db *dDB.DumbDB
+
+	operations  *prometheus.Desc
+	opDuration  *prometheus.Desc
+	txDuration  *prometheus.Desc
+	gets        *prometheus.Desc
+	hits        *prometheus.Desc
+	misses      *prometheus.Desc
+	stores      *prometheus.Desc
+	removes     *prometheus.Desc
+	scans       *prometheus.Desc
+	errors      *prometheus.Desc
+	bucketKeys  *prometheus.Desc
+	bucketBytes *prometheus.Desc
+	bucketAlloc *prometheus.Desc
+	fileSize    *prometheus.Desc
+	freePages   *prometheus.Desc
+	openTx      *prometheus.Desc
+}
+
+/*
+ * NewCollector
+ * Collector for db. All metrics are labelled with the DB file name so
+ * several DBs can be registered together.
+ */
+func NewCollector(db *dDB.DumbDB) *Collector {
+	labels := prometheus.Labels{"db": db.DbFullName}
+	desc := func(name, help string, variable ...string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(NAMESPACE, "", name), help, variable, labels)
+	}
+	return &Collector{
+		db:          db,
+		operations:  desc("operations_total", "Number of DumbDB operations.", "op"),
+		opDuration:  desc("operation_duration_seconds", "Latency of DumbDB operations.", "op"),
+		txDuration:  desc("transaction_duration_seconds", "Time spent in bolt transactions.", "type"),
+		gets:        desc("gets_total", "Number of point lookups."),
+		hits:        desc("hits_total", "Number of point lookups which found the key."),
+		misses:      desc("misses_total", "Number of point lookups which did not find the key."),
+		stores:      desc("stores_total", "Number of stored records."),
+		removes:     desc("removes_total", "Number of removed records and buckets."),
+		scans:       desc("scans_total", "Number of bucket scans."),
+		errors:      desc("errors_total", "Number of failed operations."),
+		bucketKeys:  desc("bucket_keys", "Number of keys in a bucket.", "bucket"),
+		bucketBytes: desc("bucket_size_bytes", "Bytes used by a bucket.", "bucket"),
+		bucketAlloc: desc("bucket_allocated_bytes", "Bytes allocated for the pages of a bucket.", "bucket"),
+		fileSize:    desc("file_size_bytes", "Size of the DB file."),
+		freePages:   desc("free_pages", "Number of free pages in the DB file."),
+		openTx:      desc("open_read_transactions", "Number of open read transactions."),
+	}
+}
+
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{
+		c.operations, c.opDuration, c.txDuration,
+		c.gets, c.hits, c.misses, c.stores, c.removes, c.scans, c.errors,
+		c.bucketKeys, c.bucketBytes, c.bucketAlloc,
+		c.fileSize, c.freePages, c.openTx,
+	} {
+		ch <- d
+	}
+}
+
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	st, err := c.db.Stats()
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.fileSize, err)
+		return
+	}
+
+	for op, l := range st.Ops.Latency {
+		ch <- prometheus.MustNewConstMetric(c.operations, prometheus.CounterValue, float64(l.Count), op)
+		ch <- histogram(c.opDuration, l, op)
+	}
+	for typ, l := range st.Ops.Transactions {
+		ch <- histogram(c.txDuration, l, typ)
+	}
+
+	counters := []struct {
+		d *prometheus.Desc
+		v uint64
+	}{
+		{c.gets, st.Ops.Gets},
+		{c.hits, st.Ops.Hits},
+		{c.misses, st.Ops.Misses},
+		{c.stores, st.Ops.Stores},
+		{c.removes, st.Ops.Removes},
+		{c.scans, st.Ops.Scans},
+		{c.errors, st.Ops.Errors},
+	}
+	for _, cnt := range counters {
+		ch <- prometheus.MustNewConstMetric(cnt.d, prometheus.CounterValue, float64(cnt.v))
+	}
+
+	for name, b := range st.Buckets {
+		ch <- prometheus.MustNewConstMetric(c.bucketKeys, prometheus.GaugeValue, float64(b.KeyN), name)
+		ch <- prometheus.MustNewConstMetric(c.bucketBytes, prometheus.GaugeValue, float64(b.InUse), name)
+		ch <- prometheus.MustNewConstMetric(c.bucketAlloc, prometheus.GaugeValue, float64(b.Allocated), name)
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.fileSize, prometheus.GaugeValue, float64(st.FileSize))
+	ch <- prometheus.MustNewConstMetric(c.freePages, prometheus.GaugeValue, float64(st.Pages.FreePageN))
+	ch <- prometheus.MustNewConstMetric(c.openTx, prometheus.GaugeValue, float64(st.Pages.OpenTxN))
+}
+
+/*
+ * histogram
+ * Convert dumbDB latency buckets to a cumulative Prometheus histogram.
+ */
+func histogram(d *prometheus.Desc, l dDB.LatencyStats, label string) prometheus.Metric {
+	buckets := make(map[float64]uint64, len(dDB.LATENCY_BUCKETS))
+	var cumulative uint64
+	for i, bound := range dDB.LATENCY_BUCKETS {
+		if i < len(l.Buckets) {
+			cumulative += l.Buckets[i]
+		}
+		buckets[bound.Seconds()] = cumulative
+	}
+	return prometheus.MustNewConstHistogram(d, l.Count, l.Total.Seconds(), buckets, label)
+}
+
+/*
+ * Handler
+ * http.Handler serving the metrics of the given DBs in the Prometheus text
+ * format, or OpenMetrics if the scraper asks for it.
+ */
+func Handler(dbs ...*dDB.DumbDB) http.Handler {
+	reg := prometheus.NewRegistry()
+	for _, db := range dbs {
+		reg.MustRegister(NewCollector(db))
+	}
+	return promhttp.HandlerFor(reg, promhttp.HandlerOpts{EnableOpenMetrics: true})
+}
@@ -0,0 +1,364 @@
+/*
+ * Package mobile wraps DumbDB in types which can cross the gomobile bind
+ * boundary. Only strings, []byte, int, bool, pointers to the structs of this
+ * package and its interfaces are used in the exported API.
+ *
+ *	gomobile bind -target=android dumbDB/mobile
+ */
+package mobile
+
+import (
+	"encoding/json"
+	"errors"
+
+	dDB "dumbDB"
+)
+
+// Change operations passed to WatchCallback.OnChange.
+const (
+	OpPut          = int(dDB.ChangePut)
+	OpDelete       = int(dDB.ChangeDelete)
+	OpDeleteBucket = int(dDB.ChangeDeleteBucket)
+)
+
+// Records fetched at a time by a KVIterator.
+const ITERATOR_PAGE_SIZE = 100
+
+var ErrClosed = errors.New("database closed")
+var ErrNilRecordList = errors.New("record list is nil")
+
+/*
+ * DB
+ * A DumbDB opened for use from Java/Kotlin or ObjC/Swift.
+ */
+type DB struct {
+	db *dDB.DumbDB
+}
+
+/*
+ * Open
+ * Open the DB file <dir>/<name>.dumbDB. Logs are discarded.
+ */
+func Open(dir string, name string) (*DB, error) {
+	db := dDB.NewDumbDB(dir, name, nil)
+	if db == nil {
+		return nil, errors.New("failed to open database " + dir + "/" + name)
+	}
+	return &DB{db: db}, nil
+}
+
+func (d *DB) Close() error {
+	if d.db == nil {
+		return ErrClosed
+	}
+	err := d.db.Close()
+	d.db = nil
+	return err
+}
+
+func (d *DB) Path() string {
+	if d.db == nil {
+		return ""
+	}
+	return d.db.DbFullName
+}
+
+func (d *DB) Get(bucket string, key []byte) ([]byte, error) {
+	if d.db == nil {
+		return nil, ErrClosed
+	}
+	return d.db.Get(key, bucket)
+}
+
+func (d *DB) GetString(bucket string, key string) (string, error) {
+	v, err := d.Get(bucket, []byte(key))
+	return string(v), err
+}
+
+/*
+ * GetMultiple
+ * Values for all keys in a RecordList, fails if any key is missing and
+ * with ErrNilRecordList if keys is nil.
+ */
+func (d *DB) GetMultiple(bucket string, keys *RecordList) (*RecordList, error) {
+	if d.db == nil {
+		return nil, ErrClosed
+	}
+	if keys == nil {
+		return nil, ErrNilRecordList
+	}
+	values, err := d.db.GetMultiple(keys.records, bucket)
+	if err != nil {
+		return nil, err
+	}
+	return &RecordList{records: values}, nil
+}
+
+func (d *DB) Put(bucket string, key []byte, value []byte) error {
+	if d.db == nil {
+		return ErrClosed
+	}
+	return d.db.Store([][]byte{key, value}, bucket)
+}
+
+func (d *DB) PutString(bucket string, key string, value string) error {
+	return d.Put(bucket, []byte(key), []byte(value))
+}
+
+func (d *DB) Remove(bucket string, key []byte) error {
+	if d.db == nil {
+		return ErrClosed
+	}
+	return d.db.Remove(key, bucket)
+}
+
+func (d *DB) RemoveBucket(bucket string) error {
+	if d.db == nil {
+		return ErrClosed
+	}
+	return d.db.RemoveBucket(bucket)
+}
+
+/*
+ * GetAll
+ * All values of a bucket, last key first like DumbDB.GetAll.
+ */
+func (d *DB) GetAll(bucket string) (*RecordList, error) {
+	if d.db == nil {
+		return nil, ErrClosed
+	}
+	values, err := d.db.GetAll(bucket)
+	if err != nil {
+		return nil, err
+	}
+	return &RecordList{records: values}, nil
+}
+
+/*
+ * GetLimited
+ * See DumbDB.GetLimited. cookie can be empty to start from the last key.
+ */
+func (d *DB) GetLimited(bucket string, size int, cookie []byte) (*RecordList, error) {
+	if d.db == nil {
+		return nil, ErrClosed
+	}
+	if len(cookie) == 0 {
+		cookie = nil
+	}
+	values, err := d.db.GetLimited(bucket, size, cookie)
+	if err != nil {
+		return nil, err
+	}
+	records := make([][]byte, 0, len(values))
+	for _, v := range values {
+		if v != nil {
+			records = append(records, v)
+		}
+	}
+	return &RecordList{records: records}, nil
+}
+
+/*
+ * Scan
+ * Iterator over the records of a bucket with keys starting with prefix.
+ * limit 0 means no limit. Records are fetched in pages as the iterator
+ * advances, no transaction is kept open in between.
+ */
+func (d *DB) Scan(bucket string, prefix []byte, reverse bool, limit int) (*KVIterator, error) {
+	if d.db == nil {
+		return nil, ErrClosed
+	}
+	it := &KVIterator{
+		db:     d.db,
+		bucket: bucket,
+		opts:   dDB.ScanOptions{Prefix: prefix, Reverse: reverse},
+		limit:  limit,
+		pos:    -1,
+	}
+	if err := it.fetch(); err != nil {
+		return nil, err
+	}
+	return it, nil
+}
+
+/*
+ * StatsJSON
+ * DumbDB.Stats encoded as JSON.
+ */
+func (d *DB) StatsJSON() (string, error) {
+	if d.db == nil {
+		return "", ErrClosed
+	}
+	st, err := d.db.Stats()
+	if err != nil {
+		return "", err
+	}
+	b, err := json.Marshal(st)
+	return string(b), err
+}
+
+/*
+ * WatchCallback
+ * Implemented in Java/Kotlin or ObjC/Swift to receive changes.
+ * op is one of OpPut, OpDelete or OpDeleteBucket.
+ */
+type WatchCallback interface {
+	OnChange(op int, bucket string, key []byte, value []byte)
+}
+
+type Watch struct {
+	cancel func()
+}
+
+/*
+ * Watch
+ * Call cb for every committed change to bucket ("" for all buckets) with
+ * keys starting with prefix. cb is called on a background thread.
+ */
+func (d *DB) Watch(bucket string, prefix []byte, cb WatchCallback) (*Watch, error) {
+	if d.db == nil {
+		return nil, ErrClosed
+	}
+	cancel := d.db.Watch(bucket, prefix, func(c dDB.Change) {
+		cb.OnChange(int(c.Op), c.Bucket, c.Key, c.Value)
+	})
+	return &Watch{cancel: cancel}, nil
+}
+
+func (w *Watch) Cancel() {
+	w.cancel()
+}
+
+/*
+ * RecordList
+ * List of byte slices, e.g. the values returned by GetAll.
+ */
+type RecordList struct {
+	records [][]byte
+}
+
+func NewRecordList() *RecordList {
+	return &RecordList{}
+}
+
+func (l *RecordList) Len() int {
+	return len(l.records)
+}
+
+/*
+ * Get
+ * Record at index i, nil if i is out of range.
+ */
+func (l *RecordList) Get(i int) []byte {
+	if i < 0 || i >= len(l.records) {
+		return nil
+	}
+	return l.records[i]
+}
+
+func (l *RecordList) Add(record []byte) {
+	l.records = append(l.records, append([]byte(nil), record...))
+}
+
+/*
+ * KVIterator
+ * Iterates over the records of a Scan.
+ *
+ *	for it.Next() { use(it.Key(), it.Value()) }
+ *	if err := it.Err(); ...
+ */
+type KVIterator struct {
+	db     *dDB.DumbDB
+	bucket string
+	opts   dDB.ScanOptions
+	limit  int
+
+	keys   [][]byte
+	values [][]byte
+	pos    int
+	seen   int
+	done   bool
+	err    error
+}
+
+/*
+ * fetch
+ * Load the next page of records after the last key returned.
+ */
+func (it *KVIterator) fetch() error {
+	it.keys, it.values, it.pos = nil, nil, -1
+
+	page := ITERATOR_PAGE_SIZE
+	if it.limit > 0 && it.limit-it.seen < page {
+		page = it.limit - it.seen
+	}
+	opts := it.opts
+	opts.Limit = page + 1
+	err := it.db.Scan(it.bucket, opts, func(k, v []byte) error {
+		it.keys = append(it.keys, append([]byte(nil), k...))
+		it.values = append(it.values, append([]byte(nil), v...))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// The extra record is where the next page starts.
+	if len(it.keys) > page {
+		if it.opts.Reverse {
+			it.opts.End = append(append([]byte(nil), it.keys[page]...), 0)
+		} else {
+			it.opts.Start = it.keys[page]
+		}
+		it.keys, it.values = it.keys[:page], it.values[:page]
+	} else {
+		it.done = true
+	}
+	return nil
+}
+
+/*
+ * Next
+ * Advance to the next record. Returns false at the end or on error.
+ */
+func (it *KVIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	if it.limit > 0 && it.seen >= it.limit {
+		return false
+	}
+	it.pos++
+	if it.pos >= len(it.keys) {
+		if it.done {
+			return false
+		}
+		if it.err = it.fetch(); it.err != nil {
+			return false
+		}
+		it.pos++
+		if it.pos >= len(it.keys) {
+			return false
+		}
+	}
+	it.seen++
+	return true
+}
+
+func (it *KVIterator) Key() []byte {
+	if it.pos < 0 || it.pos >= len(it.keys) {
+		return nil
+	}
+	return it.keys[it.pos]
+}
+
+func (it *KVIterator) Value() []byte {
+	if it.pos < 0 || it.pos >= len(it.values) {
+		return nil
+	}
+	return it.values[it.pos]
+}
+
+func (it *KVIterator) Err() error {
+	return it.err
+}
@@ -0,0 +1,76 @@
+package dumbDatabase
+
+import (
+	"context"
+
+	"dumbDB/backend"
+
+	"github.com/boltdb/bolt"
+)
+
+/*
+ * Modify
+ * Read-modify-write of a single key in one write transaction. fn gets the
+ * current value, nil if the key does not exist, and returns the new value.
+ * Returning a nil value deletes the key, returning an error aborts without
+ * writing. Writers are serialized, so fn is called exactly once and never
+ * has to be retried. old is only valid until fn returns.
+ * @param 	bucket		name of bucket, created if a value is stored
+ * @param 	key		byte slice containing key
+ * @param 	fn		computes the new value
+ */
+func (db *DumbDB) Modify(bucket string, key []byte, fn func(old []byte) ([]byte, error)) error {
+	return db.ModifyCtx(context.Background(), bucket, key, fn)
+}
+
+/*
+ * ModifyCtx
+ * Modify with a context passed to the operation hooks. Returns ctx.Err()
+ * if ctx is done before the operation completes.
+ */
+func (db *DumbDB) ModifyCtx(ctx context.Context, bucket string, key []byte,
+	fn func(old []byte) ([]byte, error)) (err error) {
+
+	ctx, op := db.startOp(ctx, "Modify", bucket, len(key))
+	defer func() { db.finishOp(ctx, op, err) }()
+
+	if db.read_only {
+		return bolt.ErrDatabaseReadOnly
+	}
+	if err = checkUserBucket(bucket); err != nil {
+		return err
+	}
+	err = db.update(ctx, func(tx backend.Tx) error {
+
+		if len(key) > MAX_KEY_LEN {
+			return bolt.ErrKeyTooLarge
+		}
+
+		var old []byte
+		bkt := tx.Bucket([]byte(bucket))
+		if bkt != nil {
+			old = bkt.Get(key)
+		}
+		val, e := fn(old)
+		if e != nil {
+			return e
+		}
+
+		if val == nil {
+			if old == nil {
+				return nil
+			}
+			op.Results = 1
+			return db.deleteRecord(ctx, tx, bkt, bucket, key)
+		}
+
+		if bkt == nil {
+			if bkt, e = tx.CreateBucketIfNotExists([]byte(bucket)); e != nil {
+				return e
+			}
+		}
+		op.Results = 1
+		return db.putRecord(ctx, tx, bkt, bucket, key, val)
+	})
+	return
+}
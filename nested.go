@@ -0,0 +1,353 @@
+package dumbDatabase
+
+import (
+	"encoding/binary"
+
+	"dumbDB/backend"
+	"dumbDB/keys"
+
+	"github.com/boltdb/bolt"
+)
+
+/*
+ * Nested
+ * A bucket nested in a user bucket, reached through the names in path.
+ * Nested buckets hold raw records: writes to them are replicated, but run
+ * no triggers, watchers or schema checks and are not indexed. Scans and
+ * reads of the parent bucket skip them, removing the parent bucket
+ * removes them.
+ */
+type Nested struct {
+	tx     *Tx
+	bucket string
+	path   [][]byte
+}
+
+/*
+ * Nested
+ * Handle to the bucket nested in bucket at path, which is created by the
+ * first Put.
+ * @param 	bucket		name of the top-level bucket
+ * @param 	path		names of the nested buckets leading to it
+ */
+func (t *Tx) Nested(bucket string, path ...[]byte) *Nested {
+	return &Nested{tx: t, bucket: bucket, path: path}
+}
+
+/*
+ * Nested
+ * Handle to the bucket nested in n at name.
+ */
+func (n *Nested) Nested(name []byte) *Nested {
+	path := append(append([][]byte(nil), n.path...), name)
+	return &Nested{tx: n.tx, bucket: n.bucket, path: path}
+}
+
+/*
+ * find
+ * The nested bucket, nil if it or one of its parents does not exist.
+ */
+func (n *Nested) find() backend.Bucket {
+	return findNested(n.tx.tx.Bucket([]byte(n.bucket)), n.path)
+}
+
+func findNested(b backend.Bucket, path [][]byte) backend.Bucket {
+	for _, name := range path {
+		if b == nil {
+			return nil
+		}
+		b = b.Bucket(name)
+	}
+	return b
+}
+
+/*
+ * checkWrite
+ * Error of a write through n, if any. Only the sequence of the top-level
+ * bucket itself may be written, records go through Tx.Store.
+ */
+func (n *Nested) checkWrite(top_ok bool) error {
+	if err := n.tx.ctx.Err(); err != nil {
+		return err
+	}
+	if !n.tx.tx.Writable() {
+		return bolt.ErrTxNotWritable
+	}
+	if err := checkUserBucket(n.bucket); err != nil {
+		return err
+	}
+	if len(n.path) == 0 && !top_ok {
+		return bolt.ErrBucketNameRequired
+	}
+	return nil
+}
+
+/*
+ * create
+ * The nested bucket, created with its parents if needed.
+ */
+func (n *Nested) create() (backend.Bucket, error) {
+	return createPath(n.tx.tx, []byte(n.bucket), n.path)
+}
+
+func createPath(tx backend.Tx, bucket []byte, path [][]byte) (backend.Bucket, error) {
+	bkt, err := tx.CreateBucketIfNotExists(bucket)
+	for _, name := range path {
+		if err != nil {
+			return nil, err
+		}
+		bkt, err = bkt.CreateBucketIfNotExists(name)
+	}
+	return bkt, err
+}
+
+/*
+ * logKey
+ * Key of the replication log entry of a write to key in n, the tuple of
+ * the bucket names in path followed by key if it is not nil.
+ */
+func (n *Nested) logKey(key []byte) []byte {
+	if len(n.path) == 0 && key == nil {
+		return nil
+	}
+	tuple := make([]interface{}, 0, len(n.path)+1)
+	for _, name := range n.path {
+		tuple = append(tuple, name)
+	}
+	if key != nil {
+		tuple = append(tuple, key)
+	}
+	return keys.MustEncode(tuple...)
+}
+
+/*
+ * Get
+ * Same as Tx.Get, for the nested bucket.
+ */
+func (n *Nested) Get(key []byte) ([]byte, error) {
+	if err := n.tx.ctx.Err(); err != nil {
+		return nil, err
+	}
+	bkt := n.find()
+	if bkt == nil {
+		return nil, bolt.ErrBucketNotFound
+	}
+	if val := bkt.Get(key); val != nil {
+		return val, nil
+	}
+	return nil, bolt.ErrKeyRequired
+}
+
+/*
+ * Scan
+ * Same as Tx.Scan, for the nested bucket.
+ */
+func (n *Nested) Scan(opts ScanOptions, fn func(key, val []byte) error) error {
+	if err := n.tx.ctx.Err(); err != nil {
+		return err
+	}
+	bkt := n.find()
+	if bkt == nil {
+		return bolt.ErrBucketNotFound
+	}
+	return scanCursor(n.tx.ctx, bkt.Cursor(), opts, fn)
+}
+
+/*
+ * Buckets
+ * Names of the buckets nested in n, in byte order. Tx.Nested(bucket)
+ * without path lists those of the top-level bucket.
+ */
+func (n *Nested) Buckets() ([][]byte, error) {
+	if err := n.tx.ctx.Err(); err != nil {
+		return nil, err
+	}
+	bkt := n.find()
+	if bkt == nil {
+		return nil, bolt.ErrBucketNotFound
+	}
+	names := make([][]byte, 0)
+	c := bkt.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		if v == nil {
+			names = append(names, k)
+		}
+	}
+	return names, nil
+}
+
+/*
+ * Sequence
+ * Current sequence of the nested bucket, or of the top-level bucket for
+ * Tx.Nested(bucket) without path.
+ */
+func (n *Nested) Sequence() (uint64, error) {
+	if err := n.tx.ctx.Err(); err != nil {
+		return 0, err
+	}
+	bkt := n.find()
+	if bkt == nil {
+		return 0, bolt.ErrBucketNotFound
+	}
+	return bkt.Sequence(), nil
+}
+
+/*
+ * SetSequence
+ * Set the sequence returned by Sequence, creating the buckets if needed.
+ * Fails with bolt.ErrTxNotWritable in a View.
+ */
+func (n *Nested) SetSequence(seq uint64) error {
+	if err := n.checkWrite(true); err != nil {
+		return err
+	}
+	bkt, err := n.create()
+	if err != nil {
+		return err
+	}
+	if err = bkt.SetSequence(seq); err != nil {
+		return err
+	}
+	return n.tx.db.logWrite(n.tx.tx, opSequence, []byte(n.bucket), n.logKey(nil), seqKey(seq))
+}
+
+/*
+ * Put
+ * Set key to val, creating the nested bucket and its parents if needed.
+ * Fails with bolt.ErrTxNotWritable in a View.
+ */
+func (n *Nested) Put(key, val []byte) error {
+	if err := n.checkWrite(false); err != nil {
+		return err
+	}
+	if len(key) > MAX_KEY_LEN {
+		return bolt.ErrKeyTooLarge
+	}
+	bkt, err := n.create()
+	if err != nil {
+		return err
+	}
+	if err = bkt.Put(key, val); err != nil {
+		return err
+	}
+	return n.tx.db.logWrite(n.tx.tx, opNestedPut, []byte(n.bucket), n.logKey(key), val)
+}
+
+/*
+ * Delete
+ * Remove key. Fails with bolt.ErrBucketNotFound if the nested bucket does
+ * not exist, and with bolt.ErrTxNotWritable in a View.
+ */
+func (n *Nested) Delete(key []byte) error {
+	if err := n.checkWrite(false); err != nil {
+		return err
+	}
+	bkt := n.find()
+	if bkt == nil {
+		return bolt.ErrBucketNotFound
+	}
+	if bkt.Get(key) == nil {
+		return nil
+	}
+	if err := bkt.Delete(key); err != nil {
+		return err
+	}
+	return n.tx.db.logWrite(n.tx.tx, opNestedDelete, []byte(n.bucket), n.logKey(key), nil)
+}
+
+/*
+ * Drop
+ * Remove the nested bucket with everything in it. Fails with
+ * bolt.ErrBucketNotFound if it does not exist.
+ */
+func (n *Nested) Drop() error {
+	if err := n.checkWrite(false); err != nil {
+		return err
+	}
+	parent := findNested(n.tx.tx.Bucket([]byte(n.bucket)), n.path[:len(n.path)-1])
+	if parent == nil {
+		return bolt.ErrBucketNotFound
+	}
+	if err := parent.DeleteBucket(n.path[len(n.path)-1]); err != nil {
+		return err
+	}
+	return n.tx.db.logWrite(n.tx.tx, opNestedDeleteBucket, []byte(n.bucket), n.logKey(nil), nil)
+}
+
+/*
+ * logNames
+ * Bucket names, and record key, of the key of a log entry, see logKey.
+ */
+func logNames(key []byte) ([][]byte, error) {
+	tuple, err := keys.Decode(key)
+	if err != nil || len(tuple) == 0 {
+		return nil, ErrReplicationCorrupt
+	}
+	names := make([][]byte, len(tuple))
+	for i, v := range tuple {
+		var ok bool
+		if names[i], ok = v.([]byte); !ok {
+			return nil, ErrReplicationCorrupt
+		}
+	}
+	return names, nil
+}
+
+/*
+ * applySequence
+ * Apply a replicated sequence of a bucket, nested if the entry has a key.
+ */
+func applySequence(tx backend.Tx, e replEntry) error {
+	if len(e.val) != 8 || isHiddenBucket(e.bucket) {
+		return ErrReplicationCorrupt
+	}
+	var path [][]byte
+	if len(e.key) > 0 {
+		var err error
+		if path, err = logNames(e.key); err != nil {
+			return err
+		}
+	}
+	bkt, err := createPath(tx, e.bucket, path)
+	if err != nil {
+		return err
+	}
+	return bkt.SetSequence(binary.BigEndian.Uint64(e.val))
+}
+
+/*
+ * applyNested
+ * Apply a replicated write to a nested bucket.
+ */
+func applyNested(tx backend.Tx, e replEntry) error {
+	names, err := logNames(e.key)
+	if err != nil {
+		return err
+	}
+
+	switch e.op {
+	case opNestedPut:
+		if len(names) < 2 {
+			return ErrReplicationCorrupt
+		}
+		bkt, e2 := createPath(tx, e.bucket, names[:len(names)-1])
+		if e2 != nil {
+			return e2
+		}
+		return bkt.Put(names[len(names)-1], e.val)
+	case opNestedDelete:
+		if len(names) < 2 {
+			return ErrReplicationCorrupt
+		}
+		if bkt := findNested(tx.Bucket(e.bucket), names[:len(names)-1]); bkt != nil {
+			return bkt.Delete(names[len(names)-1])
+		}
+	case opNestedDeleteBucket:
+		if parent := findNested(tx.Bucket(e.bucket), names[:len(names)-1]); parent != nil {
+			if e2 := parent.DeleteBucket(names[len(names)-1]); e2 != nil && e2 != bolt.ErrBucketNotFound {
+				return e2
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package dumbDatabase
+
+import (
+	"context"
+	"time"
+)
+
+/*
+ * OpInfo
+ * Describes a DumbDB operation to an OpHook. Err, Results and Duration are
+ * only set when After is called.
+ */
+type OpInfo struct {
+	// Name of the method, e.g. "Get", "Store", "GetAll"
+	Op     string
+	Bucket string
+	// Total length of the keys passed to the operation
+	KeyLen int
+	Start  time.Time
+
+	Results  int
+	Err      error
+	Duration time.Duration
+
+	hook_ctx []context.Context
+}
+
+/*
+ * OpHook
+ * Middleware called around every public DumbDB operation. The context
+ * returned by Before is the one passed to After for this hook, which lets
+ * tracing hooks keep their span in it.
+ */
+type OpHook interface {
+	Before(ctx context.Context, op *OpInfo) context.Context
+	After(ctx context.Context, op *OpInfo)
+}
+
+/*
+ * AddOpHook
+ * Register a hook called around every operation. Hooks are called in the
+ * order they were added, and After in reverse order.
+ * Should be called before the DB is shared between goroutines.
+ */
+func (db *DumbDB) AddOpHook(h OpHook) {
+	db.op_hooks = append(db.op_hooks, h)
+}
+
+/*
+ * startOp
+ * Called at the start of every operation to run the Before hooks.
+ */
+func (db *DumbDB) startOp(ctx context.Context, name string, bucket string, key_len int) (context.Context, *OpInfo) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	op := &OpInfo{Op: name, Bucket: bucket, KeyLen: key_len, Start: time.Now()}
+	if len(db.op_hooks) > 0 {
+		op.hook_ctx = make([]context.Context, len(db.op_hooks))
+		for i, h := range db.op_hooks {
+			ctx = h.Before(ctx, op)
+			op.hook_ctx[i] = ctx
+		}
+	}
+	return ctx, op
+}
+
+/*
+ * finishOp
+ * Called at the end of every operation to update the counters, log it
+ * and run the After hooks.
+ */
+func (db *DumbDB) finishOp(ctx context.Context, op *OpInfo, err error, kv ...interface{}) {
+	op.Err = err
+	op.Duration = time.Since(op.Start)
+	db.counters.record(op.Op, op.Duration, err)
+	if op.Results > 0 {
+		kv = append(kv, "results", op.Results)
+	}
+	db.logOp(op.Op, op.Bucket, op.Start, err, kv...)
+	for i := len(op.hook_ctx) - 1; i >= 0; i-- {
+		db.op_hooks[i].After(op.hook_ctx[i], op)
+	}
+}
@@ -0,0 +1,37 @@
+package dumbDatabase
+
+import (
+	"dumbDB/backend"
+	"dumbDB/backend/boltdb"
+)
+
+/*
+ * Option
+ * Configures a DB when it is opened, see NewDumbDB.
+ */
+type Option func(*config)
+
+type config struct {
+	opener backend.Opener
+}
+
+func newConfig(opts []Option) *config {
+	c := &config{opener: boltdb.Open}
+	for _, o := range opts {
+		o(c)
+	}
+	return c
+}
+
+/*
+ * WithBackend
+ * Store the DB with the given storage backend instead of boltdb/bolt.
+ * @param 	open		opener of the backend, e.g. bbolt.Open or memory.Open
+ */
+func WithBackend(open backend.Opener) Option {
+	return func(c *config) {
+		if open != nil {
+			c.opener = open
+		}
+	}
+}
@@ -0,0 +1,640 @@
+package dumbDatabase
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"dumbDB/backend"
+	"dumbDB/keys"
+
+	"github.com/boltdb/bolt"
+)
+
+var (
+	// Returned by Query methods for an unknown operator or a bad value.
+	ErrInvalidQuery = errors.New("invalid query")
+)
+
+/*
+ * Document
+ * A record returned by a Query, with its value decoded from JSON. Numbers
+ * are float64 as with encoding/json.
+ */
+type Document struct {
+	Key   []byte
+	Value map[string]interface{}
+}
+
+type condition struct {
+	field string
+	op    string
+	value interface{}
+}
+
+type ordering struct {
+	field string
+	desc  bool
+}
+
+/*
+ * Query
+ * Finds the JSON objects of a bucket matching all its conditions. Built
+ * with DumbDB.Query and run with All, Each, Count or Decode. A Query
+ * uses an index created with CreateIndex for one of its conditions when
+ * there is one, and scans the bucket otherwise. Records which are not
+ * JSON objects never match. Without OrderBy, records come in the order of
+ * the index or of their keys.
+ */
+type Query struct {
+	db     *DumbDB
+	bucket string
+	conds  []condition
+	order  []ordering
+	fields []string
+	// Field aggregated groups are keyed by
+	group_by string
+	limit    int
+	offset   int
+	err      error
+}
+
+/*
+ * Query
+ * Start a query on the JSON objects of bucket.
+ * db.Query("Users").Where("Position", "=", "Engineer").OrderBy("Name").Limit(10)
+ * @param 	bucket		name of bucket
+ */
+func (db *DumbDB) Query(bucket string) *Query {
+	return &Query{db: db, bucket: bucket}
+}
+
+/*
+ * Where
+ * Only records whose field compares to value with op. op is one of =, !=,
+ * <, <=, >, >=, in (value is a slice) and prefix (value is a string).
+ * value is compared after conversion to JSON, so any numeric type matches
+ * JSON numbers. <, <=, > and >= only match values of the same JSON type.
+ * A record without the field never matches.
+ * @param 	field		name of the field, nested fields are separated by "."
+ * @param 	op		comparison operator
+ * @param 	value		value compared to
+ */
+func (q *Query) Where(field string, op string, value interface{}) *Query {
+	v, err := toJSONValue(value)
+	if err == nil {
+		err = checkCondition(op, v)
+	}
+	if err != nil {
+		q.setErr(fmt.Errorf("%w: %s %s: %s", ErrInvalidQuery, field, op, err.Error()))
+		return q
+	}
+	q.conds = append(q.conds, condition{field: field, op: op, value: v})
+	return q
+}
+
+/*
+ * OrderBy
+ * Sort results by field, ascending. Later calls sort records with equal
+ * values in the earlier fields. Records without the field come first,
+ * then null, booleans, numbers, strings, arrays and objects. Records which
+ * compare equal are returned in key order.
+ */
+func (q *Query) OrderBy(field string) *Query {
+	q.order = append(q.order, ordering{field: field})
+	return q
+}
+
+/*
+ * OrderByDesc
+ * OrderBy, descending.
+ */
+func (q *Query) OrderByDesc(field string) *Query {
+	q.order = append(q.order, ordering{field: field, desc: true})
+	return q
+}
+
+/*
+ * Limit
+ * Return at most n records, 0 for no limit.
+ */
+func (q *Query) Limit(n int) *Query {
+	q.limit = n
+	return q
+}
+
+/*
+ * Offset
+ * Skip the first n matching records.
+ */
+func (q *Query) Offset(n int) *Query {
+	q.offset = n
+	return q
+}
+
+/*
+ * Select
+ * Only return fields of the matching records. Nested fields keep their
+ * parent objects.
+ */
+func (q *Query) Select(fields ...string) *Query {
+	q.fields = append(q.fields, fields...)
+	return q
+}
+
+func (q *Query) setErr(err error) {
+	if q.err == nil {
+		q.err = err
+	}
+}
+
+/*
+ * All
+ * Run the query and return the matching records.
+ */
+func (q *Query) All() ([]Document, error) {
+	return q.AllCtx(context.Background())
+}
+
+/*
+ * AllCtx
+ * All with a context passed to the operation hooks. Returns ctx.Err()
+ * if ctx is done before the query completes.
+ */
+func (q *Query) AllCtx(ctx context.Context) ([]Document, error) {
+	docs := make([]Document, 0)
+	err := q.EachCtx(ctx, func(doc Document) error {
+		docs = append(docs, doc)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
+
+/*
+ * Each
+ * Run the query and call fn with every matching record, streaming them
+ * from the bucket unless the results have to be sorted in memory. fn runs
+ * inside a read transaction and must not write to the DB. Return
+ * ErrStopScan from fn to stop.
+ */
+func (q *Query) Each(fn func(doc Document) error) error {
+	return q.EachCtx(context.Background(), fn)
+}
+
+/*
+ * EachCtx
+ * Each with a context passed to the operation hooks.
+ */
+func (q *Query) EachCtx(ctx context.Context, fn func(doc Document) error) (err error) {
+	ctx, op := q.db.startOp(ctx, "Query", q.bucket, 0)
+	defer func() { q.db.finishOp(ctx, op, err) }()
+
+	if q.err != nil {
+		return q.err
+	}
+	err = q.db.view(ctx, func(tx backend.Tx) error {
+		return q.run(ctx, tx, func(doc Document) error {
+			op.Results++
+			return fn(doc)
+		})
+	})
+	if err == ErrStopScan {
+		err = nil
+	}
+	return
+}
+
+/*
+ * Count
+ * No of matching records, after Offset and Limit.
+ */
+func (q *Query) Count() (n int, err error) {
+	err = q.Each(func(Document) error {
+		n++
+		return nil
+	})
+	return
+}
+
+/*
+ * Decode
+ * Run the query and decode the matching records into dst, a pointer to a
+ * slice of structs or maps, with encoding/json.
+ * @param 	dst		e.g. *[]User
+ */
+func (q *Query) Decode(dst interface{}) error {
+	docs, err := q.All()
+	if err != nil {
+		return err
+	}
+	values := make([]map[string]interface{}, len(docs))
+	for i, d := range docs {
+		values[i] = d.Value
+	}
+	b, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, dst)
+}
+
+/*
+ * Explain
+ * How the query finds its records: "index <field>" or "scan".
+ */
+func (q *Query) Explain() (plan string, err error) {
+	if q.err != nil {
+		return "", q.err
+	}
+	err = q.db.view(context.Background(), func(tx backend.Tx) error {
+		fields, e := bucketIndexes(tx, q.bucket)
+		if e != nil {
+			return e
+		}
+		if c, _ := q.indexCondition(fields); c != nil {
+			plan = "index " + c.field
+		} else {
+			plan = "scan"
+		}
+		return nil
+	})
+	return
+}
+
+/*
+ * run
+ * Find, filter, sort, page and project the records.
+ */
+func (q *Query) run(ctx context.Context, tx backend.Tx, fn func(Document) error) error {
+	bkt := tx.Bucket([]byte(q.bucket))
+	if bkt == nil {
+		return bolt.ErrBucketNotFound
+	}
+	fields, err := bucketIndexes(tx, q.bucket)
+	if err != nil {
+		return err
+	}
+	c, ranges := q.indexCondition(fields)
+	var idx backend.Bucket
+	if c != nil {
+		if idx = tx.Bucket(indexBucket(q.bucket)); idx == nil {
+			return nil
+		}
+	}
+	sorted := len(q.order) == 0 ||
+		(c != nil && len(q.order) == 1 && q.order[0].field == c.field && !q.order[0].desc &&
+			!hasLongStrings(idx, c.field))
+
+	// scanCursor returns nil for ErrStopScan, stopped ends the index ranges
+	skipped, sent, stopped := 0, 0, false
+	emit := func(doc Document) error {
+		if skipped < q.offset {
+			skipped++
+			return nil
+		}
+		if q.limit > 0 && sent >= q.limit {
+			stopped = true
+			return ErrStopScan
+		}
+		sent++
+		e := fn(q.project(doc))
+		stopped = e == ErrStopScan
+		return e
+	}
+
+	var matched []Document
+	visit := func(k, v []byte) error {
+		doc := decodeObject(v)
+		if doc == nil || !q.matches(doc) {
+			return nil
+		}
+		d := Document{Key: bytes.Clone(k), Value: doc}
+		if sorted {
+			return emit(d)
+		}
+		matched = append(matched, d)
+		return nil
+	}
+
+	if c != nil {
+		for _, r := range ranges {
+			if stopped {
+				break
+			}
+			err = scanCursor(ctx, idx.Cursor(), r, func(entry, _ []byte) error {
+				k, e := indexedKey(entry)
+				if e != nil {
+					return e
+				}
+				if v := bkt.Get(k); v != nil {
+					return visit(k, v)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+	} else if err = scanCursor(ctx, bkt.Cursor(), ScanOptions{}, visit); err != nil {
+		return err
+	}
+
+	if sorted {
+		return nil
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		return q.less(matched[i], matched[j])
+	})
+	for _, d := range matched {
+		if err = emit(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+ * indexCondition
+ * The condition answered from an index, with the index ranges it covers
+ * in index order, nil if no condition can use one. Equality is preferred
+ * over ranges.
+ */
+func (q *Query) indexCondition(fields []string) (*condition, []ScanOptions) {
+	indexed := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		indexed[f] = true
+	}
+	var best *condition
+	var best_ranges []ScanOptions
+	for i := range q.conds {
+		c := &q.conds[i]
+		if !indexed[c.field] {
+			continue
+		}
+		ranges, ok := c.indexRanges()
+		if !ok {
+			continue
+		}
+		if c.op == "=" || c.op == "in" {
+			return c, ranges
+		}
+		if best == nil {
+			best, best_ranges = c, ranges
+		}
+	}
+	return best, best_ranges
+}
+
+/*
+ * indexRanges
+ * Ranges of index entries whose values may match c, ok is false if the
+ * index can not answer c. Conditions on strings include the entries of
+ * long strings.
+ */
+func (c *condition) indexRanges() (ranges []ScanOptions, ok bool) {
+	long := ScanOptions{Prefix: keys.MustEncode(c.field, RANK_LONG_STRING)}
+	if c.op == "in" {
+		strs := false
+		for _, v := range c.value.([]interface{}) {
+			tuple, ok := indexTuple(c.field, v)
+			if !ok {
+				return nil, false
+			}
+			ranges = append(ranges, ScanOptions{Prefix: keys.MustEncode(tuple...)})
+			strs = strs || tuple[1] == RANK_STRING
+		}
+		if strs {
+			ranges = append(ranges, long)
+		}
+		sort.Slice(ranges, func(i, j int) bool {
+			return bytes.Compare(ranges[i].Prefix, ranges[j].Prefix) < 0
+		})
+		// Equal values would return their records twice
+		uniq := ranges[:0]
+		for i, r := range ranges {
+			if i == 0 || !bytes.Equal(r.Prefix, ranges[i-1].Prefix) {
+				uniq = append(uniq, r)
+			}
+		}
+		return uniq, true
+	}
+
+	tuple, ok := indexTuple(c.field, c.value)
+	if !ok {
+		return nil, false
+	}
+	at := keys.MustEncode(tuple...)
+	rank := keys.MustEncode(tuple[:2]...)
+	switch c.op {
+	case "=":
+		ranges = []ScanOptions{{Prefix: at}}
+	case "prefix":
+		// The encoded string without its terminator
+		ranges = []ScanOptions{{Prefix: at[:len(at)-1]}}
+	}
+	if ranges == nil && len(tuple) < 3 {
+		// null is only equal to itself
+		return nil, false
+	}
+	switch c.op {
+	case "<":
+		ranges = []ScanOptions{{Start: rank, End: at}}
+	case "<=":
+		ranges = []ScanOptions{{Start: rank, End: keys.PrefixEnd(at)}}
+	case ">":
+		ranges = []ScanOptions{{Start: keys.PrefixEnd(at), End: keys.PrefixEnd(rank)}}
+	case ">=":
+		ranges = []ScanOptions{{Start: at, End: keys.PrefixEnd(rank)}}
+	}
+	if ranges == nil {
+		return nil, false
+	}
+	if tuple[1] == RANK_STRING {
+		ranges = append(ranges, long)
+	}
+	return ranges, true
+}
+
+func (q *Query) matches(doc map[string]interface{}) bool {
+	for _, c := range q.conds {
+		if !c.matches(doc) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *condition) matches(doc map[string]interface{}) bool {
+	v, ok := fieldValue(doc, c.field)
+	if !ok {
+		return false
+	}
+	switch c.op {
+	case "=":
+		return equalJSON(v, c.value)
+	case "!=":
+		return !equalJSON(v, c.value)
+	case "in":
+		return containsJSON(c.value.([]interface{}), v)
+	case "prefix":
+		s, ok := v.(string)
+		return ok && strings.HasPrefix(s, c.value.(string))
+	}
+	cmp, ok := compareScalars(v, c.value)
+	if !ok {
+		return false
+	}
+	switch c.op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	}
+	return cmp >= 0
+}
+
+func checkCondition(op string, v interface{}) error {
+	switch op {
+	case "=", "!=", "<", "<=", ">", ">=":
+		return nil
+	case "in":
+		if _, ok := v.([]interface{}); !ok {
+			return fmt.Errorf("value must be a slice")
+		}
+		return nil
+	case "prefix":
+		if _, ok := v.(string); !ok {
+			return fmt.Errorf("value must be a string")
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown operator")
+}
+
+/*
+ * toJSONValue
+ * v as encoding/json would decode it from its JSON.
+ */
+func toJSONValue(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out interface{}
+	err = json.Unmarshal(b, &out)
+	return out, err
+}
+
+/*
+ * compareScalars
+ * Order of two booleans, numbers or strings. ok is false for other values
+ * or values of different types.
+ */
+func compareScalars(a, b interface{}) (cmp int, ok bool) {
+	switch a := a.(type) {
+	case bool:
+		b, ok := b.(bool)
+		if !ok {
+			return 0, false
+		}
+		if a == b {
+			return 0, true
+		}
+		if !a {
+			return -1, true
+		}
+		return 1, true
+	case float64:
+		b, ok := b.(float64)
+		if !ok {
+			return 0, false
+		}
+		if a < b {
+			return -1, true
+		}
+		if a > b {
+			return 1, true
+		}
+		return 0, true
+	case string:
+		b, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(a, b), true
+	}
+	return 0, false
+}
+
+func sortRank(v interface{}, ok bool) int {
+	if !ok {
+		return -1
+	}
+	switch v.(type) {
+	case nil:
+		return int(RANK_NULL)
+	case bool:
+		return int(RANK_BOOL)
+	case float64:
+		return int(RANK_NUMBER)
+	case string:
+		return int(RANK_STRING)
+	case []interface{}:
+		return int(RANK_STRING) + 1
+	}
+	return int(RANK_STRING) + 2
+}
+
+func (q *Query) less(a, b Document) bool {
+	for _, o := range q.order {
+		va, oka := fieldValue(a.Value, o.field)
+		vb, okb := fieldValue(b.Value, o.field)
+		cmp := sortRank(va, oka) - sortRank(vb, okb)
+		if cmp == 0 {
+			cmp, _ = compareScalars(va, vb)
+		}
+		if cmp == 0 {
+			continue
+		}
+		return (cmp < 0) != o.desc
+	}
+	return false
+}
+
+/*
+ * project
+ * doc with only the selected fields.
+ */
+func (q *Query) project(doc Document) Document {
+	if len(q.fields) == 0 {
+		return doc
+	}
+	value := make(map[string]interface{})
+	for _, f := range q.fields {
+		v, ok := fieldValue(doc.Value, f)
+		if !ok {
+			continue
+		}
+		names := strings.Split(f, ".")
+		obj := value
+		for _, name := range names[:len(names)-1] {
+			child, ok := obj[name].(map[string]interface{})
+			if !ok {
+				child = make(map[string]interface{})
+				obj[name] = child
+			}
+			obj = child
+		}
+		obj[names[len(names)-1]] = v
+	}
+	return Document{Key: doc.Key, Value: value}
+}
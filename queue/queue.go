@@ -0,0 +1,459 @@
+/*
+ * Package queue is a durable work queue stored in a dumbDB bucket. Messages
+ * survive restarts and are delivered at least once: a dequeued message is
+ * leased for a visibility timeout and delivered again unless it is acked
+ * before the lease ends. Messages failing too often are moved to a
+ * dead-letter bucket.
+ *
+ *	q := queue.New(db, "Uploads", queue.Options{})
+ *	q.Enqueue(request)
+ *
+ *	lease, err := q.DequeueWait(ctx)
+ *	if upload(lease.Body) == nil {
+ *		q.Ack(lease)
+ *	} else {
+ *		q.Nack(lease)
+ *	}
+ */
+package queue
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"time"
+
+	dDB "dumbDB"
+	"dumbDB/keys"
+
+	"github.com/boltdb/bolt"
+)
+
+const (
+	DEFAULT_VISIBILITY   = 30 * time.Second
+	DEFAULT_MAX_ATTEMPTS = 5
+	// Backoff after the first failure, doubled after each further one
+	DEFAULT_BACKOFF     = time.Second
+	DEFAULT_MAX_BACKOFF = 5 * time.Minute
+	// Suffix of the default dead-letter bucket
+	DEAD_LETTER_SUFFIX = "_dead"
+)
+
+var (
+	// Returned by Dequeue when no message is visible.
+	ErrEmpty = errors.New("queue is empty")
+
+	// Returned by Ack and Nack when the message was delivered again after
+	// the lease ended, or was already acked.
+	ErrLeaseExpired = errors.New("lease expired")
+)
+
+// Keys in the queue bucket.
+const (
+	KEY_SEQ   = "seq"
+	KEY_MSG   = "msg"
+	KEY_READY = "ready"
+)
+
+/*
+ * Options
+ * Zero values select the defaults.
+ */
+type Options struct {
+	// How long a dequeued message is leased
+	Visibility time.Duration
+	// Deliveries after which a failing message is dead-lettered
+	MaxAttempts int
+	// Delay before a nacked message is visible again, by no of attempts
+	Backoff func(attempts int) time.Duration
+	// Bucket of dead-lettered messages, the queue bucket + DEAD_LETTER_SUFFIX
+	DeadLetter string
+}
+
+/*
+ * Message
+ * A message as stored in the queue and dead-letter buckets.
+ */
+type Message struct {
+	ID       uint64
+	Body     []byte
+	Attempts int
+	Enqueued time.Time
+	// When the message is visible to Dequeue, in Unix nanoseconds
+	Visible int64
+}
+
+/*
+ * Lease
+ * A dequeued message, to be acked or nacked before Deadline.
+ */
+type Lease struct {
+	Message
+	Deadline time.Time
+}
+
+type Queue struct {
+	db     *dDB.DumbDB
+	bucket string
+	opts   Options
+}
+
+/*
+ * New
+ * The queue stored in bucket.
+ * @param 	db		DB holding the queue
+ * @param 	bucket		name of bucket, only used by the queue
+ * @param 	opts		visibility, retries and dead-letter bucket
+ */
+func New(db *dDB.DumbDB, bucket string, opts Options) *Queue {
+	if opts.Visibility <= 0 {
+		opts.Visibility = DEFAULT_VISIBILITY
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = DEFAULT_MAX_ATTEMPTS
+	}
+	if opts.Backoff == nil {
+		opts.Backoff = ExponentialBackoff(DEFAULT_BACKOFF, DEFAULT_MAX_BACKOFF)
+	}
+	if opts.DeadLetter == "" {
+		opts.DeadLetter = bucket + DEAD_LETTER_SUFFIX
+	}
+	return &Queue{db: db, bucket: bucket, opts: opts}
+}
+
+/*
+ * ExponentialBackoff
+ * base after the first attempt, doubled after each further one, up to max.
+ */
+func ExponentialBackoff(base, max time.Duration) func(attempts int) time.Duration {
+	return func(attempts int) time.Duration {
+		d := base
+		for i := 1; i < attempts && d < max; i++ {
+			d *= 2
+		}
+		if d > max {
+			d = max
+		}
+		return d
+	}
+}
+
+func msgKey(id uint64) []byte {
+	return keys.MustEncode(KEY_MSG, id)
+}
+
+func readyKey(visible int64, id uint64) []byte {
+	return keys.MustEncode(KEY_READY, visible, id)
+}
+
+func (q *Queue) load(tx *dDB.Tx, id uint64) (*Message, error) {
+	v, err := tx.Get(msgKey(id), q.bucket)
+	if err == bolt.ErrKeyRequired || err == bolt.ErrBucketNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	m := new(Message)
+	return m, json.Unmarshal(v, m)
+}
+
+func (q *Queue) save(tx *dDB.Tx, bucket string, m *Message) error {
+	v, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return tx.Store([][]byte{msgKey(m.ID), v}, bucket)
+}
+
+/*
+ * schedule
+ * Save m, visible to Dequeue at the given time.
+ */
+func (q *Queue) schedule(tx *dDB.Tx, m *Message, visible int64) error {
+	m.Visible = visible
+	if err := q.save(tx, q.bucket, m); err != nil {
+		return err
+	}
+	return tx.Store([][]byte{readyKey(visible, m.ID), []byte{}}, q.bucket)
+}
+
+/*
+ * unschedule
+ * Remove m from the queue.
+ */
+func (q *Queue) unschedule(tx *dDB.Tx, m *Message) error {
+	if err := tx.Remove(readyKey(m.Visible, m.ID), q.bucket); err != nil {
+		return err
+	}
+	return tx.Remove(msgKey(m.ID), q.bucket)
+}
+
+/*
+ * Enqueue
+ * Add a message, visible to Dequeue right away. Returns its ID.
+ */
+func (q *Queue) Enqueue(body []byte) (uint64, error) {
+	return q.EnqueueAfter(body, 0)
+}
+
+/*
+ * EnqueueAfter
+ * Add a message, visible to Dequeue after delay. Returns its ID.
+ */
+func (q *Queue) EnqueueAfter(body []byte, delay time.Duration) (id uint64, err error) {
+	err = q.db.Update(func(tx *dDB.Tx) error {
+		seq := keys.MustEncode(KEY_SEQ)
+		if v, e := tx.Get(seq, q.bucket); e == nil && len(v) == 8 {
+			id = binary.BigEndian.Uint64(v)
+		}
+		id++
+		if e := tx.Store([][]byte{seq, binary.BigEndian.AppendUint64(nil, id)}, q.bucket); e != nil {
+			return e
+		}
+		if body == nil {
+			body = []byte{}
+		}
+		m := &Message{ID: id, Body: body, Enqueued: time.Now()}
+		return q.schedule(tx, m, time.Now().Add(delay).UnixNano())
+	})
+	return
+}
+
+/*
+ * Dequeue
+ * Lease the next visible message, fails with ErrEmpty if there is none.
+ * Messages whose lease ended after MaxAttempts deliveries are moved to the
+ * dead-letter bucket instead of being delivered again.
+ */
+func (q *Queue) Dequeue() (*Lease, error) {
+	var lease *Lease
+	err := q.db.Update(func(tx *dDB.Tx) error {
+		now := time.Now()
+		for {
+			m, e := q.next(tx, now.UnixNano())
+			if e != nil || m == nil {
+				return e
+			}
+			if m.Attempts >= q.opts.MaxAttempts {
+				if e = q.deadLetter(tx, m); e != nil {
+					return e
+				}
+				continue
+			}
+			if e = tx.Remove(readyKey(m.Visible, m.ID), q.bucket); e != nil {
+				return e
+			}
+			m.Attempts++
+			deadline := now.Add(q.opts.Visibility)
+			if e = q.schedule(tx, m, deadline.UnixNano()); e != nil {
+				return e
+			}
+			lease = &Lease{Message: *m, Deadline: deadline}
+			return nil
+		}
+	})
+	if err == nil && lease == nil {
+		err = ErrEmpty
+	}
+	return lease, err
+}
+
+/*
+ * next
+ * First message visible at now, nil if there is none.
+ */
+func (q *Queue) next(tx *dDB.Tx, now int64) (*Message, error) {
+	var id uint64
+	opts := dDB.ScanOptions{
+		Start: keys.MustEncode(KEY_READY),
+		End:   keys.PrefixEnd(keys.MustEncode(KEY_READY, now)),
+		Limit: 1,
+	}
+	err := tx.Scan(q.bucket, opts, func(k, _ []byte) error {
+		tuple, e := keys.Decode(k)
+		if e != nil {
+			return e
+		}
+		var ok bool
+		if len(tuple) != 3 {
+			return keys.ErrInvalidKey
+		}
+		if id, ok = tuple[2].(uint64); !ok || id == 0 {
+			return keys.ErrInvalidKey
+		}
+		return nil
+	})
+	if err == bolt.ErrBucketNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if id == 0 {
+		return nil, nil
+	}
+	return q.load(tx, id)
+}
+
+func (q *Queue) deadLetter(tx *dDB.Tx, m *Message) error {
+	if err := q.unschedule(tx, m); err != nil {
+		return err
+	}
+	return q.save(tx, q.opts.DeadLetter, m)
+}
+
+/*
+ * DequeueWait
+ * Dequeue which waits for a message to become visible until ctx is done.
+ */
+func (q *Queue) DequeueWait(ctx context.Context) (*Lease, error) {
+	wake := make(chan struct{}, 1)
+	cancel := q.db.Watch(q.bucket, keys.MustEncode(KEY_READY), func(dDB.Change) {
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	})
+	defer cancel()
+
+	for {
+		lease, err := q.Dequeue()
+		if err != ErrEmpty {
+			return lease, err
+		}
+
+		// Wake up when a message is added or the next one becomes visible.
+		wait := q.opts.Visibility
+		if next, e := q.nextVisible(); e != nil {
+			return nil, e
+		} else if !next.IsZero() {
+			wait = time.Until(next)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-wake:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+/*
+ * nextVisible
+ * When the earliest scheduled message becomes visible, zero if the queue
+ * is empty.
+ */
+func (q *Queue) nextVisible() (next time.Time, err error) {
+	err = q.db.View(func(tx *dDB.Tx) error {
+		opts := dDB.ScanOptions{Prefix: keys.MustEncode(KEY_READY), Limit: 1}
+		e := tx.Scan(q.bucket, opts, func(k, _ []byte) error {
+			tuple, e := keys.Decode(k)
+			if e != nil {
+				return e
+			}
+			next = time.Unix(0, tuple[1].(int64))
+			return nil
+		})
+		if e == bolt.ErrBucketNotFound {
+			return nil
+		}
+		return e
+	})
+	return
+}
+
+/*
+ * leased
+ * The message of l if l is still its current lease.
+ */
+func (q *Queue) leased(tx *dDB.Tx, l *Lease) (*Message, error) {
+	m, err := q.load(tx, l.ID)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil || m.Attempts != l.Attempts {
+		return nil, ErrLeaseExpired
+	}
+	return m, nil
+}
+
+/*
+ * Ack
+ * Remove a message which was processed. Fails with ErrLeaseExpired if it
+ * was delivered again in the meantime.
+ */
+func (q *Queue) Ack(l *Lease) error {
+	return q.db.Update(func(tx *dDB.Tx) error {
+		m, err := q.leased(tx, l)
+		if err != nil {
+			return err
+		}
+		return q.unschedule(tx, m)
+	})
+}
+
+/*
+ * Nack
+ * Return a message which failed. It is visible again after the backoff
+ * for its no of attempts, or moved to the dead-letter bucket after
+ * MaxAttempts. Fails with ErrLeaseExpired if it was delivered again in the
+ * meantime.
+ */
+func (q *Queue) Nack(l *Lease) error {
+	return q.db.Update(func(tx *dDB.Tx) error {
+		m, err := q.leased(tx, l)
+		if err != nil {
+			return err
+		}
+		if m.Attempts >= q.opts.MaxAttempts {
+			return q.deadLetter(tx, m)
+		}
+		if err = tx.Remove(readyKey(m.Visible, m.ID), q.bucket); err != nil {
+			return err
+		}
+		return q.schedule(tx, m, time.Now().Add(q.opts.Backoff(m.Attempts)).UnixNano())
+	})
+}
+
+/*
+ * Len
+ * No of messages in the queue, leased ones included.
+ */
+func (q *Queue) Len() (n int, err error) {
+	err = q.db.View(func(tx *dDB.Tx) error {
+		e := tx.Scan(q.bucket, dDB.ScanOptions{Prefix: keys.MustEncode(KEY_READY)}, func(_, _ []byte) error {
+			n++
+			return nil
+		})
+		if e == bolt.ErrBucketNotFound {
+			return nil
+		}
+		return e
+	})
+	return
+}
+
+/*
+ * DeadLetters
+ * Messages moved to the dead-letter bucket, by ID.
+ */
+func (q *Queue) DeadLetters() ([]Message, error) {
+	msgs := make([]Message, 0)
+	err := q.db.Scan(q.opts.DeadLetter, dDB.ScanOptions{}, func(_, v []byte) error {
+		var m Message
+		if e := json.Unmarshal(v, &m); e != nil {
+			return e
+		}
+		msgs = append(msgs, m)
+		return nil
+	})
+	if err == bolt.ErrBucketNotFound {
+		err = nil
+	}
+	return msgs, err
+}
@@ -0,0 +1,911 @@
+package dumbDatabase
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"dumbDB/backend"
+	"dumbDB/keys"
+
+	"github.com/boltdb/bolt"
+)
+
+// Buckets starting with this prefix are used internally by dumbDB and are
+// never replicated or shown to the user.
+const HIDDEN_BUCKET_PREFIX = "__dumbDB_"
+
+const REPLICATION_LOG_BUCKET = HIDDEN_BUCKET_PREFIX + "replog"
+const META_BUCKET = HIDDEN_BUCKET_PREFIX + "meta"
+
+// Returned by writes to a bucket starting with HIDDEN_BUCKET_PREFIX.
+var ErrHiddenBucket = errors.New("bucket is reserved for dumbDB")
+
+// Number of log entries kept on the primary if not specified.
+const DEFAULT_RETAINED_ENTRIES = 10000
+
+// Interval after which an idle primary sends a heartbeat to the follower.
+const REPLICATION_HEARTBEAT = time.Second
+
+var (
+	// Returned by Follow when the stream skips entries the follower has not seen.
+	ErrReplicationGap = errors.New("replication stream has a gap")
+
+	// Returned by Replicate when the follower claims to be ahead of the primary.
+	ErrReplicationDiverged = errors.New("follower is ahead of primary")
+
+	// Returned when replication is used on a DB which was not set up for it.
+	ErrReplicationDisabled = errors.New("replication not enabled")
+
+	// Returned by Follow on a malformed stream.
+	ErrReplicationCorrupt = errors.New("corrupt replication stream")
+)
+
+var metaAppliedSeq = []byte("repl.applied")
+
+// Operations recorded in the replication log.
+const (
+	opPut          = byte(ChangePut)
+	opDelete       = byte(ChangeDelete)
+	opDeleteBucket = byte(ChangeDeleteBucket)
+)
+
+// Writes to nested buckets. The key of their entries is the tuple of the
+// nested bucket names, followed by the record key for puts and deletes.
+const (
+	opNestedPut byte = iota + 16
+	opNestedDelete
+	opNestedDeleteBucket
+)
+
+// Change of the META record key, defining an index, aggregate, schema or
+// full-text index of the entry's bucket. An empty value removes it.
+const opDefine byte = 32
+
+// New sequence of the entry's bucket, its value is the seqKey. The key is
+// empty, or the tuple of names of a nested bucket.
+const opSequence byte = 33
+
+// Frames sent over the replication stream.
+const (
+	frameEntry byte = iota + 1
+	frameSnapshot
+	frameHeartbeat
+	frameSnapshotRecord
+	frameSnapshotEnd
+	frameSnapshotBucket
+	frameSnapshotDefinition
+)
+
+type replEntry struct {
+	op     byte
+	ts     int64
+	bucket []byte
+	key    []byte
+	val    []byte
+}
+
+/*
+ * ReplicationStatus
+ * Replication position of a follower.
+ * Lag is the time since the last applied entry was committed on the primary
+ * and is zero once the follower has caught up.
+ */
+type ReplicationStatus struct {
+	AppliedSeq  uint64
+	PrimarySeq  uint64
+	Behind      uint64
+	Lag         time.Duration
+	LastContact time.Time
+}
+
+type replicationState struct {
+	mu sync.Mutex
+	// Primary side
+	enabled bool
+	retain  uint64
+	commits chan struct{}
+	// Follower side
+	applied_ts   int64
+	primary_seq  uint64
+	last_contact time.Time
+}
+
+/*
+ * commitWait
+ * Returns a channel closed after the next commit containing log entries.
+ */
+func (r *replicationState) commitWait() <-chan struct{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.commits == nil {
+		r.commits = make(chan struct{})
+	}
+	return r.commits
+}
+
+func (r *replicationState) commitDone() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.commits != nil {
+		close(r.commits)
+		r.commits = nil
+	}
+}
+
+/*
+ * checkUserBucket
+ * ErrHiddenBucket if bucket is one of the hidden buckets of dumbDB, which
+ * are only written by dumbDB itself.
+ */
+func checkUserBucket(bucket string) error {
+	if isHiddenBucket([]byte(bucket)) {
+		return ErrHiddenBucket
+	}
+	return nil
+}
+
+func isHiddenBucket(name []byte) bool {
+	return len(name) >= len(HIDDEN_BUCKET_PREFIX) &&
+		string(name[:len(HIDDEN_BUCKET_PREFIX)]) == HIDDEN_BUCKET_PREFIX
+}
+
+func seqKey(seq uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, seq)
+	return b
+}
+
+/*
+ * NewFollower
+ * Opens a follower DB. A follower is read-only for users, it is only written
+ * to by Follow with the stream produced by Replicate on the primary.
+ * @param 	root_path	directory containing the DB file
+ * @param 	name		name of the DB
+ * @param 	logger_out	log output
+ * @param 	opts		options, see WithBackend
+ */
+func NewFollower(root_path string, name string, logger_out io.Writer, opts ...Option) *DumbDB {
+	db := NewDumbDB(root_path, name, logger_out, opts...)
+	if db == nil {
+		return nil
+	}
+	db.read_only = true
+	return db
+}
+
+/*
+ * EnableReplication
+ * Start recording committed writes in the replication log. Only the last
+ * 'retain' entries are kept, followers further behind are sent a snapshot.
+ * @param 	retain		no of entries to keep. 0 for default.
+ */
+func (db *DumbDB) EnableReplication(retain int) error {
+	if db.read_only {
+		return bolt.ErrDatabaseReadOnly
+	}
+	if retain <= 0 {
+		retain = DEFAULT_RETAINED_ENTRIES
+	}
+	ctx, op := db.startOp(context.Background(), "EnableReplication", REPLICATION_LOG_BUCKET, 0)
+	err := db.update(ctx, func(tx backend.Tx) error {
+		_, e := tx.CreateBucketIfNotExists([]byte(REPLICATION_LOG_BUCKET))
+		return e
+	})
+	db.finishOp(ctx, op, err, "retain", retain)
+	if err != nil {
+		return err
+	}
+	db.repl.mu.Lock()
+	db.repl.enabled = true
+	db.repl.retain = uint64(retain)
+	db.repl.mu.Unlock()
+	return nil
+}
+
+/*
+ * logWrite
+ * Append a write to the replication log inside the writing transaction.
+ */
+func (db *DumbDB) logWrite(tx backend.Tx, op byte, bucket, key, val []byte) error {
+	db.repl.mu.Lock()
+	enabled, retain := db.repl.enabled, db.repl.retain
+	db.repl.mu.Unlock()
+	if !enabled || isHiddenBucket(bucket) {
+		return nil
+	}
+
+	log_bkt := tx.Bucket([]byte(REPLICATION_LOG_BUCKET))
+	if log_bkt == nil {
+		return ErrReplicationDisabled
+	}
+	seq, err := log_bkt.NextSequence()
+	if err != nil {
+		return err
+	}
+	e := replEntry{op: op, ts: time.Now().UnixNano(), bucket: bucket, key: key, val: val}
+	if err = log_bkt.Put(seqKey(seq), e.encode()); err != nil {
+		return err
+	}
+
+	if seq > retain {
+		cutoff := seqKey(seq - retain)
+		old := make([][]byte, 0)
+		c := log_bkt.Cursor()
+		for k, _ := c.First(); k != nil && string(k) <= string(cutoff); k, _ = c.Next() {
+			old = append(old, k)
+		}
+		for _, k := range old {
+			if err = log_bkt.Delete(k); err != nil {
+				return err
+			}
+		}
+	}
+	tx.OnCommit(db.repl.commitDone)
+	return nil
+}
+
+/*
+ * setDefinition
+ * Set the META record key, defining an index, aggregate, schema or
+ * full-text index of bucket, to val, removing it if val is nil. The change
+ * is logged, followers rebuild the derived data of bucket from it.
+ */
+func (db *DumbDB) setDefinition(tx backend.Tx, bucket string, key, val []byte) error {
+	meta, err := tx.CreateBucketIfNotExists([]byte(META_BUCKET))
+	if err != nil {
+		return err
+	}
+	if val == nil {
+		err = meta.Delete(key)
+	} else {
+		err = meta.Put(key, val)
+	}
+	if err != nil {
+		return err
+	}
+	return db.logWrite(tx, opDefine, []byte(bucket), key, val)
+}
+
+/*
+ * nextSequence
+ * Increment the sequence of bkt, the user bucket named bucket, and log the
+ * new value so the IDs of a promoted follower keep increasing.
+ */
+func (db *DumbDB) nextSequence(tx backend.Tx, bkt backend.Bucket, bucket string) (uint64, error) {
+	seq, err := bkt.NextSequence()
+	if err != nil {
+		return 0, err
+	}
+	return seq, db.logWrite(tx, opSequence, []byte(bucket), nil, seqKey(seq))
+}
+
+/*
+ * definitionBucket
+ * User bucket defined by the META record key. ok is false if key is not a
+ * definition.
+ */
+func definitionBucket(key []byte) (bucket string, ok bool) {
+	for _, prefix := range [][]byte{indexDefKey(""), aggregateDefKey(""), schemaKey(""), textIndexDefKey("")} {
+		if bytes.HasPrefix(key, prefix) && len(key) > len(prefix) {
+			bucket = string(key[len(prefix):])
+			return bucket, !isHiddenBucket([]byte(bucket))
+		}
+	}
+	return "", false
+}
+
+/*
+ * Replicate
+ * Stream committed writes to a follower. Entries after 'from' are sent
+ * followed by new commits as they happen. If the follower is new (from is 0)
+ * or behind the retained log, a full snapshot is sent first.
+ * Blocks until stop is closed or writing to w fails.
+ * @param 	w		transport to the follower
+ * @param 	from		last seq applied by the follower, see AppliedSeq
+ * @param 	stop		closed to end replication
+ */
+func (db *DumbDB) Replicate(w io.Writer, from uint64, stop <-chan struct{}) error {
+	db.repl.mu.Lock()
+	enabled := db.repl.enabled
+	db.repl.mu.Unlock()
+	if !enabled {
+		return ErrReplicationDisabled
+	}
+
+	ctx, op := db.startOp(context.Background(), "Replicate", "", 0)
+	bw := bufio.NewWriter(w)
+	heartbeat := time.NewTicker(REPLICATION_HEARTBEAT)
+	defer heartbeat.Stop()
+
+	for {
+		// Register for the next commit before reading so none are missed.
+		wait := db.repl.commitWait()
+
+		err := db.view(ctx, func(tx backend.Tx) error {
+			log_bkt := tx.Bucket([]byte(REPLICATION_LOG_BUCKET))
+			if log_bkt == nil {
+				return ErrReplicationDisabled
+			}
+			last := log_bkt.Sequence()
+			if from > last {
+				return ErrReplicationDiverged
+			}
+
+			c := log_bkt.Cursor()
+			first := last + 1
+			if k, _ := c.First(); k != nil {
+				first = binary.BigEndian.Uint64(k)
+			}
+			if from == 0 || from+1 < first {
+				db.logInfo("Sending snapshot", "op", "Replicate", "bucket", "", "seq", last, "duration", time.Since(op.Start))
+				if e := writeSnapshot(bw, tx, last); e != nil {
+					return e
+				}
+				from = last
+			}
+
+			for k, v := c.Seek(seqKey(from + 1)); k != nil; k, v = c.Next() {
+				if e := writeFrame(bw, frameEntry, append(k[:8:8], v...)); e != nil {
+					return e
+				}
+				from = binary.BigEndian.Uint64(k)
+			}
+			return writeHeartbeat(bw, last)
+		})
+		if err == nil {
+			err = bw.Flush()
+		}
+		if err != nil {
+			db.finishOp(ctx, op, err, "seq", from)
+			return err
+		}
+
+		select {
+		case <-stop:
+			db.finishOp(ctx, op, nil, "seq", from)
+			return nil
+		case <-wait:
+		case <-heartbeat.C:
+		}
+	}
+}
+
+/*
+ * Follow
+ * Apply a replication stream produced by Replicate on the primary.
+ * Returns nil when the stream ends.
+ * @param 	r		transport from the primary
+ */
+func (db *DumbDB) Follow(r io.Reader) error {
+	ctx, op := db.startOp(context.Background(), "Follow", "", 0)
+	br := bufio.NewReader(r)
+	for {
+		typ, payload, err := readFrame(br)
+		if err == io.EOF {
+			db.finishOp(ctx, op, nil)
+			return nil
+		}
+		if err != nil {
+			db.finishOp(ctx, op, err)
+			return err
+		}
+
+		switch typ {
+		case frameEntry:
+			err = db.applyEntry(payload)
+		case frameSnapshot:
+			err = db.applySnapshot(payload, br)
+		case frameHeartbeat:
+			err = db.applyHeartbeat(payload)
+		default:
+			err = ErrReplicationCorrupt
+		}
+		if err != nil {
+			db.finishOp(ctx, op, err, "frame", typ)
+			return err
+		}
+	}
+}
+
+/*
+ * AppliedSeq
+ * Seq of the last replication log entry applied on this follower.
+ */
+func (db *DumbDB) AppliedSeq() (seq uint64) {
+	db.view(context.Background(), func(tx backend.Tx) error {
+		seq = appliedSeq(tx)
+		return nil
+	})
+	return
+}
+
+/*
+ * ReplicationLag
+ * Returns how far this follower is behind the primary, as of the last
+ * message received from it.
+ */
+func (db *DumbDB) ReplicationLag() ReplicationStatus {
+	applied := db.AppliedSeq()
+
+	db.repl.mu.Lock()
+	defer db.repl.mu.Unlock()
+	st := ReplicationStatus{
+		AppliedSeq:  applied,
+		PrimarySeq:  db.repl.primary_seq,
+		LastContact: db.repl.last_contact,
+	}
+	if st.PrimarySeq > applied {
+		st.Behind = st.PrimarySeq - applied
+		if db.repl.applied_ts != 0 {
+			st.Lag = time.Since(time.Unix(0, db.repl.applied_ts))
+		}
+	}
+	return st
+}
+
+func appliedSeq(tx backend.Tx) uint64 {
+	meta := tx.Bucket([]byte(META_BUCKET))
+	if meta == nil {
+		return 0
+	}
+	v := meta.Get(metaAppliedSeq)
+	if len(v) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(v)
+}
+
+func setAppliedSeq(tx backend.Tx, seq uint64) error {
+	meta, err := tx.CreateBucketIfNotExists([]byte(META_BUCKET))
+	if err != nil {
+		return err
+	}
+	return meta.Put(metaAppliedSeq, seqKey(seq))
+}
+
+func (db *DumbDB) applyEntry(payload []byte) error {
+	if len(payload) < 8 {
+		return ErrReplicationCorrupt
+	}
+	seq := binary.BigEndian.Uint64(payload[:8])
+	e, err := decodeEntry(payload[8:])
+	if err != nil {
+		return err
+	}
+
+	err = db.update(context.Background(), func(tx backend.Tx) error {
+		applied := appliedSeq(tx)
+		if seq <= applied {
+			return nil
+		}
+		if seq != applied+1 {
+			return ErrReplicationGap
+		}
+
+		switch e.op {
+		case opPut:
+			bkt, e2 := tx.CreateBucketIfNotExists(e.bucket)
+			if e2 != nil {
+				return e2
+			}
+			if e2 = applyRecord(tx, bkt, string(e.bucket), e.key, e.val); e2 != nil {
+				return e2
+			}
+		case opDelete:
+			if bkt := tx.Bucket(e.bucket); bkt != nil {
+				if e2 := applyRecord(tx, bkt, string(e.bucket), e.key, nil); e2 != nil {
+					return e2
+				}
+			}
+		case opDeleteBucket:
+			if e2 := tx.DeleteBucket(e.bucket); e2 != nil && e2 != bolt.ErrBucketNotFound {
+				return e2
+			}
+			if e2 := dropDerived(tx, string(e.bucket)); e2 != nil {
+				return e2
+			}
+		case opDefine:
+			if e2 := applyDefinition(tx, e); e2 != nil {
+				return e2
+			}
+			return setAppliedSeq(tx, seq)
+		case opSequence:
+			if e2 := applySequence(tx, e); e2 != nil {
+				return e2
+			}
+			return setAppliedSeq(tx, seq)
+		case opNestedPut, opNestedDelete, opNestedDeleteBucket:
+			if e2 := applyNested(tx, e); e2 != nil {
+				return e2
+			}
+			return setAppliedSeq(tx, seq)
+		default:
+			return ErrReplicationCorrupt
+		}
+		db.notifyWatchers(tx, e.op, e.bucket, e.key, e.val)
+		return setAppliedSeq(tx, seq)
+	})
+	if err == nil {
+		db.repl.mu.Lock()
+		db.repl.applied_ts = e.ts
+		db.repl.mu.Unlock()
+	}
+	return err
+}
+
+/*
+ * applyRecord
+ * Set key of bkt to val, or remove it if val is nil, keeping the derived
+ * data of bucket up to date.
+ */
+func applyRecord(tx backend.Tx, bkt backend.Bucket, bucket string, key, val []byte) error {
+	derived, err := loadDerived(tx, bucket)
+	if err != nil {
+		return err
+	}
+	old := bytes.Clone(bkt.Get(key))
+	if val == nil {
+		if old == nil {
+			return nil
+		}
+		err = bkt.Delete(key)
+	} else {
+		err = bkt.Put(key, val)
+	}
+	if err != nil {
+		return err
+	}
+	return derived.update(tx, bucket, key, old, val)
+}
+
+/*
+ * applyDefinition
+ * Apply a replicated change of a definition, rebuilding the derived data
+ * of its bucket unless it is a schema.
+ */
+func applyDefinition(tx backend.Tx, e replEntry) error {
+	bucket, ok := definitionBucket(e.key)
+	if !ok || bucket != string(e.bucket) {
+		return ErrReplicationCorrupt
+	}
+	meta, err := tx.CreateBucketIfNotExists([]byte(META_BUCKET))
+	if err != nil {
+		return err
+	}
+	if len(e.val) == 0 {
+		err = meta.Delete(e.key)
+	} else {
+		err = meta.Put(e.key, e.val)
+	}
+	if err != nil || bytes.Equal(e.key, schemaKey(bucket)) {
+		return err
+	}
+	return rebuildDerived(context.Background(), tx, bucket)
+}
+
+func (db *DumbDB) applyHeartbeat(payload []byte) error {
+	if len(payload) != 8 {
+		return ErrReplicationCorrupt
+	}
+	db.repl.mu.Lock()
+	db.repl.primary_seq = binary.BigEndian.Uint64(payload)
+	db.repl.last_contact = time.Now()
+	db.repl.mu.Unlock()
+	return nil
+}
+
+/*
+ * applySnapshot
+ * Replace all user buckets and definitions with the ones in the snapshot,
+ * then rebuild the derived data of the defined buckets. The snapshot
+ * records follow the frame header on the stream, up to a frameSnapshotEnd.
+ * They are applied in a single transaction.
+ */
+func (db *DumbDB) applySnapshot(payload []byte, br *bufio.Reader) error {
+	if len(payload) != 8 {
+		return ErrReplicationCorrupt
+	}
+	start := time.Now()
+	seq := binary.BigEndian.Uint64(payload)
+
+	return db.update(context.Background(), func(dst backend.Tx) error {
+		existing := make([][]byte, 0)
+		dst.ForEach(func(name []byte, _ backend.Bucket) error {
+			if !isHiddenBucket(name) || isDerivedBucket(name) {
+				existing = append(existing, append([]byte(nil), name...))
+			}
+			return nil
+		})
+		for _, name := range existing {
+			if e := dst.DeleteBucket(name); e != nil {
+				return e
+			}
+		}
+		meta, e := dst.CreateBucketIfNotExists([]byte(META_BUCKET))
+		if e != nil {
+			return e
+		}
+		var defs [][]byte
+		c := meta.Cursor()
+		for k, _ := c.First(); k != nil; k, _ = c.Next() {
+			if _, ok := definitionBucket(k); ok {
+				defs = append(defs, bytes.Clone(k))
+			}
+		}
+		for _, k := range defs {
+			if e = meta.Delete(k); e != nil {
+				return e
+			}
+		}
+
+		defined := make(map[string]bool)
+		var bkt backend.Bucket
+		var bkt_name []byte
+		for {
+			typ, rec, e := readFrame(br)
+			if e == io.EOF {
+				return ErrReplicationCorrupt
+			}
+			if e != nil {
+				return e
+			}
+			if typ == frameSnapshotEnd {
+				break
+			}
+			if typ != frameSnapshotRecord && typ != frameSnapshotBucket && typ != frameSnapshotDefinition {
+				return ErrReplicationCorrupt
+			}
+			r, e := decodeEntry(rec)
+			if e != nil {
+				return e
+			}
+
+			if typ == frameSnapshotDefinition {
+				bucket, ok := definitionBucket(r.key)
+				if !ok || bucket != string(r.bucket) || len(r.val) == 0 {
+					return ErrReplicationCorrupt
+				}
+				if e = meta.Put(r.key, r.val); e != nil {
+					return e
+				}
+				defined[bucket] = true
+				continue
+			}
+
+			if typ == frameSnapshotBucket {
+				if bkt == nil || string(r.bucket) != string(bkt_name) || len(r.val) != 8 {
+					return ErrReplicationCorrupt
+				}
+				if bkt, e = createNested(dst.Bucket(r.bucket), r.key); e != nil {
+					return e
+				}
+				if e = bkt.SetSequence(binary.BigEndian.Uint64(r.val)); e != nil {
+					return e
+				}
+				continue
+			}
+
+			// A record without key starts the next bucket.
+			if len(r.key) == 0 {
+				if len(r.val) != 8 || isHiddenBucket(r.bucket) {
+					return ErrReplicationCorrupt
+				}
+				if bkt, e = dst.CreateBucket(r.bucket); e != nil {
+					return e
+				}
+				bkt_name = r.bucket
+				if e = bkt.SetSequence(binary.BigEndian.Uint64(r.val)); e != nil {
+					return e
+				}
+				continue
+			}
+			if bkt == nil || string(r.bucket) != string(bkt_name) {
+				return ErrReplicationCorrupt
+			}
+			if e = bkt.Put(r.key, r.val); e != nil {
+				return e
+			}
+		}
+		for bucket := range defined {
+			if e := rebuildDerived(context.Background(), dst, bucket); e != nil {
+				return e
+			}
+		}
+		db.logInfo("Applied snapshot", "op", "Follow", "bucket", "", "seq", seq, "duration", time.Since(start))
+		return setAppliedSeq(dst, seq)
+	})
+}
+
+/*
+ * createNested
+ * Create the nested bucket of top at path, a tuple of bucket names whose
+ * parents must exist.
+ */
+func createNested(top backend.Bucket, path []byte) (backend.Bucket, error) {
+	names, err := keys.Decode(path)
+	if err != nil || len(names) == 0 {
+		return nil, ErrReplicationCorrupt
+	}
+	b := top
+	for i, n := range names {
+		name, ok := n.([]byte)
+		if !ok || b == nil {
+			return nil, ErrReplicationCorrupt
+		}
+		if i == len(names)-1 {
+			return b.CreateBucket(name)
+		}
+		b = b.Bucket(name)
+	}
+	return nil, ErrReplicationCorrupt
+}
+
+/*
+ * copyBucket
+ * Copy the records, sequence and nested buckets of src to dst.
+ */
+func copyBucket(dst, src backend.Bucket) error {
+	if err := dst.SetSequence(src.Sequence()); err != nil {
+		return err
+	}
+	c := src.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		if v != nil {
+			if err := dst.Put(k, v); err != nil {
+				return err
+			}
+			continue
+		}
+		nb, err := dst.CreateBucket(k)
+		if err != nil {
+			return err
+		}
+		if err = copyBucket(nb, src.Bucket(k)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e replEntry) encode() []byte {
+	b := make([]byte, 0, 1+8+3*binary.MaxVarintLen64+len(e.bucket)+len(e.key)+len(e.val))
+	b = append(b, e.op)
+	b = append(b, seqKey(uint64(e.ts))...)
+	for _, f := range [][]byte{e.bucket, e.key, e.val} {
+		b = appendUvarint(b, uint64(len(f)))
+		b = append(b, f...)
+	}
+	return b
+}
+
+func decodeEntry(b []byte) (e replEntry, err error) {
+	if len(b) < 9 {
+		return e, ErrReplicationCorrupt
+	}
+	e.op = b[0]
+	e.ts = int64(binary.BigEndian.Uint64(b[1:9]))
+	b = b[9:]
+	fields := make([][]byte, 3)
+	for i := range fields {
+		l, n := binary.Uvarint(b)
+		if n <= 0 || uint64(len(b)-n) < l {
+			return e, ErrReplicationCorrupt
+		}
+		fields[i] = b[n : n+int(l)]
+		b = b[n+int(l):]
+	}
+	e.bucket, e.key, e.val = fields[0], fields[1], fields[2]
+	return e, nil
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	buf := make([]byte, binary.MaxVarintLen64)
+	return append(b, buf[:binary.PutUvarint(buf, v)]...)
+}
+
+func writeFrame(w io.Writer, typ byte, payload []byte) error {
+	hdr := appendUvarint([]byte{typ}, uint64(len(payload)))
+	if _, err := w.Write(hdr); err != nil {
+		return err
+	}
+	_, err := w.Write(payload)
+	return err
+}
+
+func readFrame(br *bufio.Reader) (typ byte, payload []byte, err error) {
+	typ, err = br.ReadByte()
+	if err != nil {
+		return
+	}
+	l, err := binary.ReadUvarint(br)
+	if err != nil {
+		return 0, nil, ErrReplicationCorrupt
+	}
+	payload = make([]byte, l)
+	if _, err = io.ReadFull(br, payload); err != nil {
+		return 0, nil, ErrReplicationCorrupt
+	}
+	return
+}
+
+func writeHeartbeat(w io.Writer, seq uint64) error {
+	return writeFrame(w, frameHeartbeat, seqKey(seq))
+}
+
+/*
+ * writeSnapshot
+ * Send the definitions and all user buckets as of tx. Each bucket is sent
+ * as a record without key carrying its sequence, followed by a record per
+ * key and then by its nested buckets.
+ */
+func writeSnapshot(w io.Writer, tx backend.Tx, seq uint64) error {
+	if err := writeFrame(w, frameSnapshot, seqKey(seq)); err != nil {
+		return err
+	}
+	if meta := tx.Bucket([]byte(META_BUCKET)); meta != nil {
+		c := meta.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			bucket, ok := definitionBucket(k)
+			if !ok || v == nil {
+				continue
+			}
+			r := replEntry{op: opDefine, bucket: []byte(bucket), key: k, val: v}
+			if err := writeFrame(w, frameSnapshotDefinition, r.encode()); err != nil {
+				return err
+			}
+		}
+	}
+	err := tx.ForEach(func(name []byte, b backend.Bucket) error {
+		if isHiddenBucket(name) {
+			return nil
+		}
+		start := replEntry{op: opPut, bucket: name, val: seqKey(b.Sequence())}
+		if e := writeFrame(w, frameSnapshotRecord, start.encode()); e != nil {
+			return e
+		}
+		return writeSnapshotBucket(w, name, nil, b)
+	})
+	if err != nil {
+		return err
+	}
+	return writeFrame(w, frameSnapshotEnd, nil)
+}
+
+/*
+ * writeSnapshotBucket
+ * Send the records of b, then each of its nested buckets as a
+ * frameSnapshotBucket record with the names leading to it from the
+ * top-level bucket name as key, followed by its contents.
+ */
+func writeSnapshotBucket(w io.Writer, name []byte, path []interface{}, b backend.Bucket) error {
+	var nested [][]byte
+	c := b.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		if v == nil {
+			nested = append(nested, bytes.Clone(k))
+			continue
+		}
+		r := replEntry{op: opPut, bucket: name, key: k, val: v}
+		if err := writeFrame(w, frameSnapshotRecord, r.encode()); err != nil {
+			return err
+		}
+	}
+	for _, k := range nested {
+		sub := b.Bucket(k)
+		sub_path := append(append([]interface{}(nil), path...), k)
+		start := replEntry{op: opPut, bucket: name, key: keys.MustEncode(sub_path...), val: seqKey(sub.Sequence())}
+		if err := writeFrame(w, frameSnapshotBucket, start.encode()); err != nil {
+			return err
+		}
+		if err := writeSnapshotBucket(w, name, sub_path, sub); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package rpc
+
+import (
+	"context"
+	"io"
+
+	dDB "dumbDB"
+
+	"google.golang.org/grpc"
+)
+
+/*
+ * Client
+ * A remote DumbDB. It implements dumbDB.KV with the same errors as the
+ * embedded DB, so code can switch between local and remote stores.
+ */
+type Client struct {
+	c    DumbDBClient
+	conn *grpc.ClientConn
+}
+
+var _ dDB.KV = (*Client)(nil)
+
+/*
+ * Dial
+ * Client for the server at target, e.g. "localhost:7070". Close closes the
+ * connection.
+ */
+func Dial(target string, opts ...grpc.DialOption) (*Client, error) {
+	conn, err := grpc.NewClient(target, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{c: NewDumbDBClient(conn), conn: conn}, nil
+}
+
+/*
+ * NewClient
+ * Client using an existing connection, which Close leaves open.
+ */
+func NewClient(conn grpc.ClientConnInterface) *Client {
+	return &Client{c: NewDumbDBClient(conn)}
+}
+
+func (cl *Client) Close() error {
+	if cl.conn == nil {
+		return nil
+	}
+	return cl.conn.Close()
+}
+
+func (cl *Client) Get(key []byte, bucket string) ([]byte, error) {
+	return cl.GetCtx(context.Background(), key, bucket)
+}
+
+func (cl *Client) GetCtx(ctx context.Context, key []byte, bucket string) ([]byte, error) {
+	resp, err := cl.c.Get(ctx, &GetRequest{Bucket: bucket, Key: key})
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+	if resp.Value == nil {
+		return []byte{}, nil
+	}
+	return resp.Value, nil
+}
+
+func (cl *Client) GetMultiple(keys [][]byte, bucket string) ([][]byte, error) {
+	return cl.GetMultipleCtx(context.Background(), keys, bucket)
+}
+
+func (cl *Client) GetMultipleCtx(ctx context.Context, keys [][]byte, bucket string) ([][]byte, error) {
+	resp, err := cl.c.GetMultiple(ctx, &GetMultipleRequest{Bucket: bucket, Keys: keys})
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+	return resp.Values, nil
+}
+
+func (cl *Client) Store(record [][]byte, bucket string) error {
+	return cl.StoreCtx(context.Background(), record, bucket)
+}
+
+func (cl *Client) StoreCtx(ctx context.Context, record [][]byte, bucket string) error {
+	_, err := cl.c.Store(ctx, &StoreRequest{Bucket: bucket, Record: &Record{Key: record[0], Value: record[1]}})
+	return fromStatus(err)
+}
+
+func (cl *Client) Remove(key []byte, bucket string) error {
+	return cl.RemoveCtx(context.Background(), key, bucket)
+}
+
+func (cl *Client) RemoveCtx(ctx context.Context, key []byte, bucket string) error {
+	_, err := cl.c.Remove(ctx, &RemoveRequest{Bucket: bucket, Key: key})
+	return fromStatus(err)
+}
+
+func (cl *Client) Scan(bucket string, opts dDB.ScanOptions, fn func(key, val []byte) error) error {
+	return cl.ScanCtx(context.Background(), bucket, opts, fn)
+}
+
+/*
+ * ScanCtx
+ * Same as DumbDB.ScanCtx. Records are streamed, fn returning an error or
+ * ErrStopScan cancels the stream.
+ */
+func (cl *Client) ScanCtx(ctx context.Context, bucket string, opts dDB.ScanOptions, fn func(key, val []byte) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := cl.c.Scan(ctx, &ScanRequest{
+		Bucket:  bucket,
+		Prefix:  opts.Prefix,
+		Start:   opts.Start,
+		End:     opts.End,
+		Reverse: opts.Reverse,
+		Limit:   int32(opts.Limit),
+	})
+	if err != nil {
+		return fromStatus(err)
+	}
+	for {
+		rec, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fromStatus(err)
+		}
+		if err = fn(rec.Key, rec.Value); err != nil {
+			if err == dDB.ErrStopScan {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+/*
+ * Watch
+ * Same as DumbDB.Watch. Returns once the server has registered the watch.
+ * The watch ends when cancel is called or the connection fails.
+ */
+func (cl *Client) Watch(bucket string, prefix []byte, fn func(dDB.Change)) (cancel func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := cl.c.Watch(ctx, &WatchRequest{Bucket: bucket, Prefix: prefix})
+	if err != nil {
+		return cancel
+	}
+	if _, err = stream.Header(); err != nil {
+		return cancel
+	}
+	go func() {
+		for {
+			c, err := stream.Recv()
+			if err != nil {
+				return
+			}
+			fn(dDB.Change{Op: dDB.ChangeOp(c.Op), Bucket: c.Bucket, Key: c.Key, Value: c.Value})
+		}
+	}()
+	return cancel
+}
+
+func (cl *Client) Txn(changes []dDB.Change) error {
+	return cl.TxnCtx(context.Background(), changes)
+}
+
+func (cl *Client) TxnCtx(ctx context.Context, changes []dDB.Change) error {
+	req := &TxnRequest{Changes: make([]*Change, len(changes))}
+	for i, c := range changes {
+		req.Changes[i] = &Change{Op: ChangeOp(c.Op), Bucket: c.Bucket, Key: c.Key, Value: c.Value}
+	}
+	_, err := cl.c.Txn(ctx, req)
+	return fromStatus(err)
+}
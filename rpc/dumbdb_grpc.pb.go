@@ -0,0 +1,397 @@
+// Remote access to a DumbDB. Generate the Go code with
+//
+//	protoc --go_out=. --go_opt=paths=source_relative \
+//		--go-grpc_out=. --go-grpc_opt=paths=source_relative rpc/dumbdb.proto
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             v5.27.1
+// source: rpc/dumbdb.proto
+
+package rpc
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	DumbDB_Get_FullMethodName         = "/dumbdb.v1.DumbDB/Get"
+	DumbDB_GetMultiple_FullMethodName = "/dumbdb.v1.DumbDB/GetMultiple"
+	DumbDB_Store_FullMethodName       = "/dumbdb.v1.DumbDB/Store"
+	DumbDB_Remove_FullMethodName      = "/dumbdb.v1.DumbDB/Remove"
+	DumbDB_Scan_FullMethodName        = "/dumbdb.v1.DumbDB/Scan"
+	DumbDB_Watch_FullMethodName       = "/dumbdb.v1.DumbDB/Watch"
+	DumbDB_Txn_FullMethodName         = "/dumbdb.v1.DumbDB/Txn"
+)
+
+// DumbDBClient is the client API for DumbDB service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type DumbDBClient interface {
+	Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetResponse, error)
+	GetMultiple(ctx context.Context, in *GetMultipleRequest, opts ...grpc.CallOption) (*GetMultipleResponse, error)
+	Store(ctx context.Context, in *StoreRequest, opts ...grpc.CallOption) (*StoreResponse, error)
+	Remove(ctx context.Context, in *RemoveRequest, opts ...grpc.CallOption) (*RemoveResponse, error)
+	// Records in key order, see dumbDB.ScanOptions.
+	Scan(ctx context.Context, in *ScanRequest, opts ...grpc.CallOption) (DumbDB_ScanClient, error)
+	// Committed changes until the client cancels the call.
+	Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (DumbDB_WatchClient, error)
+	// Changes applied in one transaction, or not at all.
+	Txn(ctx context.Context, in *TxnRequest, opts ...grpc.CallOption) (*TxnResponse, error)
+}
+
+type dumbDBClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewDumbDBClient(cc grpc.ClientConnInterface) DumbDBClient {
+	return &dumbDBClient{cc}
+}
+
+func (c *dumbDBClient) Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetResponse, error) {
+	out := new(GetResponse)
+	err := c.cc.Invoke(ctx, DumbDB_Get_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dumbDBClient) GetMultiple(ctx context.Context, in *GetMultipleRequest, opts ...grpc.CallOption) (*GetMultipleResponse, error) {
+	out := new(GetMultipleResponse)
+	err := c.cc.Invoke(ctx, DumbDB_GetMultiple_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dumbDBClient) Store(ctx context.Context, in *StoreRequest, opts ...grpc.CallOption) (*StoreResponse, error) {
+	out := new(StoreResponse)
+	err := c.cc.Invoke(ctx, DumbDB_Store_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dumbDBClient) Remove(ctx context.Context, in *RemoveRequest, opts ...grpc.CallOption) (*RemoveResponse, error) {
+	out := new(RemoveResponse)
+	err := c.cc.Invoke(ctx, DumbDB_Remove_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dumbDBClient) Scan(ctx context.Context, in *ScanRequest, opts ...grpc.CallOption) (DumbDB_ScanClient, error) {
+	stream, err := c.cc.NewStream(ctx, &DumbDB_ServiceDesc.Streams[0], DumbDB_Scan_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &dumbDBScanClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type DumbDB_ScanClient interface {
+	Recv() (*Record, error)
+	grpc.ClientStream
+}
+
+type dumbDBScanClient struct {
+	grpc.ClientStream
+}
+
+func (x *dumbDBScanClient) Recv() (*Record, error) {
+	m := new(Record)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *dumbDBClient) Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (DumbDB_WatchClient, error) {
+	stream, err := c.cc.NewStream(ctx, &DumbDB_ServiceDesc.Streams[1], DumbDB_Watch_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &dumbDBWatchClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type DumbDB_WatchClient interface {
+	Recv() (*Change, error)
+	grpc.ClientStream
+}
+
+type dumbDBWatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *dumbDBWatchClient) Recv() (*Change, error) {
+	m := new(Change)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *dumbDBClient) Txn(ctx context.Context, in *TxnRequest, opts ...grpc.CallOption) (*TxnResponse, error) {
+	out := new(TxnResponse)
+	err := c.cc.Invoke(ctx, DumbDB_Txn_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DumbDBServer is the server API for DumbDB service.
+// All implementations must embed UnimplementedDumbDBServer
+// for forward compatibility
+type DumbDBServer interface {
+	Get(context.Context, *GetRequest) (*GetResponse, error)
+	GetMultiple(context.Context, *GetMultipleRequest) (*GetMultipleResponse, error)
+	Store(context.Context, *StoreRequest) (*StoreResponse, error)
+	Remove(context.Context, *RemoveRequest) (*RemoveResponse, error)
+	// Records in key order, see dumbDB.ScanOptions.
+	Scan(*ScanRequest, DumbDB_ScanServer) error
+	// Committed changes until the client cancels the call.
+	Watch(*WatchRequest, DumbDB_WatchServer) error
+	// Changes applied in one transaction, or not at all.
+	Txn(context.Context, *TxnRequest) (*TxnResponse, error)
+	mustEmbedUnimplementedDumbDBServer()
+}
+
+// UnimplementedDumbDBServer must be embedded to have forward compatible implementations.
+type UnimplementedDumbDBServer struct {
+}
+
+func (UnimplementedDumbDBServer) Get(context.Context, *GetRequest) (*GetResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
+}
+func (UnimplementedDumbDBServer) GetMultiple(context.Context, *GetMultipleRequest) (*GetMultipleResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetMultiple not implemented")
+}
+func (UnimplementedDumbDBServer) Store(context.Context, *StoreRequest) (*StoreResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Store not implemented")
+}
+func (UnimplementedDumbDBServer) Remove(context.Context, *RemoveRequest) (*RemoveResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Remove not implemented")
+}
+func (UnimplementedDumbDBServer) Scan(*ScanRequest, DumbDB_ScanServer) error {
+	return status.Errorf(codes.Unimplemented, "method Scan not implemented")
+}
+func (UnimplementedDumbDBServer) Watch(*WatchRequest, DumbDB_WatchServer) error {
+	return status.Errorf(codes.Unimplemented, "method Watch not implemented")
+}
+func (UnimplementedDumbDBServer) Txn(context.Context, *TxnRequest) (*TxnResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Txn not implemented")
+}
+func (UnimplementedDumbDBServer) mustEmbedUnimplementedDumbDBServer() {}
+
+// UnsafeDumbDBServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to DumbDBServer will
+// result in compilation errors.
+type UnsafeDumbDBServer interface {
+	mustEmbedUnimplementedDumbDBServer()
+}
+
+func RegisterDumbDBServer(s grpc.ServiceRegistrar, srv DumbDBServer) {
+	s.RegisterService(&DumbDB_ServiceDesc, srv)
+}
+
+func _DumbDB_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DumbDBServer).Get(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DumbDB_Get_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DumbDBServer).Get(ctx, req.(*GetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DumbDB_GetMultiple_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetMultipleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DumbDBServer).GetMultiple(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DumbDB_GetMultiple_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DumbDBServer).GetMultiple(ctx, req.(*GetMultipleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DumbDB_Store_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StoreRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DumbDBServer).Store(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DumbDB_Store_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DumbDBServer).Store(ctx, req.(*StoreRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DumbDB_Remove_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RemoveRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DumbDBServer).Remove(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DumbDB_Remove_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DumbDBServer).Remove(ctx, req.(*RemoveRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DumbDB_Scan_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ScanRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(DumbDBServer).Scan(m, &dumbDBScanServer{stream})
+}
+
+type DumbDB_ScanServer interface {
+	Send(*Record) error
+	grpc.ServerStream
+}
+
+type dumbDBScanServer struct {
+	grpc.ServerStream
+}
+
+func (x *dumbDBScanServer) Send(m *Record) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _DumbDB_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(DumbDBServer).Watch(m, &dumbDBWatchServer{stream})
+}
+
+type DumbDB_WatchServer interface {
+	Send(*Change) error
+	grpc.ServerStream
+}
+
+type dumbDBWatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *dumbDBWatchServer) Send(m *Change) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _DumbDB_Txn_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TxnRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DumbDBServer).Txn(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DumbDB_Txn_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DumbDBServer).Txn(ctx, req.(*TxnRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// DumbDB_ServiceDesc is the grpc.ServiceDesc for DumbDB service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var DumbDB_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "dumbdb.v1.DumbDB",
+	HandlerType: (*DumbDBServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Get",
+			Handler:    _DumbDB_Get_Handler,
+		},
+		{
+			MethodName: "GetMultiple",
+			Handler:    _DumbDB_GetMultiple_Handler,
+		},
+		{
+			MethodName: "Store",
+			Handler:    _DumbDB_Store_Handler,
+		},
+		{
+			MethodName: "Remove",
+			Handler:    _DumbDB_Remove_Handler,
+		},
+		{
+			MethodName: "Txn",
+			Handler:    _DumbDB_Txn_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Scan",
+			Handler:       _DumbDB_Scan_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Watch",
+			Handler:       _DumbDB_Watch_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "rpc/dumbdb.proto",
+}
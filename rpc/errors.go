@@ -0,0 +1,148 @@
+package rpc
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+
+	dDB "dumbDB"
+
+	"github.com/boltdb/bolt"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Reason of the ErrorInfo detail sent with a *dDB.ValidationError.
+const REASON_INVALID_RECORD = "INVALID_RECORD"
+
+/*
+ * knownErrors
+ * DumbDB errors sent as a status code and their message, so the client can
+ * return the same error value as the embedded DB.
+ */
+var knownErrors = []struct {
+	err  error
+	code codes.Code
+}{
+	{bolt.ErrBucketNotFound, codes.NotFound},
+	{bolt.ErrKeyRequired, codes.NotFound},
+	{bolt.ErrInvalid, codes.NotFound},
+	{bolt.ErrKeyTooLarge, codes.InvalidArgument},
+	{bolt.ErrValueTooLarge, codes.InvalidArgument},
+	{bolt.ErrBucketNameRequired, codes.InvalidArgument},
+	{bolt.ErrIncompatibleValue, codes.InvalidArgument},
+	{dDB.ErrInvalidChange, codes.InvalidArgument},
+	{bolt.ErrDatabaseReadOnly, codes.FailedPrecondition},
+	{dDB.ErrHiddenBucket, codes.PermissionDenied},
+	{bolt.ErrDatabaseNotOpen, codes.Unavailable},
+}
+
+/*
+ * toStatus
+ * gRPC status error for an error returned by DumbDB.
+ */
+func toStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+	if err == context.Canceled || err == context.DeadlineExceeded {
+		return status.FromContextError(err).Err()
+	}
+	var val_err *dDB.ValidationError
+	if errors.As(err, &val_err) {
+		return validationStatus(val_err, err.Error())
+	}
+	for _, k := range knownErrors {
+		if errors.Is(err, k.err) {
+			return status.Error(k.code, err.Error())
+		}
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+/*
+ * fromStatus
+ * Inverse of toStatus. Unknown errors are returned as the status error.
+ */
+func fromStatus(err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	switch st.Code() {
+	case codes.OK:
+		return nil
+	case codes.Canceled:
+		return context.Canceled
+	case codes.DeadlineExceeded:
+		return context.DeadlineExceeded
+	}
+	if val_err := fromValidationStatus(st); val_err != nil {
+		return val_err
+	}
+	for _, k := range knownErrors {
+		if st.Code() == k.code && st.Message() == k.err.Error() {
+			return k.err
+		}
+	}
+	return err
+}
+
+/*
+ * validationStatus
+ * InvalidArgument status for a *dDB.ValidationError, with the bucket and
+ * key in an ErrorInfo and the failing fields in a BadRequest detail.
+ */
+func validationStatus(val_err *dDB.ValidationError, msg string) error {
+	st := status.New(codes.InvalidArgument, msg)
+	info := &errdetails.ErrorInfo{
+		Reason: REASON_INVALID_RECORD,
+		Domain: "dumbdb",
+		Metadata: map[string]string{
+			"bucket": val_err.Bucket,
+			"key":    base64.StdEncoding.EncodeToString(val_err.Key),
+		},
+	}
+	req := &errdetails.BadRequest{}
+	for _, f := range val_err.Errors {
+		req.FieldViolations = append(req.FieldViolations,
+			&errdetails.BadRequest_FieldViolation{Field: f.Path, Description: f.Message})
+	}
+	if with, err := st.WithDetails(info, req); err == nil {
+		st = with
+	}
+	return st.Err()
+}
+
+/*
+ * fromValidationStatus
+ * Inverse of validationStatus, nil if st is not one of its statuses.
+ */
+func fromValidationStatus(st *status.Status) *dDB.ValidationError {
+	if st.Code() != codes.InvalidArgument {
+		return nil
+	}
+	var val_err *dDB.ValidationError
+	for _, d := range st.Details() {
+		switch d := d.(type) {
+		case *errdetails.ErrorInfo:
+			if d.Reason != REASON_INVALID_RECORD {
+				return nil
+			}
+			key, err := base64.StdEncoding.DecodeString(d.Metadata["key"])
+			if err != nil {
+				return nil
+			}
+			val_err = &dDB.ValidationError{Bucket: d.Metadata["bucket"], Key: key}
+		case *errdetails.BadRequest:
+			if val_err == nil {
+				return nil
+			}
+			for _, f := range d.FieldViolations {
+				val_err.Errors = append(val_err.Errors, dDB.FieldError{Path: f.Field, Message: f.Description})
+			}
+		}
+	}
+	return val_err
+}
@@ -0,0 +1,133 @@
+package rpc
+
+import (
+	"context"
+
+	dDB "dumbDB"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// Changes buffered per Watch call before the watcher waits for the client.
+const WATCH_BUFFER = 256
+
+type server struct {
+	UnimplementedDumbDBServer
+	db *dDB.DumbDB
+}
+
+/*
+ * NewServer
+ * DumbDBServer serving a local DB. The DB is not closed by the server.
+ */
+func NewServer(db *dDB.DumbDB) DumbDBServer {
+	return &server{db: db}
+}
+
+/*
+ * Register
+ * Register a server for db with s.
+ *
+ *	s := grpc.NewServer()
+ *	rpc.Register(s, db)
+ *	s.Serve(lis)
+ */
+func Register(s *grpc.Server, db *dDB.DumbDB) {
+	RegisterDumbDBServer(s, NewServer(db))
+}
+
+func (s *server) Get(ctx context.Context, req *GetRequest) (*GetResponse, error) {
+	val, err := s.db.GetCtx(ctx, req.Key, req.Bucket)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &GetResponse{Value: val}, nil
+}
+
+func (s *server) GetMultiple(ctx context.Context, req *GetMultipleRequest) (*GetMultipleResponse, error) {
+	values, err := s.db.GetMultipleCtx(ctx, req.Keys, req.Bucket)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &GetMultipleResponse{Values: values}, nil
+}
+
+func (s *server) Store(ctx context.Context, req *StoreRequest) (*StoreResponse, error) {
+	rec := req.GetRecord()
+	val := rec.GetValue()
+	if val == nil {
+		val = []byte{}
+	}
+	if err := s.db.StoreCtx(ctx, [][]byte{rec.GetKey(), val}, req.Bucket); err != nil {
+		return nil, toStatus(err)
+	}
+	return &StoreResponse{}, nil
+}
+
+func (s *server) Remove(ctx context.Context, req *RemoveRequest) (*RemoveResponse, error) {
+	if err := s.db.RemoveCtx(ctx, req.Key, req.Bucket); err != nil {
+		return nil, toStatus(err)
+	}
+	return &RemoveResponse{}, nil
+}
+
+func (s *server) Scan(req *ScanRequest, stream DumbDB_ScanServer) error {
+	opts := dDB.ScanOptions{
+		Prefix:  req.Prefix,
+		Start:   req.Start,
+		End:     req.End,
+		Reverse: req.Reverse,
+		Limit:   int(req.Limit),
+	}
+	err := s.db.ScanCtx(stream.Context(), req.Bucket, opts, func(k, v []byte) error {
+		return stream.Send(&Record{Key: k, Value: v})
+	})
+	return toStatus(err)
+}
+
+/*
+ * Watch
+ * Headers are sent once the watch is registered, so a client waiting for
+ * them sees every change committed after its Watch call.
+ */
+func (s *server) Watch(req *WatchRequest, stream DumbDB_WatchServer) error {
+	ctx := stream.Context()
+	changes := make(chan dDB.Change, WATCH_BUFFER)
+	cancel := s.db.Watch(req.Bucket, req.Prefix, func(c dDB.Change) {
+		select {
+		case changes <- c:
+		case <-ctx.Done():
+		}
+	})
+	defer cancel()
+	if err := stream.SendHeader(metadata.MD{}); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return toStatus(ctx.Err())
+		case c := <-changes:
+			err := stream.Send(&Change{Op: ChangeOp(c.Op), Bucket: c.Bucket, Key: c.Key, Value: c.Value})
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *server) Txn(ctx context.Context, req *TxnRequest) (*TxnResponse, error) {
+	changes := make([]dDB.Change, len(req.Changes))
+	for i, c := range req.Changes {
+		changes[i] = dDB.Change{Op: dDB.ChangeOp(c.Op), Bucket: c.Bucket, Key: c.Key, Value: c.Value}
+		if c.Op == ChangeOp_CHANGE_OP_PUT && c.Value == nil {
+			changes[i].Value = []byte{}
+		}
+	}
+	if err := s.db.TxnCtx(ctx, changes); err != nil {
+		return nil, toStatus(err)
+	}
+	return &TxnResponse{}, nil
+}
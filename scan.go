@@ -0,0 +1,150 @@
+package dumbDatabase
+
+import (
+	"bytes"
+	"context"
+	"errors"
+
+	"dumbDB/backend"
+
+	"github.com/boltdb/bolt"
+)
+
+// Return from a scan callback to stop the scan without an error.
+var ErrStopScan = errors.New("stop scan")
+
+/*
+ * ScanOptions
+ * Selects the records visited by Scan. All bounds are optional.
+ */
+type ScanOptions struct {
+	// Only keys starting with Prefix
+	Prefix []byte
+	// Only keys >= Start
+	Start []byte
+	// Only keys < End
+	End []byte
+	// Walk from the largest key down
+	Reverse bool
+	// Max no of records, 0 for no limit
+	Limit int
+}
+
+/*
+ * Scan
+ * Walk the records of a bucket in key order.
+ * key and val are only valid inside fn, copy them to keep them.
+ * @param 	bucket		name of bucket
+ * @param 	opts		range, direction and limit of the scan
+ * @param 	fn		called for every record. Return ErrStopScan to stop.
+ */
+func (db *DumbDB) Scan(bucket string, opts ScanOptions, fn func(key, val []byte) error) error {
+	return db.ScanCtx(context.Background(), bucket, opts, fn)
+}
+
+/*
+ * ScanCtx
+ * Scan which is abandoned with ctx.Err() when ctx is done. The context is
+ * checked between every cursor step.
+ */
+func (db *DumbDB) ScanCtx(ctx context.Context, bucket string, opts ScanOptions, fn func(key, val []byte) error) (err error) {
+	ctx, op := db.startOp(ctx, "Scan", bucket, len(opts.Prefix))
+	err = db.view(ctx, func(tx backend.Tx) error {
+		bkt := tx.Bucket([]byte(bucket))
+		if bkt == nil {
+			return bolt.ErrBucketNotFound
+		}
+		return scanCursor(ctx, bkt.Cursor(), opts, func(k, v []byte) error {
+			op.Results++
+			return fn(k, v)
+		})
+	})
+	db.finishOp(ctx, op, err)
+	return
+}
+
+/*
+ * prefixEnd
+ * Smallest key larger than all keys starting with prefix, nil if there is none.
+ */
+func prefixEnd(prefix []byte) []byte {
+	end := append([]byte(nil), prefix...)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return nil
+}
+
+/*
+ * scanBounds
+ * Returns the range [lo, hi) selected by opts, nil meaning unbounded.
+ */
+func scanBounds(opts ScanOptions) (lo, hi []byte) {
+	lo, hi = opts.Start, opts.End
+	if len(opts.Prefix) > 0 {
+		if lo == nil || bytes.Compare(opts.Prefix, lo) > 0 {
+			lo = opts.Prefix
+		}
+		if end := prefixEnd(opts.Prefix); end != nil && (hi == nil || bytes.Compare(end, hi) < 0) {
+			hi = end
+		}
+	}
+	return
+}
+
+/*
+ * scanCursor
+ * Walk c according to opts. Nested buckets are skipped.
+ */
+func scanCursor(ctx context.Context, c backend.Cursor, opts ScanOptions, fn func(k, v []byte) error) error {
+	lo, hi := scanBounds(opts)
+
+	var k, v []byte
+	next := c.Next
+	switch {
+	case opts.Reverse && hi != nil:
+		if k, v = c.Seek(hi); k == nil {
+			k, v = c.Last()
+		} else {
+			k, v = c.Prev()
+		}
+		next = c.Prev
+	case opts.Reverse:
+		k, v = c.Last()
+		next = c.Prev
+	case lo != nil:
+		k, v = c.Seek(lo)
+	default:
+		k, v = c.First()
+	}
+
+	n := 0
+	for ; k != nil; k, v = next() {
+		if opts.Reverse && lo != nil && bytes.Compare(k, lo) < 0 {
+			break
+		}
+		if !opts.Reverse && hi != nil && bytes.Compare(k, hi) >= 0 {
+			break
+		}
+		if v == nil {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if opts.Limit > 0 && n >= opts.Limit {
+			break
+		}
+		n++
+		if err := fn(k, v); err != nil {
+			if err == ErrStopScan {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package dumbDatabase
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"dumbDB/backend"
+
+	"github.com/boltdb/bolt"
+)
+
+var (
+	// Returned by SetSchema when the schema is not a valid JSON Schema.
+	ErrInvalidSchema = errors.New("invalid JSON schema")
+)
+
+/*
+ * FieldError
+ * A value failing its schema. Path is the JSON pointer of the value in the
+ * document, "" for the document itself.
+ */
+type FieldError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+/*
+ * ValidationError
+ * Returned when a record does not match the schema of its bucket, with
+ * every failing value.
+ */
+type ValidationError struct {
+	Bucket string
+	Key    []byte
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, f := range e.Errors {
+		msgs[i] = pointerOrRoot(f.Path) + ": " + f.Message
+	}
+	return fmt.Sprintf("invalid record %q in %s: %s", e.Key, e.Bucket, strings.Join(msgs, "; "))
+}
+
+/*
+ * schemaCache
+ * Compiled schemas by bucket, with the document they were compiled from.
+ * The document in the meta bucket is the reference, a cached schema is
+ * only used while its document is unchanged.
+ */
+type schemaCache struct {
+	mu      sync.Mutex
+	schemas map[string]cachedSchema
+}
+
+type cachedSchema struct {
+	doc    []byte
+	schema *jsonSchema
+}
+
+func schemaKey(bucket string) []byte {
+	return []byte("schema." + bucket)
+}
+
+/*
+ * SetSchema
+ * Attach a JSON Schema to bucket. Every record stored in bucket afterwards,
+ * by any write method, must match it or the write fails with a
+ * *ValidationError. Records already in bucket are not checked, see
+ * ValidateBucket. The schema is kept in the DB file.
+ * @param 	bucket		name of bucket
+ * @param 	schema		JSON Schema document, nil to remove the schema
+ */
+func (db *DumbDB) SetSchema(bucket string, schema []byte) error {
+	return db.SetSchemaCtx(context.Background(), bucket, schema)
+}
+
+/*
+ * SetSchemaCtx
+ * SetSchema with a context passed to the operation hooks. Returns ctx.Err()
+ * if ctx is done before the operation completes.
+ */
+func (db *DumbDB) SetSchemaCtx(ctx context.Context, bucket string, schema []byte) (err error) {
+	ctx, op := db.startOp(ctx, "SetSchema", bucket, 0)
+	defer func() { db.finishOp(ctx, op, err) }()
+
+	if db.read_only {
+		return bolt.ErrDatabaseReadOnly
+	}
+	if err = checkUserBucket(bucket); err != nil {
+		return err
+	}
+	if schema != nil {
+		if _, err = compileSchema(schema); err != nil {
+			return err
+		}
+	}
+	err = db.update(ctx, func(tx backend.Tx) error {
+		return db.setDefinition(tx, bucket, schemaKey(bucket), schema)
+	})
+	return
+}
+
+/*
+ * GetSchema
+ * The JSON Schema of bucket, nil if it has none.
+ * @param 	bucket		name of bucket
+ */
+func (db *DumbDB) GetSchema(bucket string) (schema []byte, err error) {
+	ctx, op := db.startOp(context.Background(), "GetSchema", bucket, 0)
+	err = db.view(ctx, func(tx backend.Tx) error {
+		if meta := tx.Bucket([]byte(META_BUCKET)); meta != nil {
+			schema = bytes.Clone(meta.Get(schemaKey(bucket)))
+		}
+		return nil
+	})
+	db.finishOp(ctx, op, err)
+	return
+}
+
+/*
+ * ValidateBucket
+ * Check every record of bucket against its schema, e.g. after the schema
+ * changed. Returns the records which do not match, in key order.
+ * @param 	bucket		name of bucket
+ * @returns 	invalid		one error per invalid record
+ */
+func (db *DumbDB) ValidateBucket(bucket string) ([]*ValidationError, error) {
+	return db.ValidateBucketCtx(context.Background(), bucket)
+}
+
+/*
+ * ValidateBucketCtx
+ * ValidateBucket with a context passed to the operation hooks. Returns
+ * ctx.Err() if ctx is done before all records are checked.
+ */
+func (db *DumbDB) ValidateBucketCtx(ctx context.Context, bucket string) (invalid []*ValidationError, err error) {
+	ctx, op := db.startOp(ctx, "ValidateBucket", bucket, 0)
+	defer func() { db.finishOp(ctx, op, err) }()
+
+	invalid = make([]*ValidationError, 0)
+	err = db.view(ctx, func(tx backend.Tx) error {
+		schema, e := db.bucketSchema(tx, bucket)
+		if e != nil {
+			return e
+		}
+		bkt := tx.Bucket([]byte(bucket))
+		if bkt == nil {
+			return bolt.ErrBucketNotFound
+		}
+		if schema == nil {
+			return nil
+		}
+		return scanCursor(ctx, bkt.Cursor(), ScanOptions{}, func(k, v []byte) error {
+			if errs := schema.validate(v); errs != nil {
+				invalid = append(invalid, &ValidationError{Bucket: bucket, Key: bytes.Clone(k), Errors: errs})
+			}
+			return nil
+		})
+	})
+	op.Results = len(invalid)
+	return
+}
+
+/*
+ * bucketSchema
+ * Compiled schema of bucket as of tx, nil if it has none.
+ */
+func (db *DumbDB) bucketSchema(tx backend.Tx, bucket string) (*jsonSchema, error) {
+	meta := tx.Bucket([]byte(META_BUCKET))
+	if meta == nil {
+		return nil, nil
+	}
+	doc := meta.Get(schemaKey(bucket))
+	if doc == nil {
+		return nil, nil
+	}
+
+	db.schemas.mu.Lock()
+	defer db.schemas.mu.Unlock()
+	if c, ok := db.schemas.schemas[bucket]; ok && bytes.Equal(c.doc, doc) {
+		return c.schema, nil
+	}
+	schema, err := compileSchema(doc)
+	if err != nil {
+		return nil, err
+	}
+	if db.schemas.schemas == nil {
+		db.schemas.schemas = make(map[string]cachedSchema)
+	}
+	db.schemas.schemas[bucket] = cachedSchema{doc: bytes.Clone(doc), schema: schema}
+	return schema, nil
+}
+
+/*
+ * validateRecord
+ * Check a record about to be stored against the schema of its bucket.
+ */
+func (db *DumbDB) validateRecord(tx backend.Tx, bucket string, key, val []byte) error {
+	schema, err := db.bucketSchema(tx, bucket)
+	if err != nil || schema == nil {
+		return err
+	}
+	if errs := schema.validate(val); errs != nil {
+		return &ValidationError{Bucket: bucket, Key: bytes.Clone(key), Errors: errs}
+	}
+	return nil
+}
@@ -0,0 +1,360 @@
+package dumbDatabase
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"dumbDB/backend"
+	"dumbDB/keys"
+	"dumbDB/text"
+)
+
+// BM25 parameters
+const (
+	BM25_K1 = 1.2
+	BM25_B  = 0.75
+)
+
+/*
+ * SearchHit
+ * A record matching a search.
+ */
+type SearchHit struct {
+	Key   []byte
+	Value []byte
+	Score float64
+}
+
+/*
+ * searchTerm
+ * Words which must follow each other in a record, or a prefix of terms.
+ */
+type searchTerm struct {
+	words  []string
+	prefix string
+}
+
+/*
+ * Search
+ * Records of bucket matching query in its full-text index, best first by
+ * BM25. Records with the same score are ordered by key.
+ *
+ * Words in a query must all be in a record, clauses separated by OR are
+ * alternatives: `milk eggs OR bread` matches records with milk and eggs,
+ * or with bread. Words in double quotes must follow each other, words
+ * ending with * match all terms starting with them. Words and phrases are
+ * analyzed as the records are, prefixes are only lowercased. Words
+ * without terms, like removed stop words, are ignored.
+ * @param 	bucket		name of bucket, see CreateTextIndex
+ * @param 	query		words, phrases and prefixes
+ */
+func (db *DumbDB) Search(bucket string, query string) ([]SearchHit, error) {
+	return db.SearchCtx(context.Background(), bucket, query)
+}
+
+/*
+ * SearchCtx
+ * Search with a context passed to the operation hooks. Returns ctx.Err()
+ * if ctx is done before the search completes.
+ */
+func (db *DumbDB) SearchCtx(ctx context.Context, bucket string, query string) (hits []SearchHit, err error) {
+	ctx, op := db.startOp(ctx, "Search", bucket, 0)
+	defer func() { db.finishOp(ctx, op, err) }()
+
+	hits = make([]SearchHit, 0)
+	err = db.view(ctx, func(tx backend.Tx) error {
+		idx, e := bucketTextIndex(tx, bucket)
+		if e != nil {
+			return e
+		}
+		if idx == nil {
+			return fmt.Errorf("%w: %s has no full-text index", ErrInvalidQuery, bucket)
+		}
+		a, e := idx.analyzer()
+		if e != nil {
+			return e
+		}
+		clauses, e := parseSearch(a, query)
+		if e != nil {
+			return e
+		}
+		bkt, fts := tx.Bucket([]byte(bucket)), tx.Bucket(textIndexBucket(bucket))
+		if bkt == nil || fts == nil {
+			return nil
+		}
+		s := &searcher{ctx: ctx, fts: fts, lengths: make(map[string]uint64)}
+		records, total, e := textStats(fts)
+		if e != nil || records == 0 {
+			return e
+		}
+		s.records, s.avg_length = float64(records), float64(total)/float64(records)
+
+		scores := make(map[string]float64)
+		for _, all := range clauses {
+			matched, e := s.matchAll(all)
+			if e != nil {
+				return e
+			}
+			for k, score := range matched {
+				scores[k] += score
+			}
+		}
+		for k, score := range scores {
+			if v := bkt.Get([]byte(k)); v != nil {
+				hits = append(hits, SearchHit{Key: []byte(k), Value: bytes.Clone(v), Score: score})
+			}
+		}
+		return nil
+	})
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return bytes.Compare(hits[i].Key, hits[j].Key) < 0
+	})
+	op.Results = len(hits)
+	return
+}
+
+/*
+ * parseSearch
+ * Clauses of query, any of which must match. A clause matches records
+ * matching all of its terms.
+ */
+func parseSearch(a text.Analyzer, query string) ([][]searchTerm, error) {
+	var clauses [][]searchTerm
+	var all []searchTerm
+	// Clause has words, which may all be ignored
+	seen, or := false, false
+	rest := strings.TrimSpace(query)
+	for rest != "" {
+		var word string
+		if rest[0] == '"' {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("%w: unterminated phrase in %q", ErrInvalidQuery, query)
+			}
+			word, rest = rest[:end+2], rest[end+2:]
+		} else {
+			end := strings.IndexFunc(rest, func(r rune) bool { return unicode.IsSpace(r) || r == '"' })
+			if end < 0 {
+				end = len(rest)
+			}
+			word, rest = rest[:end], rest[end:]
+		}
+		rest = strings.TrimLeftFunc(rest, unicode.IsSpace)
+
+		switch {
+		case word == "OR":
+			if !seen {
+				return nil, fmt.Errorf("%w: OR without words before it in %q", ErrInvalidQuery, query)
+			}
+			if len(all) > 0 {
+				clauses = append(clauses, all)
+			}
+			all, seen, or = nil, false, true
+			continue
+		case word == "AND":
+			continue
+		case word[0] == '"':
+			if words := a.Analyze(word[1 : len(word)-1]); len(words) > 0 {
+				all = append(all, searchTerm{words: words})
+			}
+		case strings.HasSuffix(word, "*"):
+			prefix := strings.ToLower(strings.TrimRight(word, "*"))
+			if prefix == "" {
+				return nil, fmt.Errorf("%w: empty prefix in %q", ErrInvalidQuery, query)
+			}
+			all = append(all, searchTerm{prefix: prefix})
+		default:
+			if words := a.Analyze(word); len(words) > 0 {
+				all = append(all, searchTerm{words: words})
+			}
+		}
+		seen = true
+	}
+	if !seen {
+		if or {
+			return nil, fmt.Errorf("%w: OR without words after it in %q", ErrInvalidQuery, query)
+		}
+		return nil, fmt.Errorf("%w: empty query", ErrInvalidQuery)
+	}
+	if len(all) > 0 {
+		clauses = append(clauses, all)
+	}
+	return clauses, nil
+}
+
+/*
+ * searcher
+ * Scores the records of a full-text index within a transaction.
+ */
+type searcher struct {
+	ctx        context.Context
+	fts        backend.Bucket
+	records    float64
+	avg_length float64
+	// No of terms of records, by key
+	lengths map[string]uint64
+}
+
+/*
+ * matchAll
+ * Scores of the records matching all of the terms.
+ */
+func (s *searcher) matchAll(all []searchTerm) (map[string]float64, error) {
+	var scores map[string]float64
+	for _, t := range all {
+		matched, err := s.match(t)
+		if err != nil {
+			return nil, err
+		}
+		if scores == nil {
+			scores = matched
+			continue
+		}
+		for k := range scores {
+			if score, ok := matched[k]; ok {
+				scores[k] += score
+			} else {
+				delete(scores, k)
+			}
+		}
+	}
+	return scores, nil
+}
+
+/*
+ * match
+ * Scores of the records matching t, the sum of the BM25 scores of its
+ * terms.
+ */
+func (s *searcher) match(t searchTerm) (map[string]float64, error) {
+	scores := make(map[string]float64)
+	if t.prefix != "" {
+		postings, err := s.postings(termPrefix(t.prefix))
+		if err != nil {
+			return nil, err
+		}
+		for _, docs := range postings {
+			if err = s.score(scores, docs, len(docs)); err != nil {
+				return nil, err
+			}
+		}
+		return scores, nil
+	}
+
+	phrase := make([]map[string][]uint64, len(t.words))
+	for i, w := range t.words {
+		postings, err := s.postings(keys.MustEncode(TEXT_POSTING, w))
+		if err != nil {
+			return nil, err
+		}
+		phrase[i] = postings[w]
+	}
+	matched := make(map[string]bool)
+	for k, starts := range phrase[0] {
+		for _, p := range starts {
+			if inPhrase(phrase, k, p) {
+				matched[k] = true
+				break
+			}
+		}
+	}
+	for _, docs := range phrase {
+		found := make(map[string][]uint64, len(matched))
+		for k := range matched {
+			found[k] = docs[k]
+		}
+		if err := s.score(scores, found, len(docs)); err != nil {
+			return nil, err
+		}
+	}
+	return scores, nil
+}
+
+/*
+ * inPhrase
+ * The words of phrase follow each other in the record key, starting at
+ * position p.
+ */
+func inPhrase(phrase []map[string][]uint64, key string, p uint64) bool {
+	for i := 1; i < len(phrase); i++ {
+		pos := phrase[i][key]
+		j := sort.Search(len(pos), func(j int) bool { return pos[j] >= p+uint64(i) })
+		if j == len(pos) || pos[j] != p+uint64(i) {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+ * postings
+ * Positions of the terms starting with prefix, by term and record key.
+ */
+func (s *searcher) postings(prefix []byte) (map[string]map[string][]uint64, error) {
+	postings := make(map[string]map[string][]uint64)
+	err := scanCursor(s.ctx, s.fts.Cursor(), ScanOptions{Prefix: prefix}, func(k, v []byte) error {
+		tuple, err := keys.Decode(k)
+		if err != nil || len(tuple) != 3 {
+			return errCorruptTextIndex
+		}
+		t, _ := tuple[1].(string)
+		key, _ := tuple[2].([]byte)
+		var pos []uint64
+		prev := uint64(0)
+		for len(v) > 0 {
+			d, n := binary.Uvarint(v)
+			if n <= 0 {
+				return errCorruptTextIndex
+			}
+			prev += d
+			pos = append(pos, prev)
+			v = v[n:]
+		}
+		if postings[t] == nil {
+			postings[t] = make(map[string][]uint64)
+		}
+		postings[t][string(key)] = pos
+		return nil
+	})
+	return postings, err
+}
+
+/*
+ * score
+ * Add the BM25 scores of a term to scores, for the records in docs.
+ * The term is in df records.
+ */
+func (s *searcher) score(scores map[string]float64, docs map[string][]uint64, df int) error {
+	idf := math.Log(1 + (s.records-float64(df)+0.5)/(float64(df)+0.5))
+	for k, pos := range docs {
+		length, err := s.length(k)
+		if err != nil {
+			return err
+		}
+		tf := float64(len(pos))
+		norm := 1 - BM25_B + BM25_B*float64(length)/s.avg_length
+		scores[k] += idf * tf * (BM25_K1 + 1) / (tf + BM25_K1*norm)
+	}
+	return nil
+}
+
+func (s *searcher) length(key string) (uint64, error) {
+	if l, ok := s.lengths[key]; ok {
+		return l, nil
+	}
+	l, n := binary.Uvarint(s.fts.Get(keys.MustEncode(TEXT_DOC, []byte(key))))
+	if n <= 0 {
+		return 0, errCorruptTextIndex
+	}
+	s.lengths[key] = l
+	return l, nil
+}
@@ -0,0 +1,167 @@
+package server
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	dDB "dumbDB"
+)
+
+// Page size of a scan without a limit, and the largest page allowed.
+const (
+	DEFAULT_PAGE_SIZE = 100
+	MAX_PAGE_SIZE     = 1000
+)
+
+// Records streamed between flushes of an NDJSON scan.
+const NDJSON_FLUSH_EVERY = 64
+
+/*
+ * scanRecord
+ * A record in a scan response. Value is base64 in JSON.
+ */
+type scanRecord struct {
+	Key   string `json:"key"`
+	Value []byte `json:"value"`
+}
+
+/*
+ * handleScan
+ * Records of a bucket in key order. Query parameters:
+ *
+ *	prefix, start, end	key bounds in key_enc, end is exclusive
+ *	reverse			walk from the largest key down
+ *	limit			records per page, at most MAX_PAGE_SIZE
+ *	page_token		next_page_token of the previous page
+ *
+ * Responds with {"records": [...], "next_page_token": "..."}, the token
+ * being empty on the last page. With Accept: application/x-ndjson (or
+ * format=ndjson) all records, up to limit if given, are streamed as one
+ * JSON object per line instead.
+ */
+func (h *handler) handleScan(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	key_enc := q.Get("key_enc")
+	opts := dDB.ScanOptions{}
+	bounds := []struct {
+		param string
+		dst   *[]byte
+	}{{"prefix", &opts.Prefix}, {"start", &opts.Start}, {"end", &opts.End}}
+	for _, b := range bounds {
+		if q.Get(b.param) == "" {
+			continue
+		}
+		v, err := decodeKey(q.Get(b.param), key_enc)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		*b.dst = v
+	}
+
+	var err error
+	if v := q.Get("reverse"); v != "" {
+		if opts.Reverse, err = strconv.ParseBool(v); err != nil {
+			writeError(w, badRequest(errors.New("invalid reverse")))
+			return
+		}
+	}
+	limit := 0
+	if v := q.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 0 {
+			writeError(w, badRequest(errors.New("invalid limit")))
+			return
+		}
+	}
+	if tok := q.Get("page_token"); tok != "" {
+		bound, err := base64.RawURLEncoding.DecodeString(tok)
+		if err != nil {
+			writeError(w, badRequest(errors.New("invalid page_token")))
+			return
+		}
+		// The token is the first key of the page, End is exclusive.
+		if opts.Reverse {
+			opts.End = append(bound, 0)
+		} else {
+			opts.Start = bound
+		}
+	}
+
+	if q.Get("format") == "ndjson" || strings.Contains(r.Header.Get("Accept"), "application/x-ndjson") {
+		opts.Limit = limit
+		h.streamScan(w, r, opts, key_enc)
+		return
+	}
+
+	if limit == 0 {
+		limit = DEFAULT_PAGE_SIZE
+	}
+	if limit > MAX_PAGE_SIZE {
+		limit = MAX_PAGE_SIZE
+	}
+	opts.Limit = limit + 1
+
+	records := make([]scanRecord, 0)
+	next := ""
+	err = h.db.ScanCtx(r.Context(), r.PathValue("bucket"), opts, func(k, v []byte) error {
+		if len(records) == limit {
+			next = base64.RawURLEncoding.EncodeToString(k)
+			return dDB.ErrStopScan
+		}
+		records = append(records, scanRecord{encodeKey(k, key_enc), append([]byte(nil), v...)})
+		return nil
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"records": records, "next_page_token": next})
+}
+
+/*
+ * streamScan
+ * Write the records of a scan as NDJSON while the scan runs. Errors after
+ * the first record can only be reported as a final {"error": ...} line.
+ */
+func (h *handler) streamScan(w http.ResponseWriter, r *http.Request, opts dDB.ScanOptions, key_enc string) {
+	flusher, _ := w.(http.Flusher)
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	n := 0
+	err := h.db.ScanCtx(r.Context(), r.PathValue("bucket"), opts, func(k, v []byte) error {
+		if n == 0 {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.WriteHeader(http.StatusOK)
+		}
+		n++
+		if e := enc.Encode(scanRecord{encodeKey(k, key_enc), v}); e != nil {
+			return e
+		}
+		if n%NDJSON_FLUSH_EVERY == 0 {
+			if e := bw.Flush(); e != nil {
+				return e
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		return nil
+	})
+	if err != nil && n == 0 {
+		writeError(w, err)
+		return
+	}
+	if n == 0 {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.WriteHeader(http.StatusOK)
+	}
+	if err != nil {
+		enc.Encode(map[string]string{"error": err.Error()})
+	}
+	bw.Flush()
+}
@@ -0,0 +1,429 @@
+/*
+ * Package server exposes a DumbDB over HTTP with JSON bodies.
+ *
+ *	GET    /buckets                          bucket names
+ *	DELETE /buckets/{bucket}                 remove a bucket
+ *	GET    /buckets/{bucket}/keys            scan, see handleScan
+ *	GET    /buckets/{bucket}/keys/{key}      value, with an ETag
+ *	PUT    /buckets/{bucket}/keys/{key}      store the request body
+ *	DELETE /buckets/{bucket}/keys/{key}      remove a key
+ *	POST   /batch                            atomic batch of writes
+ *
+ * Keys in paths and JSON are utf8 unless the key_enc query parameter
+ * selects hex, base64 (URL alphabet, no padding) or uint64 (little-endian).
+ * Values in JSON bodies are base64 encoded. PUT and DELETE honour If-Match
+ * and If-None-Match, failing with 412 Precondition Failed.
+ */
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	dDB "dumbDB"
+
+	"github.com/boltdb/bolt"
+)
+
+// Largest request body accepted by PUT and POST /batch.
+const MAX_BODY_SIZE = 32 << 20
+
+var ErrPreconditionFailed = errors.New("precondition failed")
+
+type handler struct {
+	db  *dDB.DumbDB
+	mux *http.ServeMux
+}
+
+/*
+ * NewHandler
+ * http.Handler serving the key-value API of db.
+ * @param 	db		DB to serve, it is not closed by the handler
+ */
+func NewHandler(db *dDB.DumbDB) http.Handler {
+	h := &handler{db: db, mux: http.NewServeMux()}
+	h.mux.HandleFunc("GET /buckets", h.handleBuckets)
+	h.mux.HandleFunc("DELETE /buckets/{bucket}", userBucket(h.handleRemoveBucket))
+	h.mux.HandleFunc("GET /buckets/{bucket}/keys", userBucket(h.handleScan))
+	h.mux.HandleFunc("GET /buckets/{bucket}/keys/{key}", userBucket(h.handleGet))
+	h.mux.HandleFunc("PUT /buckets/{bucket}/keys/{key}", userBucket(h.handlePut))
+	h.mux.HandleFunc("DELETE /buckets/{bucket}/keys/{key}", userBucket(h.handleDelete))
+	h.mux.HandleFunc("POST /batch", h.handleBatch)
+	return h
+}
+
+/*
+ * userBucket
+ * Serve the bucket routes only for buckets of the user, the hidden buckets
+ * of dumbDB are neither read nor written over HTTP.
+ */
+func userBucket(fn http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.PathValue("bucket"), dDB.HIDDEN_BUCKET_PREFIX) {
+			writeError(w, dDB.ErrHiddenBucket)
+			return
+		}
+		fn(w, r)
+	}
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+/*
+ * ETag
+ * Strong entity tag of a value, quoted as in the ETag header.
+ */
+func ETag(val []byte) string {
+	sum := sha256.Sum256(val)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+func (h *handler) handleBuckets(w http.ResponseWriter, r *http.Request) {
+	names, err := h.db.BucketsCtx(r.Context())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"buckets": names})
+}
+
+func (h *handler) handleRemoveBucket(w http.ResponseWriter, r *http.Request) {
+	if err := h.db.RemoveBucketCtx(r.Context(), r.PathValue("bucket")); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) handleGet(w http.ResponseWriter, r *http.Request) {
+	key, err := decodeKey(r.PathValue("key"), r.URL.Query().Get("key_enc"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	val, err := h.db.GetCtx(r.Context(), key, r.PathValue("bucket"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	etag := ETag(val)
+	w.Header().Set("ETag", etag)
+	if matchETag(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	if json.Valid(val) {
+		w.Header().Set("Content-Type", "application/json")
+	} else {
+		w.Header().Set("Content-Type", "application/octet-stream")
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(val)))
+	w.Write(val)
+}
+
+func (h *handler) handlePut(w http.ResponseWriter, r *http.Request) {
+	key, err := decodeKey(r.PathValue("key"), r.URL.Query().Get("key_enc"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	val, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MAX_BODY_SIZE))
+	if err != nil {
+		writeError(w, badRequest(err))
+		return
+	}
+
+	bucket := r.PathValue("bucket")
+	cond := condition{if_match: r.Header.Get("If-Match"), if_none_match: r.Header.Get("If-None-Match")}
+	created := false
+	err = h.db.UpdateCtx(r.Context(), func(tx *dDB.Tx) error {
+		exists, e := cond.check(tx, key, bucket)
+		if e != nil {
+			return e
+		}
+		created = !exists
+		return tx.Store([][]byte{key, val}, bucket)
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("ETag", ETag(val))
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	} else {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (h *handler) handleDelete(w http.ResponseWriter, r *http.Request) {
+	key, err := decodeKey(r.PathValue("key"), r.URL.Query().Get("key_enc"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	bucket := r.PathValue("bucket")
+	cond := condition{if_match: r.Header.Get("If-Match"), if_none_match: r.Header.Get("If-None-Match")}
+	err = h.db.UpdateCtx(r.Context(), func(tx *dDB.Tx) error {
+		exists, e := cond.check(tx, key, bucket)
+		if e != nil {
+			return e
+		}
+		if !exists {
+			return bolt.ErrKeyRequired
+		}
+		return tx.Remove(key, bucket)
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+/*
+ * condition
+ * If-Match and If-None-Match headers of a write.
+ */
+type condition struct {
+	if_match      string
+	if_none_match string
+}
+
+/*
+ * check
+ * Compare the conditions with the current value of key inside the writing
+ * transaction, so no other write can come in between.
+ * @returns 	exists		whether key has a value
+ */
+func (c condition) check(tx *dDB.Tx, key []byte, bucket string) (exists bool, err error) {
+	val, err := tx.Get(key, bucket)
+	if err != nil && err != bolt.ErrKeyRequired && err != bolt.ErrBucketNotFound {
+		return false, err
+	}
+	exists = err == nil
+	etag := ""
+	if exists {
+		etag = ETag(val)
+	}
+	if c.if_match != "" && (!exists || !matchETag(c.if_match, etag)) {
+		return exists, ErrPreconditionFailed
+	}
+	if c.if_none_match != "" && exists && matchETag(c.if_none_match, etag) {
+		return exists, ErrPreconditionFailed
+	}
+	return exists, nil
+}
+
+/*
+ * matchETag
+ * Whether an If-Match / If-None-Match header value matches etag. "*"
+ * matches any existing value.
+ */
+func matchETag(header string, etag string) bool {
+	if header == "" || etag == "" {
+		return false
+	}
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimPrefix(strings.TrimSpace(t), "W/")
+		if t == "*" || t == etag {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+ * batchWrite
+ * One write of POST /batch. Value is base64 in JSON.
+ */
+type batchWrite struct {
+	Bucket  string `json:"bucket"`
+	Key     string `json:"key"`
+	Value   []byte `json:"value,omitempty"`
+	Delete  bool   `json:"delete,omitempty"`
+	IfMatch string `json:"if_match,omitempty"`
+}
+
+/*
+ * handleBatch
+ * Apply {"writes": [...]} in one transaction. If any write fails nothing
+ * is written and the index of the failed write is returned.
+ */
+func (h *handler) handleBatch(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Writes []batchWrite `json:"writes"`
+	}
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, MAX_BODY_SIZE))
+	if err := dec.Decode(&req); err != nil {
+		writeError(w, badRequest(err))
+		return
+	}
+
+	key_enc := r.URL.Query().Get("key_enc")
+	keys := make([][]byte, len(req.Writes))
+	for i, bw := range req.Writes {
+		key, err := decodeKey(bw.Key, key_enc)
+		if err == nil && bw.Bucket == "" {
+			err = badRequest(errors.New("bucket required"))
+		}
+		if err != nil {
+			writeError(w, fmt.Errorf("write %d: %w", i, err))
+			return
+		}
+		keys[i] = key
+	}
+
+	etags := make([]string, len(req.Writes))
+	err := h.db.UpdateCtx(r.Context(), func(tx *dDB.Tx) error {
+		for i, bw := range req.Writes {
+			exists, e := condition{if_match: bw.IfMatch}.check(tx, keys[i], bw.Bucket)
+			if e == nil && bw.Delete {
+				if exists {
+					e = tx.Remove(keys[i], bw.Bucket)
+				}
+			} else if e == nil {
+				if bw.Value == nil {
+					bw.Value = []byte{}
+				}
+				e = tx.Store([][]byte{keys[i], bw.Value}, bw.Bucket)
+				etags[i] = ETag(bw.Value)
+			}
+			if e != nil {
+				return fmt.Errorf("write %d: %w", i, e)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"written": len(req.Writes), "etags": etags})
+}
+
+/*
+ * decodeKey
+ * Key from a URL path or JSON body in the encoding selected by key_enc.
+ */
+func decodeKey(s string, key_enc string) ([]byte, error) {
+	var key []byte
+	var err error
+	switch key_enc {
+	case "", "utf8":
+		key = []byte(s)
+	case "hex":
+		key, err = hex.DecodeString(s)
+	case "base64":
+		key, err = base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	case "uint64":
+		var n uint64
+		if n, err = strconv.ParseUint(s, 10, 64); err == nil {
+			key = make([]byte, 8)
+			binary.LittleEndian.PutUint64(key, n)
+		}
+	default:
+		err = fmt.Errorf("unknown key_enc %q", key_enc)
+	}
+	if err != nil {
+		return nil, badRequest(err)
+	}
+	if len(key) == 0 {
+		return nil, badRequest(errors.New("key required"))
+	}
+	return key, nil
+}
+
+/*
+ * encodeKey
+ * Inverse of decodeKey. uint64 keys which are not 8 bytes long are
+ * returned as hex.
+ */
+func encodeKey(key []byte, key_enc string) string {
+	switch key_enc {
+	case "hex":
+		return hex.EncodeToString(key)
+	case "base64":
+		return base64.RawURLEncoding.EncodeToString(key)
+	case "uint64":
+		if len(key) == 8 {
+			return strconv.FormatUint(binary.LittleEndian.Uint64(key), 10)
+		}
+		return hex.EncodeToString(key)
+	}
+	return string(key)
+}
+
+type requestError struct {
+	err error
+}
+
+func (e requestError) Error() string { return e.err.Error() }
+func (e requestError) Unwrap() error { return e.err }
+
+func badRequest(err error) error {
+	return requestError{err}
+}
+
+/*
+ * statusOf
+ * HTTP status for an error returned by DumbDB or the handler.
+ */
+func statusOf(err error) int {
+	var req_err requestError
+	var val_err *dDB.ValidationError
+	switch {
+	case errors.As(err, &req_err):
+		return http.StatusBadRequest
+	case errors.As(err, &val_err):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, ErrPreconditionFailed):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, bolt.ErrBucketNotFound), errors.Is(err, bolt.ErrKeyRequired):
+		return http.StatusNotFound
+	case errors.Is(err, bolt.ErrKeyTooLarge), errors.Is(err, bolt.ErrValueTooLarge),
+		errors.Is(err, bolt.ErrBucketNameRequired):
+		return http.StatusBadRequest
+	case errors.Is(err, bolt.ErrDatabaseReadOnly), errors.Is(err, dDB.ErrHiddenBucket):
+		return http.StatusForbidden
+	case errors.Is(err, bolt.ErrDatabaseNotOpen):
+		return http.StatusServiceUnavailable
+	}
+	var max_err *http.MaxBytesError
+	if errors.As(err, &max_err) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusInternalServerError
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := statusOf(err)
+	msg := err.Error()
+	if errors.Is(err, bolt.ErrKeyRequired) {
+		msg = "key not found"
+	}
+	var val_err *dDB.ValidationError
+	if errors.As(err, &val_err) {
+		writeJSON(w, status, map[string]interface{}{"error": msg, "errors": val_err.Errors})
+		return
+	}
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,313 @@
+package dumbDatabase
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"dumbDB/backend"
+
+	"github.com/boltdb/bolt"
+)
+
+/*
+ * Stats
+ * Snapshot of the DB statistics returned by DumbDB.Stats.
+ * Use Sub to get the activity between two snapshots.
+ */
+type Stats struct {
+	Time     time.Time
+	FileSize int64
+	Pages    PageStats
+	Tx       TxStats
+	Buckets  map[string]BucketStats
+	Ops      OpStats
+}
+
+// Freelist and read transaction stats from the backend.
+type PageStats struct {
+	FreePageN     int
+	PendingPageN  int
+	FreeAlloc     int
+	FreelistInuse int
+	TxN           int
+	OpenTxN       int
+}
+
+// Page, node and write activity of backend transactions.
+type TxStats struct {
+	PageCount     int
+	PageAlloc     int
+	CursorCount   int
+	NodeCount     int
+	NodeDeref     int
+	Rebalance     int
+	RebalanceTime time.Duration
+	Split         int
+	Spill         int
+	SpillTime     time.Duration
+	Write         int
+	WriteTime     time.Duration
+}
+
+// Size of a user bucket. Sizes are in bytes.
+type BucketStats struct {
+	KeyN      int
+	Depth     int
+	PageN     int
+	Allocated int
+	InUse     int
+}
+
+// Counters of dumbDB operations since the DB was opened.
+type OpStats struct {
+	Gets    uint64
+	Hits    uint64
+	Misses  uint64
+	Stores  uint64
+	Removes uint64
+	Scans   uint64
+	Errors  uint64
+	// Keyed by operation name, e.g. "Get", "Store", "GetAll"
+	Latency map[string]LatencyStats
+	// Time spent in backend transactions, keyed by "read" and "write"
+	Transactions map[string]LatencyStats
+}
+
+// Upper bounds of the latency histogram buckets.
+var LATENCY_BUCKETS = []time.Duration{
+	10 * time.Microsecond,
+	50 * time.Microsecond,
+	100 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+}
+
+/*
+ * LatencyStats
+ * Buckets[i] counts the samples in (LATENCY_BUCKETS[i-1], LATENCY_BUCKETS[i]],
+ * the last entry counts samples above the largest bound.
+ */
+type LatencyStats struct {
+	Count   uint64
+	Total   time.Duration
+	Max     time.Duration
+	Buckets []uint64
+}
+
+func (l *LatencyStats) observe(d time.Duration) {
+	if l.Buckets == nil {
+		l.Buckets = make([]uint64, len(LATENCY_BUCKETS)+1)
+	}
+	i := 0
+	for i < len(LATENCY_BUCKETS) && d > LATENCY_BUCKETS[i] {
+		i++
+	}
+	l.Buckets[i]++
+	l.Count++
+	l.Total += d
+	if d > l.Max {
+		l.Max = d
+	}
+}
+
+func (l LatencyStats) sub(prev LatencyStats) LatencyStats {
+	diff := LatencyStats{
+		Count:   l.Count - prev.Count,
+		Total:   l.Total - prev.Total,
+		Max:     l.Max,
+		Buckets: make([]uint64, len(l.Buckets)),
+	}
+	for i := range l.Buckets {
+		diff.Buckets[i] = l.Buckets[i]
+		if i < len(prev.Buckets) {
+			diff.Buckets[i] -= prev.Buckets[i]
+		}
+	}
+	return diff
+}
+
+func (l LatencyStats) Mean() time.Duration {
+	if l.Count == 0 {
+		return 0
+	}
+	return l.Total / time.Duration(l.Count)
+}
+
+type opCounters struct {
+	mu  sync.Mutex
+	ops OpStats
+}
+
+func isMiss(op string, err error) bool {
+	return (op == "Get" && err == bolt.ErrKeyRequired) ||
+		(op == "GetMultiple" && err == bolt.ErrInvalid)
+}
+
+func (c *opCounters) record(op string, d time.Duration, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch op {
+	case "Get", "GetMultiple":
+		c.ops.Gets++
+		if err == nil {
+			c.ops.Hits++
+		}
+	case "Store":
+		c.ops.Stores++
+	case "Remove", "RemoveBucket":
+		c.ops.Removes++
+	case "GetAll", "GetLimited", "Scan":
+		c.ops.Scans++
+	}
+	if isMiss(op, err) {
+		c.ops.Misses++
+	} else if err != nil {
+		c.ops.Errors++
+	}
+
+	if c.ops.Latency == nil {
+		c.ops.Latency = make(map[string]LatencyStats)
+	}
+	l := c.ops.Latency[op]
+	l.observe(d)
+	c.ops.Latency[op] = l
+}
+
+func (c *opCounters) recordTx(writable bool, d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	typ := "read"
+	if writable {
+		typ = "write"
+	}
+	if c.ops.Transactions == nil {
+		c.ops.Transactions = make(map[string]LatencyStats)
+	}
+	l := c.ops.Transactions[typ]
+	l.observe(d)
+	c.ops.Transactions[typ] = l
+}
+
+func copyLatencies(m map[string]LatencyStats) map[string]LatencyStats {
+	cp := make(map[string]LatencyStats, len(m))
+	for k, l := range m {
+		l.Buckets = append([]uint64(nil), l.Buckets...)
+		cp[k] = l
+	}
+	return cp
+}
+
+func (c *opCounters) snapshot() OpStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.ops
+	s.Latency = copyLatencies(c.ops.Latency)
+	s.Transactions = copyLatencies(c.ops.Transactions)
+	return s
+}
+
+/*
+ * Stats
+ * Returns the current statistics of the DB.
+ */
+func (db *DumbDB) Stats() (st Stats, err error) {
+	st.Time = time.Now()
+	st.Ops = db.counters.snapshot()
+
+	bs := db.dbP.Stats()
+	st.Pages = PageStats{
+		FreePageN:     bs.FreePageN,
+		PendingPageN:  bs.PendingPageN,
+		FreeAlloc:     bs.FreeAlloc,
+		FreelistInuse: bs.FreelistInuse,
+		TxN:           bs.TxN,
+		OpenTxN:       bs.OpenTxN,
+	}
+	st.Tx = TxStats{
+		PageCount:     bs.Tx.PageCount,
+		PageAlloc:     bs.Tx.PageAlloc,
+		CursorCount:   bs.Tx.CursorCount,
+		NodeCount:     bs.Tx.NodeCount,
+		NodeDeref:     bs.Tx.NodeDeref,
+		Rebalance:     bs.Tx.Rebalance,
+		RebalanceTime: bs.Tx.RebalanceTime,
+		Split:         bs.Tx.Split,
+		Spill:         bs.Tx.Spill,
+		SpillTime:     bs.Tx.SpillTime,
+		Write:         bs.Tx.Write,
+		WriteTime:     bs.Tx.WriteTime,
+	}
+
+	if path := db.dbP.Path(); path != "" {
+		if fi, e := os.Stat(path); e == nil {
+			st.FileSize = fi.Size()
+		}
+	}
+
+	st.Buckets = make(map[string]BucketStats)
+	err = db.view(context.Background(), func(tx backend.Tx) error {
+		return tx.ForEach(func(name []byte, b backend.Bucket) error {
+			if isHiddenBucket(name) {
+				return nil
+			}
+			st.Buckets[string(name)] = BucketStats(b.Stats())
+			return nil
+		})
+	})
+	return
+}
+
+/*
+ * Sub
+ * Returns the difference between two snapshots, s taken after prev.
+ * Counters are subtracted, gauges like sizes and Max latencies are kept from s.
+ */
+func (s Stats) Sub(prev Stats) Stats {
+	diff := s
+	diff.Pages.TxN = s.Pages.TxN - prev.Pages.TxN
+
+	diff.Tx = TxStats{
+		PageCount:     s.Tx.PageCount - prev.Tx.PageCount,
+		PageAlloc:     s.Tx.PageAlloc - prev.Tx.PageAlloc,
+		CursorCount:   s.Tx.CursorCount - prev.Tx.CursorCount,
+		NodeCount:     s.Tx.NodeCount - prev.Tx.NodeCount,
+		NodeDeref:     s.Tx.NodeDeref - prev.Tx.NodeDeref,
+		Rebalance:     s.Tx.Rebalance - prev.Tx.Rebalance,
+		RebalanceTime: s.Tx.RebalanceTime - prev.Tx.RebalanceTime,
+		Split:         s.Tx.Split - prev.Tx.Split,
+		Spill:         s.Tx.Spill - prev.Tx.Spill,
+		SpillTime:     s.Tx.SpillTime - prev.Tx.SpillTime,
+		Write:         s.Tx.Write - prev.Tx.Write,
+		WriteTime:     s.Tx.WriteTime - prev.Tx.WriteTime,
+	}
+
+	diff.Ops = OpStats{
+		Gets:         s.Ops.Gets - prev.Ops.Gets,
+		Hits:         s.Ops.Hits - prev.Ops.Hits,
+		Misses:       s.Ops.Misses - prev.Ops.Misses,
+		Stores:       s.Ops.Stores - prev.Ops.Stores,
+		Removes:      s.Ops.Removes - prev.Ops.Removes,
+		Scans:        s.Ops.Scans - prev.Ops.Scans,
+		Errors:       s.Ops.Errors - prev.Ops.Errors,
+		Latency:      make(map[string]LatencyStats, len(s.Ops.Latency)),
+		Transactions: make(map[string]LatencyStats, len(s.Ops.Transactions)),
+	}
+	for op, l := range s.Ops.Latency {
+		diff.Ops.Latency[op] = l.sub(prev.Ops.Latency[op])
+	}
+	for typ, l := range s.Ops.Transactions {
+		diff.Ops.Transactions[typ] = l.sub(prev.Ops.Transactions[typ])
+	}
+	return diff
+}
@@ -0,0 +1,220 @@
+package structures
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	dDB "dumbDB"
+	"dumbDB/keys"
+
+	"github.com/boltdb/bolt"
+)
+
+/*
+ * List
+ * Sequence of values with O(1) push and pop at both ends. Items are stored
+ * under their position in the nested bucket LIST_ITEM, the positions of
+ * the first and last+1 item are kept in the LIST_META record.
+ */
+type List struct {
+	tx     *dDB.Tx
+	bucket string
+	name   string
+}
+
+/*
+ * NewList
+ * The list name in bucket, as seen by tx. A list which was never pushed to
+ * is empty.
+ * @param 	tx		transaction, writable for pushes and pops
+ * @param 	bucket		name of bucket
+ * @param 	name		name of the list
+ */
+func NewList(tx *dDB.Tx, bucket, name string) *List {
+	return &List{tx: tx, bucket: bucket, name: name}
+}
+
+func (l *List) meta() *dDB.Nested {
+	return l.tx.Nested(l.bucket, []byte(l.name))
+}
+
+func (l *List) items() *dDB.Nested {
+	return l.meta().Nested([]byte(LIST_ITEM))
+}
+
+func itemKey(pos int64) []byte {
+	return keys.MustEncode(pos)
+}
+
+func (l *List) bounds() (head, tail int64, err error) {
+	v, err := get(l.meta(), []byte(LIST_META))
+	if err != nil || v == nil {
+		return 0, 0, err
+	}
+	if len(v) != 16 {
+		return 0, 0, bolt.ErrIncompatibleValue
+	}
+	return int64(binary.BigEndian.Uint64(v)), int64(binary.BigEndian.Uint64(v[8:])), nil
+}
+
+func (l *List) setBounds(head, tail int64) error {
+	if head == tail {
+		return l.meta().Delete([]byte(LIST_META))
+	}
+	v := binary.BigEndian.AppendUint64(nil, uint64(head))
+	return l.meta().Put([]byte(LIST_META), binary.BigEndian.AppendUint64(v, uint64(tail)))
+}
+
+/*
+ * Len
+ * No of items in the list.
+ */
+func (l *List) Len() (int, error) {
+	head, tail, err := l.bounds()
+	return int(tail - head), err
+}
+
+/*
+ * LPush
+ * Insert vals at the head, one after the other, so the last one ends up
+ * first. Returns the new length.
+ */
+func (l *List) LPush(vals ...[]byte) (int, error) {
+	head, tail, err := l.bounds()
+	if err != nil || len(vals) == 0 {
+		return int(tail - head), err
+	}
+	for _, v := range vals {
+		head--
+		if err = l.items().Put(itemKey(head), v); err != nil {
+			return 0, err
+		}
+	}
+	return int(tail - head), l.setBounds(head, tail)
+}
+
+/*
+ * RPush
+ * Append vals at the tail. Returns the new length.
+ */
+func (l *List) RPush(vals ...[]byte) (int, error) {
+	head, tail, err := l.bounds()
+	if err != nil || len(vals) == 0 {
+		return int(tail - head), err
+	}
+	for _, v := range vals {
+		if err = l.items().Put(itemKey(tail), v); err != nil {
+			return 0, err
+		}
+		tail++
+	}
+	return int(tail - head), l.setBounds(head, tail)
+}
+
+/*
+ * LPop
+ * Remove and return the first item. Fails with ErrEmpty.
+ */
+func (l *List) LPop() ([]byte, error) {
+	head, tail, err := l.bounds()
+	if err != nil {
+		return nil, err
+	}
+	if head == tail {
+		return nil, ErrEmpty
+	}
+	return l.pop(head, head+1, tail)
+}
+
+/*
+ * RPop
+ * Remove and return the last item. Fails with ErrEmpty.
+ */
+func (l *List) RPop() ([]byte, error) {
+	head, tail, err := l.bounds()
+	if err != nil {
+		return nil, err
+	}
+	if head == tail {
+		return nil, ErrEmpty
+	}
+	return l.pop(tail-1, head, tail-1)
+}
+
+func (l *List) pop(pos, head, tail int64) ([]byte, error) {
+	v, err := get(l.items(), itemKey(pos))
+	if err != nil {
+		return nil, err
+	}
+	v = bytes.Clone(v)
+	if err = l.items().Delete(itemKey(pos)); err != nil {
+		return nil, err
+	}
+	return v, l.setBounds(head, tail)
+}
+
+/*
+ * Index
+ * Item at index i, negative indexes count from the tail (-1 is the last).
+ * Fails with ErrOutOfRange.
+ */
+func (l *List) Index(i int) ([]byte, error) {
+	head, tail, err := l.bounds()
+	if err != nil {
+		return nil, err
+	}
+	n := int(tail - head)
+	if i < 0 {
+		i += n
+	}
+	if i < 0 || i >= n {
+		return nil, ErrOutOfRange
+	}
+	v, err := get(l.items(), itemKey(head+int64(i)))
+	return bytes.Clone(v), err
+}
+
+/*
+ * Range
+ * Items from index start to stop, both included. Negative indexes count
+ * from the tail, Range(0, -1) returns all items. Indexes outside the list
+ * are clamped.
+ */
+func (l *List) Range(start, stop int) ([][]byte, error) {
+	head, tail, err := l.bounds()
+	if err != nil {
+		return nil, err
+	}
+	vals := make([][]byte, 0)
+	start, stop = clampRange(start, stop, int(tail-head))
+	if start > stop {
+		return vals, nil
+	}
+	opts := dDB.ScanOptions{Start: itemKey(head + int64(start)), End: itemKey(head + int64(stop) + 1)}
+	err = scan(l.items(), opts, func(k, v []byte) error {
+		vals = append(vals, bytes.Clone(v))
+		return nil
+	})
+	return vals, err
+}
+
+/*
+ * clampRange
+ * Resolve Redis style inclusive indexes into [0, n). start > stop if the
+ * range is empty.
+ */
+func clampRange(start, stop, n int) (int, int) {
+	if start < 0 {
+		start += n
+	}
+	if stop < 0 {
+		stop += n
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= n {
+		stop = n - 1
+	}
+	return start, stop
+}
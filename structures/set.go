@@ -0,0 +1,183 @@
+package structures
+
+import (
+	"bytes"
+	"sort"
+
+	dDB "dumbDB"
+	"dumbDB/keys"
+)
+
+/*
+ * Set
+ * Unordered collection of distinct members. Members are stored as keys
+ * of the nested bucket SET_MEMBER, so they are listed in byte order. The
+ * no of members is kept in the SET_CARD record.
+ */
+type Set struct {
+	tx     *dDB.Tx
+	bucket string
+	name   string
+}
+
+/*
+ * NewSet
+ * The set name in bucket, as seen by tx.
+ * @param 	tx		transaction, writable for adds and removes
+ * @param 	bucket		name of bucket
+ * @param 	name		name of the set
+ */
+func NewSet(tx *dDB.Tx, bucket, name string) *Set {
+	return &Set{tx: tx, bucket: bucket, name: name}
+}
+
+func (s *Set) meta() *dDB.Nested {
+	return s.tx.Nested(s.bucket, []byte(s.name))
+}
+
+func (s *Set) members() *dDB.Nested {
+	return s.meta().Nested([]byte(SET_MEMBER))
+}
+
+func memberKey(m []byte) []byte {
+	return keys.MustEncode(m)
+}
+
+/*
+ * Add
+ * Add members. Returns how many were not in the set yet.
+ */
+func (s *Set) Add(members ...[]byte) (int, error) {
+	added := 0
+	for _, m := range members {
+		ok, err := s.IsMember(m)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			continue
+		}
+		if err = s.members().Put(memberKey(m), []byte{}); err != nil {
+			return 0, err
+		}
+		added++
+	}
+	if added == 0 {
+		return 0, nil
+	}
+	_, err := addInt(s.meta(), []byte(SET_CARD), int64(added))
+	return added, err
+}
+
+/*
+ * Remove
+ * Remove members. Returns how many were in the set.
+ */
+func (s *Set) Remove(members ...[]byte) (int, error) {
+	removed := 0
+	for _, m := range members {
+		ok, err := s.IsMember(m)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			continue
+		}
+		if err = s.members().Delete(memberKey(m)); err != nil {
+			return 0, err
+		}
+		removed++
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+	_, err := addInt(s.meta(), []byte(SET_CARD), -int64(removed))
+	return removed, err
+}
+
+/*
+ * IsMember
+ * Whether m is in the set.
+ */
+func (s *Set) IsMember(m []byte) (bool, error) {
+	v, err := get(s.members(), memberKey(m))
+	return v != nil, err
+}
+
+/*
+ * Card
+ * No of members.
+ */
+func (s *Set) Card() (int, error) {
+	n, err := getInt(s.meta(), []byte(SET_CARD))
+	return int(n), err
+}
+
+/*
+ * Members
+ * All members in byte order.
+ */
+func (s *Set) Members() ([][]byte, error) {
+	members := make([][]byte, 0)
+	err := scan(s.members(), dDB.ScanOptions{}, func(k, _ []byte) error {
+		m, e := lastElement[[]byte](k)
+		if e != nil {
+			return e
+		}
+		members = append(members, m)
+		return nil
+	})
+	return members, err
+}
+
+/*
+ * Intersect
+ * Members which are also in all others, in byte order.
+ */
+func (s *Set) Intersect(others ...*Set) ([][]byte, error) {
+	members, err := s.Members()
+	if err != nil {
+		return nil, err
+	}
+	common := make([][]byte, 0, len(members))
+	for _, m := range members {
+		in_all := true
+		for _, o := range others {
+			ok, e := o.IsMember(m)
+			if e != nil {
+				return nil, e
+			}
+			if !ok {
+				in_all = false
+				break
+			}
+		}
+		if in_all {
+			common = append(common, m)
+		}
+	}
+	return common, nil
+}
+
+/*
+ * Union
+ * Members of the set or any of others, in byte order.
+ */
+func (s *Set) Union(others ...*Set) ([][]byte, error) {
+	all := make(map[string]struct{})
+	for _, set := range append([]*Set{s}, others...) {
+		members, err := set.Members()
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range members {
+			all[string(m)] = struct{}{}
+		}
+	}
+	union := make([][]byte, 0, len(all))
+	for m := range all {
+		union = append(union, []byte(m))
+	}
+	sort.Slice(union, func(i, j int) bool { return bytes.Compare(union[i], union[j]) < 0 })
+	return union, nil
+}
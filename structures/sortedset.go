@@ -0,0 +1,243 @@
+package structures
+
+import (
+	"encoding/binary"
+	"math"
+
+	dDB "dumbDB"
+	"dumbDB/keys"
+)
+
+/*
+ * SortedSet
+ * Members ordered by a float score, then by member. Each member has two
+ * records: its score by member in the nested bucket ZSET_MEMBER, and an
+ * empty one keyed by score and member in ZSET_SCORE which keeps the order.
+ */
+type SortedSet struct {
+	tx     *dDB.Tx
+	bucket string
+	name   string
+}
+
+/*
+ * Member
+ * Member of a sorted set with its score.
+ */
+type Member struct {
+	Value []byte
+	Score float64
+}
+
+/*
+ * NewSortedSet
+ * The sorted set name in bucket, as seen by tx.
+ * @param 	tx		transaction, writable for adds and removes
+ * @param 	bucket		name of bucket
+ * @param 	name		name of the sorted set
+ */
+func NewSortedSet(tx *dDB.Tx, bucket, name string) *SortedSet {
+	return &SortedSet{tx: tx, bucket: bucket, name: name}
+}
+
+func (z *SortedSet) meta() *dDB.Nested {
+	return z.tx.Nested(z.bucket, []byte(z.name))
+}
+
+func (z *SortedSet) members() *dDB.Nested {
+	return z.meta().Nested([]byte(ZSET_MEMBER))
+}
+
+func (z *SortedSet) scores() *dDB.Nested {
+	return z.meta().Nested([]byte(ZSET_SCORE))
+}
+
+func scoreKey(score float64, m []byte) []byte {
+	return keys.MustEncode(score, m)
+}
+
+/*
+ * Score
+ * Score of m. ok is false if m is not in the set.
+ */
+func (z *SortedSet) Score(m []byte) (score float64, ok bool, err error) {
+	v, err := get(z.members(), memberKey(m))
+	if err != nil || v == nil {
+		return 0, false, err
+	}
+	return math.Float64frombits(binary.BigEndian.Uint64(v)), true, nil
+}
+
+/*
+ * Add
+ * Add m with score, or change its score if it is in the set. Returns
+ * whether m was added.
+ */
+func (z *SortedSet) Add(m []byte, score float64) (bool, error) {
+	if math.IsNaN(score) {
+		return false, ErrInvalidScore
+	}
+	if score == 0 {
+		// -0 and 0 have different keys
+		score = 0
+	}
+	old, ok, err := z.Score(m)
+	if err != nil {
+		return false, err
+	}
+	if ok {
+		if old == score {
+			return false, nil
+		}
+		if err = z.scores().Delete(scoreKey(old, m)); err != nil {
+			return false, err
+		}
+	}
+	val := binary.BigEndian.AppendUint64(nil, math.Float64bits(score))
+	if err = z.members().Put(memberKey(m), val); err != nil {
+		return false, err
+	}
+	if err = z.scores().Put(scoreKey(score, m), []byte{}); err != nil {
+		return false, err
+	}
+	if ok {
+		return false, nil
+	}
+	_, err = addInt(z.meta(), []byte(ZSET_CARD), 1)
+	return true, err
+}
+
+/*
+ * IncrBy
+ * Add delta to the score of m, adding m with score delta if it is not in
+ * the set. Returns the new score.
+ */
+func (z *SortedSet) IncrBy(m []byte, delta float64) (float64, error) {
+	score, _, err := z.Score(m)
+	if err != nil {
+		return 0, err
+	}
+	score += delta
+	_, err = z.Add(m, score)
+	return score, err
+}
+
+/*
+ * Remove
+ * Remove members. Returns how many were in the set.
+ */
+func (z *SortedSet) Remove(members ...[]byte) (int, error) {
+	removed := 0
+	for _, m := range members {
+		score, ok, err := z.Score(m)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			continue
+		}
+		if err = z.members().Delete(memberKey(m)); err != nil {
+			return 0, err
+		}
+		if err = z.scores().Delete(scoreKey(score, m)); err != nil {
+			return 0, err
+		}
+		removed++
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+	_, err := addInt(z.meta(), []byte(ZSET_CARD), -int64(removed))
+	return removed, err
+}
+
+/*
+ * Card
+ * No of members.
+ */
+func (z *SortedSet) Card() (int, error) {
+	n, err := getInt(z.meta(), []byte(ZSET_CARD))
+	return int(n), err
+}
+
+/*
+ * Rank
+ * Position of m in score order, starting at 0. ok is false if m is not
+ * in the set. Takes time linear in the rank.
+ */
+func (z *SortedSet) Rank(m []byte) (rank int, ok bool, err error) {
+	score, ok, err := z.Score(m)
+	if err != nil || !ok {
+		return 0, false, err
+	}
+	err = scan(z.scores(), dDB.ScanOptions{End: scoreKey(score, m)}, func(_, _ []byte) error {
+		rank++
+		return nil
+	})
+	return rank, true, err
+}
+
+/*
+ * RangeByRank
+ * Members from rank start to stop, both included, in score order.
+ * Negative ranks count from the highest score, RangeByRank(0, -1) returns
+ * all members.
+ */
+func (z *SortedSet) RangeByRank(start, stop int) ([]Member, error) {
+	n, err := z.Card()
+	if err != nil {
+		return nil, err
+	}
+	members := make([]Member, 0)
+	start, stop = clampRange(start, stop, n)
+	if start > stop {
+		return members, nil
+	}
+	rank := 0
+	err = scan(z.scores(), dDB.ScanOptions{Limit: stop + 1}, func(k, _ []byte) error {
+		if rank >= start {
+			m, e := decodeMember(k)
+			if e != nil {
+				return e
+			}
+			members = append(members, m)
+		}
+		rank++
+		return nil
+	})
+	return members, err
+}
+
+/*
+ * RangeByScore
+ * Members with min <= score <= max, in score order.
+ */
+func (z *SortedSet) RangeByScore(min, max float64) ([]Member, error) {
+	members := make([]Member, 0)
+	opts := dDB.ScanOptions{Start: keys.MustEncode(min), End: keys.PrefixEnd(keys.MustEncode(max))}
+	err := scan(z.scores(), opts, func(k, _ []byte) error {
+		m, e := decodeMember(k)
+		if e != nil {
+			return e
+		}
+		members = append(members, m)
+		return nil
+	})
+	return members, err
+}
+
+func decodeMember(key []byte) (Member, error) {
+	tuple, err := keys.Decode(key)
+	if err != nil {
+		return Member{}, err
+	}
+	if len(tuple) != 2 {
+		return Member{}, keys.ErrInvalidKey
+	}
+	score, ok1 := tuple[0].(float64)
+	value, ok2 := tuple[1].([]byte)
+	if !ok1 || !ok2 {
+		return Member{}, keys.ErrInvalidKey
+	}
+	return Member{Value: value, Score: score}, nil
+}
@@ -0,0 +1,121 @@
+/*
+ * Package structures provides Redis-like lists, sets and sorted sets stored
+ * in dumbDB buckets. They work inside a transaction, so they can be updated
+ * atomically with each other and with plain records:
+ *
+ *	db.Update(func(tx *dDB.Tx) error {
+ *		if _, err := structures.NewList(tx, "Queues", "jobs").RPush(job); err != nil {
+ *			return err
+ *		}
+ *		_, err := structures.NewSet(tx, "Tags", "pending").Add(job_id)
+ *		return err
+ *	})
+ *
+ * A structure is identified by its name within a bucket. It is stored in
+ * a nested bucket of that name, see dDB.Nested, holding its counters and
+ * a nested bucket per kind of record. Keys are encoded with the keys
+ * package so they sort like the positions, members and scores in them.
+ * Several structures and other records can share a bucket, and a list,
+ * a set and a sorted set can share a name. Values returned are copies and
+ * stay valid after the transaction.
+ */
+package structures
+
+import (
+	"encoding/binary"
+	"errors"
+
+	dDB "dumbDB"
+	"dumbDB/keys"
+
+	"github.com/boltdb/bolt"
+)
+
+var (
+	// Returned when popping from an empty list.
+	ErrEmpty = errors.New("list is empty")
+
+	// Returned by List.Index for indexes outside the list.
+	ErrOutOfRange = errors.New("index out of range")
+
+	// Returned when adding a NaN score to a sorted set.
+	ErrInvalidScore = errors.New("score is NaN")
+)
+
+// Nested buckets and records in the bucket of a structure.
+const (
+	LIST_ITEM   = "list"
+	LIST_META   = "list.meta"
+	SET_MEMBER  = "set"
+	SET_CARD    = "set.card"
+	ZSET_MEMBER = "zset"
+	ZSET_SCORE  = "zset.score"
+	ZSET_CARD   = "zset.card"
+)
+
+/*
+ * get
+ * Value of key, nil if it or the nested bucket does not exist.
+ */
+func get(n *dDB.Nested, key []byte) ([]byte, error) {
+	v, err := n.Get(key)
+	if err == bolt.ErrKeyRequired || err == bolt.ErrBucketNotFound {
+		return nil, nil
+	}
+	return v, err
+}
+
+/*
+ * scan
+ * Scan which treats a missing nested bucket as empty.
+ */
+func scan(n *dDB.Nested, opts dDB.ScanOptions, fn func(k, v []byte) error) error {
+	err := n.Scan(opts, fn)
+	if err == bolt.ErrBucketNotFound {
+		return nil
+	}
+	return err
+}
+
+func getInt(n *dDB.Nested, key []byte) (int64, error) {
+	v, err := get(n, key)
+	if err != nil || v == nil {
+		return 0, err
+	}
+	if len(v) != 8 {
+		return 0, bolt.ErrIncompatibleValue
+	}
+	return int64(binary.BigEndian.Uint64(v)), nil
+}
+
+/*
+ * addInt
+ * Add delta to the counter at key, removing it when it drops to 0.
+ */
+func addInt(n *dDB.Nested, key []byte, delta int64) (int64, error) {
+	v, err := getInt(n, key)
+	if err != nil {
+		return 0, err
+	}
+	v += delta
+	if v == 0 {
+		return 0, n.Delete(key)
+	}
+	return v, n.Put(key, binary.BigEndian.AppendUint64(nil, uint64(v)))
+}
+
+/*
+ * lastElement
+ * Last element of the tuple key, which must be of type T.
+ */
+func lastElement[T any](key []byte) (v T, err error) {
+	tuple, err := keys.Decode(key)
+	if err != nil {
+		return v, err
+	}
+	v, ok := tuple[len(tuple)-1].(T)
+	if !ok {
+		return v, keys.ErrInvalidKey
+	}
+	return v, nil
+}
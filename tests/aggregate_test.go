@@ -0,0 +1,173 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	dDB "dumbDB"
+)
+
+// 1. Count, sum, min, max and avg orders per category, over the whole
+// bucket and over an index range.
+// 2. A materialized aggregate stays equal to the computed one through
+// Store, Modify, Remove and RemoveBucket.
+// 3. Min, Max and Avg are NaN without values.
+// 4. GetAggregateCtx and DropAggregateCtx return ctx.Err() of a done ctx.
+func TestAggregate(t *testing.T) {
+
+	dbName := "TestAggregate"
+	dbP := dDB.NewDumbDB(".", dbName, os.Stdout, testOpts...)
+	if dbP == nil {
+		t.Fatalf("Error creating DB %s", dbName)
+	}
+	defer removeDbFile(dbP.DbFullName)
+	defer dbP.Close()
+
+	store := func(id int, category string, total interface{}) {
+		v := fmt.Sprintf(`{"Category": %q, "Total": %v}`, category, total)
+		if err := dbP.Store([][]byte{[]byte(fmt.Sprint(id)), []byte(v)}, "Orders"); err != nil {
+			t.Fatalf("Error in Store Error: %s", err.Error())
+		}
+	}
+	store(1, "books", 10)
+	store(2, "books", 30)
+	store(3, "games", 60)
+	store(4, "music", `"n/a"`)
+	dbP.Store([][]byte{[]byte("5"), []byte("not json")}, "Orders")
+
+	aggs := []dDB.Aggregator{dDB.Count(), dDB.Sum("Total"), dDB.Min("Total"), dDB.Max("Total"), dDB.Avg("Total")}
+	groups, err := dbP.Query("Orders").GroupBy("Category").Aggregate(aggs...)
+	if err != nil {
+		t.Fatalf("Error in Aggregate Error: %s", err.Error())
+	}
+	if len(groups) != 3 || groups[0].Key != "books" ||
+		!reflect.DeepEqual(groups[0].Values, []float64{2, 40, 10, 30, 20}) {
+		t.Errorf("Incorrect books group Expected: [2 40 10 30 20] Got: %v", groups)
+	}
+	if v := groups[2].Values; groups[2].Key != "music" || v[0] != 1 || v[1] != 0 || !math.IsNaN(v[2]) || !math.IsNaN(v[4]) {
+		t.Errorf("Incorrect music group Expected: [1 0 NaN NaN NaN] Got: %v", v)
+	}
+
+	dbP.CreateIndex("Orders", "Total")
+	groups, _ = dbP.Query("Orders").Where("Total", ">", 10).Aggregate(dDB.Count(), dDB.Sum("Total"))
+	if len(groups) != 1 || groups[0].Key != nil || !reflect.DeepEqual(groups[0].Values, []float64{2, 90}) {
+		t.Errorf("Incorrect total Expected: [2 90] Got: %v", groups)
+	}
+
+	if err = dbP.CreateAggregate("Orders", "by_category", "Category", aggs...); err != nil {
+		t.Fatalf("Error in CreateAggregate Error: %s", err.Error())
+	}
+	same := func(step string) {
+		exp, _ := dbP.Query("Orders").GroupBy("Category").Aggregate(aggs...)
+		got, err := dbP.GetAggregate("Orders", "by_category")
+		if err != nil || fmt.Sprint(got) != fmt.Sprint(exp) {
+			t.Errorf("Incorrect aggregate after %s Expected: %v Got: %v %v", step, exp, got, err)
+		}
+	}
+	same("CreateAggregate")
+	store(6, "games", 5)
+	store(3, "books", 70)
+	same("Store")
+	dbP.Modify("Orders", []byte("2"), func(old []byte) ([]byte, error) {
+		return []byte(`{"Category": "music", "Total": 1}`), nil
+	})
+	dbP.Remove([]byte("1"), "Orders")
+	same("Remove")
+	if got, _ := dbP.GetAggregate("Orders", "by_category"); len(got) != 3 || got[0].Values[2] != 70 {
+		t.Errorf("Incorrect min of books Expected: %v Got: %v", 70, got)
+	}
+
+	dbP.RemoveBucket("Orders")
+	store(7, "books", 3)
+	same("RemoveBucket")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err = dbP.GetAggregateCtx(ctx, "Orders", "by_category"); err != context.Canceled {
+		t.Errorf("Incorrect error Expected: %v Got: %v", context.Canceled, err)
+	}
+	if err = dbP.DropAggregateCtx(ctx, "Orders", "by_category"); err != context.Canceled {
+		t.Errorf("Incorrect error Expected: %v Got: %v", context.Canceled, err)
+	}
+	if err = dbP.DropAggregate("Orders", "by_category"); err != nil {
+		t.Errorf("Error in DropAggregate Error: %v", err)
+	}
+	if _, err = dbP.GetAggregate("Orders", "by_category"); err == nil {
+		t.Errorf("Dropped aggregate still readable")
+	}
+}
+
+// 1. Records whose group value is too long for a key can be stored while
+// a materialized aggregate exists.
+// 2. The aggregate stays equal to the computed one, with long groups
+// sorted among the others.
+func TestAggregate_LongGroups(t *testing.T) {
+
+	dbName := "TestAggregate_LongGroups"
+	dbP := dDB.NewDumbDB(".", dbName, os.Stdout, testOpts...)
+	if dbP == nil {
+		t.Fatalf("Error creating DB %s", dbName)
+	}
+	defer removeDbFile(dbP.DbFullName)
+	defer dbP.Close()
+
+	aggs := []dDB.Aggregator{dDB.Count(), dDB.Sum("Total"), dDB.Max("Total")}
+	if err := dbP.CreateAggregate("Orders", "by_category", "Category", aggs...); err != nil {
+		t.Fatalf("Error in CreateAggregate Error: %s", err.Error())
+	}
+	a, z := "a"+strings.Repeat("x", 40000), "z"+strings.Repeat("x", 40000)
+	store := func(id int, category string, total int) {
+		v := fmt.Sprintf(`{"Category": %q, "Total": %d}`, category, total)
+		if err := dbP.Store([][]byte{[]byte(fmt.Sprint(id)), []byte(v)}, "Orders"); err != nil {
+			t.Fatalf("Error in Store Error: %s", err.Error())
+		}
+	}
+	same := func(step string, n int) {
+		exp, _ := dbP.Query("Orders").GroupBy("Category").Aggregate(aggs...)
+		got, err := dbP.GetAggregate("Orders", "by_category")
+		if err != nil || len(got) != n || !reflect.DeepEqual(got, exp) {
+			t.Errorf("Incorrect aggregate after %s Expected: %d groups Got: %d %v", step, len(exp), len(got), err)
+		}
+	}
+	store(1, z, 10)
+	store(2, "m", 20)
+	store(3, a, 30)
+	store(4, z, 40)
+	same("Store", 3)
+	dbP.Remove([]byte("3"), "Orders")
+	store(4, "m", 50)
+	same("Remove", 2)
+}
+
+// 1. -0 and 0 fall in the same group and are the same value.
+// 2. The materialized aggregate is equal to the computed one.
+func TestAggregate_NegativeZero(t *testing.T) {
+
+	dbName := "TestAggregate_NegativeZero"
+	dbP := dDB.NewDumbDB(".", dbName, os.Stdout, testOpts...)
+	if dbP == nil {
+		t.Fatalf("Error creating DB %s", dbName)
+	}
+	defer removeDbFile(dbP.DbFullName)
+	defer dbP.Close()
+
+	aggs := []dDB.Aggregator{dDB.Count(), dDB.Min("V"), dDB.Max("V")}
+	if err := dbP.CreateAggregate("B", "by_f", "F", aggs...); err != nil {
+		t.Fatalf("Error in CreateAggregate Error: %s", err.Error())
+	}
+	dbP.Store([][]byte{[]byte("a"), []byte(`{"F": -0, "V": 0}`)}, "B")
+	dbP.Store([][]byte{[]byte("b"), []byte(`{"F": 0, "V": -0}`)}, "B")
+
+	exp, _ := dbP.Query("B").GroupBy("F").Aggregate(aggs...)
+	got, err := dbP.GetAggregate("B", "by_f")
+	if err != nil || fmt.Sprint(got) != fmt.Sprint(exp) {
+		t.Errorf("Incorrect aggregate Expected: %v Got: %v %v", exp, got, err)
+	}
+	if fmt.Sprint(got) != "[{0 [2 0 0]}]" {
+		t.Errorf("Incorrect groups Expected: [{0 [2 0 0]}] Got: %v", got)
+	}
+}
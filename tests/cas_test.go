@@ -0,0 +1,88 @@
+package tests
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+
+	dDB "dumbDB"
+)
+
+// 1. PutIfAbsent, ReplaceIfExists, CompareAndSwap and DeleteIfEquals only
+//    write when the stored value matches, else return a *ConflictError.
+// 2. Concurrent CompareAndSwap loops on a counter lose no updates.
+func TestDumbDB_CompareAndSwap(t *testing.T) {
+
+	dbName := "TestDumbDB_CompareAndSwap"
+	dbP := dDB.NewDumbDB(".", dbName, os.Stdout, testOpts...)
+	if dbP == nil {
+		t.Fatalf("Error creating DB %s", dbName)
+	}
+	defer removeDbFile(dbP.DbFullName)
+	defer dbP.Close()
+
+	key := []byte("k")
+	if err := dbP.ReplaceIfExists(dbName, key, []byte("a")); !errors.Is(err, dDB.ErrConflict) {
+		t.Errorf("Incorrect error Expected: %v Got: %v", dDB.ErrConflict, err)
+	}
+	if err := dbP.PutIfAbsent(dbName, key, []byte("a")); err != nil {
+		t.Errorf("Error in PutIfAbsent Error: %v", err)
+	}
+	err := dbP.PutIfAbsent(dbName, key, []byte("b"))
+	var conflict *dDB.ConflictError
+	if !errors.As(err, &conflict) || string(conflict.Current) != "a" || conflict.Op != "PutIfAbsent" {
+		t.Errorf("Incorrect conflict Expected: current a Got: %v", err)
+	}
+	if err = dbP.ReplaceIfExists(dbName, key, []byte("b")); err != nil {
+		t.Errorf("Error in ReplaceIfExists Error: %v", err)
+	}
+	if err = dbP.CompareAndSwap(dbName, key, []byte("a"), []byte("c")); !errors.Is(err, dDB.ErrConflict) {
+		t.Errorf("Incorrect error Expected: %v Got: %v", dDB.ErrConflict, err)
+	}
+	if err = dbP.CompareAndSwap(dbName, key, []byte("b"), []byte("c")); err != nil {
+		t.Errorf("Error in CompareAndSwap Error: %v", err)
+	}
+	if err = dbP.CompareAndSwap(dbName, []byte("new"), nil, []byte("x")); err != nil {
+		t.Errorf("Error in CompareAndSwap of missing key Error: %v", err)
+	}
+	if err = dbP.DeleteIfEquals(dbName, key, []byte("b")); !errors.Is(err, dDB.ErrConflict) {
+		t.Errorf("Incorrect error Expected: %v Got: %v", dDB.ErrConflict, err)
+	}
+	if err = dbP.DeleteIfEquals(dbName, key, []byte("c")); err != nil {
+		t.Errorf("Error in DeleteIfEquals Error: %v", err)
+	}
+	if _, err = dbP.Get(key, dbName); err == nil {
+		t.Errorf("Key not deleted by DeleteIfEquals")
+	}
+
+	counter := []byte("counter")
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 25; {
+				old, _ := dbP.Get(counter, dbName)
+				next := make([]byte, 8)
+				if old != nil {
+					binary.BigEndian.PutUint64(next, binary.BigEndian.Uint64(old)+1)
+				} else {
+					binary.BigEndian.PutUint64(next, 1)
+				}
+				e := dbP.CompareAndSwap(dbName, counter, old, next)
+				if e == nil {
+					i++
+				} else if !errors.Is(e, dDB.ErrConflict) {
+					t.Errorf("Error in CompareAndSwap Error: %v", e)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if v, _ := dbP.Get(counter, dbName); len(v) != 8 || binary.BigEndian.Uint64(v) != 100 {
+		t.Errorf("Lost updates Expected: %d Got: %v", 100, v)
+	}
+}
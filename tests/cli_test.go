@@ -0,0 +1,184 @@
+package tests
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	dDB "dumbDB"
+	"dumbDB/cli"
+	"dumbDB/structures"
+)
+
+func runCLI(t *testing.T, stdin string, args ...string) (string, int) {
+	var out, errOut bytes.Buffer
+	code := cli.Run(args, strings.NewReader(stdin), &out, &errOut)
+	if code != cli.EXIT_OK {
+		t.Logf("dumbdb %v: %s", args, errOut.String())
+	}
+	return out.String(), code
+}
+
+// 1. List buckets and get a value using the uint64 key encoding.
+// 2. Scan with a limit in reverse, and put / rm keys, also after "--".
+// 3. Dump a DB and load it into a new one.
+// 4. Check and compact a DB, and validate a bucket against its schema.
+func TestCLI_Commands(t *testing.T) {
+
+	requireFile(t)
+	dbName := "TestCLI_Commands"
+	dbP := dDB.NewDumbDB(".", dbName, os.Stdout, testOpts...)
+	if dbP == nil {
+		t.Fatalf("Error creating DB %s", dbName)
+	}
+	path := dbP.DbFullName
+	defer removeDbFile(path)
+	storeUsers(t, dbP, "Users", User1, User2, User3, User4, User5)
+	dbP.SetSchema("Users", []byte(`{"properties": {"Position": {"not": {"const": "Architect"}}}}`))
+	dbP.Close()
+
+	out, code := runCLI(t, "", "buckets", path)
+	if code != cli.EXIT_OK || out != "Users\t5\n" {
+		t.Errorf("Incorrect buckets output Expected: %q Got: %q", "Users\t5\n", out)
+	}
+
+	out, code = runCLI(t, "", "get", "-key-enc", "uint64", path, "Users", "3")
+	if code != cli.EXIT_OK || (UserRecord{}).PutVal([]byte(out)) != User3 {
+		t.Errorf("Incorrect get output Expected: %v Got: %s", User3, out)
+	}
+	if !strings.Contains(out, "\n  \"Name\"") {
+		t.Errorf("JSON value not indented Got: %s", out)
+	}
+
+	out, code = runCLI(t, "", "scan", path, "Users", "--key-enc=uint64", "--reverse", "--limit", "2")
+	expected := fmt.Sprintf("5\t%s\n4\t%s\n", User5.GetVal(), User4.GetVal())
+	if code != cli.EXIT_OK || out != expected {
+		t.Errorf("Incorrect scan output Expected: %q Got: %q", expected, out)
+	}
+
+	if _, code = runCLI(t, "", "put", path, "Notes", "hello", "-"); code != cli.EXIT_OK {
+		t.Errorf("put failed")
+	}
+	if _, code = runCLI(t, "", "put", "-key-enc", "hex", "-value-enc", "hex", path, "Notes", "6869", "00ff"); code != cli.EXIT_OK {
+		t.Errorf("put failed")
+	}
+	out, _ = runCLI(t, "", "scan", "-key-enc", "hex", path, "Notes")
+	if out != "68656c6c6f\t\n6869\t00ff\n" {
+		t.Errorf("Incorrect scan output Got: %q", out)
+	}
+	if _, code = runCLI(t, "", "rm", path, "Notes", "hello"); code != cli.EXIT_OK {
+		t.Errorf("rm failed")
+	}
+	if _, code = runCLI(t, "", "put", path, "Notes", "--", "-a", "-b"); code != cli.EXIT_OK {
+		t.Errorf("put after -- failed")
+	}
+	if out, _ = runCLI(t, "", "get", "-key-enc", "utf8", path, "Notes", "--", "-a"); out != "-b\n" {
+		t.Errorf("Incorrect get output Expected: %q Got: %q", "-b\n", out)
+	}
+	if _, code = runCLI(t, "", "rm", path, "Notes", "--", "-a"); code != cli.EXIT_OK {
+		t.Errorf("rm after -- failed")
+	}
+	if _, code = runCLI(t, "", "get", path, "Notes", "hello"); code != cli.EXIT_ERROR {
+		t.Errorf("Incorrect exit code for missing key Expected: %d Got: %d", cli.EXIT_ERROR, code)
+	}
+
+	dump, code := runCLI(t, "", "dump", path)
+	if code != cli.EXIT_OK || strings.Count(dump, "\n") != 6 {
+		t.Errorf("Incorrect no of dumped records Expected: %d Got: %d", 6, strings.Count(dump, "\n"))
+	}
+	copyPath := "./" + dbName + "_copy" + dDB.DEFAULT_SUFFIX
+	defer removeDbFile(copyPath)
+	if _, code = runCLI(t, dump, "load", copyPath); code != cli.EXIT_OK {
+		t.Errorf("load failed")
+	}
+	if dump2, _ := runCLI(t, "", "dump", copyPath); dump2 != dump {
+		t.Errorf("Loaded DB differs Expected: %s Got: %s", dump, dump2)
+	}
+
+	if out, code = runCLI(t, "", "check", path); code != cli.EXIT_OK || out != "ok\n" {
+		t.Errorf("Incorrect check output Expected: %q Got: %q", "ok\n", out)
+	}
+	if _, code = runCLI(t, "", "rm", path, "Notes"); code != cli.EXIT_OK {
+		t.Errorf("rm bucket failed")
+	}
+	if _, code = runCLI(t, "", "compact", path); code != cli.EXIT_OK {
+		t.Errorf("compact failed")
+	}
+	if out, _ = runCLI(t, "", "buckets", path); out != "Users\t5\n" {
+		t.Errorf("Incorrect buckets after compact Expected: %q Got: %q", "Users\t5\n", out)
+	}
+	out, code = runCLI(t, "", "validate", "-key-enc", "uint64", path, "Users")
+	if code != cli.EXIT_ERROR || out != "3\t/Position\tmust not match the schema in not\n" {
+		t.Errorf("Incorrect validate output Got: %q", out)
+	}
+
+	if _, code = runCLI(t, "", "get", path, "Users"); code != cli.EXIT_USAGE {
+		t.Errorf("Incorrect exit code for missing args Expected: %d Got: %d", cli.EXIT_USAGE, code)
+	}
+	if _, code = runCLI(t, "", "buckets", "./missing"+dDB.DEFAULT_SUFFIX); code != cli.EXIT_ERROR {
+		t.Errorf("Incorrect exit code for missing file Expected: %d Got: %d", cli.EXIT_ERROR, code)
+	}
+}
+
+// 1. Dump a DB with structures in nested buckets and a bucket sequence.
+// 2. Load it into a new one, the structures and the sequence are kept.
+func TestCLI_DumpNested(t *testing.T) {
+
+	requireFile(t)
+	dbName := "TestCLI_DumpNested"
+	dbP := dDB.NewDumbDB(".", dbName, os.Stdout, testOpts...)
+	if dbP == nil {
+		t.Fatalf("Error creating DB %s", dbName)
+	}
+	path := dbP.DbFullName
+	defer removeDbFile(path)
+	storeUsers(t, dbP, "Users", User1, User2)
+	err := dbP.Update(func(tx *dDB.Tx) error {
+		if _, e := structures.NewList(tx, "Users", "jobs").RPush([]byte("a"), []byte("b")); e != nil {
+			return e
+		}
+		_, e := structures.NewSet(tx, "Users", "tags").Add([]byte("x"))
+		return e
+	})
+	if err != nil {
+		t.Fatalf("Error in Update Error: %s", err.Error())
+	}
+	dbP.NextID("Users")
+	dbP.NextID("Users")
+	dbP.Close()
+
+	dump, code := runCLI(t, "", "dump", path)
+	if code != cli.EXIT_OK {
+		t.Fatalf("dump failed")
+	}
+	copyPath := "./" + dbName + "_copy" + dDB.DEFAULT_SUFFIX
+	defer removeDbFile(copyPath)
+	if _, code = runCLI(t, dump, "load", copyPath); code != cli.EXIT_OK {
+		t.Fatalf("load failed")
+	}
+	if dump2, _ := runCLI(t, "", "dump", copyPath); dump2 != dump {
+		t.Errorf("Loaded DB differs Expected: %s Got: %s", dump, dump2)
+	}
+
+	copyP := dDB.NewDumbDB(".", dbName+"_copy", os.Stdout)
+	if copyP == nil {
+		t.Fatalf("Error opening DB %s", copyPath)
+	}
+	defer copyP.Close()
+	copyP.View(func(tx *dDB.Tx) error {
+		items, e := structures.NewList(tx, "Users", "jobs").Range(0, -1)
+		if e != nil || fmt.Sprintf("%s", items) != "[a b]" {
+			t.Errorf("Incorrect list Expected: [a b] Got: %s %v", items, e)
+		}
+		members, e := structures.NewSet(tx, "Users", "tags").Members()
+		if e != nil || fmt.Sprintf("%s", members) != "[x]" {
+			t.Errorf("Incorrect set Expected: [x] Got: %s %v", members, e)
+		}
+		return nil
+	})
+	if id, e := copyP.NextID("Users"); e != nil || id != 3 {
+		t.Errorf("Incorrect next ID Expected: 3 Got: %d %v", id, e)
+	}
+}
@@ -0,0 +1,137 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	dDB "dumbDB"
+)
+
+func storeUsers(t *testing.T, dbP *dDB.DumbDB, bucket string, users ...UserRecord) {
+	for _, u := range users {
+		if err := dbP.Store(u.GetRecord(), bucket); err != nil {
+			t.Fatalf("Error creating Record Record: %v Error: %s", u, err.Error())
+		}
+	}
+}
+
+func scanIDs(t *testing.T, dbP *dDB.DumbDB, bucket string, opts dDB.ScanOptions) []int {
+	ids := make([]int, 0)
+	err := dbP.Scan(bucket, opts, func(k, v []byte) error {
+		ids = append(ids, UserRecord{}.PutVal(v).ID)
+		return nil
+	})
+	if err != nil {
+		t.Errorf("Error scanning Error: %s", err.Error())
+	}
+	return ids
+}
+
+// 1. Scan a bucket forward and in reverse with limits.
+// 2. Scan a key range and a key prefix.
+func TestDumbDB_Scan(t *testing.T) {
+
+	dbName := "TestDumbDB_Scan"
+	dbP := dDB.NewDumbDB(".", dbName, os.Stdout, testOpts...)
+
+	if dbP == nil {
+		t.Fatalf("Error creating DB %s", dbName)
+	}
+	defer removeDbFile(dbP.DbFullName)
+
+	storeUsers(t, dbP, dbName, User1, User2, User3, User4, User5)
+
+	cases := []struct {
+		opts     dDB.ScanOptions
+		expected []int
+	}{
+		{dDB.ScanOptions{}, []int{1, 2, 3, 4, 5}},
+		{dDB.ScanOptions{Reverse: true, Limit: 2}, []int{5, 4}},
+		{dDB.ScanOptions{Start: User2.GetKey(), End: User4.GetKey()}, []int{2, 3}},
+		{dDB.ScanOptions{Start: User2.GetKey(), End: User4.GetKey(), Reverse: true}, []int{3, 2}},
+		{dDB.ScanOptions{Prefix: []byte{3}}, []int{3}},
+		{dDB.ScanOptions{Prefix: []byte{3}, Reverse: true}, []int{3}},
+		{dDB.ScanOptions{Prefix: []byte{9}}, []int{}},
+	}
+	for _, c := range cases {
+		ids := scanIDs(t, dbP, dbName, c.opts)
+		if len(ids) != len(c.expected) {
+			t.Errorf("Incorrect scan result Options: %+v Expected: %v Got: %v", c.opts, c.expected, ids)
+			continue
+		}
+		for i := range ids {
+			if ids[i] != c.expected[i] {
+				t.Errorf("Incorrect scan result Options: %+v Expected: %v Got: %v", c.opts, c.expected, ids)
+				break
+			}
+		}
+	}
+
+	count := 0
+	err := dbP.Scan(dbName, dDB.ScanOptions{}, func(k, v []byte) error {
+		count++
+		if bytes.Equal(k, User2.GetKey()) {
+			return dDB.ErrStopScan
+		}
+		return nil
+	})
+	if err != nil || count != 2 {
+		t.Errorf("Scan did not stop Expected: %d Got: %d Error: %v", 2, count, err)
+	}
+}
+
+// 1. Operations on a cancelled context fail with context.Canceled.
+// 2. A scan is abandoned between cursor steps when the context is cancelled.
+// 3. Opening a DB locked by another handle gives up at the deadline.
+func TestDumbDB_Context(t *testing.T) {
+
+	dbName := "TestDumbDB_Context"
+	dbP := dDB.NewDumbDB(".", dbName, os.Stdout, testOpts...)
+
+	if dbP == nil {
+		t.Fatalf("Error creating DB %s", dbName)
+	}
+	defer removeDbFile(dbP.DbFullName)
+
+	storeUsers(t, dbP, dbName, User1, User2, User3)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := dbP.StoreCtx(ctx, User4.GetRecord(), dbName); err != context.Canceled {
+		t.Errorf("Expected Error: %v Got: %v", context.Canceled, err)
+	}
+	if _, err := dbP.GetCtx(ctx, User1.GetKey(), dbName); err != context.Canceled {
+		t.Errorf("Expected Error: %v Got: %v", context.Canceled, err)
+	}
+	if recs, err := dbP.GetAllCtx(ctx, dbName); err != context.Canceled || recs != nil {
+		t.Errorf("Expected Error: %v Got: %v %v", context.Canceled, err, recs)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	count := 0
+	err := dbP.ScanCtx(ctx, dbName, dDB.ScanOptions{}, func(k, v []byte) error {
+		count++
+		cancel()
+		return nil
+	})
+	if err != context.Canceled || count != 1 {
+		t.Errorf("Expected Error: %v after 1 record Got: %v after %d", context.Canceled, err, count)
+	}
+
+	if !curBackend.file {
+		return
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if locked := dDB.NewDumbDBCtx(ctx, ".", dbName, os.Stdout, testOpts...); locked != nil {
+		t.Errorf("Expected open of locked DB to fail")
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Errorf("Open did not give up at the deadline Took: %v", time.Since(start))
+	}
+}
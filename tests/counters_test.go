@@ -0,0 +1,86 @@
+package tests
+
+import (
+	"math"
+	"os"
+	"sync"
+	"testing"
+
+	dDB "dumbDB"
+
+	"github.com/boltdb/bolt"
+)
+
+// 1. Concurrent Incr calls lose no updates, Decr subtracts.
+// 2. Float counters add up and overflowing or non counter values fail.
+// 3. IncrBatch applies all increments or, on error, none.
+func TestDumbDB_Counters(t *testing.T) {
+
+	dbName := "TestDumbDB_Counters"
+	dbP := dDB.NewDumbDB(".", dbName, os.Stdout, testOpts...)
+	if dbP == nil {
+		t.Fatalf("Error creating DB %s", dbName)
+	}
+	defer removeDbFile(dbP.DbFullName)
+	defer dbP.Close()
+
+	unread := []byte("unread")
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 25; i++ {
+				if _, err := dbP.Incr(dbName, unread, 2); err != nil {
+					t.Errorf("Error in Incr Error: %v", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if v, err := dbP.Decr(dbName, unread, 50); err != nil || v != 150 {
+		t.Errorf("Incorrect counter Expected: %d Got: %d %v", 150, v, err)
+	}
+	if v, err := dbP.GetCounter(dbName, unread); err != nil || v != 150 {
+		t.Errorf("Incorrect counter Expected: %d Got: %d %v", 150, v, err)
+	}
+	if v, err := dbP.GetCounter("Missing", unread); err != nil || v != 0 {
+		t.Errorf("Incorrect missing counter Expected: %d Got: %d %v", 0, v, err)
+	}
+
+	dbP.IncrFloat(dbName, []byte("score"), 1.25)
+	if v, err := dbP.DecrFloat(dbName, []byte("score"), 0.5); err != nil || v != 0.75 {
+		t.Errorf("Incorrect float counter Expected: %v Got: %v %v", 0.75, v, err)
+	}
+	if v, _ := dbP.GetFloatCounter(dbName, []byte("score")); v != 0.75 {
+		t.Errorf("Incorrect float counter Expected: %v Got: %v", 0.75, v)
+	}
+
+	dbP.Incr(dbName, []byte("max"), math.MaxInt64)
+	if _, err := dbP.Incr(dbName, []byte("max"), 1); err != dDB.ErrCounterOverflow {
+		t.Errorf("Incorrect error Expected: %v Got: %v", dDB.ErrCounterOverflow, err)
+	}
+	dbP.Store([][]byte{[]byte("text"), []byte("abc")}, dbName)
+	if _, err := dbP.Incr(dbName, []byte("text"), 1); err != bolt.ErrIncompatibleValue {
+		t.Errorf("Incorrect error Expected: %v Got: %v", bolt.ErrIncompatibleValue, err)
+	}
+
+	_, err := dbP.IncrBatch([]dDB.Increment{
+		{Bucket: dbName, Key: unread, Delta: 1},
+		{Bucket: dbName, Key: []byte("text"), Delta: 1},
+	})
+	if err != bolt.ErrIncompatibleValue {
+		t.Errorf("Incorrect error Expected: %v Got: %v", bolt.ErrIncompatibleValue, err)
+	}
+	if v, _ := dbP.GetCounter(dbName, unread); v != 150 {
+		t.Errorf("Failed batch applied Expected: %d Got: %d", 150, v)
+	}
+	vals, err := dbP.IncrBatch([]dDB.Increment{
+		{Bucket: dbName, Key: unread, Delta: 1},
+		{Bucket: "Downloads", Key: []byte("file"), Delta: 3},
+		{Bucket: dbName, Key: unread, Delta: 1},
+	})
+	if err != nil || len(vals) != 3 || vals[0] != 151 || vals[1] != 3 || vals[2] != 152 {
+		t.Errorf("Incorrect batch result Expected: [151 3 152] Got: %v %v", vals, err)
+	}
+}
@@ -1,9 +1,15 @@
 package tests
 
 import (
+	"context"
+	"fmt"
 	"os"
 	"testing"
 	dDB "dumbDB"
+	"dumbDB/backend"
+	"dumbDB/backend/bbolt"
+	"dumbDB/backend/boltdb"
+	"dumbDB/backend/memory"
 	"github.com/boltdb/bolt"
 )
 
@@ -11,9 +17,45 @@ var removeDbFile = func(name string) {
 	_ = os.Remove(name)
 }
 
+type testBackend struct {
+	name string
+	open backend.Opener
+	// Stored in the DB file, which can be reopened
+	file bool
+}
+
+// Every test is run against each of these, see TestMain.
+var testBackends = []testBackend{
+	{"boltdb", boltdb.Open, true},
+	{"bbolt", bbolt.Open, true},
+	{"memory", memory.Open, false},
+}
+
+// Backend of the current run and the options to open DBs with it.
+var curBackend = testBackends[0]
+var testOpts []dDB.Option
+
+/*
+ * requireFile
+ * Skip tests which reopen the DB file when the backend has none.
+ */
+func requireFile(t *testing.T) {
+	if !curBackend.file {
+		t.Skipf("%s backend is not stored in a file", curBackend.name)
+	}
+}
+
 func TestMain(t *testing.M) {
 	os.Setenv("test", "1")
-	os.Exit(t.Run())
+	for _, b := range testBackends {
+		curBackend = b
+		testOpts = []dDB.Option{dDB.WithBackend(b.open)}
+		if code := t.Run(); code != 0 {
+			fmt.Fprintf(os.Stderr, "FAIL with %s backend\n", b.name)
+			os.Exit(code)
+		}
+	}
+	os.Exit(0)
 }
 
 // 1. Create 2 test records and store
@@ -22,7 +64,7 @@ func TestMain(t *testing.M) {
 func TestDumbDB_Store(t *testing.T) {
 
 	dbName := "TestDumbDB_Store"
-	dbP := dDB.NewDumbDB(".", dbName, os.Stdout)
+	dbP := dDB.NewDumbDB(".", dbName, os.Stdout, testOpts...)
 
 	if dbP == nil {
 		t.Errorf("Error creating DB %s", dbName)
@@ -62,7 +104,7 @@ func TestDumbDB_Store(t *testing.T) {
 func TestDumbDB_Remove(t *testing.T) {
 
 	dbName := "TestDumbDB_Remove"
-	dbP := dDB.NewDumbDB(".", dbName, os.Stdout)
+	dbP := dDB.NewDumbDB(".", dbName, os.Stdout, testOpts...)
 
 	if dbP == nil {
 		t.Errorf("Error creating DB %s", dbName)
@@ -107,7 +149,7 @@ func TestDumbDB_Remove(t *testing.T) {
 func TestDumbDB_Get(t *testing.T) {
 
 	dbName := "TestDumbDB_Get"
-	dbP := dDB.NewDumbDB(".", dbName, os.Stdout)
+	dbP := dDB.NewDumbDB(".", dbName, os.Stdout, testOpts...)
 
 	if dbP == nil {
 		t.Errorf("Error creating DB %s", dbName)
@@ -179,7 +221,7 @@ func TestDumbDB_Get(t *testing.T) {
 func TestDumbDB_GetMultiple(t *testing.T) {
 
 	dbName := "TestDumbDB_GetMultiple"
-	dbP := dDB.NewDumbDB(".", dbName, os.Stdout)
+	dbP := dDB.NewDumbDB(".", dbName, os.Stdout, testOpts...)
 
 	if dbP == nil {
 		t.Errorf("Error creating DB %s", dbName)
@@ -241,7 +283,7 @@ func TestDumbDB_GetMultiple(t *testing.T) {
 func TestDumbDB_GetAllRange(t *testing.T) {
 
 	dbName := "TestDumbDB_GetAll"
-	dbP := dDB.NewDumbDB(".", dbName, os.Stdout)
+	dbP := dDB.NewDumbDB(".", dbName, os.Stdout, testOpts...)
 
 	if dbP == nil {
 		t.Errorf("Error creating DB %s", dbName)
@@ -392,3 +434,178 @@ func doAllRecordsCheck(t *testing.T, all_recs [][] byte) {
 		}
 	}
 }
+
+// 1. Create an index so the meta bucket exists.
+// 2. Writes to the hidden buckets through DumbDB and Tx fail with ErrHiddenBucket.
+// 3. The index definition is still there afterwards.
+func TestDumbDB_HiddenBuckets(t *testing.T) {
+
+	dbName := "TestDumbDB_HiddenBuckets"
+	dbP := dDB.NewDumbDB(".", dbName, os.Stdout, testOpts...)
+	if dbP == nil {
+		t.Fatalf("Error creating DB %s", dbName)
+	}
+	defer removeDbFile(dbP.DbFullName)
+	defer dbP.Close()
+
+	if err := dbP.CreateIndex("Users", "Name"); err != nil {
+		t.Fatalf("Error in CreateIndex Error: %s", err.Error())
+	}
+	meta := dDB.META_BUCKET
+	writes := map[string]func() error{
+		"Store":        func() error { return dbP.Store(User1.GetRecord(), meta) },
+		"Remove":       func() error { return dbP.Remove([]byte("index.Users"), meta) },
+		"RemoveBucket": func() error { return dbP.RemoveBucket(meta) },
+		"Incr":         func() error { _, err := dbP.Incr(meta, []byte("n"), 1); return err },
+		"NextID":       func() error { _, err := dbP.NextID(dDB.REPLICATION_LOG_BUCKET); return err },
+		"CreateIndex":  func() error { return dbP.CreateIndex(meta, "Name") },
+		"Tx.Store": func() error {
+			return dbP.Update(func(tx *dDB.Tx) error { return tx.Store(User1.GetRecord(), meta) })
+		},
+		"Tx.RemoveBucket": func() error {
+			return dbP.Update(func(tx *dDB.Tx) error { return tx.RemoveBucket(meta) })
+		},
+		"Tx.Nested": func() error {
+			return dbP.Update(func(tx *dDB.Tx) error { return tx.Nested(meta, []byte("n")).Put([]byte("k"), []byte("v")) })
+		},
+		"Txn": func() error {
+			return dbP.Txn([]dDB.Change{{Op: dDB.ChangeDeleteBucket, Bucket: meta}})
+		},
+	}
+	for name, write := range writes {
+		if err := write(); err != dDB.ErrHiddenBucket {
+			t.Errorf("Incorrect error for %s Expected: %v Got: %v", name, dDB.ErrHiddenBucket, err)
+		}
+	}
+	if idxs, err := dbP.Indexes("Users"); err != nil || len(idxs) != 1 {
+		t.Errorf("Incorrect indexes Expected: [Name] Got: %v %v", idxs, err)
+	}
+}
+
+func putNested(tx backend.Tx) error {
+	b, err := tx.CreateBucketIfNotExists([]byte("Nested"))
+	if err != nil {
+		return err
+	}
+	if err = b.Put([]byte("a"), []byte("1")); err != nil {
+		return err
+	}
+	sub, err := b.CreateBucket([]byte("b"))
+	if err != nil {
+		return err
+	}
+	sub.SetSequence(7)
+	if err = sub.Put([]byte("c"), []byte("2")); err != nil {
+		return err
+	}
+	deeper, err := sub.CreateBucket([]byte("d"))
+	if err != nil {
+		return err
+	}
+	return deeper.Put([]byte("e"), []byte("3"))
+}
+
+func checkNested(t *testing.T, tx backend.Tx) {
+	t.Helper()
+	b := tx.Bucket([]byte("Nested"))
+	if b == nil {
+		t.Fatalf("Bucket Nested not found")
+	}
+	var got []string
+	c := b.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		got = append(got, fmt.Sprintf("%s=%v", k, v != nil))
+	}
+	if fmt.Sprint(got) != "[a=true b=false]" {
+		t.Errorf("Incorrect cursor Expected: [a=true b=false] Got: %v", got)
+	}
+	sub := b.Bucket([]byte("b"))
+	if b.Get([]byte("b")) != nil || sub == nil || sub.Sequence() != 7 || string(sub.Get([]byte("c"))) != "2" {
+		t.Fatalf("Incorrect nested bucket b")
+	}
+	if deeper := sub.Bucket([]byte("d")); deeper == nil || string(deeper.Get([]byte("e"))) != "3" {
+		t.Errorf("Incorrect nested bucket d")
+	}
+}
+
+// 1. Buckets nest in every backend, their keys have nil values in cursors.
+// 2. Values and nested buckets can not replace each other.
+// 3. Deleting a nested bucket deletes the buckets inside it.
+// 4. CompactTo copies nested buckets.
+func TestBackend_NestedBuckets(t *testing.T) {
+
+	path := "TestBackend_NestedBuckets.db"
+	defer removeDbFile(path)
+	be, err := curBackend.open(context.Background(), path)
+	if err != nil {
+		t.Fatalf("Error opening backend Error: %s", err.Error())
+	}
+	if err = be.Update(putNested); err != nil {
+		t.Fatalf("Error creating nested buckets Error: %s", err.Error())
+	}
+	be.View(func(tx backend.Tx) error {
+		checkNested(t, tx)
+		return nil
+	})
+	be.Update(func(tx backend.Tx) error {
+		b := tx.Bucket([]byte("Nested"))
+		if err := b.Put([]byte("b"), []byte("x")); err != bolt.ErrIncompatibleValue {
+			t.Errorf("Incorrect error Expected: %v Got: %v", bolt.ErrIncompatibleValue, err)
+		}
+		if err := b.Delete([]byte("b")); err != bolt.ErrIncompatibleValue {
+			t.Errorf("Incorrect error Expected: %v Got: %v", bolt.ErrIncompatibleValue, err)
+		}
+		if _, err := b.CreateBucket([]byte("a")); err != bolt.ErrIncompatibleValue {
+			t.Errorf("Incorrect error Expected: %v Got: %v", bolt.ErrIncompatibleValue, err)
+		}
+		if _, err := b.CreateBucket([]byte("b")); err != bolt.ErrBucketExists {
+			t.Errorf("Incorrect error Expected: %v Got: %v", bolt.ErrBucketExists, err)
+		}
+		if err := b.DeleteBucket([]byte("b")); err != nil {
+			t.Errorf("Error in DeleteBucket Error: %v", err)
+		}
+		if k, _ := b.Cursor().Last(); b.Bucket([]byte("b")) != nil || string(k) != "a" {
+			t.Errorf("Nested bucket not deleted")
+		}
+		return nil
+	})
+	be.Update(putNested)
+	be.Close()
+	if !curBackend.file {
+		return
+	}
+
+	dbName := "TestBackend_NestedBuckets"
+	dbP := dDB.NewDumbDB(".", dbName, os.Stdout, testOpts...)
+	if dbP == nil {
+		t.Fatalf("Error creating DB %s", dbName)
+	}
+	defer removeDbFile(dbP.DbFullName)
+	dbP.Close()
+	if be, err = curBackend.open(context.Background(), dbP.DbFullName); err != nil {
+		t.Fatalf("Error opening DB file Error: %s", err.Error())
+	}
+	err = be.Update(putNested)
+	be.Close()
+	if err != nil {
+		t.Fatalf("Error creating nested buckets Error: %s", err.Error())
+	}
+	if dbP = dDB.NewDumbDB(".", dbName, os.Stdout, testOpts...); dbP == nil {
+		t.Fatalf("Error reopening DB %s", dbName)
+	}
+	compacted := dbName + "_compact.db"
+	defer removeDbFile(compacted)
+	err = dbP.CompactTo(compacted)
+	dbP.Close()
+	if err != nil {
+		t.Fatalf("Error in CompactTo Error: %s", err.Error())
+	}
+	if be, err = curBackend.open(context.Background(), compacted); err != nil {
+		t.Fatalf("Error opening compacted file Error: %s", err.Error())
+	}
+	defer be.Close()
+	be.View(func(tx backend.Tx) error {
+		checkNested(t, tx)
+		return nil
+	})
+}
@@ -0,0 +1,76 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"testing"
+
+	dDB "dumbDB"
+)
+
+// 1. NextID returns the bucket sequence, starting at 1.
+// 2. StoreAuto assigns big-endian sequence keys by default.
+// 3. ULID, KSUID and UUIDv7 keys have their canonical length and sort in
+//    the order they were assigned.
+func TestDumbDB_IDs(t *testing.T) {
+
+	dbName := "TestDumbDB_IDs"
+	dbP := dDB.NewDumbDB(".", dbName, os.Stdout, testOpts...)
+	if dbP == nil {
+		t.Fatalf("Error creating DB %s", dbName)
+	}
+	defer removeDbFile(dbP.DbFullName)
+	defer dbP.Close()
+
+	for i := uint64(1); i <= 3; i++ {
+		if id, err := dbP.NextID("Counters"); err != nil || id != i {
+			t.Errorf("Incorrect NextID Expected: %d Got: %d %v", i, id, err)
+		}
+	}
+
+	key, err := dbP.StoreAuto("Users", User1.GetVal())
+	if err != nil || len(key) != 8 || binary.BigEndian.Uint64(key) != 1 {
+		t.Errorf("Incorrect StoreAuto key Expected: %d Got: %v %v", 1, key, err)
+	}
+	if v, err := dbP.Get(key, "Users"); err != nil || (UserRecord{}).PutVal(v) != User1 {
+		t.Errorf("Incorrect value Expected: %v Got: %s %v", User1, v, err)
+	}
+	if id, _ := dbP.NextID("Users"); id != 2 {
+		t.Errorf("StoreAuto and NextID do not share the sequence Got: %d", id)
+	}
+
+	gens := map[string]struct {
+		gen dDB.IDGenerator
+		len int
+	}{
+		"ULID":   {dDB.ULIDGenerator(), 26},
+		"KSUID":  {dDB.KSUIDGenerator(), 27},
+		"UUIDv7": {dDB.UUIDv7Generator(), 36},
+	}
+	for name, g := range gens {
+		dbP.SetIDGenerator(name, g.gen)
+		keys := make([][]byte, 0)
+		for i := 0; i < 50; i++ {
+			key, err := dbP.StoreAuto(name, []byte{byte(i)})
+			if err != nil || len(key) != g.len {
+				t.Fatalf("%s: Incorrect key Expected length: %d Got: %s %v", name, g.len, key, err)
+			}
+			keys = append(keys, key)
+		}
+		i := 0
+		dbP.Scan(name, dDB.ScanOptions{}, func(k, v []byte) error {
+			if !bytes.Equal(k, keys[i]) || v[0] != byte(i) {
+				t.Errorf("%s: Keys not in assignment order at %d Got: %s", name, i, k)
+			}
+			i++
+			return nil
+		})
+		if i != len(keys) {
+			t.Errorf("%s: Incorrect no of keys Expected: %d Got: %d", name, len(keys), i)
+		}
+	}
+	if k, _ := dbP.StoreAuto("UUIDv7", nil); k[14] != '7' {
+		t.Errorf("Incorrect UUID version Got: %s", k)
+	}
+}
@@ -0,0 +1,66 @@
+package tests
+
+import (
+	"os"
+	"testing"
+
+	dDB "dumbDB"
+
+	"github.com/boltdb/bolt"
+)
+
+// 1. An in-memory DB keeps keys ordered and returns the errors of a DB file.
+// 2. SaveTo writes a file which opens with NewDumbDB.
+// 3. Saving again replaces the file.
+func TestDumbDB_InMemory(t *testing.T) {
+
+	dbP := dDB.NewInMemory()
+	defer dbP.Close()
+	if dbP.DbFullName != dDB.IN_MEMORY_NAME {
+		t.Errorf("Incorrect name Expected: %s Got: %s", dDB.IN_MEMORY_NAME, dbP.DbFullName)
+	}
+	storeUsers(t, dbP, "Users", User3, User1, User2)
+
+	if ids := scanIDs(t, dbP, "Users", dDB.ScanOptions{}); len(ids) != 3 || ids[0] != 1 || ids[2] != 3 {
+		t.Errorf("Incorrect scan Expected: [1 2 3] Got: %v", ids)
+	}
+	if _, err := dbP.Get(User4.GetKey(), "Users"); err != bolt.ErrKeyRequired {
+		t.Errorf("Incorrect error Expected: %v Got: %v", bolt.ErrKeyRequired, err)
+	}
+	if _, err := dbP.Get(User1.GetKey(), "Missing"); err != bolt.ErrBucketNotFound {
+		t.Errorf("Incorrect error Expected: %v Got: %v", bolt.ErrBucketNotFound, err)
+	}
+	if err := dbP.Store(LargeKeyRecord{Dummy: "large"}.GetRecord(), "Users"); err != bolt.ErrKeyTooLarge {
+		t.Errorf("Incorrect error Expected: %v Got: %v", bolt.ErrKeyTooLarge, err)
+	}
+
+	path := "./TestDumbDB_InMemory" + dDB.DEFAULT_SUFFIX
+	defer removeDbFile(path)
+	if err := dbP.SaveTo(path); err != nil {
+		t.Fatalf("Error saving DB Error: %s", err.Error())
+	}
+	if err := dbP.Remove(User1.GetKey(), "Users"); err != nil {
+		t.Errorf("Error removing Error: %s", err.Error())
+	}
+
+	saved := dDB.NewDumbDB(".", "TestDumbDB_InMemory", os.Stdout)
+	if saved == nil {
+		t.Fatalf("Error opening saved DB %s", path)
+	}
+	if ids := scanIDs(t, saved, "Users", dDB.ScanOptions{}); len(ids) != 3 {
+		t.Errorf("Incorrect saved records Expected: [1 2 3] Got: %v", ids)
+	}
+	saved.Close()
+
+	if err := dbP.SaveTo(path); err != nil {
+		t.Fatalf("Error saving DB again Error: %s", err.Error())
+	}
+	saved = dDB.NewDumbDB(".", "TestDumbDB_InMemory", os.Stdout)
+	if saved == nil {
+		t.Fatalf("Error opening saved DB %s", path)
+	}
+	defer saved.Close()
+	if ids := scanIDs(t, saved, "Users", dDB.ScanOptions{}); len(ids) != 2 || ids[0] != 2 {
+		t.Errorf("Incorrect saved records Expected: [2 3] Got: %v", ids)
+	}
+}
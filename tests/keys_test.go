@@ -0,0 +1,83 @@
+package tests
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	dDB "dumbDB"
+	"dumbDB/keys"
+)
+
+// 1. Tuples decode to the values they were encoded from.
+// 2. Keys sort like their values: numbers numerically, strings with 0x00
+//    bytes and prefixes lexically, times chronologically.
+// 3. A scan over PrefixRange returns users in numeric ID order.
+func TestKeys_Encoding(t *testing.T) {
+
+	now := time.Unix(1700000000, 123).UTC()
+	tuple := []interface{}{"user", []byte{0, 1}, int64(-5), uint64(7), 2.5, now}
+	key, err := keys.Encode(tuple...)
+	if err != nil {
+		t.Fatalf("Error encoding Error: %s", err.Error())
+	}
+	if got, err := keys.Decode(key); err != nil || !reflect.DeepEqual(got, tuple) {
+		t.Errorf("Incorrect decode Expected: %v Got: %v %v", tuple, got, err)
+	}
+	if _, err = keys.Decode(key[:len(key)-1]); err != keys.ErrInvalidKey {
+		t.Errorf("Incorrect error Expected: %v Got: %v", keys.ErrInvalidKey, err)
+	}
+	if _, err = keys.Encode(struct{}{}); !errors.Is(err, keys.ErrUnsupportedType) {
+		t.Errorf("Incorrect error Expected: %v Got: %v", keys.ErrUnsupportedType, err)
+	}
+
+	ordered := [][]interface{}{
+		{"a"}, {"a\x00"}, {"a\x00b"}, {"ab"}, {"b"},
+		{math.MinInt64}, {-256}, {-1}, {0}, {2}, {256}, {math.MaxInt64},
+		{uint(2)}, {uint(256)},
+		{math.Inf(-1)}, {-1.5}, {-0.5}, {0.0}, {0.5}, {1e10}, {math.Inf(1)},
+		{now.Add(-time.Hour)}, {now}, {now.Add(time.Nanosecond)},
+	}
+	for i := 1; i < len(ordered); i++ {
+		a, b := keys.MustEncode(ordered[i-1]...), keys.MustEncode(ordered[i]...)
+		if bytes.Compare(a, b) >= 0 {
+			t.Errorf("Incorrect order Expected: %v < %v", ordered[i-1], ordered[i])
+		}
+	}
+
+	dbName := "TestKeys_Encoding"
+	dbP := dDB.NewDumbDB(".", dbName, os.Stdout, testOpts...)
+	if dbP == nil {
+		t.Fatalf("Error creating DB %s", dbName)
+	}
+	defer removeDbFile(dbP.DbFullName)
+	defer dbP.Close()
+
+	for _, id := range []uint64{256, 2, 30} {
+		dbP.Store([][]byte{keys.MustEncode("user", id), []byte("u")}, dbName)
+	}
+	dbP.Store([][]byte{keys.MustEncode("users"), []byte("x")}, dbName)
+	dbP.Store([][]byte{keys.MustEncode("v", uint64(1)), []byte("x")}, dbName)
+
+	start, end, _ := keys.PrefixRange("user")
+	ids := make([]uint64, 0)
+	dbP.Scan(dbName, dDB.ScanOptions{Start: start, End: end}, func(k, v []byte) error {
+		tuple, err := keys.Decode(k)
+		if err != nil || len(tuple) != 2 {
+			t.Errorf("Incorrect key in range Got: %v %v", tuple, err)
+			return nil
+		}
+		ids = append(ids, tuple[1].(uint64))
+		return nil
+	})
+	if !reflect.DeepEqual(ids, []uint64{2, 30, 256}) {
+		t.Errorf("Incorrect scan Expected: %v Got: %v", []uint64{2, 30, 256}, ids)
+	}
+	if keys.PrefixEnd([]byte{0x01, 0xff}) == nil || keys.PrefixEnd([]byte{0xff}) != nil {
+		t.Errorf("Incorrect PrefixEnd")
+	}
+}
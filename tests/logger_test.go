@@ -0,0 +1,97 @@
+package tests
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+
+	dDB "dumbDB"
+)
+
+type logLine struct {
+	level string
+	msg   string
+	kv    map[string]interface{}
+}
+
+type recordingLogger struct {
+	lines []logLine
+}
+
+func (r *recordingLogger) add(level, msg string, kv []interface{}) {
+	l := logLine{level: level, msg: msg, kv: make(map[string]interface{})}
+	for i := 0; i+1 < len(kv); i += 2 {
+		l.kv[kv[i].(string)] = kv[i+1]
+	}
+	r.lines = append(r.lines, l)
+}
+
+func (r *recordingLogger) Debug(msg string, kv ...interface{}) { r.add("DEBUG", msg, kv) }
+func (r *recordingLogger) Info(msg string, kv ...interface{})  { r.add("INFO", msg, kv) }
+func (r *recordingLogger) Warn(msg string, kv ...interface{})  { r.add("WARN", msg, kv) }
+func (r *recordingLogger) Error(msg string, kv ...interface{}) { r.add("ERROR", msg, kv) }
+
+// 1. Successful operations are not logged at the default level.
+// 2. At debug level every line carries op, bucket and duration.
+// 3. The slog adapter and the no-op logger can be plugged in.
+func TestDumbDB_Logger(t *testing.T) {
+
+	dbName := "TestDumbDB_Logger"
+	dbP := dDB.NewDumbDB(".", dbName, os.Stdout, testOpts...)
+
+	if dbP == nil {
+		t.Fatalf("Error creating DB %s", dbName)
+	}
+	defer removeDbFile(dbP.DbFullName)
+
+	rec := &recordingLogger{}
+	dbP.SetLogger(rec)
+
+	dbP.Store(User1.GetRecord(), dbName)
+	dbP.Get(User1.GetKey(), dbName)
+	dbP.GetAll(dbName)
+	if len(rec.lines) != 0 {
+		t.Errorf("Expected no log lines at default level Got: %v", rec.lines)
+	}
+
+	dbP.Get(User1.GetKey(), "RANDOM_BUCKET")
+	if len(rec.lines) != 1 || rec.lines[0].level != "WARN" {
+		t.Errorf("Expected a warning for missing bucket Got: %v", rec.lines)
+	}
+
+	rec.lines = nil
+	dbP.SetLogLevel(dDB.LevelDebug)
+	dbP.Store(User2.GetRecord(), dbName)
+	dbP.GetAll(dbName)
+	if len(rec.lines) != 2 {
+		t.Fatalf("Expected one line per operation Expected: %d Got: %d", 2, len(rec.lines))
+	}
+	for _, l := range rec.lines {
+		if l.kv["op"] == nil || l.kv["bucket"] != dbName || l.kv["duration"] == nil {
+			t.Errorf("Log line missing fields %v", l)
+		}
+	}
+	if rec.lines[1].kv["results"] != 2 {
+		t.Errorf("Expected result count in GetAll log line Got: %v", rec.lines[1].kv)
+	}
+
+	buf := &bytes.Buffer{}
+	dbP.SetLogger(dDB.NewSlogLogger(slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))))
+	dbP.Get(User1.GetKey(), dbName)
+	if !strings.Contains(buf.String(), "op=Get") || !strings.Contains(buf.String(), "bucket="+dbName) {
+		t.Errorf("Unexpected slog output %s", buf.String())
+	}
+
+	dbP.SetLogger(dDB.NopLogger())
+	dbP.Get(User1.GetKey(), "RANDOM_BUCKET")
+
+	dbP.SetLogLevel(dDB.LevelOff)
+	dbP.SetLogger(rec)
+	rec.lines = nil
+	dbP.Get(User1.GetKey(), "RANDOM_BUCKET")
+	if len(rec.lines) != 0 {
+		t.Errorf("Expected no log lines when logging is off Got: %v", rec.lines)
+	}
+}
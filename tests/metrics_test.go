@@ -0,0 +1,53 @@
+package tests
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	dDB "dumbDB"
+	"dumbDB/metrics"
+)
+
+// 1. Run a few operations on a DB.
+// 2. Scrape the metrics handler and check the operation, latency and size metrics.
+func TestMetrics_Handler(t *testing.T) {
+
+	dbName := "TestMetrics_Handler"
+	dbP := dDB.NewDumbDB(".", dbName, os.Stdout, testOpts...)
+
+	if dbP == nil {
+		t.Fatalf("Error creating DB %s", dbName)
+	}
+	defer removeDbFile(dbP.DbFullName)
+
+	dbP.Store(User1.GetRecord(), dbName)
+	dbP.Store(User2.GetRecord(), dbName)
+	dbP.Get(User1.GetKey(), dbName)
+	dbP.GetAll(dbName)
+
+	srv := httptest.NewServer(metrics.Handler(dbP))
+	defer srv.Close()
+
+	resp, err := srv.Client().Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Error scraping metrics Error: %s", err.Error())
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	for _, want := range []string{
+		`dumbdb_operations_total{db="./TestMetrics_Handler.dumbDB",op="Store"} 2`,
+		`dumbdb_operation_duration_seconds_count{db="./TestMetrics_Handler.dumbDB",op="Get"} 1`,
+		`dumbdb_transaction_duration_seconds_bucket{db="./TestMetrics_Handler.dumbDB",type="write",le="+Inf"} 2`,
+		`dumbdb_hits_total{db="./TestMetrics_Handler.dumbDB"} 1`,
+		`dumbdb_bucket_keys{bucket="TestMetrics_Handler",db="./TestMetrics_Handler.dumbDB"} 2`,
+		`dumbdb_file_size_bytes{db="./TestMetrics_Handler.dumbDB"}`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("Metric missing Expected: %s Got: %s", want, body)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package tests
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+	"time"
+
+	"dumbDB/mobile"
+)
+
+type changeRecorder struct {
+	changes chan string
+}
+
+func (r *changeRecorder) OnChange(op int, bucket string, key []byte, value []byte) {
+	r.changes <- fmt.Sprintf("%d %s %s %s", op, bucket, key, value)
+}
+
+// 1. Store and read records through the mobile API, GetMultiple fails
+//    without a list of keys.
+// 2. Iterate over more than one page of records in both directions.
+// 3. Receive changes through a watch callback.
+func TestMobile_DB(t *testing.T) {
+
+	dbName := "TestMobile_DB"
+	db, err := mobile.Open(".", dbName)
+	if err != nil {
+		t.Fatalf("Error creating DB %s Error: %s", dbName, err.Error())
+	}
+	defer removeDbFile(db.Path())
+	defer db.Close()
+
+	rec := &changeRecorder{changes: make(chan string, 10)}
+	w, err := db.Watch(dbName, []byte("user"), rec)
+	if err != nil {
+		t.Fatalf("Error watching bucket Error: %s", err.Error())
+	}
+
+	if err = db.PutString(dbName, "user1", "Alan"); err != nil {
+		t.Errorf("Error storing record Error: %s", err.Error())
+	}
+	if err = db.PutString(dbName, "other", "skipped"); err != nil {
+		t.Errorf("Error storing record Error: %s", err.Error())
+	}
+	select {
+	case c := <-rec.changes:
+		if c != fmt.Sprintf("%d %s user1 Alan", mobile.OpPut, dbName) {
+			t.Errorf("Incorrect change Got: %s", c)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("Watch callback not called")
+	}
+	w.Cancel()
+
+	if v, err := db.GetString(dbName, "user1"); err != nil || v != "Alan" {
+		t.Errorf("Incorrect value Expected: %s Got: %s %v", "Alan", v, err)
+	}
+	keys := mobile.NewRecordList()
+	keys.Add([]byte("user1"))
+	if vals, err := db.GetMultiple(dbName, keys); err != nil || vals.Len() != 1 || string(vals.Get(0)) != "Alan" {
+		t.Errorf("Incorrect values Expected: [Alan] Got: %v %v", vals, err)
+	}
+	if _, err := db.GetMultiple(dbName, nil); err != mobile.ErrNilRecordList {
+		t.Errorf("Incorrect error Expected: %v Got: %v", mobile.ErrNilRecordList, err)
+	}
+
+	n := mobile.ITERATOR_PAGE_SIZE*2 + 5
+	for i := 0; i < n; i++ {
+		db.PutString(dbName+"_scan", fmt.Sprintf("key%04d", i), fmt.Sprintf("%d", i))
+	}
+
+	for _, reverse := range []bool{false, true} {
+		it, err := db.Scan(dbName+"_scan", []byte("key"), reverse, 0)
+		if err != nil {
+			t.Fatalf("Error scanning Error: %s", err.Error())
+		}
+		count := 0
+		for it.Next() {
+			expected := count
+			if reverse {
+				expected = n - 1 - count
+			}
+			if string(it.Key()) != fmt.Sprintf("key%04d", expected) {
+				t.Errorf("Incorrect key Expected: key%04d Got: %s", expected, it.Key())
+				break
+			}
+			count++
+		}
+		if it.Err() != nil || count != n {
+			t.Errorf("Incorrect no of records Expected: %d Got: %d Error: %v", n, count, it.Err())
+		}
+	}
+
+	it, _ := db.Scan(dbName+"_scan", nil, false, 3)
+	count := 0
+	for it.Next() {
+		count++
+	}
+	if count != 3 {
+		t.Errorf("Incorrect no of records Expected: %d Got: %d", 3, count)
+	}
+
+	list, err := db.GetLimited(dbName+"_scan", 5, nil)
+	if err != nil || list.Len() != 5 || string(list.Get(0)) != fmt.Sprintf("%d", n-1) {
+		t.Errorf("Incorrect GetLimited result %v", err)
+	}
+}
+
+var bindableBasic = map[string]bool{
+	"bool": true, "string": true, "int": true, "int8": true, "int16": true,
+	"int32": true, "int64": true, "float32": true, "float64": true,
+}
+
+func bindableType(t ast.Expr, structs, ifaces map[string]bool) bool {
+	switch e := t.(type) {
+	case *ast.Ident:
+		return bindableBasic[e.Name] || e.Name == "error" || ifaces[e.Name]
+	case *ast.ArrayType:
+		id, ok := e.Elt.(*ast.Ident)
+		return e.Len == nil && ok && id.Name == "byte"
+	case *ast.StarExpr:
+		id, ok := e.X.(*ast.Ident)
+		return ok && structs[id.Name]
+	}
+	return false
+}
+
+func checkBindableFunc(t *testing.T, name string, ft *ast.FuncType, structs, ifaces map[string]bool) {
+	if ft.Params != nil {
+		for _, p := range ft.Params.List {
+			if !bindableType(p.Type, structs, ifaces) {
+				t.Errorf("%s has a parameter which can not be bound", name)
+			}
+		}
+	}
+	if ft.Results == nil {
+		return
+	}
+	res := ft.Results.List
+	n := 0
+	for _, r := range res {
+		if len(r.Names) == 0 {
+			n++
+		}
+		n += len(r.Names)
+	}
+	if n > 2 {
+		t.Errorf("%s returns more than 2 values", name)
+	}
+	for i, r := range res {
+		if !bindableType(r.Type, structs, ifaces) {
+			t.Errorf("%s has a result which can not be bound", name)
+		}
+		if n == 2 && i == len(res)-1 {
+			if id, ok := r.Type.(*ast.Ident); !ok || id.Name != "error" {
+				t.Errorf("%s must return error as its second value", name)
+			}
+		}
+	}
+}
+
+// Every exported function, method, struct field and interface method of the
+// mobile package only uses types gomobile bind supports.
+func TestMobile_Bindable(t *testing.T) {
+
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, "../mobile", nil, 0)
+	if err != nil {
+		t.Fatalf("Error parsing mobile package Error: %s", err.Error())
+	}
+
+	structs := make(map[string]bool)
+	ifaces := make(map[string]bool)
+	for _, pkg := range pkgs {
+		for _, f := range pkg.Files {
+			for _, d := range f.Decls {
+				gd, ok := d.(*ast.GenDecl)
+				if !ok {
+					continue
+				}
+				for _, spec := range gd.Specs {
+					ts, ok := spec.(*ast.TypeSpec)
+					if !ok || !ts.Name.IsExported() {
+						continue
+					}
+					switch ts.Type.(type) {
+					case *ast.StructType:
+						structs[ts.Name.Name] = true
+					case *ast.InterfaceType:
+						ifaces[ts.Name.Name] = true
+					default:
+						t.Errorf("Type %s can not be bound", ts.Name.Name)
+					}
+				}
+			}
+		}
+	}
+
+	for _, pkg := range pkgs {
+		for _, f := range pkg.Files {
+			for _, d := range f.Decls {
+				switch decl := d.(type) {
+				case *ast.FuncDecl:
+					if !decl.Name.IsExported() {
+						continue
+					}
+					name := decl.Name.Name
+					if decl.Recv != nil {
+						recv, ok := decl.Recv.List[0].Type.(*ast.StarExpr)
+						if !ok {
+							t.Errorf("Method %s must have a pointer receiver", name)
+							continue
+						}
+						name = recv.X.(*ast.Ident).Name + "." + name
+					}
+					checkBindableFunc(t, name, decl.Type, structs, ifaces)
+				case *ast.GenDecl:
+					for _, spec := range decl.Specs {
+						ts, ok := spec.(*ast.TypeSpec)
+						if !ok || !ts.Name.IsExported() {
+							continue
+						}
+						switch typ := ts.Type.(type) {
+						case *ast.StructType:
+							for _, field := range typ.Fields.List {
+								for _, n := range field.Names {
+									if n.IsExported() && !bindableType(field.Type, structs, ifaces) {
+										t.Errorf("Field %s.%s can not be bound", ts.Name.Name, n.Name)
+									}
+								}
+							}
+						case *ast.InterfaceType:
+							for _, m := range typ.Methods.List {
+								if ft, ok := m.Type.(*ast.FuncType); ok {
+									checkBindableFunc(t, ts.Name.Name+"."+m.Names[0].Name, ft, structs, ifaces)
+								}
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package tests
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+
+	dDB "dumbDB"
+
+	"github.com/boltdb/bolt"
+)
+
+// 1. Concurrent Modify calls on a counter lose no updates.
+// 2. An error from the function aborts, returning nil deletes the key.
+// 3. Collection.Modify patches a typed record and deletes it with ErrDelete.
+func TestDumbDB_Modify(t *testing.T) {
+
+	dbName := "TestDumbDB_Modify"
+	dbP := dDB.NewDumbDB(".", dbName, os.Stdout, testOpts...)
+	if dbP == nil {
+		t.Fatalf("Error creating DB %s", dbName)
+	}
+	defer removeDbFile(dbP.DbFullName)
+	defer dbP.Close()
+
+	counter := []byte("counter")
+	incr := func(old []byte) ([]byte, error) {
+		n := uint64(0)
+		if old != nil {
+			n = binary.BigEndian.Uint64(old)
+		}
+		next := make([]byte, 8)
+		binary.BigEndian.PutUint64(next, n+1)
+		return next, nil
+	}
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 25; i++ {
+				if err := dbP.Modify(dbName, counter, incr); err != nil {
+					t.Errorf("Error in Modify Error: %v", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if v, _ := dbP.Get(counter, dbName); len(v) != 8 || binary.BigEndian.Uint64(v) != 100 {
+		t.Errorf("Lost updates Expected: %d Got: %v", 100, v)
+	}
+
+	failed := errors.New("failed")
+	err := dbP.Modify(dbName, counter, func(old []byte) ([]byte, error) { return []byte("x"), failed })
+	if err != failed {
+		t.Errorf("Incorrect error Expected: %v Got: %v", failed, err)
+	}
+	if err = dbP.Modify(dbName, counter, func(old []byte) ([]byte, error) { return nil, nil }); err != nil {
+		t.Errorf("Error in Modify Error: %v", err)
+	}
+	if _, err = dbP.Get(counter, dbName); err != bolt.ErrKeyRequired {
+		t.Errorf("Key not deleted Expected: %v Got: %v", bolt.ErrKeyRequired, err)
+	}
+
+	users := dDB.NewCollection[UserRecord](dbP, "Users")
+	if err = users.Put(User1.GetKey(), &User1); err != nil {
+		t.Fatalf("Error in Put Error: %v", err)
+	}
+	err = users.Modify(User1.GetKey(), func(u *UserRecord) error {
+		u.Position = "Manager"
+		return nil
+	})
+	if u, e := users.Get(User1.GetKey()); err != nil || e != nil || u.Position != "Manager" || u.Name != User1.Name {
+		t.Errorf("Incorrect record after Modify Got: %v %v %v", u, err, e)
+	}
+	if err = users.Modify(User1.GetKey(), func(u *UserRecord) error { return dDB.ErrDelete }); err != nil {
+		t.Errorf("Error in Modify Error: %v", err)
+	}
+	if _, err = users.Get(User1.GetKey()); err != bolt.ErrKeyRequired {
+		t.Errorf("Record not deleted Expected: %v Got: %v", bolt.ErrKeyRequired, err)
+	}
+}
@@ -0,0 +1,205 @@
+package tests
+
+import (
+	"errors"
+	"math"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	dDB "dumbDB"
+)
+
+func docNames(docs []dDB.Document) []string {
+	names := make([]string, len(docs))
+	for i, d := range docs {
+		names[i], _ = d.Value["Name"].(string)
+	}
+	return names
+}
+
+// 1. Filter, sort, page and project the users with and without an index,
+// with the same results.
+// 2. Indexes follow Store, Modify, Remove and RemoveBucket.
+// 3. Decode the results into structs.
+// 4. An unknown operator is an error.
+func TestQuery(t *testing.T) {
+
+	dbName := "TestQuery"
+	dbP := dDB.NewDumbDB(".", dbName, os.Stdout, testOpts...)
+	if dbP == nil {
+		t.Fatalf("Error creating DB %s", dbName)
+	}
+	defer removeDbFile(dbP.DbFullName)
+	defer dbP.Close()
+
+	storeUsers(t, dbP, "Users", User1, User2, User3, User4, User5)
+	dbP.Store([][]byte{[]byte("not json"), []byte("x")}, "Users")
+
+	check := func(plan string) {
+		q := dbP.Query("Users").Where("Position", "=", "Engineer").OrderBy("Name")
+		if p, _ := q.Explain(); p != plan {
+			t.Errorf("Incorrect plan Expected: %s Got: %s", plan, p)
+		}
+		docs, err := q.All()
+		if err != nil || !reflect.DeepEqual(docNames(docs), []string{"Alan", "April"}) {
+			t.Errorf("Incorrect engineers Expected: [Alan April] Got: %v %v", docNames(docs), err)
+		}
+		docs, _ = dbP.Query("Users").Where("ID", ">=", 2).Where("ID", "<", 5).OrderByDesc("Name").Limit(2).All()
+		if !reflect.DeepEqual(docNames(docs), []string{"Travis", "Olof"}) {
+			t.Errorf("Incorrect range Expected: [Travis Olof] Got: %v", docNames(docs))
+		}
+		docs, _ = dbP.Query("Users").Where("Position", "in", []string{"Chef", "Doctor", "Chef"}).OrderBy("Name").Offset(1).All()
+		if !reflect.DeepEqual(docNames(docs), []string{"Travis"}) {
+			t.Errorf("Incorrect in Expected: [Travis] Got: %v", docNames(docs))
+		}
+		docs, _ = dbP.Query("Users").Where("Name", "prefix", "A").Where("ID", "!=", 1).Select("Name").All()
+		if len(docs) != 1 || !reflect.DeepEqual(docs[0].Value, map[string]interface{}{"Name": "April"}) {
+			t.Errorf("Incorrect projection Expected: [{Name: April}] Got: %v", docs)
+		}
+		if n, _ := dbP.Query("Users").Where("ID", "<=", 3).Count(); n != 3 {
+			t.Errorf("Incorrect count Expected: %d Got: %d", 3, n)
+		}
+	}
+	check("scan")
+	if err := dbP.CreateIndex("Users", "Position"); err != nil {
+		t.Fatalf("Error in CreateIndex Error: %s", err.Error())
+	}
+	dbP.CreateIndex("Users", "ID")
+	dbP.CreateIndex("Users", "Name")
+	if f, _ := dbP.Indexes("Users"); !reflect.DeepEqual(f, []string{"Position", "ID", "Name"}) {
+		t.Errorf("Incorrect indexes Expected: [Position ID Name] Got: %v", f)
+	}
+	check("index Position")
+
+	dbP.Modify("Users", User2.GetKey(), func(old []byte) ([]byte, error) {
+		u := (UserRecord{}).PutVal(old)
+		u.Position = "Engineer"
+		return u.GetVal(), nil
+	})
+	dbP.Remove(User1.GetKey(), "Users")
+	docs, _ := dbP.Query("Users").Where("Position", "=", "Engineer").All()
+	if !reflect.DeepEqual(docNames(docs), []string{"Olof", "April"}) {
+		t.Errorf("Index not updated Expected: [Olof April] Got: %v", docNames(docs))
+	}
+
+	var users []UserRecord
+	if err := dbP.Query("Users").Where("ID", ">", 3).OrderBy("ID").Decode(&users); err != nil {
+		t.Errorf("Error in Decode Error: %v", err)
+	}
+	if !reflect.DeepEqual(users, []UserRecord{User4, User5}) {
+		t.Errorf("Incorrect users Expected: %v Got: %v", []UserRecord{User4, User5}, users)
+	}
+
+	dbP.RemoveBucket("Users")
+	storeUsers(t, dbP, "Users", User3)
+	if n, _ := dbP.Query("Users").Where("Position", "=", "Engineer").Count(); n != 0 {
+		t.Errorf("Stale index entries after RemoveBucket Got: %d", n)
+	}
+	if n, _ := dbP.Query("Users").Where("Position", "=", "Architect").Count(); n != 1 {
+		t.Errorf("Incorrect count Expected: %d Got: %d", 1, n)
+	}
+	dbP.DropIndex("Users", "Position")
+	if p, _ := dbP.Query("Users").Where("Position", "=", "Architect").Explain(); p != "scan" {
+		t.Errorf("Incorrect plan Expected: scan Got: %s", p)
+	}
+
+	if _, err := dbP.Query("Users").Where("ID", "~", 1).All(); !errors.Is(err, dDB.ErrInvalidQuery) {
+		t.Errorf("Incorrect error Expected: %v Got: %v", dDB.ErrInvalidQuery, err)
+	}
+}
+
+// 1. A string too long for an index entry can be stored in an indexed field.
+// 2. Index queries find it and sort it with the other values.
+// 3. The entry follows Store and Remove.
+func TestQuery_LongStrings(t *testing.T) {
+
+	dbName := "TestQuery_LongStrings"
+	dbP := dDB.NewDumbDB(".", dbName, os.Stdout, testOpts...)
+	if dbP == nil {
+		t.Fatalf("Error creating DB %s", dbName)
+	}
+	defer removeDbFile(dbP.DbFullName)
+	defer dbP.Close()
+
+	if err := dbP.CreateIndex("B", "name"); err != nil {
+		t.Fatalf("Error in CreateIndex Error: %s", err.Error())
+	}
+	long := "n" + strings.Repeat("x", 40000)
+	for k, name := range map[string]string{"a": long, "b": "m", "c": "z"} {
+		if err := dbP.Store([][]byte{[]byte(k), []byte(`{"name": "` + name + `"}`)}, "B"); err != nil {
+			t.Fatalf("Error storing %s Error: %s", k, err.Error())
+		}
+	}
+
+	check := func(q *dDB.Query, exp ...string) {
+		t.Helper()
+		if p, _ := q.Explain(); p != "index name" {
+			t.Errorf("Incorrect plan Expected: index name Got: %s", p)
+		}
+		docs, err := q.All()
+		if exp == nil {
+			exp = []string{}
+		}
+		ks := make([]string, len(docs))
+		for i, d := range docs {
+			ks[i] = string(d.Key)
+		}
+		if err != nil || !reflect.DeepEqual(ks, exp) {
+			t.Errorf("Incorrect keys Expected: %v Got: %v %v", exp, ks, err)
+		}
+	}
+	check(dbP.Query("B").Where("name", "=", long), "a")
+	check(dbP.Query("B").Where("name", "prefix", "nxx"), "a")
+	check(dbP.Query("B").Where("name", "in", []string{"z", long}).OrderBy("name"), "a", "c")
+	check(dbP.Query("B").Where("name", ">", "l").OrderBy("name"), "b", "a", "c")
+	check(dbP.Query("B").Where("name", "<", "n").OrderBy("name"), "b")
+
+	dbP.Store([][]byte{[]byte("a"), []byte(`{"name": "y"}`)}, "B")
+	check(dbP.Query("B").Where("name", "=", long))
+	dbP.Store([][]byte{[]byte("d"), []byte(`{"name": "` + long + `"}`)}, "B")
+	dbP.Remove([]byte("d"), "B")
+	check(dbP.Query("B").Where("name", ">", "l").OrderBy("name"), "b", "a", "c")
+}
+
+// 1. -0 and 0 are equal, a scan finds both for either of them.
+// 2. An index on the field returns the same records.
+func TestQuery_NegativeZero(t *testing.T) {
+
+	dbName := "TestQuery_NegativeZero"
+	dbP := dDB.NewDumbDB(".", dbName, os.Stdout, testOpts...)
+	if dbP == nil {
+		t.Fatalf("Error creating DB %s", dbName)
+	}
+	defer removeDbFile(dbP.DbFullName)
+	defer dbP.Close()
+
+	dbP.Store([][]byte{[]byte("a"), []byte(`{"F": -0}`)}, "B")
+	dbP.Store([][]byte{[]byte("b"), []byte(`{"F": 0}`)}, "B")
+	dbP.Store([][]byte{[]byte("c"), []byte(`{"F": 1}`)}, "B")
+
+	queries := []*dDB.Query{
+		dbP.Query("B").Where("F", "=", 0),
+		dbP.Query("B").Where("F", "=", math.Copysign(0, -1)),
+		dbP.Query("B").Where("F", "<", 1),
+		dbP.Query("B").Where("F", ">=", 0),
+	}
+	count := func() []int {
+		ns := make([]int, len(queries))
+		for i, q := range queries {
+			ns[i], _ = q.Count()
+		}
+		return ns
+	}
+	scanned := count()
+	if exp := []int{2, 2, 2, 3}; !reflect.DeepEqual(scanned, exp) {
+		t.Errorf("Incorrect counts Expected: %v Got: %v", exp, scanned)
+	}
+	if err := dbP.CreateIndex("B", "F"); err != nil {
+		t.Fatalf("Error in CreateIndex Error: %s", err.Error())
+	}
+	if indexed := count(); !reflect.DeepEqual(indexed, scanned) {
+		t.Errorf("Incorrect counts with index Expected: %v Got: %v", scanned, indexed)
+	}
+}
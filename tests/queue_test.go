@@ -0,0 +1,144 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	dDB "dumbDB"
+	"dumbDB/keys"
+	"dumbDB/queue"
+)
+
+//  1. Messages are dequeued in order and removed by Ack.
+//  2. A message whose lease ended is delivered again, the old lease is stale.
+//  3. Nacked messages come back, and go to the dead-letter bucket after
+//     MaxAttempts.
+//  4. DequeueWait blocks until a message is enqueued or the context is done.
+//  5. Messages survive reopening the DB file.
+func TestQueue(t *testing.T) {
+
+	dbName := "TestQueue"
+	dbP := dDB.NewDumbDB(".", dbName, os.Stdout, testOpts...)
+	if dbP == nil {
+		t.Fatalf("Error creating DB %s", dbName)
+	}
+	defer removeDbFile(dbP.DbFullName)
+
+	opts := queue.Options{
+		Visibility:  50 * time.Millisecond,
+		MaxAttempts: 2,
+		Backoff:     func(int) time.Duration { return 0 },
+	}
+	q := queue.New(dbP, "Uploads", opts)
+	if _, err := q.Dequeue(); err != queue.ErrEmpty {
+		t.Errorf("Incorrect error Expected: %v Got: %v", queue.ErrEmpty, err)
+	}
+	for _, b := range []string{"a", "b", "c"} {
+		if _, err := q.Enqueue([]byte(b)); err != nil {
+			t.Fatalf("Error in Enqueue Error: %s", err.Error())
+		}
+	}
+
+	l, err := q.Dequeue()
+	if err != nil || string(l.Body) != "a" || l.Attempts != 1 {
+		t.Fatalf("Incorrect lease Expected: a Got: %v %v", l, err)
+	}
+	if err = q.Ack(l); err != nil {
+		t.Errorf("Error in Ack Error: %v", err)
+	}
+	if err = q.Ack(l); err != queue.ErrLeaseExpired {
+		t.Errorf("Incorrect error Expected: %v Got: %v", queue.ErrLeaseExpired, err)
+	}
+
+	stale, _ := q.Dequeue()
+	q.Dequeue()
+	time.Sleep(60 * time.Millisecond)
+	l, err = q.Dequeue()
+	if err != nil || string(l.Body) != "b" || l.Attempts != 2 {
+		t.Fatalf("Expired lease not delivered again Got: %v %v", l, err)
+	}
+	if err = q.Ack(stale); err != queue.ErrLeaseExpired {
+		t.Errorf("Incorrect error Expected: %v Got: %v", queue.ErrLeaseExpired, err)
+	}
+	if err = q.Nack(l); err != nil {
+		t.Errorf("Error in Nack Error: %v", err)
+	}
+	if dead, _ := q.DeadLetters(); len(dead) != 1 || string(dead[0].Body) != "b" {
+		t.Errorf("Incorrect dead letters Expected: [b] Got: %v", dead)
+	}
+	if l, err = q.Dequeue(); err != nil || string(l.Body) != "c" {
+		t.Fatalf("Incorrect lease Expected: c Got: %v %v", l, err)
+	}
+	q.Ack(l)
+
+	q.Enqueue([]byte("x"))
+	l, _ = q.Dequeue()
+	if err = q.Nack(l); err != nil {
+		t.Errorf("Error in Nack Error: %v", err)
+	}
+	if l, err = q.Dequeue(); err != nil || string(l.Body) != "x" || l.Attempts != 2 {
+		t.Errorf("Nacked message not delivered again Got: %v %v", l, err)
+	}
+	q.Ack(l)
+	if n, _ := q.Len(); n != 0 {
+		t.Errorf("Incorrect length Expected: %d Got: %d", 0, n)
+	}
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		q.Enqueue([]byte("d"))
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if l, err = q.DequeueWait(ctx); err != nil || string(l.Body) != "d" {
+		t.Errorf("Incorrect DequeueWait Expected: d Got: %v %v", l, err)
+	}
+	q.Ack(l)
+	ctx, cancel = context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	if _, err = q.DequeueWait(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Incorrect error Expected: %v Got: %v", context.DeadlineExceeded, err)
+	}
+
+	q.Enqueue([]byte("e"))
+	dbP.Close()
+	if !curBackend.file {
+		return
+	}
+	dbP = dDB.NewDumbDB(".", dbName, os.Stdout, testOpts...)
+	if dbP == nil {
+		t.Fatalf("Error reopening DB %s", dbName)
+	}
+	defer dbP.Close()
+	q = queue.New(dbP, "Uploads", opts)
+	if n, _ := q.Len(); n != 1 {
+		t.Errorf("Incorrect length after reopen Expected: %d Got: %d", 1, n)
+	}
+	if l, err = q.Dequeue(); err != nil || string(l.Body) != "e" {
+		t.Errorf("Incorrect message after reopen Expected: e Got: %v %v", l, err)
+	}
+}
+
+// 1. Dequeue returns the error of an undecodable ready key instead of
+// ErrEmpty.
+func TestQueue_CorruptKey(t *testing.T) {
+
+	dbName := "TestQueue_CorruptKey"
+	dbP := dDB.NewDumbDB(".", dbName, os.Stdout, testOpts...)
+	if dbP == nil {
+		t.Fatalf("Error creating DB %s", dbName)
+	}
+	defer removeDbFile(dbP.DbFullName)
+	defer dbP.Close()
+
+	key := append(keys.MustEncode(queue.KEY_READY, int64(0)), 0xff)
+	if err := dbP.Store([][]byte{key, {}}, "Uploads"); err != nil {
+		t.Fatalf("Error in Store Error: %s", err.Error())
+	}
+	q := queue.New(dbP, "Uploads", queue.Options{})
+	if _, err := q.Dequeue(); err != keys.ErrInvalidKey {
+		t.Errorf("Incorrect error Expected: %v Got: %v", keys.ErrInvalidKey, err)
+	}
+}
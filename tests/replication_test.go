@@ -0,0 +1,268 @@
+package tests
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	dDB "dumbDB"
+	"github.com/boltdb/bolt"
+)
+
+func waitForSeq(t *testing.T, follower *dDB.DumbDB, seq uint64) {
+	deadline := time.Now().Add(5 * time.Second)
+	for follower.AppliedSeq() < seq {
+		if time.Now().After(deadline) {
+			t.Fatalf("Follower did not catch up Expected: %d Got: %d", seq, follower.AppliedSeq())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func startReplication(primary, follower *dDB.DumbDB) (stop chan struct{}, done chan error) {
+	r, w := io.Pipe()
+	stop = make(chan struct{})
+	done = make(chan error, 2)
+	go func() {
+		err := primary.Replicate(w, follower.AppliedSeq(), stop)
+		w.Close()
+		done <- err
+	}()
+	go func() {
+		done <- follower.Follow(r)
+	}()
+	return
+}
+
+// 1. Store records on the primary and replicate them to a new follower.
+// 2. Writes after replication started are streamed too.
+// 3. The follower rejects writes from users.
+func TestDumbDB_Replicate(t *testing.T) {
+
+	dbName := "TestDumbDB_Replicate"
+	primary := dDB.NewDumbDB(".", dbName, os.Stdout, testOpts...)
+	follower := dDB.NewFollower(".", dbName+"_follower", os.Stdout, testOpts...)
+
+	if primary == nil || follower == nil {
+		t.Fatalf("Error creating DB %s", dbName)
+	}
+	defer removeDbFile(primary.DbFullName)
+	defer removeDbFile(follower.DbFullName)
+
+	err := primary.Store(User1.GetRecord(), dbName)
+	if err != nil {
+		t.Errorf("Error creating Record Record: %v Error: %s", User1, err.Error())
+	}
+
+	err = primary.EnableReplication(0)
+	if err != nil {
+		t.Fatalf("Error enabling replication Error: %s", err.Error())
+	}
+
+	err = primary.Store(User2.GetRecord(), dbName)
+	if err != nil {
+		t.Errorf("Error creating Record Record: %v Error: %s", User2, err.Error())
+	}
+
+	stop, done := startReplication(primary, follower)
+	waitForSeq(t, follower, 1)
+
+	err = primary.Store(User3.GetRecord(), dbName)
+	if err != nil {
+		t.Errorf("Error creating Record Record: %v Error: %s", User3, err.Error())
+	}
+	err = primary.Remove(User1.GetKey(), dbName)
+	if err != nil {
+		t.Errorf("Error deleting Record Record: %v Error: %s", User1, err.Error())
+	}
+	waitForSeq(t, follower, 3)
+
+	records, err := follower.GetAll(dbName)
+	if err != nil || len(records) != 2 {
+		t.Errorf("Returned incorrect no of records Expected: %d Got: %d", 2, len(records))
+	}
+
+	_, err = follower.Get(User1.GetKey(), dbName)
+	if err != bolt.ErrKeyRequired {
+		t.Errorf("Expected Error: %v Got: %v", bolt.ErrKeyRequired, err)
+	}
+
+	err = follower.Store(User4.GetRecord(), dbName)
+	if err != bolt.ErrDatabaseReadOnly {
+		t.Errorf("Expected Error: %v Got: %v", bolt.ErrDatabaseReadOnly, err)
+	}
+
+	st := follower.ReplicationLag()
+	if st.AppliedSeq != 3 || st.Behind != 0 || st.LastContact.IsZero() {
+		t.Errorf("Incorrect replication status %+v", st)
+	}
+
+	close(stop)
+	if err = <-done; err != nil {
+		t.Errorf("Replication failed Error: %s", err.Error())
+	}
+	if err = <-done; err != nil {
+		t.Errorf("Replication failed Error: %s", err.Error())
+	}
+}
+
+// 1. Follow a primary which only retains 2 log entries.
+// 2. Disconnect the follower and write more than 2 entries on the primary.
+// 3. On reconnect the follower is sent a snapshot and catches up.
+func TestDumbDB_ReplicateCatchUp(t *testing.T) {
+
+	dbName := "TestDumbDB_ReplicateCatchUp"
+	primary := dDB.NewDumbDB(".", dbName, os.Stdout, testOpts...)
+	follower := dDB.NewFollower(".", dbName+"_follower", os.Stdout, testOpts...)
+
+	if primary == nil || follower == nil {
+		t.Fatalf("Error creating DB %s", dbName)
+	}
+	defer removeDbFile(primary.DbFullName)
+	defer removeDbFile(follower.DbFullName)
+
+	err := primary.EnableReplication(2)
+	if err != nil {
+		t.Fatalf("Error enabling replication Error: %s", err.Error())
+	}
+	primary.Store(User1.GetRecord(), dbName)
+
+	stop, done := startReplication(primary, follower)
+	waitForSeq(t, follower, 1)
+	close(stop)
+	<-done
+	<-done
+
+	for _, u := range []UserRecord{User2, User3, User4, User5} {
+		primary.Store(u.GetRecord(), dbName)
+	}
+	primary.Remove(User1.GetKey(), dbName)
+
+	stop, done = startReplication(primary, follower)
+	waitForSeq(t, follower, 6)
+
+	records, err := follower.GetAll(dbName)
+	if err != nil || len(records) != 4 {
+		t.Errorf("Returned incorrect no of records Expected: %d Got: %d", 4, len(records))
+	}
+
+	close(stop)
+	<-done
+	<-done
+}
+
+// 1. Define an index, aggregate, full-text index and schema on the primary.
+// 2. A new follower receives them in a snapshot and builds their data.
+// 3. Later writes and definitions are streamed, the follower keeps the
+//    derived data up to date.
+func TestDumbDB_ReplicateDefinitions(t *testing.T) {
+
+	dbName := "TestDumbDB_ReplicateDefinitions"
+	primary := dDB.NewDumbDB(".", dbName, os.Stdout, testOpts...)
+	follower := dDB.NewFollower(".", dbName+"_follower", os.Stdout, testOpts...)
+
+	if primary == nil || follower == nil {
+		t.Fatalf("Error creating DB %s", dbName)
+	}
+	defer removeDbFile(primary.DbFullName)
+	defer removeDbFile(follower.DbFullName)
+
+	if err := primary.EnableReplication(2); err != nil {
+		t.Fatalf("Error enabling replication Error: %s", err.Error())
+	}
+	schema := []byte(`{"type": "object"}`)
+	storeUsers(t, primary, "Users", User1, User2)
+	primary.CreateIndex("Users", "Position")
+	primary.CreateAggregate("Users", "by_position", "Position", dDB.Count())
+	primary.CreateTextIndex("Users", dDB.TextIndex{Fields: []string{"Name"}})
+	primary.SetSchema("Users", schema)
+
+	stop, done := startReplication(primary, follower)
+	waitForSeq(t, follower, 6)
+
+	storeUsers(t, primary, "Users", User3, User5)
+	primary.Remove(User2.GetKey(), "Users")
+	primary.CreateIndex("Users", "Name")
+	waitForSeq(t, follower, 10)
+
+	if p, _ := follower.Query("Users").Where("Position", "=", "Engineer").Explain(); p != "index Position" {
+		t.Errorf("Incorrect plan Expected: index Position Got: %s", p)
+	}
+	if n, _ := follower.Query("Users").Where("Position", "=", "Engineer").Count(); n != 2 {
+		t.Errorf("Incorrect count Expected: %d Got: %d", 2, n)
+	}
+	if n, _ := follower.Query("Users").Where("Position", "=", "Doctor").Count(); n != 0 {
+		t.Errorf("Incorrect count Expected: %d Got: %d", 0, n)
+	}
+	if n, _ := follower.Query("Users").Where("Name", "=", "May").Count(); n != 1 {
+		t.Errorf("Incorrect count Expected: %d Got: %d", 1, n)
+	}
+	exp, _ := primary.GetAggregate("Users", "by_position")
+	if got, err := follower.GetAggregate("Users", "by_position"); err != nil || fmt.Sprint(got) != fmt.Sprint(exp) {
+		t.Errorf("Incorrect aggregate Expected: %v Got: %v %v", exp, got, err)
+	}
+	if hits, err := follower.Search("Users", "april"); err != nil || len(hits) != 1 {
+		t.Errorf("Incorrect no of hits Expected: %d Got: %d %v", 1, len(hits), err)
+	}
+	if hits, _ := follower.Search("Users", "olof"); len(hits) != 0 {
+		t.Errorf("Incorrect no of hits Expected: %d Got: %d", 0, len(hits))
+	}
+	if got, _ := follower.GetSchema("Users"); string(got) != string(schema) {
+		t.Errorf("Incorrect schema Expected: %s Got: %s", schema, got)
+	}
+
+	close(stop)
+	<-done
+	<-done
+}
+
+// 1. NextID and StoreAuto on the primary move the sequence of the follower.
+// 2. A copy of the follower hands out the next IDs, without reusing keys.
+func TestDumbDB_ReplicateIDs(t *testing.T) {
+
+	dbName := "TestDumbDB_ReplicateIDs"
+	primary := dDB.NewDumbDB(".", dbName, os.Stdout, testOpts...)
+	follower := dDB.NewFollower(".", dbName+"_follower", os.Stdout, testOpts...)
+
+	if primary == nil || follower == nil {
+		t.Fatalf("Error creating DB %s", dbName)
+	}
+	defer removeDbFile(primary.DbFullName)
+	defer removeDbFile(follower.DbFullName)
+
+	if err := primary.EnableReplication(0); err != nil {
+		t.Fatalf("Error enabling replication Error: %s", err.Error())
+	}
+	stop, done := startReplication(primary, follower)
+
+	primary.NextID("Events")
+	primary.NextID("Events")
+	key, err := primary.StoreAuto("Events", []byte("e3"))
+	if err != nil {
+		t.Fatalf("Error in StoreAuto Error: %s", err.Error())
+	}
+	// 2 NextIDs, then the sequence and the record of StoreAuto
+	waitForSeq(t, follower, 4)
+	close(stop)
+	<-done
+	<-done
+
+	path := "./" + dbName + "_copy" + dDB.DEFAULT_SUFFIX
+	defer removeDbFile(path)
+	if err = follower.SaveTo(path); err != nil {
+		t.Fatalf("Error saving DB Error: %s", err.Error())
+	}
+	promoted := dDB.NewDumbDB(".", dbName+"_copy", os.Stdout)
+	if promoted == nil {
+		t.Fatalf("Error opening saved DB %s", path)
+	}
+	defer promoted.Close()
+	if v, _ := promoted.Get(key, "Events"); string(v) != "e3" {
+		t.Errorf("Incorrect value Expected: e3 Got: %s", v)
+	}
+	if id, _ := promoted.NextID("Events"); id != 4 {
+		t.Errorf("Incorrect ID Expected: %d Got: %d", 4, id)
+	}
+}
@@ -0,0 +1,139 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	dDB "dumbDB"
+	"dumbDB/rpc"
+
+	"github.com/boltdb/bolt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+/*
+ * checkKV
+ * Runs the same operations against any dDB.KV, local or remote.
+ */
+func checkKV(t *testing.T, name string, kv dDB.KV, bucket string) {
+
+	changes := make(chan dDB.Change, 10)
+	cancel := kv.Watch(bucket, nil, func(c dDB.Change) { changes <- c })
+	defer cancel()
+
+	for _, u := range []UserRecord{User1, User2, User3} {
+		if err := kv.Store(u.GetRecord(), bucket); err != nil {
+			t.Fatalf("%s: Error storing Record: %v Error: %s", name, u, err.Error())
+		}
+	}
+	if v, err := kv.Get(User2.GetKey(), bucket); err != nil || (UserRecord{}).PutVal(v) != User2 {
+		t.Errorf("%s: Incorrect value Expected: %v Got: %s %v", name, User2, v, err)
+	}
+	if _, err := kv.Get(User4.GetKey(), bucket); err != bolt.ErrKeyRequired {
+		t.Errorf("%s: Incorrect error Expected: %v Got: %v", name, bolt.ErrKeyRequired, err)
+	}
+	if _, err := kv.Get(User1.GetKey(), "Missing"); err != bolt.ErrBucketNotFound {
+		t.Errorf("%s: Incorrect error Expected: %v Got: %v", name, bolt.ErrBucketNotFound, err)
+	}
+	if _, err := kv.GetMultiple([][]byte{User1.GetKey(), User4.GetKey()}, bucket); err != bolt.ErrInvalid {
+		t.Errorf("%s: Incorrect error Expected: %v Got: %v", name, bolt.ErrInvalid, err)
+	}
+	vals, err := kv.GetMultiple([][]byte{User3.GetKey(), User1.GetKey()}, bucket)
+	if err != nil || len(vals) != 2 || (UserRecord{}).PutVal(vals[0]) != User3 {
+		t.Errorf("%s: Incorrect GetMultiple result %v", name, err)
+	}
+
+	ids := make([]int, 0)
+	err = kv.Scan(bucket, dDB.ScanOptions{Reverse: true}, func(k, v []byte) error {
+		ids = append(ids, UserRecord{}.PutVal(v).ID)
+		if len(ids) == 2 {
+			return dDB.ErrStopScan
+		}
+		return nil
+	})
+	if err != nil || len(ids) != 2 || ids[0] != 3 || ids[1] != 2 {
+		t.Errorf("%s: Incorrect scan Expected: [3 2] Got: %v %v", name, ids, err)
+	}
+
+	err = kv.Txn([]dDB.Change{
+		{Op: dDB.ChangePut, Bucket: bucket, Key: User4.GetKey(), Value: User4.GetVal()},
+		{Op: dDB.ChangeDelete, Bucket: "Missing", Key: User1.GetKey()},
+	})
+	if err != bolt.ErrBucketNotFound {
+		t.Errorf("%s: Incorrect error Expected: %v Got: %v", name, bolt.ErrBucketNotFound, err)
+	}
+	err = kv.Txn([]dDB.Change{
+		{Op: dDB.ChangePut, Bucket: bucket, Key: User4.GetKey(), Value: User4.GetVal()},
+		{Op: dDB.ChangeDelete, Bucket: bucket, Key: User1.GetKey()},
+	})
+	if err != nil {
+		t.Errorf("%s: Error in Txn Error: %s", name, err.Error())
+	}
+	if err = kv.Remove(User2.GetKey(), bucket); err != nil {
+		t.Errorf("%s: Error removing Error: %s", name, err.Error())
+	}
+
+	ctx, done := context.WithCancel(context.Background())
+	done()
+	if err = kv.StoreCtx(ctx, User5.GetRecord(), bucket); err != context.Canceled {
+		t.Errorf("%s: Incorrect error Expected: %v Got: %v", name, context.Canceled, err)
+	}
+
+	expected := []dDB.ChangeOp{dDB.ChangePut, dDB.ChangePut, dDB.ChangePut, dDB.ChangePut, dDB.ChangeDelete, dDB.ChangeDelete}
+	for i, op := range expected {
+		select {
+		case c := <-changes:
+			if c.Op != op || c.Bucket != bucket {
+				t.Errorf("%s: Incorrect change %d Expected: %v Got: %v", name, i, op, c.Op)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: Change %d not received", name, i)
+		}
+	}
+}
+
+// 1. The same operations give the same results and errors on a local DB
+//    and through a gRPC client.
+// 2. A ValidationError keeps its bucket, key and fields through the client.
+func TestRPC_Client(t *testing.T) {
+
+	dbName := "TestRPC_Client"
+	dbP := dDB.NewDumbDB(".", dbName, os.Stdout, testOpts...)
+	if dbP == nil {
+		t.Fatalf("Error creating DB %s", dbName)
+	}
+	defer removeDbFile(dbP.DbFullName)
+	defer dbP.Close()
+
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	rpc.Register(s, dbP)
+	go s.Serve(lis)
+	defer s.Stop()
+
+	client, err := rpc.Dial("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Error creating client Error: %s", err.Error())
+	}
+	defer client.Close()
+
+	checkKV(t, "local", dbP, "Local")
+	checkKV(t, "remote", client, "Remote")
+
+	dbP.SetSchema("Checked", []byte(`{"required": ["name"], "properties": {"age": {"minimum": 0}}}`))
+	rec := [][]byte{[]byte("a"), []byte(`{"age": -1}`)}
+	local, remote := dbP.Store(rec, "Checked"), client.Store(rec, "Checked")
+	var verr *dDB.ValidationError
+	if !errors.As(remote, &verr) || !reflect.DeepEqual(verr, local) {
+		t.Errorf("Incorrect error Expected: %v Got: %v", local, remote)
+	}
+}
@@ -0,0 +1,141 @@
+package tests
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+
+	dDB "dumbDB"
+)
+
+const userSchema = `{
+	"type": "object",
+	"required": ["name", "age"],
+	"additionalProperties": false,
+	"properties": {
+		"name": {"type": "string", "minLength": 1},
+		"age": {"type": "integer", "minimum": 0},
+		"email": {"type": "string", "pattern": "^[^@]+@[^@]+$"},
+		"tags": {"type": "array", "items": {"enum": ["admin", "dev"]}, "uniqueItems": true},
+		"manager": {"$ref": "#"}
+	}
+}`
+
+// 1. An invalid schema, also one whose $ref loops back to itself, is rejected.
+// 2. Store and Update reject records which do not match the schema with
+// the paths of all failing values.
+// 3. Schemas are kept in the DB file.
+// 4. ValidateBucket lists the records stored before the schema was set.
+// 5. Removing the schema allows any value again.
+func TestSchema(t *testing.T) {
+
+	dbName := "TestSchema"
+	dbP := dDB.NewDumbDB(".", dbName, os.Stdout, testOpts...)
+	if dbP == nil {
+		t.Fatalf("Error creating DB %s", dbName)
+	}
+	defer removeDbFile(dbP.DbFullName)
+
+	dbP.Store([][]byte{[]byte("old"), []byte(`{"name": "", "age": 1.5}`)}, "Users")
+	dbP.Store([][]byte{[]byte("ok"), []byte(`{"name": "ann", "age": 3}`)}, "Users")
+
+	schemas := []string{`{"type": 1}`, `{"minimum": "1"}`, `{"$ref": "#/definitions/x"}`, `[`, `{"$ref": "#"}`,
+		`{"definitions": {"a": {"not": {"$ref": "#"}}}, "allOf": [{"$ref": "#/definitions/a"}]}`}
+	for _, s := range schemas {
+		if err := dbP.SetSchema("Users", []byte(s)); !errors.Is(err, dDB.ErrInvalidSchema) {
+			t.Errorf("Incorrect error for %s Expected: %v Got: %v", s, dDB.ErrInvalidSchema, err)
+		}
+	}
+	if err := dbP.SetSchema("Users", []byte(userSchema)); err != nil {
+		t.Fatalf("Error in SetSchema Error: %s", err.Error())
+	}
+
+	doc := `{"name": "bob", "age": -1, "email": "bob", "tags": ["dev", "ops", "dev"],
+		"manager": {"name": "cid"}, "x": 1}`
+	err := dbP.Store([][]byte{[]byte("bob"), []byte(doc)}, "Users")
+	var verr *dDB.ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Incorrect error Expected: ValidationError Got: %v", err)
+	}
+	paths := make([]string, len(verr.Errors))
+	for i, f := range verr.Errors {
+		paths[i] = f.Path
+	}
+	exp := []string{"/age", "/email", "/manager", "/tags", "/tags/1", "/x"}
+	if verr.Bucket != "Users" || string(verr.Key) != "bob" || !reflect.DeepEqual(paths, exp) {
+		t.Errorf("Incorrect failing paths Expected: %v Got: %v (%v)", exp, paths, err)
+	}
+	if _, err = dbP.Get([]byte("bob"), "Users"); err == nil {
+		t.Errorf("Invalid record was stored")
+	}
+	err = dbP.Update(func(tx *dDB.Tx) error {
+		return tx.Store([][]byte{[]byte("cid"), []byte(`"cid"`)}, "Users")
+	})
+	if !errors.As(err, &verr) {
+		t.Errorf("Incorrect error Expected: ValidationError Got: %v", err)
+	}
+	valid := `{"name": "bob", "age": 40, "tags": ["dev"], "manager": {"name": "ann", "age": 50}}`
+	if err = dbP.Store([][]byte{[]byte("bob"), []byte(valid)}, "Users"); err != nil {
+		t.Errorf("Error storing valid record Error: %v", err)
+	}
+
+	if curBackend.file {
+		dbP.Close()
+		dbP = dDB.NewDumbDB(".", dbName, os.Stdout, testOpts...)
+		if dbP == nil {
+			t.Fatalf("Error reopening DB %s", dbName)
+		}
+	}
+	defer dbP.Close()
+	if s, _ := dbP.GetSchema("Users"); string(s) != userSchema {
+		t.Errorf("Incorrect schema Expected: %s Got: %s", userSchema, s)
+	}
+
+	invalid, err := dbP.ValidateBucket("Users")
+	if err != nil || len(invalid) != 1 || string(invalid[0].Key) != "old" || len(invalid[0].Errors) != 2 {
+		t.Errorf("Incorrect invalid records Expected: [old] Got: %v %v", invalid, err)
+	}
+
+	dbP.SetSchema("Users", nil)
+	if err = dbP.Store([][]byte{[]byte("cid"), []byte("cid")}, "Users"); err != nil {
+		t.Errorf("Error storing without schema Error: %v", err)
+	}
+	if invalid, _ = dbP.ValidateBucket("Users"); len(invalid) != 0 {
+		t.Errorf("Incorrect invalid records Expected: [] Got: %v", invalid)
+	}
+}
+
+// 1. A property matching several patternProperties gets their errors in
+// the order of the patterns, every time.
+func TestSchema_PatternProperties(t *testing.T) {
+
+	dbName := "TestSchema_PatternProperties"
+	dbP := dDB.NewDumbDB(".", dbName, os.Stdout, testOpts...)
+	if dbP == nil {
+		t.Fatalf("Error creating DB %s", dbName)
+	}
+	defer removeDbFile(dbP.DbFullName)
+	defer dbP.Close()
+
+	schema := `{"patternProperties": {"^c": {"minimum": 10}, "^a": {"type": "string"},
+		"b$": {"maximum": 1}, "^ab": {"multipleOf": 2}}}`
+	if err := dbP.SetSchema("B", []byte(schema)); err != nil {
+		t.Fatalf("Error in SetSchema Error: %s", err.Error())
+	}
+	exp := []string{"expected string, got integer", "must be a multiple of 2", "must be <= 1"}
+	for i := 0; i < 20; i++ {
+		err := dbP.Store([][]byte{[]byte("k"), []byte(`{"ab": 5}`)}, "B")
+		var verr *dDB.ValidationError
+		if !errors.As(err, &verr) {
+			t.Fatalf("Incorrect error Expected: ValidationError Got: %v", err)
+		}
+		msgs := make([]string, len(verr.Errors))
+		for j, f := range verr.Errors {
+			msgs[j] = f.Message
+		}
+		if !reflect.DeepEqual(msgs, exp) {
+			t.Fatalf("Incorrect errors Expected: %v Got: %v", exp, msgs)
+		}
+	}
+}
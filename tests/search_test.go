@@ -0,0 +1,137 @@
+package tests
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"sort"
+	"testing"
+
+	dDB "dumbDB"
+	"dumbDB/text"
+)
+
+var notes = map[string]string{
+	"n1": `{"Title": "Shopping list", "Body": "milk, eggs and bread"}`,
+	"n2": `{"Title": "Running notes", "Body": "I went running in the park. Running is fun."}`,
+	"n3": `{"Title": "Recipe", "Body": "Mix the eggs with milk", "Tags": ["cooking", "breakfast"]}`,
+	"n4": `{"Title": "List of books", "Body": "A list of lists"}`,
+	"n5": `not json`,
+}
+
+func hitKeys(hits []dDB.SearchHit) []string {
+	ks := make([]string, len(hits))
+	for i, h := range hits {
+		ks[i] = string(h.Key)
+	}
+	return ks
+}
+
+// 1. The analyzers split, lowercase, stem and drop stop words.
+// 2. Words, OR, phrases and prefixes find the indexed notes, best first.
+// 3. The index follows Store, Update, Remove and RemoveBucket.
+// 4. Writes after reopening the DB keep the index up to date.
+// 5. Malformed queries, unknown analyzers and dropped indexes are errors.
+func TestSearch(t *testing.T) {
+
+	for w, exp := range map[string]string{"notes": "note", "noted": "note", "running": "run",
+		"jumps": "jump", "studies": "study", "hoping": "hope", "opening": "open", "sing": "sing"} {
+		if s := text.Stem(w); s != exp {
+			t.Errorf("Incorrect stem of %s Expected: %s Got: %s", w, exp, s)
+		}
+	}
+	a, _ := text.Lookup(text.ENGLISH)
+	if terms := a.Analyze("The e-mails of Zoë"); !reflect.DeepEqual(terms, []string{"e", "mail", "zoë"}) {
+		t.Errorf("Incorrect terms Expected: [e mail zoë] Got: %v", terms)
+	}
+
+	dbName := "TestSearch"
+	dbP := dDB.NewDumbDB(".", dbName, os.Stdout, testOpts...)
+	if dbP == nil {
+		t.Fatalf("Error creating DB %s", dbName)
+	}
+	defer removeDbFile(dbP.DbFullName)
+
+	for k, v := range notes {
+		dbP.Store([][]byte{[]byte(k), []byte(v)}, "Notes")
+	}
+	idx := dDB.TextIndex{Fields: []string{"Title", "Body", "Tags"}}
+	if err := dbP.CreateTextIndex("Notes", dDB.TextIndex{Fields: idx.Fields, Analyzer: "none"}); !errors.Is(err, dDB.ErrUnknownAnalyzer) {
+		t.Errorf("Incorrect error Expected: %v Got: %v", dDB.ErrUnknownAnalyzer, err)
+	}
+	if err := dbP.CreateTextIndex("Notes", idx); err != nil {
+		t.Fatalf("Error in CreateTextIndex Error: %s", err.Error())
+	}
+
+	check := func(query string, exp ...string) {
+		t.Helper()
+		hits, err := dbP.Search("Notes", query)
+		if err != nil {
+			t.Errorf("Error in Search %s Error: %v", query, err)
+		}
+		if exp == nil {
+			exp = []string{}
+		}
+		if ks := hitKeys(hits); !reflect.DeepEqual(ks, exp) {
+			t.Errorf("Incorrect hits for %s Expected: %v Got: %v", query, exp, ks)
+		}
+	}
+	check("eggs milk", "n1", "n3")
+	check("MILK AND eggs", "n1", "n3")
+	check("list", "n4", "n1")
+	check("runs", "n2")
+	check(`"shopping list"`, "n1")
+	check(`"list shopping"`)
+	check(`"list milk"`)
+	check("break*", "n3")
+	check("shop* OR park", "n1", "n2")
+	check("eggs books")
+	hits, _ := dbP.Search("Notes", "bread OR books")
+	ks := hitKeys(hits)
+	sort.Strings(ks)
+	if !reflect.DeepEqual(ks, []string{"n1", "n4"}) {
+		t.Fatalf("Incorrect hits Expected: [n1 n4] Got: %v", ks)
+	}
+	if hits[0].Score < hits[1].Score || string(hits[0].Value) != notes[string(hits[0].Key)] {
+		t.Errorf("Incorrect first hit Got: %s %v %s", hits[0].Key, hits[0].Score, hits[0].Value)
+	}
+
+	dbP.Update(func(tx *dDB.Tx) error {
+		return tx.Store([][]byte{[]byte("n4"), []byte(`{"Title": "Empty"}`)}, "Notes")
+	})
+	check("list", "n1")
+	dbP.Remove([]byte("n1"), "Notes")
+	check("list")
+	dbP.RemoveBucket("Notes")
+	check("milk")
+	dbP.Store([][]byte{[]byte("n3"), []byte(notes["n3"])}, "Notes")
+	check("milk", "n3")
+
+	if curBackend.file {
+		dbP.Close()
+		dbP = dDB.NewDumbDB(".", dbName, os.Stdout, testOpts...)
+		if dbP == nil {
+			t.Fatalf("Error reopening DB %s", dbName)
+		}
+	}
+	defer dbP.Close()
+	if got, _ := dbP.GetTextIndex("Notes"); got == nil || !reflect.DeepEqual(*got, idx) {
+		t.Errorf("Incorrect text index Expected: %v Got: %v", idx, got)
+	}
+	dbP.Store([][]byte{[]byte("n2"), []byte(notes["n2"])}, "Notes")
+	check("run* OR cook*", "n2", "n3")
+
+	for _, q := range []string{`"milk`, "OR milk", "milk OR", "", "*"} {
+		if _, err := dbP.Search("Notes", q); !errors.Is(err, dDB.ErrInvalidQuery) {
+			t.Errorf("Incorrect error for %q Expected: %v Got: %v", q, dDB.ErrInvalidQuery, err)
+		}
+	}
+	dbP.DropTextIndex("Notes")
+	dbP.Store([][]byte{[]byte("n1"), []byte(notes["n1"])}, "Notes")
+	if _, err := dbP.Search("Notes", "milk"); !errors.Is(err, dDB.ErrInvalidQuery) {
+		t.Errorf("Incorrect error Expected: %v Got: %v", dDB.ErrInvalidQuery, err)
+	}
+	if got, _ := dbP.GetTextIndex("Notes"); got != nil {
+		t.Errorf("Incorrect text index Expected: <nil> Got: %v", got)
+	}
+}
@@ -0,0 +1,187 @@
+package tests
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	dDB "dumbDB"
+	"dumbDB/server"
+)
+
+func doRequest(t *testing.T, method, url string, body string, header map[string]string) *http.Response {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Error creating request Error: %s", err.Error())
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Error sending request Error: %s", err.Error())
+	}
+	return resp
+}
+
+func expectStatus(t *testing.T, resp *http.Response, status int) []byte {
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != status {
+		t.Errorf("%s %s Incorrect status Expected: %d Got: %d %s",
+			resp.Request.Method, resp.Request.URL, status, resp.StatusCode, b)
+	}
+	return b
+}
+
+type scanPage struct {
+	Records []struct {
+		Key   string
+		Value []byte
+	}
+	NextPageToken string `json:"next_page_token"`
+}
+
+// 1. PUT / GET / DELETE keys with ETag and If-Match / If-None-Match.
+// 2. Page through a scan forward and in reverse with page tokens.
+// 3. Stream a scan as NDJSON.
+// 4. Apply a batch atomically, failing it on a precondition.
+// 5. A record not matching the schema is rejected with 422 and its
+//    failing fields.
+func TestServer_API(t *testing.T) {
+
+	dbName := "TestServer_API"
+	dbP := dDB.NewDumbDB(".", dbName, os.Stdout, testOpts...)
+	if dbP == nil {
+		t.Fatalf("Error creating DB %s", dbName)
+	}
+	defer removeDbFile(dbP.DbFullName)
+	defer dbP.Close()
+	storeUsers(t, dbP, "Users", User1, User2, User3, User4, User5)
+
+	ts := httptest.NewServer(server.NewHandler(dbP))
+	defer ts.Close()
+	keyURL := ts.URL + "/buckets/Notes/keys/"
+
+	resp := doRequest(t, "PUT", keyURL+"a", `{"v":1}`, map[string]string{"If-None-Match": "*"})
+	etag := resp.Header.Get("ETag")
+	expectStatus(t, resp, http.StatusCreated)
+	if etag != server.ETag([]byte(`{"v":1}`)) {
+		t.Errorf("Incorrect ETag Expected: %s Got: %s", server.ETag([]byte(`{"v":1}`)), etag)
+	}
+	expectStatus(t, doRequest(t, "PUT", keyURL+"a", "x", map[string]string{"If-None-Match": "*"}),
+		http.StatusPreconditionFailed)
+
+	resp = doRequest(t, "GET", keyURL+"a", "", nil)
+	if resp.Header.Get("ETag") != etag || resp.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("Incorrect headers Got: %v", resp.Header)
+	}
+	if b := expectStatus(t, resp, http.StatusOK); string(b) != `{"v":1}` {
+		t.Errorf("Incorrect value Expected: %s Got: %s", `{"v":1}`, b)
+	}
+	expectStatus(t, doRequest(t, "GET", keyURL+"a", "", map[string]string{"If-None-Match": etag}),
+		http.StatusNotModified)
+
+	expectStatus(t, doRequest(t, "PUT", keyURL+"a", "stale", map[string]string{"If-Match": `"0"`}),
+		http.StatusPreconditionFailed)
+	resp = doRequest(t, "PUT", keyURL+"a", `{"v":2}`, map[string]string{"If-Match": etag})
+	expectStatus(t, resp, http.StatusNoContent)
+	expectStatus(t, doRequest(t, "DELETE", keyURL+"a", "", map[string]string{"If-Match": etag}),
+		http.StatusPreconditionFailed)
+	expectStatus(t, doRequest(t, "DELETE", keyURL+"a", "", map[string]string{"If-Match": resp.Header.Get("ETag")}),
+		http.StatusNoContent)
+	expectStatus(t, doRequest(t, "GET", keyURL+"a", "", nil), http.StatusNotFound)
+	expectStatus(t, doRequest(t, "GET", ts.URL+"/buckets/Missing/keys/a", "", nil), http.StatusNotFound)
+	expectStatus(t, doRequest(t, "PUT", ts.URL+"/buckets/"+dDB.META_BUCKET+"/keys/a", "x", nil), http.StatusForbidden)
+	expectStatus(t, doRequest(t, "DELETE", ts.URL+"/buckets/"+dDB.META_BUCKET, "", nil), http.StatusForbidden)
+	expectStatus(t, doRequest(t, "GET", ts.URL+"/buckets/Users/keys/x?key_enc=uint64", "", nil), http.StatusBadRequest)
+
+	b := expectStatus(t, doRequest(t, "GET", ts.URL+"/buckets/Users/keys/3?key_enc=uint64", "", nil), http.StatusOK)
+	if (UserRecord{}).PutVal(b) != User3 {
+		t.Errorf("Incorrect value Expected: %v Got: %s", User3, b)
+	}
+
+	for _, reverse := range []string{"false", "true"} {
+		ids := make([]string, 0)
+		token := ""
+		for pages := 0; pages < 5; pages++ {
+			url := ts.URL + "/buckets/Users/keys?key_enc=uint64&limit=2&reverse=" + reverse + "&page_token=" + token
+			var page scanPage
+			json.Unmarshal(expectStatus(t, doRequest(t, "GET", url, "", nil), http.StatusOK), &page)
+			for _, r := range page.Records {
+				ids = append(ids, r.Key)
+			}
+			if token = page.NextPageToken; token == "" {
+				break
+			}
+		}
+		expected := "1 2 3 4 5"
+		if reverse == "true" {
+			expected = "5 4 3 2 1"
+		}
+		if strings.Join(ids, " ") != expected {
+			t.Errorf("Incorrect scan reverse=%s Expected: %s Got: %v", reverse, expected, ids)
+		}
+	}
+
+	resp = doRequest(t, "GET", ts.URL+"/buckets/Users/keys?key_enc=uint64&start=2&end=5", "",
+		map[string]string{"Accept": "application/x-ndjson"})
+	if resp.Header.Get("Content-Type") != "application/x-ndjson" {
+		t.Errorf("Incorrect Content-Type Got: %s", resp.Header.Get("Content-Type"))
+	}
+	lines := 0
+	sc := bufio.NewScanner(resp.Body)
+	for sc.Scan() {
+		var r struct {
+			Key   string
+			Value []byte
+		}
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil || (UserRecord{}).PutVal(r.Value).ID != lines+2 {
+			t.Errorf("Incorrect NDJSON line Got: %s", sc.Text())
+		}
+		lines++
+	}
+	resp.Body.Close()
+	if lines != 3 {
+		t.Errorf("Incorrect no of NDJSON lines Expected: %d Got: %d", 3, lines)
+	}
+
+	batch := `{"writes": [
+		{"bucket": "Notes", "key": "b", "value": "YmJi"},
+		{"bucket": "Notes", "key": "c", "value": "Y2Nj", "if_match": "\"0\""}]}`
+	expectStatus(t, doRequest(t, "POST", ts.URL+"/batch", batch, nil), http.StatusPreconditionFailed)
+	expectStatus(t, doRequest(t, "GET", keyURL+"b", "", nil), http.StatusNotFound)
+
+	expectStatus(t, doRequest(t, "POST", ts.URL+"/batch?key_enc=uint64", batch, nil), http.StatusBadRequest)
+
+	batch = `{"writes": [
+		{"bucket": "Notes", "key": "Yg", "value": "YmJi"},
+		{"bucket": "Users", "key": "AQAAAAAAAAA", "delete": true}]}`
+	expectStatus(t, doRequest(t, "POST", ts.URL+"/batch?key_enc=base64", batch, nil), http.StatusOK)
+	if b = expectStatus(t, doRequest(t, "GET", keyURL+"b", "", nil), http.StatusOK); string(b) != "bbb" {
+		t.Errorf("Incorrect value Expected: %s Got: %s", "bbb", b)
+	}
+	expectStatus(t, doRequest(t, "GET", ts.URL+"/buckets/Users/keys/1?key_enc=uint64", "", nil), http.StatusNotFound)
+
+	b = expectStatus(t, doRequest(t, "GET", ts.URL+"/buckets", "", nil), http.StatusOK)
+	if string(b) != `{"buckets":["Notes","Users"]}`+"\n" {
+		t.Errorf("Incorrect buckets Got: %s", b)
+	}
+	expectStatus(t, doRequest(t, "DELETE", ts.URL+"/buckets/Notes", "", nil), http.StatusNoContent)
+
+	dbP.SetSchema("Checked", []byte(`{"required": ["name"], "properties": {"age": {"minimum": 0}}}`))
+	b = expectStatus(t, doRequest(t, "PUT", ts.URL+"/buckets/Checked/keys/a", `{"age": -1}`, nil),
+		http.StatusUnprocessableEntity)
+	var body struct {
+		Errors []dDB.FieldError
+	}
+	json.Unmarshal(b, &body)
+	if len(body.Errors) != 2 || body.Errors[0].Path != "" || body.Errors[1].Path != "/age" {
+		t.Errorf("Incorrect failing fields Expected: [ /age] Got: %s", b)
+	}
+}
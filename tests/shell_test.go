@@ -0,0 +1,116 @@
+package tests
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	dDB "dumbDB"
+	"dumbDB/cli"
+)
+
+func execShell(t *testing.T, sh *cli.Shell, out *bytes.Buffer, line string) string {
+	out.Reset()
+	if err := sh.Exec(line); err != nil {
+		t.Errorf("Error running %q Error: %s", line, err.Error())
+	}
+	return out.String()
+}
+
+// 1. Select a bucket and put / get / del / scan records in all formats.
+// 2. Read uncommitted writes inside a transaction, roll back and commit it.
+// 3. Complete command and bucket names.
+// 4. Run a script through dumbdb shell.
+func TestShell_Commands(t *testing.T) {
+
+	dbName := "TestShell_Commands"
+	dbP := dDB.NewDumbDB(".", dbName, os.Stdout, testOpts...)
+	if dbP == nil {
+		t.Fatalf("Error creating DB %s", dbName)
+	}
+	defer removeDbFile(dbP.DbFullName)
+	storeUsers(t, dbP, "Users", User1, User2, User3)
+
+	var out bytes.Buffer
+	sh := cli.NewShell(dbP, &out)
+
+	if err := sh.Exec("get 1"); err == nil {
+		t.Errorf("Expected error without a bucket selected")
+	}
+	execShell(t, sh, &out, "use Notes")
+	if sh.Prompt() != "dumbdb:Notes> " {
+		t.Errorf("Incorrect prompt Got: %q", sh.Prompt())
+	}
+	execShell(t, sh, &out, `put a {"n": 1}`)
+	execShell(t, sh, &out, `put "b c" "two words"`)
+
+	got := execShell(t, sh, &out, "get a")
+	if !strings.Contains(got, "KEY") || !strings.Contains(got, `{"n":1}`) {
+		t.Errorf("Incorrect table output Got: %q", got)
+	}
+	execShell(t, sh, &out, "format raw")
+	if got = execShell(t, sh, &out, `get "b c"`); got != "two words\n" {
+		t.Errorf("Incorrect raw output Expected: %q Got: %q", "two words\n", got)
+	}
+	execShell(t, sh, &out, "format json")
+	if got = execShell(t, sh, &out, "scan -limit 1"); got != "[\n  {\n    \"key\": \"a\",\n    \"value\": {\n      \"n\": 1\n    }\n  }\n]\n" {
+		t.Errorf("Incorrect json output Got: %q", got)
+	}
+
+	execShell(t, sh, &out, "begin")
+	execShell(t, sh, &out, "put z 26")
+	execShell(t, sh, &out, "del a")
+	if sh.Prompt() != "dumbdb:Notes*> " {
+		t.Errorf("Incorrect prompt Got: %q", sh.Prompt())
+	}
+	execShell(t, sh, &out, "format raw")
+	if got = execShell(t, sh, &out, "scan -reverse"); got != "z\t26\nb c\ttwo words\n" {
+		t.Errorf("Incorrect scan in transaction Got: %q", got)
+	}
+	if _, err := dbP.Get([]byte("z"), "Notes"); err == nil {
+		t.Errorf("Uncommitted write visible outside the transaction")
+	}
+	execShell(t, sh, &out, "rollback")
+	if got = execShell(t, sh, &out, "get a"); got != "{\"n\": 1}\n" {
+		t.Errorf("Write not rolled back Got: %q", got)
+	}
+
+	execShell(t, sh, &out, "begin")
+	execShell(t, sh, &out, "put z 26")
+	execShell(t, sh, &out, "del a")
+	if got = execShell(t, sh, &out, "commit"); got != "committed 2 writes\n" {
+		t.Errorf("Incorrect commit output Got: %q", got)
+	}
+	if v, err := dbP.Get([]byte("z"), "Notes"); err != nil || string(v) != "26" {
+		t.Errorf("Committed write missing Got: %s %v", v, err)
+	}
+	if _, err := dbP.Get([]byte("a"), "Notes"); err == nil {
+		t.Errorf("Committed delete not applied")
+	}
+
+	if got := sh.Complete("sc"); !reflect.DeepEqual(got, []string{"scan "}) {
+		t.Errorf("Incorrect completion Expected: %v Got: %v", []string{"scan "}, got)
+	}
+	if got := sh.Complete("use U"); !reflect.DeepEqual(got, []string{"use Users"}) {
+		t.Errorf("Incorrect completion Expected: %v Got: %v", []string{"use Users"}, got)
+	}
+	if sh.Exec("exit") != cli.ErrQuit {
+		t.Errorf("exit did not return ErrQuit")
+	}
+	dbP.Close()
+	if !curBackend.file {
+		return
+	}
+
+	script := "use Users\nkeyenc uint64\nformat raw\nget 2\nbegin\nput 9 x\n"
+	var stdout, stderr bytes.Buffer
+	code := cli.Run([]string{"shell", "./" + dbName + dDB.DEFAULT_SUFFIX}, strings.NewReader(script), &stdout, &stderr)
+	if code != cli.EXIT_OK || stdout.String() != string(User2.GetVal())+"\n" {
+		t.Errorf("Incorrect script output Expected: %s Got: %q %s", User2.GetVal(), stdout.String(), stderr.String())
+	}
+	if !strings.Contains(stderr.String(), "discarded 1 writes") {
+		t.Errorf("Uncommitted transaction not reported Got: %q", stderr.String())
+	}
+}
@@ -0,0 +1,64 @@
+package tests
+
+import (
+	"os"
+	"testing"
+
+	dDB "dumbDB"
+)
+
+// 1. Store records and take a snapshot of the stats.
+// 2. Hits, misses, stores, removes and errors are counted.
+// 3. The diff of two snapshots only contains the operations in between.
+func TestDumbDB_Stats(t *testing.T) {
+
+	dbName := "TestDumbDB_Stats"
+	dbP := dDB.NewDumbDB(".", dbName, os.Stdout, testOpts...)
+
+	if dbP == nil {
+		t.Fatalf("Error creating DB %s", dbName)
+	}
+	defer removeDbFile(dbP.DbFullName)
+
+	dbP.Store(User1.GetRecord(), dbName)
+	dbP.Store(User2.GetRecord(), dbName)
+
+	before, err := dbP.Stats()
+	if err != nil {
+		t.Fatalf("Error getting stats Error: %s", err.Error())
+	}
+	if before.Ops.Stores != 2 {
+		t.Errorf("Incorrect store count Expected: %d Got: %d", 2, before.Ops.Stores)
+	}
+	if before.Buckets[dbName].KeyN != 2 {
+		t.Errorf("Incorrect key count Expected: %d Got: %d", 2, before.Buckets[dbName].KeyN)
+	}
+	if curBackend.file && before.FileSize == 0 {
+		t.Error("Expected non zero file size")
+	}
+
+	dbP.Get(User1.GetKey(), dbName)
+	dbP.Get(User3.GetKey(), dbName)
+	dbP.Get(User1.GetKey(), "RANDOM_BUCKET")
+	dbP.GetAll(dbName)
+	dbP.Remove(User2.GetKey(), dbName)
+
+	after, err := dbP.Stats()
+	if err != nil {
+		t.Fatalf("Error getting stats Error: %s", err.Error())
+	}
+
+	diff := after.Sub(before)
+	if diff.Ops.Gets != 3 || diff.Ops.Hits != 1 || diff.Ops.Misses != 1 || diff.Ops.Errors != 1 {
+		t.Errorf("Incorrect get counters %+v", diff.Ops)
+	}
+	if diff.Ops.Stores != 0 || diff.Ops.Removes != 1 || diff.Ops.Scans != 1 {
+		t.Errorf("Incorrect write counters %+v", diff.Ops)
+	}
+	if diff.Ops.Latency["Get"].Count != 3 || diff.Ops.Latency["Store"].Count != 0 {
+		t.Errorf("Incorrect latency counts %+v", diff.Ops.Latency)
+	}
+	if after.Buckets[dbName].KeyN != 1 {
+		t.Errorf("Incorrect key count Expected: %d Got: %d", 1, after.Buckets[dbName].KeyN)
+	}
+}
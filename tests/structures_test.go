@@ -0,0 +1,200 @@
+package tests
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+
+	dDB "dumbDB"
+	"dumbDB/structures"
+
+	"github.com/boltdb/bolt"
+)
+
+func strs(vals [][]byte) []string {
+	s := make([]string, len(vals))
+	for i, v := range vals {
+		s[i] = string(v)
+	}
+	return s
+}
+
+// 1. Push and pop at both ends of a list and read ranges of it.
+// 2. Add and remove set members, intersect and union sets.
+// 3. Rank sorted set members and range them by rank and score.
+// 4. Structures in a failed Update are rolled back with it.
+func TestStructures(t *testing.T) {
+
+	dbName := "TestStructures"
+	dbP := dDB.NewDumbDB(".", dbName, os.Stdout, testOpts...)
+	if dbP == nil {
+		t.Fatalf("Error creating DB %s", dbName)
+	}
+	defer removeDbFile(dbP.DbFullName)
+	defer dbP.Close()
+
+	err := dbP.Update(func(tx *dDB.Tx) error {
+		l := structures.NewList(tx, dbName, "jobs")
+		l.RPush([]byte("b"), []byte("c"))
+		if n, _ := l.LPush([]byte("a")); n != 3 {
+			t.Errorf("Incorrect length Expected: %d Got: %d", 3, n)
+		}
+		if got, _ := l.Range(0, -1); !reflect.DeepEqual(strs(got), []string{"a", "b", "c"}) {
+			t.Errorf("Incorrect range Expected: [a b c] Got: %v", strs(got))
+		}
+		if got, _ := l.Range(-2, 10); !reflect.DeepEqual(strs(got), []string{"b", "c"}) {
+			t.Errorf("Incorrect range Expected: [b c] Got: %v", strs(got))
+		}
+		if v, _ := l.Index(-1); string(v) != "c" {
+			t.Errorf("Incorrect index Expected: c Got: %s", v)
+		}
+		if v, _ := l.RPop(); string(v) != "c" {
+			t.Errorf("Incorrect pop Expected: c Got: %s", v)
+		}
+		l.LPop()
+		l.LPop()
+		if _, e := l.LPop(); e != structures.ErrEmpty {
+			t.Errorf("Incorrect error Expected: %v Got: %v", structures.ErrEmpty, e)
+		}
+
+		a, b := structures.NewSet(tx, dbName, "a"), structures.NewSet(tx, dbName, "b")
+		if n, _ := a.Add([]byte("x"), []byte("y"), []byte("x"), []byte("z")); n != 3 {
+			t.Errorf("Incorrect no added Expected: %d Got: %d", 3, n)
+		}
+		b.Add([]byte("y"), []byte("z"), []byte("w"))
+		a.Remove([]byte("z"), []byte("missing"))
+		if n, _ := a.Card(); n != 2 {
+			t.Errorf("Incorrect card Expected: %d Got: %d", 2, n)
+		}
+		if got, _ := a.Intersect(b); !reflect.DeepEqual(strs(got), []string{"y"}) {
+			t.Errorf("Incorrect intersection Expected: [y] Got: %v", strs(got))
+		}
+		if got, _ := a.Union(b); !reflect.DeepEqual(strs(got), []string{"w", "x", "y", "z"}) {
+			t.Errorf("Incorrect union Expected: [w x y z] Got: %v", strs(got))
+		}
+
+		z := structures.NewSortedSet(tx, dbName, "scores")
+		z.Add([]byte("ann"), 30)
+		z.Add([]byte("bob"), -5)
+		z.Add([]byte("cid"), 12.5)
+		z.Add([]byte("bob"), 50)
+		z.IncrBy([]byte("dan"), 12.5)
+		if n, _ := z.Card(); n != 4 {
+			t.Errorf("Incorrect card Expected: %d Got: %d", 4, n)
+		}
+		if r, ok, _ := z.Rank([]byte("ann")); !ok || r != 2 {
+			t.Errorf("Incorrect rank Expected: %d Got: %d", 2, r)
+		}
+		top, _ := z.RangeByRank(-2, -1)
+		if len(top) != 2 || string(top[0].Value) != "ann" || string(top[1].Value) != "bob" || top[1].Score != 50 {
+			t.Errorf("Incorrect rank range Expected: [ann bob] Got: %v", top)
+		}
+		mid, _ := z.RangeByScore(12.5, 30)
+		if len(mid) != 3 || string(mid[0].Value) != "cid" || string(mid[1].Value) != "dan" {
+			t.Errorf("Incorrect score range Expected: [cid dan ann] Got: %v", mid)
+		}
+		z.Remove([]byte("ann"))
+		if _, ok, _ := z.Score([]byte("ann")); ok {
+			t.Errorf("Removed member still has a score")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Error in Update Error: %s", err.Error())
+	}
+
+	failed := errors.New("failed")
+	dbP.Update(func(tx *dDB.Tx) error {
+		structures.NewList(tx, dbName, "jobs").RPush([]byte("lost"))
+		return failed
+	})
+	dbP.View(func(tx *dDB.Tx) error {
+		if n, _ := structures.NewList(tx, dbName, "jobs").Len(); n != 0 {
+			t.Errorf("Push of failed Update visible Got length: %d", n)
+		}
+		if _, e := structures.NewList(tx, dbName, "jobs").RPush([]byte("x")); e == nil {
+			t.Errorf("Push in View did not fail")
+		}
+		return nil
+	})
+}
+
+// 1. Structures are kept in nested buckets, which GetAll skips.
+// 2. A new follower receives them in a snapshot.
+// 3. Later changes and dropped nested buckets are streamed to it.
+func TestStructures_Replicate(t *testing.T) {
+
+	dbName := "TestStructures_Replicate"
+	primary := dDB.NewDumbDB(".", dbName, os.Stdout, testOpts...)
+	follower := dDB.NewFollower(".", dbName+"_follower", os.Stdout, testOpts...)
+	if primary == nil || follower == nil {
+		t.Fatalf("Error creating DB %s", dbName)
+	}
+	defer removeDbFile(primary.DbFullName)
+	defer removeDbFile(follower.DbFullName)
+
+	if err := primary.EnableReplication(2); err != nil {
+		t.Fatalf("Error enabling replication Error: %s", err.Error())
+	}
+	// 13 log entries: 4 for the list, 3 for the set and 6 for the sorted set
+	err := primary.Update(func(tx *dDB.Tx) error {
+		if _, e := structures.NewList(tx, dbName, "jobs").RPush([]byte("a"), []byte("b"), []byte("c")); e != nil {
+			return e
+		}
+		if _, e := structures.NewSet(tx, dbName, "tags").Add([]byte("x"), []byte("y")); e != nil {
+			return e
+		}
+		z := structures.NewSortedSet(tx, dbName, "scores")
+		z.Add([]byte("ann"), 1)
+		_, e := z.Add([]byte("bob"), 2)
+		return e
+	})
+	if err != nil {
+		t.Fatalf("Error in Update Error: %s", err.Error())
+	}
+	if records, _ := primary.GetAll(dbName); len(records) != 0 {
+		t.Errorf("Returned incorrect no of records Expected: %d Got: %d", 0, len(records))
+	}
+
+	stop, done := startReplication(primary, follower)
+	waitForSeq(t, follower, 13)
+
+	// 9 more: 2 for the pop, 2 for the remove, 3 for the new score and 2
+	// for the dropped bucket
+	err = primary.Update(func(tx *dDB.Tx) error {
+		structures.NewList(tx, dbName, "jobs").LPop()
+		structures.NewSet(tx, dbName, "tags").Remove([]byte("x"))
+		structures.NewSortedSet(tx, dbName, "scores").Add([]byte("ann"), 3)
+		tmp := tx.Nested(dbName, []byte("tmp"))
+		if e := tmp.Put([]byte("k"), []byte("v")); e != nil {
+			return e
+		}
+		return tmp.Drop()
+	})
+	if err != nil {
+		t.Fatalf("Error in Update Error: %s", err.Error())
+	}
+	waitForSeq(t, follower, 22)
+
+	follower.View(func(tx *dDB.Tx) error {
+		if got, _ := structures.NewList(tx, dbName, "jobs").Range(0, -1); !reflect.DeepEqual(strs(got), []string{"b", "c"}) {
+			t.Errorf("Incorrect range Expected: [b c] Got: %v", strs(got))
+		}
+		if got, _ := structures.NewSet(tx, dbName, "tags").Members(); !reflect.DeepEqual(strs(got), []string{"y"}) {
+			t.Errorf("Incorrect members Expected: [y] Got: %v", strs(got))
+		}
+		all, _ := structures.NewSortedSet(tx, dbName, "scores").RangeByRank(0, -1)
+		if len(all) != 2 || string(all[0].Value) != "bob" || string(all[1].Value) != "ann" || all[1].Score != 3 {
+			t.Errorf("Incorrect rank range Expected: [bob ann] Got: %v", all)
+		}
+		if _, e := tx.Nested(dbName, []byte("tmp")).Get([]byte("k")); e != bolt.ErrBucketNotFound {
+			t.Errorf("Expected Error: %v Got: %v", bolt.ErrBucketNotFound, e)
+		}
+		return nil
+	})
+
+	close(stop)
+	<-done
+	<-done
+}
@@ -0,0 +1,111 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	dDB "dumbDB"
+	"dumbDB/tracing"
+	"github.com/boltdb/bolt"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type recordingHook struct {
+	before []string
+	after  []dDB.OpInfo
+}
+
+func (h *recordingHook) Before(ctx context.Context, op *dDB.OpInfo) context.Context {
+	h.before = append(h.before, op.Op)
+	return ctx
+}
+
+func (h *recordingHook) After(ctx context.Context, op *dDB.OpInfo) {
+	h.after = append(h.after, *op)
+}
+
+// 1. A hook sees every operation before and after it runs.
+// 2. After gets the result count, key length and error.
+func TestDumbDB_OpHook(t *testing.T) {
+
+	dbName := "TestDumbDB_OpHook"
+	dbP := dDB.NewDumbDB(".", dbName, os.Stdout, testOpts...)
+
+	if dbP == nil {
+		t.Fatalf("Error creating DB %s", dbName)
+	}
+	defer removeDbFile(dbP.DbFullName)
+
+	h := &recordingHook{}
+	dbP.AddOpHook(h)
+
+	dbP.Store(User1.GetRecord(), dbName)
+	dbP.Store(User2.GetRecord(), dbName)
+	dbP.GetAll(dbName)
+	dbP.Get(User3.GetKey(), dbName)
+
+	if len(h.before) != 4 || len(h.after) != 4 {
+		t.Fatalf("Incorrect no of hook calls Expected: %d Got: %d %d", 4, len(h.before), len(h.after))
+	}
+	if h.after[0].Op != "Store" || h.after[0].KeyLen != 8 || h.after[0].Bucket != dbName {
+		t.Errorf("Incorrect op info %+v", h.after[0])
+	}
+	if h.after[2].Op != "GetAll" || h.after[2].Results != 2 {
+		t.Errorf("Incorrect op info %+v", h.after[2])
+	}
+	if h.after[3].Err != bolt.ErrKeyRequired || h.after[3].Duration <= 0 {
+		t.Errorf("Incorrect op info %+v", h.after[3])
+	}
+}
+
+// 1. Calls through the Ctx methods create spans nested in the caller's span.
+// 2. Failed operations mark the span as failed.
+func TestTracing_Spans(t *testing.T) {
+
+	dbName := "TestTracing_Spans"
+	dbP := dDB.NewDumbDB(".", dbName, os.Stdout, testOpts...)
+
+	if dbP == nil {
+		t.Fatalf("Error creating DB %s", dbName)
+	}
+	defer removeDbFile(dbP.DbFullName)
+
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	dbP.AddOpHook(tracing.NewHook(tp.Tracer("dumbDB")))
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "request")
+	dbP.StoreCtx(ctx, User1.GetRecord(), dbName)
+	dbP.GetCtx(ctx, User1.GetKey(), "RANDOM_BUCKET")
+	parent.End()
+
+	spans := sr.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("Incorrect no of spans Expected: %d Got: %d", 3, len(spans))
+	}
+
+	store, get := spans[0], spans[1]
+	if store.Name() != "dumbDB.Store" || get.Name() != "dumbDB.Get" {
+		t.Errorf("Incorrect span names %s %s", store.Name(), get.Name())
+	}
+	if store.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("Span not nested in caller span")
+	}
+
+	found := false
+	for _, a := range store.Attributes() {
+		if a.Key == attribute.Key("dumbdb.bucket") && a.Value.AsString() == dbName {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Bucket attribute missing %v", store.Attributes())
+	}
+	if get.Status().Code != codes.Error {
+		t.Errorf("Expected failed span Got: %v", get.Status())
+	}
+}
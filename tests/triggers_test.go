@@ -0,0 +1,104 @@
+package tests
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+
+	dDB "dumbDB"
+)
+
+// 1. A BeforeStore trigger rejects a write and nothing is stored.
+// 2. AfterStore and AfterRemove triggers write to another bucket in the
+// same transaction, also for writes made by Modify and Incr.
+// 3. BeforeRemove sees the record which is removed.
+// 4. AfterCommit runs only for committed changes.
+// 5. Removing a missing key runs no triggers.
+// 6. Removed triggers are no longer called.
+func TestTriggers(t *testing.T) {
+
+	dbName := "TestTriggers"
+	dbP := dDB.NewDumbDB(".", dbName, os.Stdout, testOpts...)
+	if dbP == nil {
+		t.Fatalf("Error creating DB %s", dbName)
+	}
+	defer removeDbFile(dbP.DbFullName)
+	defer dbP.Close()
+
+	invalid := errors.New("empty value")
+	dbP.BeforeStore("Users", func(tx *dDB.Tx, c dDB.Change) error {
+		if len(c.Value) == 0 {
+			return invalid
+		}
+		return nil
+	})
+	dbP.AfterStore("Users", func(tx *dDB.Tx, c dDB.Change) error {
+		return tx.Store([][]byte{c.Key, c.Value}, "Audit")
+	})
+	dbP.AfterRemove("Users", func(tx *dDB.Tx, c dDB.Change) error {
+		return tx.Remove(c.Key, "Audit")
+	})
+	var removed []string
+	dbP.BeforeRemove("Users", func(tx *dDB.Tx, c dDB.Change) error {
+		v, err := tx.Get(c.Key, c.Bucket)
+		removed = append(removed, string(v))
+		return err
+	})
+	var committed []string
+	stop := dbP.AfterCommit("", func(c dDB.Change) {
+		committed = append(committed, c.Bucket+":"+string(c.Key))
+	})
+
+	if err := dbP.Store([][]byte{[]byte("ann"), []byte{}}, "Users"); err != invalid {
+		t.Errorf("Incorrect error Expected: %v Got: %v", invalid, err)
+	}
+	if _, err := dbP.Get([]byte("ann"), "Users"); err == nil {
+		t.Errorf("Rejected record was stored")
+	}
+	if len(committed) != 0 {
+		t.Errorf("AfterCommit called for rejected write Got: %v", committed)
+	}
+
+	dbP.Store([][]byte{[]byte("ann"), []byte("1")}, "Users")
+	dbP.Modify("Users", []byte("ann"), func(old []byte) ([]byte, error) {
+		return append(old, '2'), nil
+	})
+	dbP.Incr("Users", []byte("bob"), 3)
+	if v, err := dbP.Get([]byte("ann"), "Audit"); err != nil || string(v) != "12" {
+		t.Errorf("Incorrect audit record Expected: 12 Got: %s %v", v, err)
+	}
+	if _, err := dbP.Get([]byte("bob"), "Audit"); err != nil {
+		t.Errorf("Counter not audited Error: %v", err)
+	}
+
+	if err := dbP.Remove([]byte("ann"), "Users"); err != nil {
+		t.Errorf("Error in Remove Error: %v", err)
+	}
+	if !reflect.DeepEqual(removed, []string{"12"}) {
+		t.Errorf("Incorrect removed records Expected: [12] Got: %v", removed)
+	}
+	if _, err := dbP.Get([]byte("ann"), "Audit"); err == nil {
+		t.Errorf("Audit record not removed")
+	}
+	if err := dbP.Remove([]byte("zed"), "Users"); err != nil {
+		t.Errorf("Error in Remove Error: %v", err)
+	}
+	if len(removed) != 1 {
+		t.Errorf("BeforeRemove called for missing key Got: %v", removed)
+	}
+
+	exp := []string{
+		"Users:ann", "Audit:ann", "Users:ann", "Audit:ann", "Users:bob", "Audit:bob",
+		"Users:ann", "Audit:ann",
+	}
+	if !reflect.DeepEqual(committed, exp) {
+		t.Errorf("Incorrect committed changes Expected: %v Got: %v", exp, committed)
+	}
+
+	stop()
+	dbP.Store([][]byte{[]byte("cid"), []byte("1")}, "Users")
+	if len(committed) != len(exp) {
+		t.Errorf("Removed trigger called Got: %v", committed)
+	}
+}
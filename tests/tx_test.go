@@ -0,0 +1,66 @@
+package tests
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	dDB "dumbDB"
+
+	"github.com/boltdb/bolt"
+)
+
+// 1. Writes of a failed Update are rolled back.
+// 2. Writes of a successful Update are all committed.
+// 3. View can read but not write.
+func TestDumbDB_Tx(t *testing.T) {
+
+	dbName := "TestDumbDB_Tx"
+	dbP := dDB.NewDumbDB(".", dbName, os.Stdout, testOpts...)
+	if dbP == nil {
+		t.Fatalf("Error creating DB %s", dbName)
+	}
+	defer removeDbFile(dbP.DbFullName)
+	defer dbP.Close()
+	storeUsers(t, dbP, dbName, User1)
+
+	failed := errors.New("failed")
+	err := dbP.Update(func(tx *dDB.Tx) error {
+		if e := tx.Store(User2.GetRecord(), dbName); e != nil {
+			return e
+		}
+		return failed
+	})
+	if err != failed {
+		t.Errorf("Incorrect error Expected: %v Got: %v", failed, err)
+	}
+	if _, err = dbP.Get(User2.GetKey(), dbName); err != bolt.ErrKeyRequired {
+		t.Errorf("Write of failed Update visible Got: %v", err)
+	}
+
+	err = dbP.Update(func(tx *dDB.Tx) error {
+		if e := tx.Store(User2.GetRecord(), dbName); e != nil {
+			return e
+		}
+		if v, e := tx.Get(User2.GetKey(), dbName); e != nil || (UserRecord{}).PutVal(v) != User2 {
+			t.Errorf("Write not visible inside Update Got: %s %v", v, e)
+		}
+		return tx.Remove(User1.GetKey(), dbName)
+	})
+	if err != nil {
+		t.Errorf("Error in Update Error: %s", err.Error())
+	}
+	if ids := scanIDs(t, dbP, dbName, dDB.ScanOptions{}); len(ids) != 1 || ids[0] != User2.ID {
+		t.Errorf("Incorrect records after Update Expected: %v Got: %v", []int{User2.ID}, ids)
+	}
+
+	err = dbP.View(func(tx *dDB.Tx) error {
+		if _, e := tx.Get(User2.GetKey(), dbName); e != nil {
+			t.Errorf("Error reading in View Error: %s", e.Error())
+		}
+		return tx.Store(User3.GetRecord(), dbName)
+	})
+	if err != bolt.ErrTxNotWritable {
+		t.Errorf("Incorrect error Expected: %v Got: %v", bolt.ErrTxNotWritable, err)
+	}
+}
@@ -0,0 +1,259 @@
+/*
+ * Package text turns text into the terms of a full-text index. An Analyzer
+ * splits text into tokens and passes them through filters, e.g. lowercasing
+ * and stemming. Analyzers are registered by name, the name is stored with
+ * the index so every process writing to the DB analyzes records alike.
+ */
+package text
+
+import (
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Analyzers registered by this package.
+const (
+	// Words, lowercased and stemmed
+	STANDARD = "standard"
+	// Words, lowercased
+	SIMPLE = "simple"
+	// Words, lowercased, without ENGLISH_STOP_WORDS and stemmed
+	ENGLISH = "english"
+)
+
+/*
+ * Tokenizer
+ * Splits text into tokens, in the order they appear.
+ */
+type Tokenizer func(text string) []string
+
+/*
+ * Filter
+ * Rewrites a token. Returning "" drops the token.
+ */
+type Filter func(token string) string
+
+/*
+ * Analyzer
+ * Turns text into the terms of the index: the tokens of Tokenizer passed
+ * through Filters in order. Records and queries are analyzed alike.
+ */
+type Analyzer struct {
+	Tokenizer Tokenizer
+	Filters   []Filter
+}
+
+// Common English words left out by StopWords(ENGLISH_STOP_WORDS...).
+var ENGLISH_STOP_WORDS = []string{
+	"a", "an", "and", "are", "as", "at", "be", "but", "by", "for", "if",
+	"in", "into", "is", "it", "no", "not", "of", "on", "or", "such", "that",
+	"the", "their", "then", "there", "these", "they", "this", "to", "was",
+	"will", "with",
+}
+
+var registry = struct {
+	sync.RWMutex
+	analyzers map[string]Analyzer
+}{analyzers: map[string]Analyzer{
+	STANDARD: {Tokenizer: Words, Filters: []Filter{Lowercase, Stem}},
+	SIMPLE:   {Tokenizer: Words, Filters: []Filter{Lowercase}},
+	ENGLISH:  {Tokenizer: Words, Filters: []Filter{Lowercase, StopWords(ENGLISH_STOP_WORDS...), Stem}},
+}}
+
+/*
+ * Register
+ * Make a under name available to full-text indexes. Every process writing
+ * to a DB whose index uses name must register the same analyzer, writes
+ * fail otherwise. Registering an existing name replaces it.
+ * @param 	name		name stored with the index
+ * @param 	a		analyzer
+ */
+func Register(name string, a Analyzer) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.analyzers[name] = a
+}
+
+/*
+ * Lookup
+ * Analyzer registered under name.
+ */
+func Lookup(name string) (a Analyzer, ok bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	a, ok = registry.analyzers[name]
+	return
+}
+
+/*
+ * Analyze
+ * Terms of text.
+ * @param 	text		text of a record or query
+ */
+func (a Analyzer) Analyze(text string) []string {
+	tokenize := a.Tokenizer
+	if tokenize == nil {
+		tokenize = Words
+	}
+	var terms []string
+	for _, t := range tokenize(text) {
+		for _, f := range a.Filters {
+			if t = f(t); t == "" {
+				break
+			}
+		}
+		if t != "" {
+			terms = append(terms, t)
+		}
+	}
+	return terms
+}
+
+/*
+ * Words
+ * Runs of letters and digits in text.
+ */
+func Words(text string) []string {
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+/*
+ * Lowercase
+ * token in lower case.
+ */
+func Lowercase(token string) string {
+	return strings.ToLower(token)
+}
+
+/*
+ * StopWords
+ * Filter dropping words. Place it after Lowercase.
+ * @param 	words		lower case words to drop
+ */
+func StopWords(words ...string) Filter {
+	stop := make(map[string]bool, len(words))
+	for _, w := range words {
+		stop[w] = true
+	}
+	return func(token string) string {
+		if stop[token] {
+			return ""
+		}
+		return token
+	}
+}
+
+/*
+ * Stem
+ * Light English stemmer removing plural, -ed and -ing endings, after the
+ * first step of the Porter algorithm: "notes", "noted" and "noting" become
+ * "note". Tokens which are not lower case ASCII letters are kept as is.
+ */
+func Stem(token string) string {
+	if len(token) <= 3 {
+		return token
+	}
+	for i := 0; i < len(token); i++ {
+		if token[i] < 'a' || token[i] > 'z' {
+			return token
+		}
+	}
+	w := token
+
+	// Plurals
+	switch {
+	case strings.HasSuffix(w, "sses"):
+		w = w[:len(w)-2]
+	case strings.HasSuffix(w, "ies"):
+		if len(w) > 4 {
+			w = w[:len(w)-3] + "y"
+		} else {
+			w = w[:len(w)-1]
+		}
+	case strings.HasSuffix(w, "ss"), strings.HasSuffix(w, "us"), strings.HasSuffix(w, "is"):
+	case strings.HasSuffix(w, "s"):
+		w = w[:len(w)-1]
+	}
+
+	// Past tense and gerunds
+	switch {
+	case strings.HasSuffix(w, "eed"):
+		if measure(w[:len(w)-3]) > 0 {
+			w = w[:len(w)-1]
+		}
+	case strings.HasSuffix(w, "ied") && len(w) > 4:
+		w = w[:len(w)-3] + "y"
+	case strings.HasSuffix(w, "ed"):
+		w = restore(w, w[:len(w)-2])
+	case strings.HasSuffix(w, "ing"):
+		w = restore(w, w[:len(w)-3])
+	}
+	return w
+}
+
+/*
+ * restore
+ * stem of w after removing -ed or -ing, w if stem has no vowel. Fixes up
+ * the end of the stem as in "hoping" -> "hope" and "running" -> "run".
+ */
+func restore(w, stem string) string {
+	if !hasVowel(stem) {
+		return w
+	}
+	n := len(stem)
+	switch {
+	case strings.HasSuffix(stem, "at"), strings.HasSuffix(stem, "bl"), strings.HasSuffix(stem, "iz"):
+		return stem + "e"
+	case n >= 2 && stem[n-1] == stem[n-2] && consonant(stem, n-1) &&
+		!strings.ContainsRune("lsz", rune(stem[n-1])):
+		return stem[:n-1]
+	case measure(stem) == 1 && endsCVC(stem):
+		return stem + "e"
+	}
+	return stem
+}
+
+// consonant reports if w[i] is a consonant. y is one after a vowel or at
+// the start.
+func consonant(w string, i int) bool {
+	switch w[i] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return false
+	case 'y':
+		return i == 0 || !consonant(w, i-1)
+	}
+	return true
+}
+
+func hasVowel(w string) bool {
+	for i := range w {
+		if !consonant(w, i) {
+			return true
+		}
+	}
+	return false
+}
+
+// measure counts the vowel-consonant sequences in w.
+func measure(w string) int {
+	m := 0
+	for i := 1; i < len(w); i++ {
+		if consonant(w, i) && !consonant(w, i-1) {
+			m++
+		}
+	}
+	return m
+}
+
+// endsCVC reports if w ends with consonant, vowel, consonant and the last
+// one is not w, x or y.
+func endsCVC(w string) bool {
+	n := len(w)
+	if n < 3 || !consonant(w, n-1) || consonant(w, n-2) || !consonant(w, n-3) {
+		return false
+	}
+	return !strings.ContainsRune("wxy", rune(w[n-1]))
+}
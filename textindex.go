@@ -0,0 +1,393 @@
+package dumbDatabase
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"dumbDB/backend"
+	"dumbDB/keys"
+	"dumbDB/text"
+
+	"github.com/boltdb/bolt"
+)
+
+// The full-text index of a bucket is kept in a hidden bucket with this
+// prefix followed by the bucket name.
+const TEXT_INDEX_BUCKET_PREFIX = HIDDEN_BUCKET_PREFIX + "fts."
+
+const (
+	// Terms longer than this are not indexed
+	MAX_TERM_LEN = 64
+	// Gap in term positions between the values of a record, so phrases do
+	// not match across fields
+	TERM_FIELD_GAP = 100
+)
+
+// Keys in the full-text index bucket.
+const (
+	// TEXT_POSTING, term, record key -> positions of term in the record
+	TEXT_POSTING = "p"
+	// TEXT_DOC, record key -> no of terms and distinct terms of the record
+	TEXT_DOC = "d"
+	// TEXT_STATS -> no of records and total no of terms
+	TEXT_STATS = "n"
+)
+
+// Returned by writes to a bucket whose full-text index uses an analyzer
+// which is not registered in this process, see text.Register.
+var ErrUnknownAnalyzer = errors.New("unknown analyzer")
+
+var errCorruptTextIndex = errors.New("corrupt full-text index")
+
+/*
+ * TextIndex
+ * Full-text index of a bucket.
+ */
+type TextIndex struct {
+	// Indexed fields, nested fields are separated by "."
+	Fields []string
+	// Name of a text analyzer, text.STANDARD if ""
+	Analyzer string `json:",omitempty"`
+}
+
+func (idx *TextIndex) analyzer() (text.Analyzer, error) {
+	name := idx.Analyzer
+	if name == "" {
+		name = text.STANDARD
+	}
+	a, ok := text.Lookup(name)
+	if !ok {
+		return a, fmt.Errorf("%w: %q", ErrUnknownAnalyzer, name)
+	}
+	return a, nil
+}
+
+func textIndexBucket(bucket string) []byte {
+	return []byte(TEXT_INDEX_BUCKET_PREFIX + bucket)
+}
+
+func textIndexDefKey(bucket string) []byte {
+	return []byte("fts." + bucket)
+}
+
+/*
+ * CreateTextIndex
+ * Index the string fields of the JSON objects in bucket for Search.
+ * Arrays of strings are indexed too. Existing records are indexed right
+ * away, replacing a previous full-text index of bucket, and every later
+ * write keeps the index up to date.
+ * @param 	bucket		name of bucket
+ * @param 	idx		fields and analyzer
+ */
+func (db *DumbDB) CreateTextIndex(bucket string, idx TextIndex) error {
+	return db.CreateTextIndexCtx(context.Background(), bucket, idx)
+}
+
+/*
+ * CreateTextIndexCtx
+ * CreateTextIndex with a context passed to the operation hooks. Returns
+ * ctx.Err() if ctx is done before the operation completes.
+ */
+func (db *DumbDB) CreateTextIndexCtx(ctx context.Context, bucket string, idx TextIndex) (err error) {
+	ctx, op := db.startOp(ctx, "CreateTextIndex", bucket, 0)
+	defer func() { db.finishOp(ctx, op, err) }()
+
+	if db.read_only {
+		return bolt.ErrDatabaseReadOnly
+	}
+	if err = checkUserBucket(bucket); err != nil {
+		return err
+	}
+	if len(idx.Fields) == 0 {
+		return fmt.Errorf("%w: no fields", ErrInvalidQuery)
+	}
+	if _, err = idx.analyzer(); err != nil {
+		return err
+	}
+	err = db.update(ctx, func(tx backend.Tx) error {
+		if e := db.setTextIndex(tx, bucket, &idx); e != nil {
+			return e
+		}
+		n, e := rebuildTextIndex(ctx, tx, bucket, &idx)
+		op.Results = n
+		return e
+	})
+	return
+}
+
+/*
+ * DropTextIndex
+ * Remove the full-text index of bucket. Dropping a missing index does
+ * nothing.
+ * @param 	bucket		name of bucket
+ */
+func (db *DumbDB) DropTextIndex(bucket string) error {
+	return db.DropTextIndexCtx(context.Background(), bucket)
+}
+
+/*
+ * DropTextIndexCtx
+ * DropTextIndex with a context passed to the operation hooks. Returns
+ * ctx.Err() if ctx is done before the operation completes.
+ */
+func (db *DumbDB) DropTextIndexCtx(ctx context.Context, bucket string) (err error) {
+	ctx, op := db.startOp(ctx, "DropTextIndex", bucket, 0)
+	defer func() { db.finishOp(ctx, op, err) }()
+
+	if db.read_only {
+		return bolt.ErrDatabaseReadOnly
+	}
+	if err = checkUserBucket(bucket); err != nil {
+		return err
+	}
+	err = db.update(ctx, func(tx backend.Tx) error {
+		if e := db.setTextIndex(tx, bucket, nil); e != nil {
+			return e
+		}
+		if e := tx.DeleteBucket(textIndexBucket(bucket)); e != nil && e != bolt.ErrBucketNotFound {
+			return e
+		}
+		return nil
+	})
+	return
+}
+
+/*
+ * GetTextIndex
+ * Full-text index of bucket, nil if it has none.
+ * @param 	bucket		name of bucket
+ */
+func (db *DumbDB) GetTextIndex(bucket string) (idx *TextIndex, err error) {
+	ctx, op := db.startOp(context.Background(), "GetTextIndex", bucket, 0)
+	err = db.view(ctx, func(tx backend.Tx) error {
+		idx, err = bucketTextIndex(tx, bucket)
+		return err
+	})
+	db.finishOp(ctx, op, err)
+	return
+}
+
+func bucketTextIndex(tx backend.Tx, bucket string) (*TextIndex, error) {
+	meta := tx.Bucket([]byte(META_BUCKET))
+	if meta == nil {
+		return nil, nil
+	}
+	v := meta.Get(textIndexDefKey(bucket))
+	if v == nil {
+		return nil, nil
+	}
+	idx := &TextIndex{}
+	if err := json.Unmarshal(v, idx); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+func (db *DumbDB) setTextIndex(tx backend.Tx, bucket string, idx *TextIndex) error {
+	if idx == nil {
+		return db.setDefinition(tx, bucket, textIndexDefKey(bucket), nil)
+	}
+	v, err := json.Marshal(idx)
+	if err != nil {
+		return err
+	}
+	return db.setDefinition(tx, bucket, textIndexDefKey(bucket), v)
+}
+
+/*
+ * rebuildTextIndex
+ * Replace the full-text index of bucket with one of its current records.
+ * Returns the no of records indexed.
+ */
+func rebuildTextIndex(ctx context.Context, tx backend.Tx, bucket string, idx *TextIndex) (n int, err error) {
+	if err = tx.DeleteBucket(textIndexBucket(bucket)); err != nil && err != bolt.ErrBucketNotFound {
+		return 0, err
+	}
+	bkt := tx.Bucket([]byte(bucket))
+	if bkt == nil {
+		return 0, nil
+	}
+	a, err := idx.analyzer()
+	if err != nil {
+		return 0, err
+	}
+	fts, err := tx.CreateBucket(textIndexBucket(bucket))
+	if err != nil {
+		return 0, err
+	}
+	err = scanCursor(ctx, bkt.Cursor(), ScanOptions{}, func(k, v []byte) error {
+		n++
+		return addTextRecord(fts, idx.Fields, a, k, v)
+	})
+	return
+}
+
+/*
+ * updateTextIndex
+ * Replace the terms of the record key with those of val, nil if the
+ * record was removed. Called inside the writing transaction.
+ */
+func updateTextIndex(tx backend.Tx, bucket string, idx *TextIndex, key, val []byte) error {
+	a, err := idx.analyzer()
+	if err != nil {
+		return err
+	}
+	fts, err := tx.CreateBucketIfNotExists(textIndexBucket(bucket))
+	if err != nil {
+		return err
+	}
+	if err = removeTextRecord(fts, key); err != nil {
+		return err
+	}
+	if val == nil {
+		return nil
+	}
+	return addTextRecord(fts, idx.Fields, a, key, val)
+}
+
+/*
+ * recordTerms
+ * Positions of the terms in the fields of the JSON object val, and the no
+ * of terms. Records which are not JSON objects have none.
+ */
+func recordTerms(fields []string, a text.Analyzer, val []byte) (positions map[string][]uint64, length uint64) {
+	doc := decodeObject(val)
+	if doc == nil {
+		return nil, 0
+	}
+	positions = make(map[string][]uint64)
+	pos := uint64(0)
+	for _, f := range fields {
+		for _, s := range fieldStrings(doc, f) {
+			for _, t := range a.Analyze(s) {
+				if len(t) > MAX_TERM_LEN {
+					continue
+				}
+				positions[t] = append(positions[t], pos)
+				pos++
+				length++
+			}
+			pos += TERM_FIELD_GAP
+		}
+	}
+	return
+}
+
+/*
+ * fieldStrings
+ * Strings in a possibly nested field of doc.
+ */
+func fieldStrings(doc map[string]interface{}, field string) []string {
+	v, _ := fieldValue(doc, field)
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var strs []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				strs = append(strs, s)
+			}
+		}
+		return strs
+	}
+	return nil
+}
+
+func addTextRecord(fts backend.Bucket, fields []string, a text.Analyzer, key, val []byte) error {
+	positions, length := recordTerms(fields, a, val)
+	if length == 0 {
+		return nil
+	}
+	doc := binary.AppendUvarint(nil, length)
+	for t, pos := range positions {
+		var v []byte
+		prev := uint64(0)
+		for _, p := range pos {
+			v = binary.AppendUvarint(v, p-prev)
+			prev = p
+		}
+		if err := fts.Put(keys.MustEncode(TEXT_POSTING, t, key), v); err != nil {
+			return err
+		}
+		doc = binary.AppendUvarint(doc, uint64(len(t)))
+		doc = append(doc, t...)
+	}
+	if err := fts.Put(keys.MustEncode(TEXT_DOC, key), doc); err != nil {
+		return err
+	}
+	return updateTextStats(fts, 1, int64(length))
+}
+
+func removeTextRecord(fts backend.Bucket, key []byte) error {
+	doc_key := keys.MustEncode(TEXT_DOC, key)
+	doc := fts.Get(doc_key)
+	if doc == nil {
+		return nil
+	}
+	length, terms, err := decodeTextDoc(doc)
+	if err != nil {
+		return err
+	}
+	for _, t := range terms {
+		if err = fts.Delete(keys.MustEncode(TEXT_POSTING, t, key)); err != nil {
+			return err
+		}
+	}
+	if err = fts.Delete(doc_key); err != nil {
+		return err
+	}
+	return updateTextStats(fts, -1, -int64(length))
+}
+
+func decodeTextDoc(v []byte) (length uint64, terms []string, err error) {
+	length, n := binary.Uvarint(v)
+	if n <= 0 {
+		return 0, nil, errCorruptTextIndex
+	}
+	for v = v[n:]; len(v) > 0; {
+		l, n := binary.Uvarint(v)
+		if n <= 0 || uint64(len(v)-n) < l {
+			return 0, nil, errCorruptTextIndex
+		}
+		terms = append(terms, string(v[n:n+int(l)]))
+		v = v[n+int(l):]
+	}
+	return
+}
+
+func textStats(fts backend.Bucket) (records, total uint64, err error) {
+	v := fts.Get(keys.MustEncode(TEXT_STATS))
+	if v == nil {
+		return 0, 0, nil
+	}
+	records, n := binary.Uvarint(v)
+	if n <= 0 {
+		return 0, 0, errCorruptTextIndex
+	}
+	total, m := binary.Uvarint(v[n:])
+	if m <= 0 {
+		return 0, 0, errCorruptTextIndex
+	}
+	return
+}
+
+func updateTextStats(fts backend.Bucket, records, total int64) error {
+	r, t, err := textStats(fts)
+	if err != nil {
+		return err
+	}
+	v := binary.AppendUvarint(nil, uint64(int64(r)+records))
+	v = binary.AppendUvarint(v, uint64(int64(t)+total))
+	return fts.Put(keys.MustEncode(TEXT_STATS), v)
+}
+
+// Prefix of the postings of all terms starting with prefix.
+func termPrefix(prefix string) []byte {
+	k := keys.MustEncode(TEXT_POSTING, prefix)
+	// Without the terminator of the string
+	return k[:len(k)-1]
+}
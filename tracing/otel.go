@@ -0,0 +1,54 @@
+/*
+ * Package tracing creates OpenTelemetry spans for DumbDB operations.
+ *
+ *	db.AddOpHook(tracing.NewHook(otel.Tracer("dumbDB")))
+ *	val, err := db.GetCtx(ctx, key, "Users")
+ */
+package tracing
+
+import (
+	"context"
+
+	dDB "dumbDB"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const SPAN_PREFIX = "dumbDB."
+
+type hook struct {
+	tracer trace.Tracer
+}
+
+/*
+ * NewHook
+ * OpHook starting a span named "dumbDB.<Op>" for every operation.
+ * The span is a child of the span in the context passed to the *Ctx methods.
+ */
+func NewHook(tracer trace.Tracer) dDB.OpHook {
+	return hook{tracer: tracer}
+}
+
+func (h hook) Before(ctx context.Context, op *dDB.OpInfo) context.Context {
+	ctx, _ = h.tracer.Start(ctx, SPAN_PREFIX+op.Op,
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithTimestamp(op.Start),
+		trace.WithAttributes(
+			attribute.String("db.system", "boltdb"),
+			attribute.String("db.operation.name", op.Op),
+			attribute.String("dumbdb.bucket", op.Bucket),
+			attribute.Int("dumbdb.key_length", op.KeyLen),
+		))
+	return ctx
+}
+
+func (h hook) After(ctx context.Context, op *dDB.OpInfo) {
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attribute.Int("dumbdb.results", op.Results))
+	if op.Err != nil {
+		span.RecordError(op.Err)
+		span.SetStatus(codes.Error, op.Err.Error())
+	}
+	span.End(trace.WithTimestamp(op.Start.Add(op.Duration)))
+}
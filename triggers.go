@@ -0,0 +1,253 @@
+package dumbDatabase
+
+import (
+	"bytes"
+	"context"
+	"sync"
+
+	"dumbDB/backend"
+)
+
+/*
+ * Trigger
+ * Called inside the writing transaction when a record of a bucket is
+ * stored or removed. c.Value is the new value for stores and nil for
+ * removes. Returning an error aborts the transaction, and the write fails
+ * with it. tx may be used to read the current record (in a Before
+ * trigger) and to write other records, which run their own triggers.
+ * c.Key and c.Value are only valid until the transaction ends.
+ */
+type Trigger func(tx *Tx, c Change) error
+
+const (
+	triggerBeforeStore = iota
+	triggerAfterStore
+	triggerBeforeRemove
+	triggerAfterRemove
+	triggerKinds
+)
+
+type trigger struct {
+	bucket string
+	fn     Trigger
+	commit func(Change)
+}
+
+type triggerState struct {
+	mu       sync.RWMutex
+	triggers [triggerKinds][]*trigger
+	commit   []*trigger
+}
+
+/*
+ * BeforeStore
+ * Call fn before a record is stored in bucket, by any write method.
+ * @param 	bucket		name of bucket, "" for all buckets
+ * @param 	fn		validates or rejects the write
+ * @returns 	remove		unregisters fn
+ */
+func (db *DumbDB) BeforeStore(bucket string, fn Trigger) (remove func()) {
+	return db.triggers.add(&db.triggers.triggers[triggerBeforeStore], &trigger{bucket: bucket, fn: fn})
+}
+
+/*
+ * AfterStore
+ * Call fn after a record is stored in bucket, in the same transaction.
+ * @param 	bucket		name of bucket, "" for all buckets
+ * @param 	fn		e.g. updates records derived from the stored one
+ * @returns 	remove		unregisters fn
+ */
+func (db *DumbDB) AfterStore(bucket string, fn Trigger) (remove func()) {
+	return db.triggers.add(&db.triggers.triggers[triggerAfterStore], &trigger{bucket: bucket, fn: fn})
+}
+
+/*
+ * BeforeRemove
+ * Call fn before a record is removed from bucket. The record can still be
+ * read with tx.Get.
+ * @param 	bucket		name of bucket, "" for all buckets
+ * @param 	fn		validates or rejects the remove
+ * @returns 	remove		unregisters fn
+ */
+func (db *DumbDB) BeforeRemove(bucket string, fn Trigger) (remove func()) {
+	return db.triggers.add(&db.triggers.triggers[triggerBeforeRemove], &trigger{bucket: bucket, fn: fn})
+}
+
+/*
+ * AfterRemove
+ * Call fn after a record is removed from bucket, in the same transaction.
+ * @param 	bucket		name of bucket, "" for all buckets
+ * @param 	fn		e.g. removes records derived from the removed one
+ * @returns 	remove		unregisters fn
+ */
+func (db *DumbDB) AfterRemove(bucket string, fn Trigger) (remove func()) {
+	return db.triggers.add(&db.triggers.triggers[triggerAfterRemove], &trigger{bucket: bucket, fn: fn})
+}
+
+/*
+ * AfterCommit
+ * Call fn for every change to bucket once its transaction commits, before
+ * the write method returns. Unlike Watch, fn runs on the writing goroutine
+ * and must not write to the DB. Removing a bucket is also a change.
+ * @param 	bucket		name of bucket, "" for all buckets
+ * @param 	fn		called with every committed change
+ * @returns 	remove		unregisters fn
+ */
+func (db *DumbDB) AfterCommit(bucket string, fn func(Change)) (remove func()) {
+	return db.triggers.add(&db.triggers.commit, &trigger{bucket: bucket, commit: fn})
+}
+
+func (s *triggerState) add(list *[]*trigger, t *trigger) func() {
+	s.mu.Lock()
+	*list = append(*list, t)
+	s.mu.Unlock()
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for i, o := range *list {
+			if o == t {
+				*list = append((*list)[:i:i], (*list)[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+/*
+ * matching
+ * Registered triggers of bucket, copied so they can run without the lock.
+ */
+func (s *triggerState) matching(list *[]*trigger, bucket string) []*trigger {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var matched []*trigger
+	for _, t := range *list {
+		if t.bucket == "" || t.bucket == bucket {
+			matched = append(matched, t)
+		}
+	}
+	return matched
+}
+
+func (db *DumbDB) runTriggers(ctx context.Context, tx backend.Tx, kind int, c Change) error {
+	for _, t := range db.triggers.matching(&db.triggers.triggers[kind], c.Bucket) {
+		if err := t.fn(&Tx{db: db, ctx: ctx, tx: tx}, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+ * putRecord
+ * Put key into bkt and record the write, running the store triggers of
+ * bucket around it. All record writes go through here or deleteRecord.
+ * The value is checked against the schema of bucket first, and the
+ * indexes, materialized aggregates and full-text index of bucket are
+ * updated with it.
+ */
+func (db *DumbDB) putRecord(ctx context.Context, tx backend.Tx, bkt backend.Bucket, bucket string, key, val []byte) error {
+	if err := db.validateRecord(tx, bucket, key, val); err != nil {
+		return err
+	}
+	c := Change{Op: ChangePut, Bucket: bucket, Key: key, Value: val}
+	if err := db.runTriggers(ctx, tx, triggerBeforeStore, c); err != nil {
+		return err
+	}
+	derived, err := loadDerived(tx, bucket)
+	if err != nil {
+		return err
+	}
+	var old []byte
+	if derived != nil {
+		old = bytes.Clone(bkt.Get(key))
+	}
+	if err = bkt.Put(key, val); err != nil {
+		return err
+	}
+	if err = db.recordWrite(tx, opPut, []byte(bucket), key, val); err != nil {
+		return err
+	}
+	if err = derived.update(tx, bucket, key, old, val); err != nil {
+		return err
+	}
+	return db.runTriggers(ctx, tx, triggerAfterStore, c)
+}
+
+/*
+ * deleteRecord
+ * Delete key from bkt and record the write, running the remove triggers
+ * of bucket around it and removing it from its indexes, aggregates and
+ * full-text index. Deleting a missing key does nothing.
+ */
+func (db *DumbDB) deleteRecord(ctx context.Context, tx backend.Tx, bkt backend.Bucket, bucket string, key []byte) error {
+	old := bkt.Get(key)
+	if old == nil {
+		return nil
+	}
+	c := Change{Op: ChangeDelete, Bucket: bucket, Key: key}
+	if err := db.runTriggers(ctx, tx, triggerBeforeRemove, c); err != nil {
+		return err
+	}
+	derived, err := loadDerived(tx, bucket)
+	if err != nil {
+		return err
+	}
+	// A BeforeRemove trigger may have changed the record
+	old = bytes.Clone(bkt.Get(key))
+	if err = bkt.Delete(key); err != nil {
+		return err
+	}
+	if err = db.recordWrite(tx, opDelete, []byte(bucket), key, nil); err != nil {
+		return err
+	}
+	if old != nil {
+		if err = derived.update(tx, bucket, key, old, nil); err != nil {
+			return err
+		}
+	}
+	return db.runTriggers(ctx, tx, triggerAfterRemove, c)
+}
+
+/*
+ * deleteBucket
+ * Delete bucket with its index entries, aggregates and full-text index
+ * and record the write. Their definitions are kept, for when the bucket is
+ * created again.
+ */
+func (db *DumbDB) deleteBucket(tx backend.Tx, bucket string) error {
+	if err := tx.DeleteBucket([]byte(bucket)); err != nil {
+		return err
+	}
+	if err := dropDerived(tx, bucket); err != nil {
+		return err
+	}
+	return db.recordWrite(tx, opDeleteBucket, []byte(bucket), nil, nil)
+}
+
+/*
+ * commitTriggers
+ * Run the AfterCommit triggers of a write once the writing transaction
+ * commits.
+ */
+func (db *DumbDB) commitTriggers(tx backend.Tx, op byte, bucket, key, val []byte) {
+	if isHiddenBucket(bucket) {
+		return
+	}
+	matched := db.triggers.matching(&db.triggers.commit, string(bucket))
+	if len(matched) == 0 {
+		return
+	}
+	c := Change{Op: ChangeOp(op), Bucket: string(bucket)}
+	if op != opDeleteBucket {
+		c.Key = append([]byte(nil), key...)
+	}
+	if val != nil {
+		c.Value = append([]byte(nil), val...)
+	}
+	tx.OnCommit(func() {
+		for _, t := range matched {
+			t.commit(c)
+		}
+	})
+}
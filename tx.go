@@ -0,0 +1,192 @@
+package dumbDatabase
+
+import (
+	"context"
+
+	"dumbDB/backend"
+
+	"github.com/boltdb/bolt"
+)
+
+/*
+ * Tx
+ * A transaction passed to the functions run by View and Update. Its
+ * methods mirror those of DumbDB. Values returned by a Tx are only valid
+ * until the transaction ends, copy them to keep them.
+ */
+type Tx struct {
+	db  *DumbDB
+	ctx context.Context
+	tx  backend.Tx
+}
+
+/*
+ * View
+ * Run fn in a read-only transaction. All reads in fn see the same snapshot.
+ * @param 	fn		reads from the DB, its error is returned by View
+ */
+func (db *DumbDB) View(fn func(tx *Tx) error) error {
+	return db.ViewCtx(context.Background(), fn)
+}
+
+/*
+ * ViewCtx
+ * View with a context passed to the operation hooks. Tx methods return
+ * ctx.Err() once ctx is done.
+ */
+func (db *DumbDB) ViewCtx(ctx context.Context, fn func(tx *Tx) error) (err error) {
+	ctx, op := db.startOp(ctx, "View", "", 0)
+	err = db.view(ctx, func(tx backend.Tx) error {
+		return fn(&Tx{db: db, ctx: ctx, tx: tx})
+	})
+	db.finishOp(ctx, op, err)
+	return
+}
+
+/*
+ * Update
+ * Run fn in a read-write transaction. All writes in fn are committed
+ * together if fn returns nil and discarded otherwise.
+ * @param 	fn		reads and writes the DB, its error is returned by Update
+ */
+func (db *DumbDB) Update(fn func(tx *Tx) error) error {
+	return db.UpdateCtx(context.Background(), fn)
+}
+
+/*
+ * UpdateCtx
+ * Update with a context passed to the operation hooks. The transaction is
+ * rolled back if ctx is done before it commits.
+ */
+func (db *DumbDB) UpdateCtx(ctx context.Context, fn func(tx *Tx) error) (err error) {
+	ctx, op := db.startOp(ctx, "Update", "", 0)
+	defer func() { db.finishOp(ctx, op, err) }()
+
+	if db.read_only {
+		return bolt.ErrDatabaseReadOnly
+	}
+	err = db.update(ctx, func(tx backend.Tx) error {
+		if e := fn(&Tx{db: db, ctx: ctx, tx: tx}); e != nil {
+			return e
+		}
+		return ctx.Err()
+	})
+	return
+}
+
+func (t *Tx) Writable() bool {
+	return t.tx.Writable()
+}
+
+/*
+ * Get
+ * Same as DumbDB.Get.
+ */
+func (t *Tx) Get(key []byte, bucket string) ([]byte, error) {
+	if err := t.ctx.Err(); err != nil {
+		return nil, err
+	}
+	bkt := t.tx.Bucket([]byte(bucket))
+	if bkt == nil {
+		return nil, bolt.ErrBucketNotFound
+	}
+	if val := bkt.Get(key); val != nil {
+		return val, nil
+	}
+	return nil, bolt.ErrKeyRequired
+}
+
+/*
+ * Scan
+ * Same as DumbDB.Scan.
+ */
+func (t *Tx) Scan(bucket string, opts ScanOptions, fn func(key, val []byte) error) error {
+	if err := t.ctx.Err(); err != nil {
+		return err
+	}
+	bkt := t.tx.Bucket([]byte(bucket))
+	if bkt == nil {
+		return bolt.ErrBucketNotFound
+	}
+	return scanCursor(t.ctx, bkt.Cursor(), opts, fn)
+}
+
+/*
+ * Buckets
+ * Same as DumbDB.Buckets.
+ */
+func (t *Tx) Buckets() ([]string, error) {
+	names := make([]string, 0)
+	err := t.tx.ForEach(func(name []byte, _ backend.Bucket) error {
+		if !isHiddenBucket(name) {
+			names = append(names, string(name))
+		}
+		return nil
+	})
+	return names, err
+}
+
+/*
+ * Store
+ * Same as DumbDB.Store. Fails with bolt.ErrTxNotWritable in a View.
+ */
+func (t *Tx) Store(record [][]byte, bucket string) error {
+	if err := t.ctx.Err(); err != nil {
+		return err
+	}
+	if !t.tx.Writable() {
+		return bolt.ErrTxNotWritable
+	}
+	if err := checkUserBucket(bucket); err != nil {
+		return err
+	}
+	if len(record[0]) > MAX_KEY_LEN {
+		return bolt.ErrKeyTooLarge
+	}
+	bkt, err := t.tx.CreateBucketIfNotExists([]byte(bucket))
+	if err != nil {
+		return err
+	}
+	return t.db.putRecord(t.ctx, t.tx, bkt, bucket, record[0], record[1])
+}
+
+/*
+ * Remove
+ * Same as DumbDB.Remove. Fails with bolt.ErrTxNotWritable in a View.
+ */
+func (t *Tx) Remove(key []byte, bucket string) error {
+	if err := t.ctx.Err(); err != nil {
+		return err
+	}
+	if !t.tx.Writable() {
+		return bolt.ErrTxNotWritable
+	}
+	if err := checkUserBucket(bucket); err != nil {
+		return err
+	}
+	if len(key) > MAX_KEY_LEN {
+		return bolt.ErrKeyTooLarge
+	}
+	bkt := t.tx.Bucket([]byte(bucket))
+	if bkt == nil {
+		return bolt.ErrBucketNotFound
+	}
+	return t.db.deleteRecord(t.ctx, t.tx, bkt, bucket, key)
+}
+
+/*
+ * RemoveBucket
+ * Same as DumbDB.RemoveBucket. Fails with bolt.ErrTxNotWritable in a View.
+ */
+func (t *Tx) RemoveBucket(bucket string) error {
+	if err := t.ctx.Err(); err != nil {
+		return err
+	}
+	if !t.tx.Writable() {
+		return bolt.ErrTxNotWritable
+	}
+	if err := checkUserBucket(bucket); err != nil {
+		return err
+	}
+	return t.db.deleteBucket(t.tx, bucket)
+}
@@ -0,0 +1,170 @@
+package dumbDatabase
+
+import (
+	"bytes"
+	"sync"
+
+	"dumbDB/backend"
+)
+
+type ChangeOp int
+
+const (
+	ChangePut ChangeOp = iota + 1
+	ChangeDelete
+	ChangeDeleteBucket
+)
+
+/*
+ * Change
+ * A committed write delivered to watchers. Key and Value are nil when a
+ * whole bucket is removed, Value is nil for deletes.
+ */
+type Change struct {
+	Op     ChangeOp
+	Bucket string
+	Key    []byte
+	Value  []byte
+}
+
+type watcher struct {
+	bucket string
+	prefix []byte
+	fn     func(Change)
+
+	mu     sync.Mutex
+	queue  []Change
+	signal chan struct{}
+	done   chan struct{}
+}
+
+type watchState struct {
+	mu       sync.Mutex
+	watchers map[*watcher]struct{}
+}
+
+/*
+ * Watch
+ * Call fn for every committed change to a bucket, in commit order.
+ * fn runs on its own goroutine per watcher, so it may use the DB.
+ * @param 	bucket		name of bucket, "" for all buckets
+ * @param 	prefix		only keys starting with prefix, nil for all keys
+ * @param 	fn		called with every change
+ * @returns 	cancel		stops the watch
+ */
+func (db *DumbDB) Watch(bucket string, prefix []byte, fn func(Change)) (cancel func()) {
+	w := &watcher{
+		bucket: bucket,
+		prefix: append([]byte(nil), prefix...),
+		fn:     fn,
+		signal: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+
+	db.watch.mu.Lock()
+	if db.watch.watchers == nil {
+		db.watch.watchers = make(map[*watcher]struct{})
+	}
+	db.watch.watchers[w] = struct{}{}
+	db.watch.mu.Unlock()
+	go w.run()
+
+	return func() {
+		db.watch.mu.Lock()
+		defer db.watch.mu.Unlock()
+		if _, ok := db.watch.watchers[w]; ok {
+			delete(db.watch.watchers, w)
+			close(w.done)
+		}
+	}
+}
+
+func (w *watcher) matches(c Change) bool {
+	if w.bucket != "" && w.bucket != c.Bucket {
+		return false
+	}
+	return c.Op == ChangeDeleteBucket || bytes.HasPrefix(c.Key, w.prefix)
+}
+
+func (w *watcher) push(c Change) {
+	w.mu.Lock()
+	w.queue = append(w.queue, c)
+	w.mu.Unlock()
+	select {
+	case w.signal <- struct{}{}:
+	default:
+	}
+}
+
+func (w *watcher) run() {
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-w.signal:
+		}
+		w.mu.Lock()
+		queue := w.queue
+		w.queue = nil
+		w.mu.Unlock()
+		for _, c := range queue {
+			select {
+			case <-w.done:
+				return
+			default:
+			}
+			w.fn(c)
+		}
+	}
+}
+
+/*
+ * publish
+ * Deliver a committed change to the matching watchers.
+ */
+func (s *watchState) publish(c Change) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for w := range s.watchers {
+		if w.matches(c) {
+			w.push(c)
+		}
+	}
+}
+
+func (s *watchState) active() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.watchers) > 0
+}
+
+func (s *watchState) closeAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for w := range s.watchers {
+		close(w.done)
+	}
+	s.watchers = nil
+}
+
+/*
+ * notifyWatchers
+ * Publish a write once the writing transaction commits.
+ */
+func (db *DumbDB) notifyWatchers(tx backend.Tx, op byte, bucket, key, val []byte) {
+	if isHiddenBucket(bucket) || !db.watch.active() {
+		return
+	}
+	c := Change{
+		Op:     ChangeOp(op),
+		Bucket: string(bucket),
+		Key:    append([]byte(nil), key...),
+	}
+	if val != nil {
+		c.Value = append([]byte(nil), val...)
+	}
+	if op == opDeleteBucket {
+		c.Key = nil
+	}
+	tx.OnCommit(func() { db.watch.publish(c) })
+}